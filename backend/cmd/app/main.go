@@ -9,6 +9,7 @@ import (
 	"github.com/amirqodi/hgm/internal"
 	"github.com/amirqodi/hgm/internal/database"
 	"github.com/amirqodi/hgm/internal/services"
+	"github.com/amirqodi/hgm/internal/utils"
 	"github.com/gofiber/fiber/v2"
 	"github.com/joho/godotenv"
 )
@@ -24,6 +25,11 @@ func main() {
 		fmt.Println("⚠️ No .env file found at", dotenvPath)
 	}
 
+	// کلید پیش‌فرض JWT در production پذیرفته نمی‌شود
+	if err := utils.CheckJwtSecret(); err != nil {
+		log.Fatal(err)
+	}
+
 	// DB connect
 	database.Connect()
 
@@ -31,9 +37,11 @@ func main() {
 	app := fiber.New()
 	app.Static("/uploads", "./uploads")
 
-	// Price services
-	services.UpdateOnStartup()
-	services.StartPriceScheduler()
+	// Price services (PRICE_PROVIDER=navasan|file|manual)
+	services.StartPriceScheduler(database.DB)
+
+	// Recurring bank charges
+	services.StartBankChargeScheduler(database.DB)
 
 	// Routes
 	internal.Setup(app)
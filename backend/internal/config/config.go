@@ -21,3 +21,8 @@ func Get(key, fallback string) string {
 	}
 	return value
 }
+
+// IsProduction حالت production با APP_ENV=production
+func IsProduction() bool {
+	return os.Getenv("APP_ENV") == "production"
+}
@@ -25,6 +25,12 @@ func Connect() {
 	if err := db.AutoMigrate(
 		&models.User{},
 		&models.Contact{},
+		&models.ShareholderProfile{},
+		&models.CustomerProfile{},
+		&models.VendorProfile{},
+		&models.ContactPhone{},
+		&models.ContactAddress{},
+		&models.Tag{},
 		&models.BankAccount{},
 		&models.CashHolder{},
 		&models.ProductService{},
@@ -34,10 +40,49 @@ func Connect() {
 		&models.TransactionAttachment{},
 		&models.Price{},
 		&models.Deposit{},
+		&models.ProfitDistribution{},
+		&models.Dividend{},
+		&models.CapitalEvent{},
+		&models.SharePercentageChange{},
+		&models.ContactMerge{},
+		&models.StatementColumnMapping{},
+		&models.BankStatement{},
+		&models.BankStatementLine{},
+		&models.ReconciliationLock{},
+		&models.BankCharge{},
+		&models.BankChargeRule{},
+		&models.CashCount{},
+		&models.CashCountItem{},
+		&models.CashHandover{},
+		&models.DocumentSequence{},
+		&models.Invoice{},
+		&models.InvoiceItem{},
+		&models.Quote{},
+		&models.QuoteItem{},
+		&models.PurchaseOrder{},
+		&models.PurchaseOrderItem{},
+		&models.GoodsReceipt{},
+		&models.GoodsReceiptItem{},
+		&models.VendorBill{},
+		&models.VendorBillItem{},
+		&models.Revaluation{},
+		&models.Notification{},
+		&models.ProductPriceChange{},
+		&models.Session{},
+		&models.LoginAttempt{},
+		&models.Setting{},
+		&models.RecoveryCode{},
+		&models.WorkOrder{},
 	); err != nil {
 		log.Fatal("Migration failed:", err)
 	}
 
 	DB = db
+	if err := migrateContactRoles(); err != nil {
+		log.Fatal("Contact roles migration failed:", err)
+	}
+	if err := migrateShareholderCapital(); err != nil {
+		log.Fatal("Capital migration failed:", err)
+	}
 	Seed()
 }
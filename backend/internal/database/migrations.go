@@ -0,0 +1,294 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/models"
+	"gorm.io/gorm"
+)
+
+// ستون‌های قدیمی جدول contacts که به رکوردهای نقش منتقل شده‌اند
+var legacyContactColumns = []string{
+	"type", "share_percentage", "amount", "car_type", "car_kilometer",
+	"address", "credit_limit", "max_overdue_days", "credit_policy",
+}
+
+type legacyContact struct {
+	ID              uint
+	Type            string
+	SharePercentage *float64
+	Amount          *float64
+	CarType         *string
+	CarKilometer    *int
+	Address         *string
+	CreditLimit     *float64
+	MaxOverdueDays  *int
+	CreditPolicy    *string
+}
+
+// migrateContactRoles مخاطبین تک‌نقشی قدیمی (ستون type) را در یک تراکنش به رکوردهای نقش منتقل می‌کند؛
+// ستون‌های قدیمی فقط وقتی حذف می‌شوند که همه ردیف‌ها رکورد نقش داشته باشند
+func migrateContactRoles() error {
+	migrator := DB.Migrator()
+	if !migrator.HasColumn(&models.Contact{}, "type") {
+		return nil
+	}
+
+	var rows []legacyContact
+	if err := DB.Table("contacts").Find(&rows).Error; err != nil {
+		return err
+	}
+
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		for _, row := range rows {
+			if err := createRoleProfile(tx, row); err != nil {
+				return fmt.Errorf("contact %d: %w", row.ID, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		ok, err := hasRoleProfile(row)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("contact %d (%s) has no role profile; legacy columns kept", row.ID, row.Type)
+		}
+	}
+
+	for _, column := range legacyContactColumns {
+		if migrator.HasColumn(&models.Contact{}, column) {
+			if err := migrator.DropColumn(&models.Contact{}, column); err != nil {
+				return fmt.Errorf("drop column %s: %w", column, err)
+			}
+			if migrator.HasColumn(&models.Contact{}, column) {
+				return fmt.Errorf("drop column %s: column still exists", column)
+			}
+		}
+	}
+	log.Println("Contact roles migrated:", len(rows))
+	return nil
+}
+
+// createRoleProfile رکورد نقش یک مخاطب قدیمی (اگر از قبل نباشد)
+func createRoleProfile(tx *gorm.DB, row legacyContact) error {
+	switch models.ContactType(row.Type) {
+	case models.Shareholder:
+		profile := models.ShareholderProfile{ContactID: row.ID}
+		if row.SharePercentage != nil {
+			profile.SharePercentage = *row.SharePercentage
+		}
+		if row.Amount != nil {
+			profile.Amount = *row.Amount
+		}
+		return tx.Where(models.ShareholderProfile{ContactID: row.ID}).FirstOrCreate(&profile).Error
+
+	case models.Customer:
+		profile := models.CustomerProfile{
+			ContactID:      row.ID,
+			CarType:        row.CarType,
+			CarKilometer:   row.CarKilometer,
+			CreditLimit:    row.CreditLimit,
+			MaxOverdueDays: row.MaxOverdueDays,
+		}
+		if row.CreditPolicy != nil {
+			profile.CreditPolicy = models.CreditPolicy(*row.CreditPolicy)
+		}
+		return tx.Where(models.CustomerProfile{ContactID: row.ID}).FirstOrCreate(&profile).Error
+
+	case models.Vendor:
+		profile := models.VendorProfile{ContactID: row.ID}
+		if row.Address != nil {
+			profile.Address = *row.Address
+		}
+		return tx.Where(models.VendorProfile{ContactID: row.ID}).FirstOrCreate(&profile).Error
+	}
+	return fmt.Errorf("unknown contact type %q", row.Type)
+}
+
+// hasRoleProfile آیا رکورد نقش متناظر با type قدیمی ساخته شده است
+func hasRoleProfile(row legacyContact) (bool, error) {
+	var model interface{}
+	switch models.ContactType(row.Type) {
+	case models.Shareholder:
+		model = &models.ShareholderProfile{}
+	case models.Customer:
+		model = &models.CustomerProfile{}
+	case models.Vendor:
+		model = &models.VendorProfile{}
+	default:
+		return false, nil
+	}
+	var count int64
+	err := DB.Model(model).Where("contact_id = ?", row.ID).Count(&count).Error
+	return count > 0, err
+}
+
+// دسته‌های تراکنش افزایش/کاهش سرمایه (همان نام‌های repositories)
+const (
+	capitalIncreaseCategory = "افزایش سهام"
+	capitalDecreaseCategory = "کاهش سهام"
+	openingCapitalNote      = "مانده اولیه سرمایه"
+)
+
+// migrateShareholderCapital مانده سرمایه و درصد سهم قدیمی سهامداران را به رکوردهای تاریخچه منتقل می‌کند:
+// هر تراکنش پرداخت‌شده افزایش/کاهش سهام (یا قسط پرداخت‌شده آن) یک رویداد با تاریخ و TransactionID خودش
+// و فقط مانده بدون سند یک رویداد مانده اولیه می‌شود. سهامدارانی که پیش‌تر فقط رویداد مانده اولیه
+// گرفته‌اند دوباره ساخته می‌شوند.
+func migrateShareholderCapital() error {
+	var profiles []models.ShareholderProfile
+	if err := DB.Find(&profiles).Error; err != nil {
+		return err
+	}
+
+	return DB.Transaction(func(tx *gorm.DB) error {
+		for _, profile := range profiles {
+			if err := backfillCapitalEvents(tx, profile); err != nil {
+				return fmt.Errorf("contact %d: %w", profile.ContactID, err)
+			}
+			if err := backfillShareHistory(tx, profile); err != nil {
+				return fmt.Errorf("contact %d: %w", profile.ContactID, err)
+			}
+		}
+		return nil
+	})
+}
+
+func contactCreatedAt(tx *gorm.DB, contactID uint) (time.Time, error) {
+	var contact models.Contact
+	err := tx.Select("id", "created_at").First(&contact, contactID).Error
+	return contact.CreatedAt, err
+}
+
+func backfillCapitalEvents(tx *gorm.DB, profile models.ShareholderProfile) error {
+	var events []models.CapitalEvent
+	if err := tx.Where("contact_id = ?", profile.ContactID).Find(&events).Error; err != nil {
+		return err
+	}
+	// رویدادهای واقعی موجود است → قبلاً منتقل شده
+	if len(events) > 1 || (len(events) == 1 && (events[0].TransactionID != nil || events[0].Notes != openingCapitalNote)) {
+		return nil
+	}
+
+	var trxs []models.Transaction
+	categories := tx.Model(&models.Category{}).Select("id").Where("name IN ?", []string{capitalIncreaseCategory, capitalDecreaseCategory})
+	if err := tx.Preload("SubTransactions").Preload("Category").
+		Where("contact_id = ? AND category_id IN (?)", profile.ContactID, categories).
+		Order("id").Find(&trxs).Error; err != nil {
+		return err
+	}
+	if len(events) == 1 && len(trxs) == 0 {
+		return nil // مانده اولیه قبلی درست است
+	}
+
+	var backfill []models.CapitalEvent
+	explained := 0.0
+	add := func(trx *models.Transaction, amount float64, date time.Time) {
+		event := models.CapitalEvent{
+			ContactID:       profile.ContactID,
+			Type:            models.CapitalContribution,
+			Amount:          amount,
+			Date:            date,
+			TransactionID:   &trx.ID,
+			MoneySourceType: trx.MoneySourceType,
+			BankAccountID:   trx.BankAccountID,
+			CashHolderID:    trx.CashHolderID,
+			Notes:           trx.Notes,
+		}
+		if trx.Category.Name == capitalDecreaseCategory {
+			event.Type = models.CapitalWithdrawal
+			amount = -amount
+		}
+		explained += amount
+		backfill = append(backfill, event)
+	}
+	for i := range trxs {
+		trx := &trxs[i]
+		if len(trx.SubTransactions) == 0 {
+			if trx.IsPaid && trx.Amount > 0 {
+				date := trx.CreatedAt
+				if trx.TransactionDate != nil {
+					date = *trx.TransactionDate
+				}
+				add(trx, trx.Amount, date)
+			}
+			continue
+		}
+		for _, sub := range trx.SubTransactions {
+			if sub.IsPaid && sub.Amount > 0 {
+				date := trx.CreatedAt
+				if sub.PaidAt != nil {
+					date = *sub.PaidAt
+				}
+				add(trx, sub.Amount, date)
+			}
+		}
+	}
+
+	// مانده قبلی (از فیلد Amount یا رویداد مانده اولیه) که با تراکنش‌ها توضیح داده نمی‌شود
+	total := profile.Amount
+	if len(events) == 1 {
+		total = events[0].Amount
+	}
+	remainder := total - explained
+	if math.Abs(remainder) >= 0.01 {
+		opening := models.CapitalEvent{
+			ContactID: profile.ContactID,
+			Type:      models.CapitalContribution,
+			Amount:    math.Abs(remainder),
+			Notes:     openingCapitalNote,
+		}
+		if remainder < 0 {
+			opening.Type = models.CapitalWithdrawal
+		}
+		createdAt, err := contactCreatedAt(tx, profile.ContactID)
+		if err != nil {
+			return err
+		}
+		opening.Date = createdAt
+		for _, e := range backfill {
+			if e.Date.Before(opening.Date) {
+				opening.Date = e.Date
+			}
+		}
+		backfill = append([]models.CapitalEvent{opening}, backfill...)
+	}
+	if len(backfill) == 0 {
+		return nil
+	}
+
+	if len(events) == 1 {
+		if err := tx.Delete(&events[0]).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Create(&backfill).Error
+}
+
+func backfillShareHistory(tx *gorm.DB, profile models.ShareholderProfile) error {
+	var changes int64
+	if err := tx.Model(&models.SharePercentageChange{}).Where("contact_id = ?", profile.ContactID).Count(&changes).Error; err != nil {
+		return err
+	}
+	if changes > 0 || profile.SharePercentage <= 0 {
+		return nil
+	}
+	createdAt, err := contactCreatedAt(tx, profile.ContactID)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.SharePercentageChange{
+		ContactID:     profile.ContactID,
+		Percentage:    profile.SharePercentage,
+		EffectiveFrom: createdAt,
+		Reason:        "migration",
+	}).Error
+}
@@ -8,15 +8,19 @@ import (
 	"github.com/amirqodi/hgm/internal/utils"
 )
 
+// defaultAdminPassword رمز پیش‌فرض ادمین؛ تا تغییر نکند ادمین باید پس از ورود رمز را عوض کند
+const defaultAdminPassword = "admin123"
+
 func Seed() {
 	adminUser := config.Get("ADMIN_USERNAME", "admin")
-	adminPass := config.Get("ADMIN_PASSWORD", "admin123")
+	adminPass := config.Get("ADMIN_PASSWORD", defaultAdminPassword)
 
 	hashed, _ := utils.HashPassword(adminPass)
 
 	user := models.User{
 		Username: adminUser,
 		Password: hashed,
+		Role:     models.RoleOwner,
 	}
 
 	if err := DB.FirstOrCreate(&user, models.User{Username: adminUser}).Error; err != nil {
@@ -24,4 +28,47 @@ func Seed() {
 	} else {
 		log.Println("Admin user seeded:", adminUser)
 	}
+
+	// کاربر ادمین قدیمی (قبل از اضافه شدن نقش‌ها) مالک در نظر گرفته می‌شود
+	if user.Role == "" {
+		DB.Model(&user).Update("role", models.RoleOwner)
+	}
+	if !user.MustChangePassword && utils.CheckPasswordHash(defaultAdminPassword, user.Password) {
+		DB.Model(&user).Update("must_change_password", true)
+	}
+	migrateLegacyRoles()
+
+	seedStatementMappings()
+}
+
+// migrateLegacyRoles نقش‌های manager/user و نقش خالی به نقش‌های کنترل دسترسی تبدیل می‌شوند
+func migrateLegacyRoles() {
+	for old, role := range models.LegacyRoles {
+		if err := DB.Model(&models.User{}).Where("role = ?", old).Update("role", role).Error; err != nil {
+			log.Println("Seeder error:", err)
+		}
+	}
+	if err := DB.Model(&models.User{}).Where("role IS NULL OR role = ''").Update("role", models.RoleAccountant).Error; err != nil {
+		log.Println("Seeder error:", err)
+	}
+}
+
+// نگاشت پیش‌فرض ستون‌های خروجی اکسل/CSV بانک‌های رایج؛ کاربر می‌تواند آن‌ها را ویرایش کند
+var statementMappingPresets = []models.StatementColumnMapping{
+	{Name: "mellat", BankName: "ملت", DateColumn: "تاریخ", TimeColumn: "زمان", DescriptionColumn: "شرح", ReferenceColumn: "شماره سند", DebitColumn: "برداشت", CreditColumn: "واریز", BalanceColumn: "مانده"},
+	{Name: "melli", BankName: "ملی", DateColumn: "تاریخ", TimeColumn: "ساعت", DescriptionColumn: "شرح", ReferenceColumn: "شماره پیگیری", DebitColumn: "بدهکار", CreditColumn: "بستانکار", BalanceColumn: "مانده"},
+	{Name: "saderat", BankName: "صادرات", DateColumn: "تاریخ", TimeColumn: "زمان", DescriptionColumn: "شرح عملیات", ReferenceColumn: "شماره سند", DebitColumn: "مبلغ برداشت", CreditColumn: "مبلغ واریز", BalanceColumn: "مانده"},
+	{Name: "tejarat", BankName: "تجارت", DateColumn: "تاریخ", TimeColumn: "ساعت", DescriptionColumn: "شرح", ReferenceColumn: "شماره مرجع", DebitColumn: "بدهکار", CreditColumn: "بستانکار", BalanceColumn: "موجودی"},
+	{Name: "pasargad", BankName: "پاسارگاد", DateColumn: "تاریخ", TimeColumn: "زمان", DescriptionColumn: "توضیحات", ReferenceColumn: "شماره پیگیری", DebitColumn: "برداشت", CreditColumn: "واریز", BalanceColumn: "مانده"},
+	{Name: "saman", BankName: "سامان", DateColumn: "تاریخ", DescriptionColumn: "شرح", ReferenceColumn: "شماره سند", AmountColumn: "مبلغ", BalanceColumn: "مانده"},
+}
+
+func seedStatementMappings() {
+	for _, preset := range statementMappingPresets {
+		preset.IsPreset = true
+		mapping := preset
+		if err := DB.Where(models.StatementColumnMapping{Name: preset.Name}).FirstOrCreate(&mapping).Error; err != nil {
+			log.Println("Seeder error:", err)
+		}
+	}
 }
@@ -0,0 +1,69 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/amirqodi/hgm/internal/utils"
+)
+
+// renderCSV همه جدول‌ها پشت سر هم با یک خط خالی بین آن‌ها؛ BOM برای نمایش درست فارسی در Excel
+func renderCSV(doc *Document) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("\ufeff")
+	w := csv.NewWriter(&buf)
+
+	w.Write([]string{doc.Title})
+	if doc.Subtitle != "" {
+		w.Write([]string{doc.Subtitle})
+	}
+	for _, field := range doc.Fields {
+		w.Write([]string{field.Label, field.Value})
+	}
+	for _, table := range doc.Tables {
+		w.Write(nil)
+		if table.Title != "" {
+			w.Write([]string{table.Title})
+		}
+		header := make([]string, len(table.Columns))
+		for i, col := range table.Columns {
+			header[i] = col.Title
+		}
+		w.Write(header)
+
+		for _, row := range table.Rows {
+			record := make([]string, len(table.Columns))
+			for i := range table.Columns {
+				if i < len(row.Cells) {
+					record[i] = plainCell(row.Cells[i])
+				}
+			}
+			if row.Level > 0 && record[0] != "" {
+				record[0] = strings.Repeat("  ", row.Level) + record[0]
+			}
+			w.Write(record)
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// plainCell مقدار خانه برای فایل‌های صفحه‌گسترده: ارقام انگلیسی بدون جداکننده و تاریخ شمسی
+func plainCell(v any) string {
+	if isEmpty(v) {
+		return ""
+	}
+	if t, ok := cellTime(v); ok {
+		return utils.FormatJalali(t)
+	}
+	if n, ok := cellFloat(v); ok {
+		return strconv.FormatFloat(n, 'f', -1, 64)
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
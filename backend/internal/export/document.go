@@ -0,0 +1,158 @@
+package export
+
+import (
+	"errors"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/config"
+	ptime "github.com/yaa110/go-persian-calendar"
+)
+
+type Format string
+
+const (
+	FormatXLSX Format = "xlsx"
+	FormatCSV  Format = "csv"
+	FormatPDF  Format = "pdf"
+)
+
+var ErrUnknownFormat = errors.New("فرمت خروجی باید xlsx، csv یا pdf باشد")
+
+type ColumnKind int
+
+const (
+	KindText    ColumnKind = iota
+	KindAmount             // مبلغ
+	KindPercent            // درصد (۱۲٫۵ یعنی ۱۲٫۵٪)
+	KindDate               // تاریخ؛ شمسی نمایش داده می‌شود
+	KindNumber             // عدد صحیح مثل شناسه یا شماره ردیف
+)
+
+type Column struct {
+	Title string
+	Kind  ColumnKind
+}
+
+// Row خانه‌ها به ترتیب ستون‌ها: string، float64، int، uint، time.Time، *time.Time یا nil
+type Row struct {
+	Cells []any
+	Bold  bool // ردیف جمع یا عنوان بخش
+	Level int  // تورفتگی ستون اول (درخت دسته‌بندی‌ها)
+}
+
+type Table struct {
+	Title   string
+	Columns []Column
+	Rows    []Row
+}
+
+// Field یک مشخصه سند (مثل شماره فاکتور یا نام خریدار) که بالای جدول‌ها چاپ می‌شود
+type Field struct {
+	Label string
+	Value string
+}
+
+// Document یک گزارش قابل خروجی؛ Name نام فایل (لاتین) بدون پسوند است
+type Document struct {
+	Name     string
+	Title    string
+	Subtitle string
+	Fields   []Field
+	Tables   []Table
+
+	QRCode    string // نشانی‌ای که به‌صورت QR در PDF چاپ می‌شود
+	Watermark string // متن کم‌رنگ روی همه صفحات PDF (مثلاً «باطل شد»)
+}
+
+// Company سربرگ گزارش‌ها از متغیرهای محیطی COMPANY_*
+type Company struct {
+	Name    string
+	Address string
+	Phone   string
+}
+
+func CompanyFromEnv() Company {
+	return Company{
+		Name:    config.Get("COMPANY_NAME", ""),
+		Address: config.Get("COMPANY_ADDRESS", ""),
+		Phone:   config.Get("COMPANY_PHONE", ""),
+	}
+}
+
+func (t *Table) AddRow(cells ...any) {
+	t.Rows = append(t.Rows, Row{Cells: cells})
+}
+
+func (t *Table) AddBoldRow(cells ...any) {
+	t.Rows = append(t.Rows, Row{Cells: cells, Bold: true})
+}
+
+func (t *Table) AddIndentedRow(level int, cells ...any) {
+	t.Rows = append(t.Rows, Row{Cells: cells, Level: level})
+}
+
+// Render خروجی سند در قالب خواسته‌شده همراه با Content-Type و نام فایل
+func Render(doc *Document, format Format) (data []byte, contentType, fileName string, err error) {
+	fileName = doc.Name + "-" + ptime.Now().Format("yyyyMMdd") + "." + string(format)
+	switch format {
+	case FormatXLSX:
+		data, err = renderXLSX(doc)
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case FormatCSV:
+		data, err = renderCSV(doc)
+		contentType = "text/csv; charset=utf-8"
+	case FormatPDF:
+		data, err = renderPDF(doc, CompanyFromEnv())
+		contentType = "application/pdf"
+	default:
+		return nil, "", "", ErrUnknownFormat
+	}
+	return data, contentType, fileName, err
+}
+
+// cellTime تاریخ خانه (nil برای خانه خالی)
+func cellTime(v any) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, !t.IsZero()
+	case *time.Time:
+		if t != nil && !t.IsZero() {
+			return *t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func cellFloat(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case *float64:
+		if n != nil {
+			return *n, true
+		}
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func isEmpty(v any) bool {
+	switch t := v.(type) {
+	case nil:
+		return true
+	case string:
+		return t == ""
+	case time.Time:
+		return t.IsZero()
+	case *time.Time:
+		return t == nil || t.IsZero()
+	case *float64:
+		return t == nil
+	}
+	return false
+}
@@ -0,0 +1,366 @@
+package export
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/utils"
+	"github.com/boombuler/barcode/qr"
+	"github.com/go-pdf/fpdf"
+	"github.com/go-pdf/fpdf/contrib/barcode"
+)
+
+// فونت‌ها داخل باینری قرار می‌گیرند تا خروجی بدون اینترنت و فونت سیستمی ساخته شود
+var (
+	//go:embed fonts/DejaVuSans.ttf
+	fontRegular []byte
+	//go:embed fonts/DejaVuSans-Bold.ttf
+	fontBold []byte
+)
+
+const (
+	pdfFont     = "dejavu"
+	pdfMargin   = 12.0
+	pdfRowH     = 6.5
+	pdfFontSize = 8.5
+)
+
+// renderPDF دو بار ساخته می‌شود تا تعداد کل صفحات در پاورقی با ارقام فارسی نوشته شود
+func renderPDF(doc *Document, company Company) ([]byte, error) {
+	first, err := buildPDF(doc, company, 0)
+	if err != nil {
+		return nil, err
+	}
+	pdf, err := buildPDF(doc, company, first.PageCount())
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func buildPDF(doc *Document, company Company, totalPages int) (*fpdf.Fpdf, error) {
+	orientation := "P"
+	for _, table := range doc.Tables {
+		if columnWeight(table.Columns) > 15 {
+			orientation = "L"
+		}
+	}
+
+	pdf := fpdf.New(orientation, "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes(pdfFont, "", fontRegular)
+	pdf.AddUTF8FontFromBytes(pdfFont, "B", fontBold)
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(false, pdfMargin)
+	pdf.SetTitle(doc.Title, true)
+	pdf.SetCreator("hgm", true)
+
+	r := &pdfRenderer{pdf: pdf}
+	r.pageW, r.pageH = pdf.GetPageSize()
+	generatedAt := time.Now()
+
+	pdf.SetHeaderFunc(func() { r.header(doc, company, generatedAt) })
+	pdf.SetFooterFunc(func() {
+		label := fmt.Sprintf("صفحه %d", pdf.PageNo())
+		if totalPages > 0 {
+			label += fmt.Sprintf(" از %d", totalPages)
+		}
+		pdf.SetFont(pdfFont, "", 7.5)
+		pdf.SetTextColor(110, 110, 110)
+		pdf.SetXY(pdfMargin, r.pageH-pdfMargin+2)
+		pdf.CellFormat(r.pageW-2*pdfMargin, 5, Visual(utils.PersianDigits(label)), "", 0, "C", false, 0, "")
+		pdf.SetTextColor(0, 0, 0)
+	})
+
+	pdf.AddPage()
+	r.fields(doc)
+	for _, table := range doc.Tables {
+		r.table(table)
+	}
+	return pdf, pdf.Error()
+}
+
+type pdfRenderer struct {
+	pdf          *fpdf.Fpdf
+	pageW, pageH float64
+	widths       []float64 // عرض ستون‌های جدول جاری
+	columns      []Column
+}
+
+// header سربرگ هر صفحه: نام و مشخصات شرکت در راست، تاریخ تهیه در چپ و عنوان گزارش در وسط
+func (r *pdfRenderer) header(doc *Document, company Company, generatedAt time.Time) {
+	pdf := r.pdf
+	width := r.pageW - 2*pdfMargin
+	if doc.Watermark != "" {
+		r.watermark(doc.Watermark)
+	}
+	pdf.SetXY(pdfMargin, pdfMargin)
+
+	pdf.SetFont(pdfFont, "", 8)
+	pdf.CellFormat(width, 6, Visual("تاریخ تهیه: "+FormatDate(generatedAt)), "", 0, "L", false, 0, "")
+	pdf.SetXY(pdfMargin, pdfMargin)
+	if company.Name != "" {
+		pdf.SetFont(pdfFont, "B", 13)
+		pdf.CellFormat(width, 6, Visual(company.Name), "", 0, "R", false, 0, "")
+	}
+	pdf.SetY(pdfMargin + 6.5)
+
+	var contact []string
+	if company.Address != "" {
+		contact = append(contact, "نشانی: "+company.Address)
+	}
+	if company.Phone != "" {
+		contact = append(contact, "تلفن: "+company.Phone)
+	}
+	if len(contact) > 0 {
+		pdf.SetFont(pdfFont, "", 8)
+		pdf.SetX(pdfMargin)
+		pdf.CellFormat(width, 5, Visual(utils.PersianDigits(strings.Join(contact, "  |  "))), "", 0, "R", false, 0, "")
+	}
+	pdf.SetY(pdfMargin + 12)
+
+	pdf.SetFont(pdfFont, "B", 12)
+	pdf.SetX(pdfMargin)
+	pdf.CellFormat(width, 7, Visual(doc.Title), "", 1, "C", false, 0, "")
+	if doc.Subtitle != "" {
+		pdf.SetFont(pdfFont, "", 9)
+		pdf.SetX(pdfMargin)
+		pdf.CellFormat(width, 5, Visual(utils.PersianDigits(doc.Subtitle)), "", 1, "C", false, 0, "")
+	}
+
+	y := pdf.GetY() + 1.5
+	pdf.SetDrawColor(120, 120, 120)
+	pdf.Line(pdfMargin, y, r.pageW-pdfMargin, y)
+	pdf.SetY(y + 3)
+}
+
+// fields مشخصات سند در دو ستون و QR در سمت چپ (فقط صفحه اول)
+func (r *pdfRenderer) fields(doc *Document) {
+	if len(doc.Fields) == 0 && doc.QRCode == "" {
+		return
+	}
+	pdf := r.pdf
+	top := pdf.GetY()
+	width := r.pageW - 2*pdfMargin
+	qrSize := 0.0
+	if doc.QRCode != "" {
+		qrSize = 26
+		key := barcode.RegisterQR(pdf, doc.QRCode, qr.M, qr.Auto)
+		barcode.Barcode(pdf, key, pdfMargin, top, qrSize, qrSize, false)
+		width -= qrSize + 4
+	}
+
+	const lineH = 5.5
+	colW := width / 2
+	for i, field := range doc.Fields {
+		x := r.pageW - pdfMargin - float64(i%2+1)*colW
+		y := top + float64(i/2)*lineH
+		pdf.SetXY(x, y)
+		pdf.SetFont(pdfFont, "B", pdfFontSize)
+		label := field.Label + ": "
+		labelW := pdf.GetStringWidth(Visual(label)) + 1
+		pdf.CellFormat(colW, lineH, Visual(label), "", 0, "R", false, 0, "")
+		pdf.SetFont(pdfFont, "", pdfFontSize)
+		pdf.SetXY(x, y)
+		pdf.CellFormat(colW-labelW, lineH, r.fit(utils.PersianDigits(field.Value), colW-labelW), "", 0, "R", false, 0, "")
+	}
+
+	bottom := top + float64((len(doc.Fields)+1)/2)*lineH
+	if top+qrSize > bottom {
+		bottom = top + qrSize
+	}
+	pdf.SetXY(pdfMargin, bottom+4)
+}
+
+// watermark متن بزرگ و کم‌رنگ مورب در وسط صفحه
+func (r *pdfRenderer) watermark(text string) {
+	pdf := r.pdf
+	cx, cy := r.pageW/2, r.pageH/2
+	pdf.SetFont(pdfFont, "B", 60)
+	pdf.SetTextColor(235, 200, 200)
+	pdf.TransformBegin()
+	pdf.TransformRotate(35, cx, cy)
+	pdf.SetXY(pdfMargin, cy-15)
+	pdf.CellFormat(r.pageW-2*pdfMargin, 30, Visual(text), "", 0, "C", false, 0, "")
+	pdf.TransformEnd()
+	pdf.SetTextColor(0, 0, 0)
+}
+
+func (r *pdfRenderer) table(table Table) {
+	pdf := r.pdf
+	r.columns = table.Columns
+	r.widths = columnWidths(table.Columns, r.pageW-2*pdfMargin)
+
+	// عنوان جدول و سرستون‌ها با حداقل یک ردیف در همان صفحه
+	titleH := 0.0
+	if table.Title != "" {
+		titleH = 8
+	}
+	r.ensureSpace(titleH + 2*pdfRowH)
+	if table.Title != "" {
+		pdf.SetFont(pdfFont, "B", 10)
+		pdf.SetX(pdfMargin)
+		pdf.CellFormat(r.pageW-2*pdfMargin, 7, Visual(table.Title), "", 1, "R", false, 0, "")
+		pdf.Ln(1)
+	}
+	r.columnHeader()
+
+	if len(table.Rows) == 0 {
+		pdf.SetFont(pdfFont, "", pdfFontSize)
+		pdf.SetX(pdfMargin)
+		pdf.CellFormat(r.pageW-2*pdfMargin, pdfRowH, Visual("موردی یافت نشد"), "1", 1, "C", false, 0, "")
+	}
+	for i, row := range table.Rows {
+		if r.ensureSpace(pdfRowH) {
+			r.columnHeader()
+		}
+		r.row(row, i%2 == 1)
+	}
+	pdf.Ln(5)
+}
+
+// ensureSpace اگر ارتفاع خواسته‌شده در صفحه جا نشود صفحه جدید باز می‌کند
+func (r *pdfRenderer) ensureSpace(h float64) bool {
+	if r.pdf.GetY()+h <= r.pageH-pdfMargin-4 {
+		return false
+	}
+	r.pdf.AddPage()
+	return true
+}
+
+func (r *pdfRenderer) columnHeader() {
+	pdf := r.pdf
+	pdf.SetFont(pdfFont, "B", pdfFontSize)
+	pdf.SetFillColor(217, 225, 242)
+	pdf.SetDrawColor(170, 170, 170)
+	y := pdf.GetY()
+	x := r.pageW - pdfMargin
+	for i, col := range r.columns {
+		x -= r.widths[i]
+		pdf.SetXY(x, y)
+		pdf.CellFormat(r.widths[i], pdfRowH, r.fit(col.Title, r.widths[i]), "1", 0, "C", true, 0, "")
+	}
+	pdf.SetXY(pdfMargin, y+pdfRowH)
+}
+
+func (r *pdfRenderer) row(row Row, shaded bool) {
+	pdf := r.pdf
+	style := ""
+	if row.Bold {
+		style = "B"
+	}
+	pdf.SetFont(pdfFont, style, pdfFontSize)
+	pdf.SetFillColor(245, 245, 245)
+	if row.Bold {
+		pdf.SetFillColor(235, 235, 235)
+	}
+	fill := shaded || row.Bold
+
+	y := pdf.GetY()
+	x := r.pageW - pdfMargin
+	for i, col := range r.columns {
+		w := r.widths[i]
+		x -= w
+		var value any
+		if i < len(row.Cells) {
+			value = row.Cells[i]
+		}
+
+		pdf.SetXY(x, y)
+		pdf.CellFormat(w, pdfRowH, "", "1", 0, "", fill, 0, "")
+
+		indent := 0.0
+		if i == 0 {
+			indent = float64(row.Level) * 4
+		}
+		align := "C"
+		if col.Kind == KindText || col.Kind == KindAmount {
+			align = "R"
+		}
+		pdf.SetXY(x, y)
+		pdf.CellFormat(w-indent, pdfRowH, r.fit(displayCell(value, col.Kind), w-indent), "", 0, align, false, 0, "")
+	}
+	pdf.SetXY(pdfMargin, y+pdfRowH)
+}
+
+// fit متن منطقی را در صورت نیاز کوتاه می‌کند و شکل نمایشی آن را برمی‌گرداند
+func (r *pdfRenderer) fit(s string, width float64) string {
+	available := width - 2*r.pdf.GetCellMargin()
+	visual := Visual(s)
+	if r.pdf.GetStringWidth(visual) <= available {
+		return visual
+	}
+	runes := []rune(s)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		visual = Visual(strings.TrimSpace(string(runes)) + "…")
+		if r.pdf.GetStringWidth(visual) <= available {
+			return visual
+		}
+	}
+	return ""
+}
+
+// displayCell متن خانه برای PDF: ارقام فارسی، جداکننده هزارگان و تاریخ شمسی
+func displayCell(v any, kind ColumnKind) string {
+	if isEmpty(v) {
+		return ""
+	}
+	if t, ok := cellTime(v); ok {
+		return FormatDate(t)
+	}
+	if n, ok := cellFloat(v); ok {
+		switch kind {
+		case KindAmount:
+			return FormatAmount(n)
+		case KindPercent:
+			return FormatPercent(n)
+		default:
+			return utils.PersianDigits(strconv.FormatFloat(n, 'f', -1, 64))
+		}
+	}
+	if s, ok := v.(string); ok {
+		return utils.PersianDigits(s)
+	}
+	return utils.PersianDigits(fmt.Sprint(v))
+}
+
+func kindWeight(kind ColumnKind) float64 {
+	switch kind {
+	case KindText:
+		return 3
+	case KindAmount:
+		return 2
+	case KindDate:
+		return 1.6
+	case KindPercent:
+		return 1.3
+	default:
+		return 1
+	}
+}
+
+func columnWeight(columns []Column) float64 {
+	total := 0.0
+	for _, col := range columns {
+		total += kindWeight(col.Kind)
+	}
+	return total
+}
+
+func columnWidths(columns []Column, available float64) []float64 {
+	total := columnWeight(columns)
+	widths := make([]float64, len(columns))
+	for i, col := range columns {
+		widths[i] = available * kindWeight(col.Kind) / total
+	}
+	return widths
+}
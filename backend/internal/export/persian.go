@@ -0,0 +1,291 @@
+package export
+
+import (
+	"math"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/amirqodi/hgm/internal/utils"
+)
+
+// شکل‌های نمایشی هر حرف: جدا، پایانی، آغازی، میانی (صفر یعنی حرف به بعدی نمی‌چسبد)
+var letterForms = map[rune][4]rune{
+	'ء': {0xFE80, 0, 0, 0},
+	'آ': {0xFE81, 0xFE82, 0, 0},
+	'أ': {0xFE83, 0xFE84, 0, 0},
+	'ؤ': {0xFE85, 0xFE86, 0, 0},
+	'إ': {0xFE87, 0xFE88, 0, 0},
+	'ئ': {0xFE89, 0xFE8A, 0xFE8B, 0xFE8C},
+	'ا': {0xFE8D, 0xFE8E, 0, 0},
+	'ب': {0xFE8F, 0xFE90, 0xFE91, 0xFE92},
+	'ة': {0xFE93, 0xFE94, 0, 0},
+	'ت': {0xFE95, 0xFE96, 0xFE97, 0xFE98},
+	'ث': {0xFE99, 0xFE9A, 0xFE9B, 0xFE9C},
+	'ج': {0xFE9D, 0xFE9E, 0xFE9F, 0xFEA0},
+	'ح': {0xFEA1, 0xFEA2, 0xFEA3, 0xFEA4},
+	'خ': {0xFEA5, 0xFEA6, 0xFEA7, 0xFEA8},
+	'د': {0xFEA9, 0xFEAA, 0, 0},
+	'ذ': {0xFEAB, 0xFEAC, 0, 0},
+	'ر': {0xFEAD, 0xFEAE, 0, 0},
+	'ز': {0xFEAF, 0xFEB0, 0, 0},
+	'س': {0xFEB1, 0xFEB2, 0xFEB3, 0xFEB4},
+	'ش': {0xFEB5, 0xFEB6, 0xFEB7, 0xFEB8},
+	'ص': {0xFEB9, 0xFEBA, 0xFEBB, 0xFEBC},
+	'ض': {0xFEBD, 0xFEBE, 0xFEBF, 0xFEC0},
+	'ط': {0xFEC1, 0xFEC2, 0xFEC3, 0xFEC4},
+	'ظ': {0xFEC5, 0xFEC6, 0xFEC7, 0xFEC8},
+	'ع': {0xFEC9, 0xFECA, 0xFECB, 0xFECC},
+	'غ': {0xFECD, 0xFECE, 0xFECF, 0xFED0},
+	'ف': {0xFED1, 0xFED2, 0xFED3, 0xFED4},
+	'ق': {0xFED5, 0xFED6, 0xFED7, 0xFED8},
+	'ك': {0xFED9, 0xFEDA, 0xFEDB, 0xFEDC},
+	'ل': {0xFEDD, 0xFEDE, 0xFEDF, 0xFEE0},
+	'م': {0xFEE1, 0xFEE2, 0xFEE3, 0xFEE4},
+	'ن': {0xFEE5, 0xFEE6, 0xFEE7, 0xFEE8},
+	'ه': {0xFEE9, 0xFEEA, 0xFEEB, 0xFEEC},
+	'و': {0xFEED, 0xFEEE, 0, 0},
+	'ى': {0xFEEF, 0xFEF0, 0, 0},
+	'ي': {0xFEF1, 0xFEF2, 0xFEF3, 0xFEF4},
+	'پ': {0xFB56, 0xFB57, 0xFB58, 0xFB59},
+	'چ': {0xFB7A, 0xFB7B, 0xFB7C, 0xFB7D},
+	'ژ': {0xFB8A, 0xFB8B, 0, 0},
+	'ک': {0xFB8E, 0xFB8F, 0xFB90, 0xFB91},
+	'گ': {0xFB92, 0xFB93, 0xFB94, 0xFB95},
+	'ی': {0xFBFC, 0xFBFD, 0xFBFE, 0xFBFF},
+	'ـ': {'ـ', 'ـ', 'ـ', 'ـ'},
+}
+
+// لام‌الف: جدا و پایانی
+var lamAlef = map[rune][2]rune{
+	'آ': {0xFEF5, 0xFEF6},
+	'أ': {0xFEF7, 0xFEF8},
+	'إ': {0xFEF9, 0xFEFA},
+	'ا': {0xFEFB, 0xFEFC},
+}
+
+var mirrored = map[rune]rune{
+	'(': ')', ')': '(', '[': ']', ']': '[', '{': '}', '}': '{',
+	'<': '>', '>': '<', '«': '»', '»': '«',
+}
+
+const (
+	zwnj = '‌'
+	zwj  = '‍'
+)
+
+// joinsNext حرفی که می‌تواند به حرف بعدی بچسبد
+func joinsNext(r rune) bool {
+	if r == zwj {
+		return true
+	}
+	forms, ok := letterForms[r]
+	return ok && forms[2] != 0
+}
+
+// joinsPrev حرفی که می‌تواند به حرف قبلی بچسبد
+func joinsPrev(r rune) bool {
+	if r == zwj {
+		return true
+	}
+	_, ok := letterForms[r]
+	return ok && r != 'ء'
+}
+
+func isMark(r rune) bool {
+	return (r >= 0x064B && r <= 0x065F) || r == 0x0670
+}
+
+// shape حروف فارسی را به شکل نمایشی (چسبیده) تبدیل می‌کند؛ اعراب حذف می‌شوند
+func shape(in []rune) []rune {
+	rs := make([]rune, 0, len(in))
+	for _, r := range in {
+		if !isMark(r) {
+			rs = append(rs, r)
+		}
+	}
+
+	out := make([]rune, 0, len(rs))
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		forms, ok := letterForms[r]
+		if !ok {
+			if r != zwnj && r != zwj {
+				out = append(out, r)
+			}
+			continue
+		}
+
+		prev := i > 0 && joinsNext(rs[i-1])
+		if r == 'ل' && i+1 < len(rs) {
+			if lig, ok := lamAlef[rs[i+1]]; ok {
+				if prev {
+					out = append(out, lig[1])
+				} else {
+					out = append(out, lig[0])
+				}
+				i++
+				continue
+			}
+		}
+
+		next := forms[2] != 0 && i+1 < len(rs) && joinsPrev(rs[i+1])
+		switch {
+		case prev && next:
+			out = append(out, forms[3])
+		case prev && forms[1] != 0:
+			out = append(out, forms[1])
+		case next:
+			out = append(out, forms[2])
+		default:
+			out = append(out, forms[0])
+		}
+	}
+	return out
+}
+
+type bidiClass int
+
+const (
+	bidiNeutral bidiClass = iota
+	bidiLTR
+	bidiRTL
+)
+
+func isDigit(r rune) bool {
+	return (r >= '0' && r <= '9') || (r >= '۰' && r <= '۹') || (r >= '٠' && r <= '٩')
+}
+
+func isRTL(r rune) bool {
+	if isDigit(r) {
+		return false
+	}
+	return (r >= 0x0590 && r <= 0x08FF) || (r >= 0xFB1D && r <= 0xFDFF) || (r >= 0xFE70 && r <= 0xFEFF)
+}
+
+func classOf(r rune) bidiClass {
+	switch {
+	case isRTL(r):
+		return bidiRTL
+	case isDigit(r) || unicode.IsLetter(r):
+		return bidiLTR
+	default:
+		return bidiNeutral
+	}
+}
+
+// Visual متن منطقی را برای چاپ در PDF آماده می‌کند: حروف چسبیده و ترتیب نمایشی راست‌به‌چپ.
+// نسخه ساده‌شده الگوریتم bidi است: اعداد و کلمات لاتین چپ‌به‌راست می‌مانند و
+// بقیه متن (با جهت پایه راست‌به‌چپ) معکوس می‌شود.
+func Visual(s string) string {
+	rs := shape([]rune(s))
+	n := len(rs)
+
+	hasRTL := false
+	cls := make([]bidiClass, n)
+	for i, r := range rs {
+		cls[i] = classOf(r)
+		hasRTL = hasRTL || cls[i] == bidiRTL
+	}
+	if !hasRTL {
+		return string(rs)
+	}
+
+	// جداکننده‌های داخل عدد، علامت منفی و درصد جزو عدد هستند
+	for i := 0; i < n; i++ {
+		if cls[i] != bidiNeutral {
+			continue
+		}
+		prevDigit := i > 0 && isDigit(rs[i-1])
+		nextDigit := i+1 < n && isDigit(rs[i+1])
+		switch rs[i] {
+		case ',', '.', '/', ':', '٬', '٫':
+			if prevDigit && nextDigit {
+				cls[i] = bidiLTR
+			}
+		case '-', '−':
+			if nextDigit && (i == 0 || cls[i-1] == bidiNeutral) {
+				cls[i] = bidiLTR
+			}
+		case '%', '٪':
+			if prevDigit {
+				cls[i] = bidiLTR
+			}
+		}
+	}
+
+	// خنثی‌ها بین دو بخش چپ‌به‌راست، چپ‌به‌راست و بقیه هم‌جهت پاراگراف هستند
+	for i := 0; i < n; {
+		if cls[i] != bidiNeutral {
+			i++
+			continue
+		}
+		j := i
+		for j < n && cls[j] == bidiNeutral {
+			j++
+		}
+		resolved := bidiRTL
+		if i > 0 && j < n && cls[i-1] == bidiLTR && cls[j] == bidiLTR {
+			resolved = bidiLTR
+		}
+		for k := i; k < j; k++ {
+			cls[k] = resolved
+		}
+		i = j
+	}
+
+	out := make([]rune, n)
+	outCls := make([]bidiClass, n)
+	for i := range rs {
+		r, c := rs[n-1-i], cls[n-1-i]
+		if c == bidiRTL {
+			if m, ok := mirrored[r]; ok {
+				r = m
+			}
+		}
+		out[i], outCls[i] = r, c
+	}
+	for i := 0; i < n; {
+		if outCls[i] != bidiLTR {
+			i++
+			continue
+		}
+		j := i
+		for j < n && outCls[j] == bidiLTR {
+			j++
+		}
+		for a, b := i, j-1; a < b; a, b = a+1, b-1 {
+			out[a], out[b] = out[b], out[a]
+		}
+		i = j
+	}
+	return string(out)
+}
+
+// FormatAmount مبلغ با ارقام فارسی و جداکننده هزارگان؛ مبالغ منفی داخل پرانتز
+func FormatAmount(v float64) string {
+	decimals := 0
+	if math.Abs(v-math.Round(v)) >= 0.005 {
+		decimals = 2
+	}
+	s := utils.GroupThousands(math.Abs(v), decimals, "٬")
+	s = utils.PersianDigits(strings.Replace(s, ".", "٫", 1))
+	if v < 0 && s != "۰" {
+		return "(" + s + ")"
+	}
+	return s
+}
+
+// FormatPercent درصد با ارقام فارسی، مثلاً ۱۲٫۵٪
+func FormatPercent(v float64) string {
+	s := strings.TrimRight(strings.TrimRight(utils.GroupThousands(v, 2, "٬"), "0"), ".")
+	return utils.PersianDigits(strings.Replace(s, ".", "٫", 1)) + "٪"
+}
+
+// FormatDate تاریخ شمسی با ارقام فارسی
+func FormatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return utils.PersianDigits(utils.FormatJalali(t))
+}
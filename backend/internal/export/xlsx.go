@@ -0,0 +1,183 @@
+package export
+
+import (
+	"github.com/xuri/excelize/v2"
+)
+
+const xlsxSheet = "گزارش"
+
+var xlsxBorder = []excelize.Border{
+	{Type: "left", Color: "BFBFBF", Style: 1}, {Type: "right", Color: "BFBFBF", Style: 1},
+	{Type: "top", Color: "BFBFBF", Style: 1}, {Type: "bottom", Color: "BFBFBF", Style: 1},
+}
+
+// renderXLSX همه جدول‌ها در یک برگه راست‌به‌چپ؛ مبالغ عددی با فرمت هزارگان ذخیره می‌شوند
+func renderXLSX(doc *Document) ([]byte, error) {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	if err := f.SetSheetName("Sheet1", xlsxSheet); err != nil {
+		return nil, err
+	}
+	rtl := true
+	if err := f.SetSheetView(xlsxSheet, 0, &excelize.ViewOptions{RightToLeft: &rtl}); err != nil {
+		return nil, err
+	}
+
+	styles, err := newXLSXStyles(f)
+	if err != nil {
+		return nil, err
+	}
+
+	maxCols := 1
+	for _, table := range doc.Tables {
+		maxCols = max(maxCols, len(table.Columns))
+	}
+	lastCol, _ := excelize.ColumnNumberToName(maxCols)
+	f.SetColWidth(xlsxSheet, "A", "A", 36)
+	if maxCols > 1 {
+		f.SetColWidth(xlsxSheet, "B", lastCol, 18)
+	}
+
+	row := 1
+	title := func(text string, style int) {
+		cell, _ := excelize.CoordinatesToCellName(1, row)
+		f.SetCellValue(xlsxSheet, cell, text)
+		f.SetCellStyle(xlsxSheet, cell, cell, style)
+		row++
+	}
+
+	title(doc.Title, styles.title)
+	if doc.Subtitle != "" {
+		title(doc.Subtitle, styles.text)
+	}
+	for _, field := range doc.Fields {
+		label, _ := excelize.CoordinatesToCellName(1, row)
+		value, _ := excelize.CoordinatesToCellName(2, row)
+		f.SetCellValue(xlsxSheet, label, field.Label)
+		f.SetCellValue(xlsxSheet, value, field.Value)
+		f.SetCellStyle(xlsxSheet, label, label, styles.bold)
+		f.SetCellStyle(xlsxSheet, value, value, styles.text)
+		row++
+	}
+
+	for _, table := range doc.Tables {
+		row++
+		if table.Title != "" {
+			title(table.Title, styles.bold)
+		}
+		for i, col := range table.Columns {
+			cell, _ := excelize.CoordinatesToCellName(i+1, row)
+			f.SetCellValue(xlsxSheet, cell, col.Title)
+			f.SetCellStyle(xlsxSheet, cell, cell, styles.header)
+		}
+		row++
+
+		for _, r := range table.Rows {
+			for i, col := range table.Columns {
+				if i >= len(r.Cells) || isEmpty(r.Cells[i]) {
+					continue
+				}
+				cell, _ := excelize.CoordinatesToCellName(i+1, row)
+				style := styles.cellStyle(col.Kind, r.Bold)
+				if n, ok := cellFloat(r.Cells[i]); ok && col.Kind != KindText {
+					f.SetCellValue(xlsxSheet, cell, n)
+				} else {
+					f.SetCellValue(xlsxSheet, cell, plainCell(r.Cells[i]))
+				}
+				if i == 0 && r.Level > 0 {
+					style = styles.indented(f, r.Level, r.Bold)
+				}
+				f.SetCellStyle(xlsxSheet, cell, cell, style)
+			}
+			row++
+		}
+	}
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type xlsxStyles struct {
+	title, header, text, bold int
+	amount, amountBold        int
+	percent, percentBold      int
+	indent                    map[[2]int]int
+}
+
+func newXLSXStyles(f *excelize.File) (*xlsxStyles, error) {
+	s := &xlsxStyles{indent: map[[2]int]int{}}
+	amountFormat := "#,##0;(#,##0)"
+	percentFormat := `0.00"%"`
+
+	defs := []struct {
+		target *int
+		style  *excelize.Style
+	}{
+		{&s.title, &excelize.Style{Font: &excelize.Font{Bold: true, Size: 14}}},
+		{&s.header, &excelize.Style{
+			Font:      &excelize.Font{Bold: true},
+			Fill:      excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"D9E1F2"}},
+			Alignment: &excelize.Alignment{Horizontal: "center"},
+			Border:    xlsxBorder,
+		}},
+		{&s.text, &excelize.Style{Border: xlsxBorder}},
+		{&s.bold, &excelize.Style{Font: &excelize.Font{Bold: true}, Border: xlsxBorder}},
+		{&s.amount, &excelize.Style{CustomNumFmt: &amountFormat, Border: xlsxBorder}},
+		{&s.amountBold, &excelize.Style{CustomNumFmt: &amountFormat, Font: &excelize.Font{Bold: true}, Border: xlsxBorder}},
+		{&s.percent, &excelize.Style{CustomNumFmt: &percentFormat, Border: xlsxBorder}},
+		{&s.percentBold, &excelize.Style{CustomNumFmt: &percentFormat, Font: &excelize.Font{Bold: true}, Border: xlsxBorder}},
+	}
+	for _, def := range defs {
+		id, err := f.NewStyle(def.style)
+		if err != nil {
+			return nil, err
+		}
+		*def.target = id
+	}
+	return s, nil
+}
+
+func (s *xlsxStyles) cellStyle(kind ColumnKind, bold bool) int {
+	switch kind {
+	case KindAmount:
+		if bold {
+			return s.amountBold
+		}
+		return s.amount
+	case KindPercent:
+		if bold {
+			return s.percentBold
+		}
+		return s.percent
+	}
+	if bold {
+		return s.bold
+	}
+	return s.text
+}
+
+// indented سبک ستون اول ردیف‌های درختی (مثل زیردسته‌ها)
+func (s *xlsxStyles) indented(f *excelize.File, level int, bold bool) int {
+	b := 0
+	if bold {
+		b = 1
+	}
+	key := [2]int{level, b}
+	if id, ok := s.indent[key]; ok {
+		return id
+	}
+	id, err := f.NewStyle(&excelize.Style{
+		Font:      &excelize.Font{Bold: bold},
+		Alignment: &excelize.Alignment{Indent: level},
+		Border:    xlsxBorder,
+	})
+	if err != nil {
+		return s.cellStyle(KindText, bold)
+	}
+	s.indent[key] = id
+	return id
+}
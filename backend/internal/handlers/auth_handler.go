@@ -1,6 +1,14 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/models"
+	"github.com/amirqodi/hgm/internal/repositories"
 	"github.com/amirqodi/hgm/internal/services"
 	"github.com/amirqodi/hgm/internal/utils"
 	"github.com/gofiber/fiber/v2"
@@ -32,20 +40,101 @@ func Login(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(errorsMap)
 	}
 
+	// قفل موقت پس از تلاش‌های ناموفق پیاپی
+	client := services.LoginClient{IP: c.IP(), UserAgent: c.Get(fiber.HeaderUserAgent)}
+	wait, err := services.CheckLoginLockout(body.Username, client, services.LoginLimitsFromEnv())
+	if err != nil {
+		errorsMap["_error"] = append(errorsMap["_error"], "خطا در بررسی ورود")
+		return c.Status(fiber.StatusInternalServerError).JSON(errorsMap)
+	}
+	if wait > 0 {
+		errorsMap["_error"] = append(errorsMap["_error"], lockedOut(c, wait))
+		return c.Status(fiber.StatusTooManyRequests).JSON(errorsMap)
+	}
+
 	// احراز هویت
-	user, authErrors := services.Authenticate(body.Username, body.Password)
+	user, authErrors := services.Authenticate(body.Username, body.Password, client)
 	if authErrors != nil && len(authErrors) > 0 {
 		return c.Status(fiber.StatusUnauthorized).JSON(authErrors)
 	}
 
-	// ایجاد توکن
-	token, err := utils.GenerateJWT(user.ID, user.Username)
+	// با ورود دومرحله‌ای، نشست پس از کد درست در /auth/login/2fa ساخته می‌شود
+	if user.TwoFactorEnabled {
+		challenge, err := services.NewLoginChallenge(user)
+		if err != nil {
+			errorsMap["_error"] = append(errorsMap["_error"], "خطا در ایجاد توکن")
+			return c.Status(fiber.StatusInternalServerError).JSON(errorsMap)
+		}
+		return c.JSON(fiber.Map{
+			"two_factor_required": true,
+			"challenge_token":     challenge,
+		})
+	}
+
+	// ایجاد نشست و توکن‌ها
+	session, refreshToken, err := repositories.CreateSession(user.ID, c.Get(fiber.HeaderUserAgent), c.IP())
 	if err != nil {
-		errorsMap["_error"] = append(errorsMap["_error"], "خطا در ایجاد توکن")
+		errorsMap["_error"] = append(errorsMap["_error"], "خطا در ایجاد نشست")
 		return c.Status(fiber.StatusInternalServerError).JSON(errorsMap)
 	}
+	return sendTokens(c, user, session, refreshToken)
+}
+
+// lockedOut تنظیم Retry-After و پیام قفل موقت ورود
+func lockedOut(c *fiber.Ctx, wait time.Duration) string {
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	minutes := int(math.Ceil(wait.Minutes()))
+	return fmt.Sprintf("به دلیل تلاش‌های ناموفق زیاد، %s دقیقه دیگر دوباره تلاش کنید", utils.PersianDigits(strconv.Itoa(minutes)))
+}
+
+// sendTokens پاسخ ورود و تازه‌سازی: توکن دسترسی کوتاه‌عمر و توکن تازه‌سازی نشست
+// (کلید token برای سازگاری با کلاینت‌های قبلی همان توکن دسترسی است)
+func sendTokens(c *fiber.Ctx, user *models.User, session *models.Session, refreshToken string) error {
+	ttl := utils.AccessTokenTTL()
+	token, err := utils.GenerateJWT(user.ID, user.Username, session.ID, ttl)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در ایجاد توکن"})
+	}
 
 	return c.JSON(fiber.Map{
-		"token": token,
+		"token":                token,
+		"access_token":         token,
+		"refresh_token":        refreshToken,
+		"expires_in":           int(ttl.Seconds()),
+		"must_change_password": user.MustChangePassword,
 	})
 }
+
+// currentUser کاربر لاگین‌شده؛ از Authorize یا بر اساس user_id ذخیره‌شده در JWT
+func currentUser(c *fiber.Ctx) (*models.User, error) {
+	if user, ok := c.Locals("user").(*models.User); ok {
+		return user, nil
+	}
+	id, ok := c.Locals("user_id").(float64)
+	if !ok {
+		return nil, errors.New("user not found in token")
+	}
+	return repositories.FindByID(uint(id))
+}
+
+// cashHolderScope تنخواهی که صندوقدار به آن محدود است؛ برای سایر نقش‌ها nil
+// (صندوقدار بدون تنخواه به هیچ تنخواهی دسترسی ندارد)
+func cashHolderScope(c *fiber.Ctx) *uint {
+	user, err := currentUser(c)
+	if err != nil || user.Role != models.RoleCashier {
+		return nil
+	}
+	if user.CashHolderID == nil {
+		none := uint(0)
+		return &none
+	}
+	return user.CashHolderID
+}
+
+// inCashHolderScope تنخواه id در محدوده دسترسی کاربر است
+func inCashHolderScope(scope, id *uint) bool {
+	return scope == nil || (id != nil && *id == *scope)
+}
+
+// errOutOfScope خطای دسترسی صندوقدار به تنخواه یا تراکنش دیگر
+var errOutOfScope = errors.New("شما فقط به تنخواه خودتان دسترسی دارید")
@@ -5,6 +5,7 @@ import (
 
 	"github.com/amirqodi/hgm/internal/models"
 	"github.com/amirqodi/hgm/internal/repositories"
+	"github.com/amirqodi/hgm/internal/services"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -15,7 +16,14 @@ func CreateBankAccount(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
 	}
 
+	if errorsMap := services.ValidateBankAccount(&account, nil); errorsMap != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errorsMap)
+	}
+
 	if err := repositories.CreateBankAccount(&account); err != nil {
+		if isCurrencyError(err) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "could not create bank account"})
 	}
 
@@ -60,11 +68,37 @@ func UpdateBankAccount(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
 	}
 
+	// فیلدهای ارسال‌نشده از رکورد فعلی برداشته می‌شوند؛ فقط فیلدهای تغییرکرده اعتبارسنجی می‌شوند
+	existing, err := repositories.GetBankAccountByID(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "account not found"})
+	}
+	if data.IBAN == "" {
+		data.IBAN = existing.IBAN
+	}
+	if data.CardNumber == "" {
+		data.CardNumber = existing.CardNumber
+	}
+	if data.AccountNumber == "" {
+		data.AccountNumber = existing.AccountNumber
+	}
+	if data.BankName == "" {
+		data.BankName = existing.BankName
+	}
+
+	if errorsMap := services.ValidateBankAccount(&data, &existing); errorsMap != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(errorsMap)
+	}
+
 	updated, err := repositories.UpdateBankAccount(uint(id), &data)
 	if err != nil {
+		if isCurrencyError(err) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "could not update account"})
 	}
 
+	updated.Warnings = data.Warnings
 	return c.JSON(updated)
 }
 
@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/database"
+	"github.com/amirqodi/hgm/internal/models"
+	"github.com/amirqodi/hgm/internal/repositories"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type bankChargeRequest struct {
+	BankAccountID uint                  `json:"bank_account_id"`
+	Kind          models.BankChargeKind `json:"kind"` // fee یا interest
+	CategoryID    *uint                 `json:"category_id"`
+	Amount        float64               `json:"amount"`
+	Date          string                `json:"date"` // میلادی یا شمسی
+	Description   string                `json:"description"`
+}
+
+type chargeFromLineRequest struct {
+	Kind        models.BankChargeKind `json:"kind"` // خالی = از جهت ردیف
+	CategoryID  *uint                 `json:"category_id"`
+	Description string                `json:"description"`
+}
+
+type bankChargeRuleRequest struct {
+	BankAccountID uint                  `json:"bank_account_id"`
+	Kind          models.BankChargeKind `json:"kind"`
+	CategoryID    *uint                 `json:"category_id"`
+	Amount        float64               `json:"amount"`
+	DayOfMonth    int                   `json:"day_of_month"`
+	Description   string                `json:"description"`
+	IsActive      *bool                 `json:"is_active"` // پیش‌فرض فعال
+}
+
+func (r *bankChargeRequest) toModel() (*models.BankCharge, error) {
+	if r.BankAccountID == 0 {
+		return nil, errors.New("حساب بانکی الزامیست")
+	}
+	date := time.Now()
+	if r.Date != "" {
+		parsed, _, err := parseDate(r.Date)
+		if err != nil {
+			return nil, errors.New("فرمت تاریخ نامعتبر است")
+		}
+		date = parsed
+	}
+	return &models.BankCharge{
+		BankAccountID: r.BankAccountID,
+		Kind:          r.Kind,
+		CategoryID:    r.CategoryID,
+		Amount:        r.Amount,
+		Date:          date,
+		Description:   r.Description,
+	}, nil
+}
+
+func (r *bankChargeRuleRequest) toModel() *models.BankChargeRule {
+	rule := &models.BankChargeRule{
+		BankAccountID: r.BankAccountID,
+		Kind:          r.Kind,
+		CategoryID:    r.CategoryID,
+		Amount:        r.Amount,
+		DayOfMonth:    r.DayOfMonth,
+		Description:   r.Description,
+		IsActive:      true,
+	}
+	if r.IsActive != nil {
+		rule.IsActive = *r.IsActive
+	}
+	return rule
+}
+
+// ---------------- CHARGES ----------------
+
+// GetBankChargesHandler ?bank_account_id=&kind=&from=&to=
+func GetBankChargesHandler(c *fiber.Ctx) error {
+	bankID, _ := strconv.Atoi(c.Query("bank_account_id", "0"))
+	filter := repositories.BankChargeFilter{
+		BankAccountID: uint(bankID),
+		Kind:          models.BankChargeKind(c.Query("kind")),
+	}
+	if c.Query("from") != "" || c.Query("to") != "" {
+		from, to, err := parseDateRange(c.Query("from"), c.Query("to"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		filter.From, filter.To = &from, &to
+	}
+
+	charges, err := repositories.GetBankCharges(filter, database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در دریافت کارمزدها"})
+	}
+	return c.JSON(charges)
+}
+
+func GetBankChargeByIDHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	charge, err := repositories.GetBankChargeByID(uint(id), database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "رکورد یافت نشد"})
+	}
+	return c.JSON(charge)
+}
+
+func CreateBankChargeHandler(c *fiber.Ctx) error {
+	var body bankChargeRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+	charge, err := body.toModel()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if user, err := currentUser(c); err == nil {
+		charge.CreatedByID = &user.ID
+	}
+
+	if err := repositories.CreateBankCharge(charge, database.DB); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(charge)
+}
+
+func UpdateBankChargeHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	var body bankChargeRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+	input, err := body.toModel()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	charge, err := repositories.UpdateBankCharge(uint(id), input, database.DB)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "رکورد یافت نشد"})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(charge)
+}
+
+func DeleteBankChargeHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	if err := repositories.DeleteBankCharge(uint(id), database.DB); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "رکورد یافت نشد"})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ---------------- STATEMENT ----------------
+
+// CreateChargeFromLineHandler ثبت کارمزد یا سود از روی ردیف صورتحساب
+func CreateChargeFromLineHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	var body chargeFromLineRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+		}
+	}
+
+	charge := models.BankCharge{
+		Kind:        body.Kind,
+		CategoryID:  body.CategoryID,
+		Description: body.Description,
+	}
+	if user, err := currentUser(c); err == nil {
+		charge.CreatedByID = &user.ID
+	}
+	line, err := repositories.CreateChargeFromLine(uint(id), &charge, database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"line":   line,
+		"charge": charge,
+	})
+}
+
+// DetectStatementChargesHandler ثبت خودکار کارمزد و سود برای ردیف‌های بدون تطبیق صورتحساب
+func DetectStatementChargesHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	created, err := repositories.DetectStatementCharges(uint(id), database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در ثبت کارمزدها"})
+	}
+	return c.JSON(fiber.Map{"created": created})
+}
+
+// ---------------- RULES ----------------
+func GetBankChargeRulesHandler(c *fiber.Ctx) error {
+	bankID, _ := strconv.Atoi(c.Query("bank_account_id", "0"))
+
+	rules, err := repositories.GetBankChargeRules(uint(bankID), database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در دریافت قواعد"})
+	}
+	return c.JSON(rules)
+}
+
+func CreateBankChargeRuleHandler(c *fiber.Ctx) error {
+	var body bankChargeRuleRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+
+	rule := body.toModel()
+	if err := repositories.CreateBankChargeRule(rule, database.DB); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(rule)
+}
+
+func UpdateBankChargeRuleHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	var body bankChargeRuleRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+
+	rule, err := repositories.UpdateBankChargeRule(uint(id), body.toModel(), database.DB)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "قاعده یافت نشد"})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(rule)
+}
+
+func DeleteBankChargeRuleHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	if err := repositories.DeleteBankChargeRule(uint(id), database.DB); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "قاعده یافت نشد"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در حذف قاعده"})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// RunBankChargeRulesHandler ثبت فوری کارمزدهای ماهانه سررسیدشده (بدون انتظار برای زمان‌بند)
+func RunBankChargeRulesHandler(c *fiber.Ctx) error {
+	posted, errs := repositories.PostDueBankCharges(time.Now(), database.DB)
+
+	messages := []string{}
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	return c.JSON(fiber.Map{"posted": posted, "errors": messages})
+}
@@ -0,0 +1,394 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"strconv"
+
+	"github.com/amirqodi/hgm/internal/database"
+	"github.com/amirqodi/hgm/internal/export"
+	"github.com/amirqodi/hgm/internal/models"
+	"github.com/amirqodi/hgm/internal/repositories"
+	"github.com/amirqodi/hgm/internal/services"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// حداکثر اختلاف روز بین تاریخ بانک و تاریخ ثبت در دفتر
+const defaultMatchToleranceDays = 3
+
+type confirmLineRequest struct {
+	MatchType string `json:"match_type"` // خالی = تایید پیشنهاد خودکار
+	MatchID   *uint  `json:"match_id"`
+}
+
+type createEntryRequest struct {
+	ContactID       uint   `json:"contact_id"`
+	CategoryID      uint   `json:"category_id"`
+	TransactionType string `json:"transaction_type"` // income یا expense (پیش‌فرض از جهت ردیف)
+	PaymentMethod   string `json:"payment_method"`
+	Notes           string `json:"notes"`
+}
+
+type lockRequest struct {
+	Until string `json:"until"`
+	Notes string `json:"notes"`
+}
+
+func toleranceDays(c *fiber.Ctx) int {
+	days, err := strconv.Atoi(c.Query("tolerance_days", c.FormValue("tolerance_days")))
+	if err != nil || days < 0 {
+		return defaultMatchToleranceDays
+	}
+	return days
+}
+
+// ---------------- IMPORT ----------------
+
+// ImportBankStatementHandler فرم: file, bank_account_id, mapping (شناسه یا نام نگاشت مثل mellat), create_charges
+func ImportBankStatementHandler(c *fiber.Ctx) error {
+	bankID, err := strconv.Atoi(c.FormValue("bank_account_id"))
+	if err != nil || bankID <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "حساب بانکی الزامیست"})
+	}
+
+	mapping, err := repositories.FindStatementMapping(c.FormValue("mapping"), database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "فایل صورتحساب الزامیست"})
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "فایل قابل خواندن نیست"})
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "فایل قابل خواندن نیست"})
+	}
+
+	lines, rowErrors, err := services.ParseBankStatement(fileHeader.Filename, data, mapping)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error(), "row_errors": rowErrors})
+	}
+
+	stmt := models.BankStatement{
+		BankAccountID: uint(bankID),
+		MappingID:     mapping.ID,
+		FileName:      fileHeader.Filename,
+	}
+	if user, err := currentUser(c); err == nil {
+		stmt.ImportedByID = &user.ID
+	}
+
+	// ثبت خودکار کارمزد و سود مگر اینکه create_charges=false ارسال شود
+	detectCharges := c.FormValue("create_charges") != "false"
+	if err := repositories.ImportBankStatement(&stmt, lines, toleranceDays(c), detectCharges, database.DB); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	result, err := repositories.GetBankStatementByID(stmt.ID, database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در دریافت صورتحساب"})
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"statement":  result,
+		"row_errors": rowErrors,
+	})
+}
+
+// ---------------- STATEMENTS ----------------
+func GetBankStatementsHandler(c *fiber.Ctx) error {
+	bankID, _ := strconv.Atoi(c.Query("bank_account_id", "0"))
+
+	statements, err := repositories.GetBankStatements(uint(bankID), database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در دریافت صورتحساب‌ها"})
+	}
+	return c.JSON(statements)
+}
+
+func GetBankStatementByIDHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	stmt, err := repositories.GetBankStatementByID(uint(id), database.DB)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "صورتحساب یافت نشد"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در دریافت صورتحساب"})
+	}
+	return sendReport(c, stmt, func() *export.Document { return bankStatementDocument(stmt) })
+}
+
+func DeleteBankStatementHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	if err := repositories.DeleteBankStatement(uint(id), database.DB); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "صورتحساب یافت نشد"})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// AutoMatchStatementHandler اجرای دوباره تطبیق خودکار برای ردیف‌های بدون تطبیق
+func AutoMatchStatementHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	matched, err := repositories.AutoMatchStatement(uint(id), toleranceDays(c), database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در تطبیق خودکار"})
+	}
+	return c.JSON(fiber.Map{"suggested": matched})
+}
+
+// ---------------- LINES ----------------
+
+// GetLineCandidatesHandler رکوردهای دفتری قابل تطبیق با یک ردیف
+func GetLineCandidatesHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	var line models.BankStatementLine
+	if err := database.DB.First(&line, id).Error; err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "ردیف صورتحساب یافت نشد"})
+	}
+
+	candidates, err := repositories.FindMatchCandidates(&line, toleranceDays(c), database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در جستجوی رکوردهای دفتری"})
+	}
+	if candidates == nil {
+		candidates = []repositories.MatchCandidate{}
+	}
+	return c.JSON(candidates)
+}
+
+func ConfirmLineHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	var body confirmLineRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+		}
+	}
+
+	line, err := repositories.ConfirmStatementLine(uint(id), body.MatchType, body.MatchID, database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(line)
+}
+
+func UnmatchLineHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	line, err := repositories.UnmatchStatementLine(uint(id), database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(line)
+}
+
+func IgnoreLineHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	line, err := repositories.IgnoreStatementLine(uint(id), database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(line)
+}
+
+// CreateEntryFromLineHandler ثبت تراکنش جاافتاده در دفتر از روی ردیف صورتحساب
+func CreateEntryFromLineHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	var body createEntryRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+	if body.ContactID == 0 || body.CategoryID == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "مخاطب و دسته‌بندی الزامی است"})
+	}
+
+	trx := models.Transaction{
+		ContactID:       body.ContactID,
+		CategoryID:      body.CategoryID,
+		TransactionType: body.TransactionType,
+		PaymentMethod:   body.PaymentMethod,
+		Notes:           body.Notes,
+	}
+	line, err := repositories.CreateEntryFromLine(uint(id), &trx, database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"line":        line,
+		"transaction": trx,
+	})
+}
+
+// ---------------- RECONCILIATION ----------------
+func GetReconciliationSummaryHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	summary, err := repositories.GetReconciliationSummary(uint(id), database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(summary)
+}
+
+func GetReconciliationLocksHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	locks, err := repositories.GetReconciliationLocks(uint(id), database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در دریافت قفل‌ها"})
+	}
+	return c.JSON(locks)
+}
+
+// LockReconciliationHandler قفل دوره تطبیق‌شده تا پایان روز until
+func LockReconciliationHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	var body lockRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+	until, dateOnly, err := parseDate(body.Until)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "فرمت تاریخ نامعتبر است"})
+	}
+	if dateOnly {
+		until = until.AddDate(0, 0, 1).Add(-1)
+	}
+
+	var userID *uint
+	if user, err := currentUser(c); err == nil {
+		userID = &user.ID
+	}
+
+	lock, err := repositories.LockReconciliation(uint(id), until, userID, body.Notes, database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(lock)
+}
+
+// ---------------- COLUMN MAPPINGS ----------------
+func GetStatementMappingsHandler(c *fiber.Ctx) error {
+	mappings, err := repositories.GetStatementMappings(database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در دریافت نگاشت‌ها"})
+	}
+	return c.JSON(mappings)
+}
+
+func CreateStatementMappingHandler(c *fiber.Ctx) error {
+	var mapping models.StatementColumnMapping
+	if err := c.BodyParser(&mapping); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+	if err := validateStatementMapping(&mapping); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := repositories.CreateStatementMapping(&mapping, database.DB); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "نگاشتی با این نام وجود دارد"})
+	}
+	return c.Status(fiber.StatusCreated).JSON(mapping)
+}
+
+func UpdateStatementMappingHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	var mapping models.StatementColumnMapping
+	if err := c.BodyParser(&mapping); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+	if err := validateStatementMapping(&mapping); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	updated, err := repositories.UpdateStatementMapping(uint(id), &mapping, database.DB)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "نگاشت یافت نشد"})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "خطا در بروزرسانی نگاشت"})
+	}
+	return c.JSON(updated)
+}
+
+func DeleteStatementMappingHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	if err := repositories.DeleteStatementMapping(uint(id), database.DB); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func validateStatementMapping(m *models.StatementColumnMapping) error {
+	if m.Name == "" {
+		return errors.New("نام نگاشت الزامی است")
+	}
+	if m.DateColumn == "" {
+		return errors.New("ستون تاریخ الزامی است")
+	}
+	if m.AmountColumn == "" && m.DebitColumn == "" && m.CreditColumn == "" {
+		return errors.New("ستون مبلغ یا ستون‌های برداشت/واریز الزامی است")
+	}
+	if m.SkipRows < 0 {
+		return errors.New("تعداد ردیف‌های رد شده نامعتبر است")
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/database"
+	"github.com/amirqodi/hgm/internal/models"
+	"github.com/amirqodi/hgm/internal/repositories"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type cashCountRequest struct {
+	CashHolderID uint                   `json:"cash_holder_id"`
+	Unit         models.CashUnit        `json:"unit"` // rial یا toman (پیش‌فرض واحد دفتر)
+	CountedAt    string                 `json:"counted_at"`
+	Notes        string                 `json:"notes"`
+	Items        []models.CashCountItem `json:"items"` // denomination, quantity
+}
+
+type cashHandoverRequest struct {
+	FromCashHolderID uint    `json:"from_cash_holder_id"`
+	ToCashHolderID   uint    `json:"to_cash_holder_id"`
+	CashCountID      *uint   `json:"cash_count_id"`
+	Amount           float64 `json:"amount"` // خالی = مبلغ شمارش یا کل موجودی
+	HandedOverAt     string  `json:"handed_over_at"`
+	Notes            string  `json:"notes"`
+}
+
+func (r *cashCountRequest) toModel() (*models.CashCount, error) {
+	count := &models.CashCount{
+		CashHolderID: r.CashHolderID,
+		Unit:         r.Unit,
+		Notes:        r.Notes,
+		Items:        r.Items,
+	}
+	if r.CountedAt != "" {
+		at, _, err := parseDate(r.CountedAt)
+		if err != nil {
+			return nil, errors.New("فرمت تاریخ نامعتبر است")
+		}
+		count.CountedAt = at
+	}
+	return count, nil
+}
+
+// checkCashCountScope شمارش صندوق دیگر برای صندوقدار یافت‌نشده به حساب می‌آید
+func checkCashCountScope(c *fiber.Ctx, id uint) error {
+	scope := cashHolderScope(c)
+	if scope == nil {
+		return nil
+	}
+	count, err := repositories.GetCashCountByID(id, database.DB)
+	if err != nil {
+		return err
+	}
+	if !inCashHolderScope(scope, &count.CashHolderID) {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetDenominationsHandler ?unit=rial|toman اسکناس و سکه‌های قابل شمارش
+func GetDenominationsHandler(c *fiber.Ctx) error {
+	unit := models.CashUnit(c.Query("unit", string(repositories.BookUnit())))
+	return c.JSON(fiber.Map{
+		"unit":          unit,
+		"book_unit":     repositories.BookUnit(),
+		"denominations": repositories.Denominations(unit),
+	})
+}
+
+// ---------------- CASH COUNTS ----------------
+
+// GetCashCountsHandler ?cash_holder_id=
+func GetCashCountsHandler(c *fiber.Ctx) error {
+	holderID, _ := strconv.Atoi(c.Query("cash_holder_id", "0"))
+	if scope := cashHolderScope(c); scope != nil {
+		if *scope == 0 {
+			return c.JSON([]models.CashCount{})
+		}
+		holderID = int(*scope)
+	}
+
+	counts, err := repositories.GetCashCounts(uint(holderID), database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در دریافت شمارش‌ها"})
+	}
+	return c.JSON(counts)
+}
+
+func GetCashCountByIDHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	count, err := repositories.GetCashCountByID(uint(id), database.DB)
+	if err == nil && !inCashHolderScope(cashHolderScope(c), &count.CashHolderID) {
+		err = gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "شمارش یافت نشد"})
+	}
+	return c.JSON(count)
+}
+
+func CreateCashCountHandler(c *fiber.Ctx) error {
+	var body cashCountRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+	count, err := body.toModel()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if !inCashHolderScope(cashHolderScope(c), &count.CashHolderID) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": errOutOfScope.Error()})
+	}
+	if user, err := currentUser(c); err == nil {
+		count.CountedByID = &user.ID
+	}
+
+	if err := repositories.CreateCashCount(count, database.DB); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(count)
+}
+
+func UpdateCashCountHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	var body cashCountRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+	input, err := body.toModel()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if !inCashHolderScope(cashHolderScope(c), &input.CashHolderID) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": errOutOfScope.Error()})
+	}
+	if err := checkCashCountScope(c, uint(id)); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "شمارش یافت نشد"})
+	}
+
+	count, err := repositories.UpdateCashCount(uint(id), input, database.DB)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "شمارش یافت نشد"})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(count)
+}
+
+// PostCashCountHandler نهایی‌سازی شمارش و ثبت اضافه/کسری صندوق
+func PostCashCountHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	if err := checkCashCountScope(c, uint(id)); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "شمارش یافت نشد"})
+	}
+
+	count, err := repositories.PostCashCount(uint(id), database.DB)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "شمارش یافت نشد"})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(count)
+}
+
+func DeleteCashCountHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	if err := repositories.DeleteCashCount(uint(id), database.DB); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "شمارش یافت نشد"})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ---------------- HANDOVERS ----------------
+
+// GetCashHandoversHandler ?cash_holder_id=
+func GetCashHandoversHandler(c *fiber.Ctx) error {
+	holderID, _ := strconv.Atoi(c.Query("cash_holder_id", "0"))
+	if scope := cashHolderScope(c); scope != nil {
+		if *scope == 0 {
+			return c.JSON([]models.CashHandover{})
+		}
+		holderID = int(*scope)
+	}
+
+	handovers, err := repositories.GetCashHandovers(uint(holderID), database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در دریافت تحویل‌ها"})
+	}
+	return c.JSON(handovers)
+}
+
+func GetCashHandoverByIDHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	handover, err := repositories.GetCashHandoverByID(uint(id), database.DB)
+	if scope := cashHolderScope(c); err == nil && !inCashHolderScope(scope, &handover.FromCashHolderID) && !inCashHolderScope(scope, &handover.ToCashHolderID) {
+		err = gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "تحویل یافت نشد"})
+	}
+	return c.JSON(handover)
+}
+
+// CreateCashHandoverHandler تحویل صندوق به تنخواه‌دار جدید (مثلاً تعویض صندوقدار)
+func CreateCashHandoverHandler(c *fiber.Ctx) error {
+	var body cashHandoverRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+
+	handover := models.CashHandover{
+		FromCashHolderID: body.FromCashHolderID,
+		ToCashHolderID:   body.ToCashHolderID,
+		CashCountID:      body.CashCountID,
+		Amount:           body.Amount,
+		Notes:            body.Notes,
+		HandedOverAt:     time.Now(),
+	}
+	if !inCashHolderScope(cashHolderScope(c), &handover.FromCashHolderID) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": errOutOfScope.Error()})
+	}
+	if body.HandedOverAt != "" {
+		at, _, err := parseDate(body.HandedOverAt)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "فرمت تاریخ نامعتبر است"})
+		}
+		handover.HandedOverAt = at
+	}
+	if user, err := currentUser(c); err == nil {
+		handover.RecordedByID = &user.ID
+	}
+
+	if err := repositories.CreateCashHandover(&handover, database.DB); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(handover)
+}
+
+// VerifyCashHandoverHandler بررسی سالم بودن امضای رکورد تحویل
+func VerifyCashHandoverHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	valid, err := repositories.VerifyCashHandover(uint(id), database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "تحویل یافت نشد"})
+	}
+	return c.JSON(fiber.Map{"id": id, "valid": valid})
+}
@@ -16,6 +16,9 @@ func CreateCashHolder(c *fiber.Ctx) error {
 	}
 
 	if err := repositories.CreateCashHolder(&holder); err != nil {
+		if isCurrencyError(err) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "could not create cash holder"})
 	}
 
@@ -39,6 +42,20 @@ func GetCashHolders(c *fiber.Ctx) error {
 		pageSize = 10
 	}
 
+	// صندوقدار فقط تنخواه خودش را می‌بیند
+	if scope := cashHolderScope(c); scope != nil {
+		holders := []models.CashHolder{}
+		if holder, err := repositories.GetCashHolderByID(*scope); err == nil {
+			holders = append(holders, holder)
+		}
+		return c.JSON(fiber.Map{
+			"results":   holders,
+			"count":     len(holders),
+			"page":      1,
+			"page_size": pageSize,
+		})
+	}
+
 	holders, total, err := repositories.GetCashHoldersWithPagination(page, pageSize, search)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -61,6 +78,9 @@ func GetCashHolderByID(c *fiber.Ctx) error {
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid ID"})
 	}
+	if holderID := uint(id); !inCashHolderScope(cashHolderScope(c), &holderID) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "cash holder not found"})
+	}
 
 	holder, err := repositories.GetCashHolderByID(uint(id))
 	if err != nil {
@@ -85,6 +105,9 @@ func UpdateCashHolder(c *fiber.Ctx) error {
 
 	updated, err := repositories.UpdateCashHolder(uint(id), &data)
 	if err != nil {
+		if isCurrencyError(err) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "could not update cash holder"})
 	}
 
@@ -1,68 +1,108 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"strconv"
 
 	"github.com/amirqodi/hgm/internal/database"
 	"github.com/amirqodi/hgm/internal/models"
 	"github.com/amirqodi/hgm/internal/repositories"
+	"github.com/amirqodi/hgm/internal/utils"
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
-// ---------------- CREATE ----------------
-// ---------------- CREATE ----------------
-func CreateContact(c *fiber.Ctx) error {
-	var contact models.Contact
-	errorsMap := make(map[string][]string)
+// legacyContactPayload فرم قدیمی مخاطب تک‌نقشی (type و فیلدهای نقش در سطح اول) که فرانت‌اند هنوز ارسال می‌کند
+type legacyContactPayload struct {
+	Type            models.ContactType  `json:"type"`
+	SharePercentage *float64            `json:"share_percentage"`
+	Amount          *float64            `json:"amount"`
+	CarType         *string             `json:"car_type"`
+	CarKilometer    *int                `json:"car_kilometer"`
+	Address         *string             `json:"address"`
+	CreditLimit     *float64            `json:"credit_limit"`
+	MaxOverdueDays  *int                `json:"max_overdue_days"`
+	CreditPolicy    models.CreditPolicy `json:"credit_policy"`
+}
 
-	if err := c.BodyParser(&contact); err != nil {
+// parseContact بدنه درخواست مخاطب و نقش‌هایی که کلیدشان در بدنه آمده است (null یعنی حذف نقش)؛
+// در فرم قدیمی، نقش type از فیلدهای سطح اول ساخته می‌شود (اگر همان نقش به شکل تودرتو نیامده باشد)
+func parseContact(c *fiber.Ctx, contact *models.Contact, errorsMap map[string][]string) (repositories.ContactRoleKeys, bool) {
+	var legacy legacyContactPayload
+	if err := c.BodyParser(contact); err != nil || c.BodyParser(&legacy) != nil {
 		errorsMap["error"] = append(errorsMap["error"], "داده‌های ارسال‌شده معتبر نیستند")
-		return c.Status(fiber.StatusBadRequest).JSON(errorsMap)
+		return nil, false
 	}
 
-	// ------------------ مقدار پیش‌فرض سرمایه اولیه ------------------
-	if contact.Type == models.Shareholder && contact.Amount == nil {
-		zero := 0.0
-		contact.Amount = &zero
-	}
-
-	// اعتبارسنجی پایه
-	if contact.FirstName == "" {
-		errorsMap["first_name"] = append(errorsMap["first_name"], "نام نمی‌تواند خالی باشد")
-	}
-	if contact.LastName == "" {
-		errorsMap["last_name"] = append(errorsMap["last_name"], "نام خانوادگی نمی‌تواند خالی باشد")
+	roles := repositories.ContactRoleKeys{}
+	var raw map[string]json.RawMessage
+	if json.Unmarshal(c.Body(), &raw) == nil {
+		for _, role := range []models.ContactType{models.Shareholder, models.Customer, models.Vendor} {
+			if _, ok := raw[string(role)]; ok {
+				roles[role] = true
+			}
+		}
 	}
-	if contact.PhoneNumber == "" {
-		errorsMap["phone_number"] = append(errorsMap["phone_number"], "شماره تلفن نمی‌تواند خالی باشد")
+	if legacy.Type != "" {
+		roles[legacy.Type] = true
 	}
 
-	// اعتبارسنجی بر اساس نوع Contact
-	switch contact.Type {
+	switch legacy.Type {
+	case "":
 	case models.Shareholder:
-		if contact.SharePercentage == nil {
-			errorsMap["share_percentage"] = append(errorsMap["share_percentage"], "درصد سهم الزامی است")
-		} else if *contact.SharePercentage > 100 {
-			errorsMap["share_percentage"] = append(errorsMap["share_percentage"], "درصد سهم نمی‌تواند بیشتر از 100 باشد")
-		} else {
-			totalShare := repositories.SumSharePercentage() // مجموع سهام موجود
-			if totalShare+*contact.SharePercentage > 100 {
-				errorsMap["share_percentage"] = append(errorsMap["share_percentage"], "مجموع درصد سهام نمی‌تواند بیشتر از 100 باشد")
+		if contact.Shareholder == nil {
+			contact.Shareholder = &models.ShareholderProfile{}
+			if legacy.SharePercentage != nil {
+				contact.Shareholder.SharePercentage = *legacy.SharePercentage
+			}
+			if legacy.Amount != nil {
+				contact.Shareholder.Amount = *legacy.Amount
 			}
 		}
 	case models.Customer:
-		if contact.CarType == nil || *contact.CarType == "" {
-			errorsMap["car_type"] = append(errorsMap["car_type"], "نوع خودرو الزامی است")
-		}
-		if contact.CarKilometer == nil || *contact.CarKilometer < 0 {
-			errorsMap["car_kilometer"] = append(errorsMap["car_kilometer"], "کیلومتر خودرو نامعتبر است")
+		if contact.Customer == nil {
+			contact.Customer = &models.CustomerProfile{
+				CarType:        legacy.CarType,
+				CarKilometer:   legacy.CarKilometer,
+				CreditLimit:    legacy.CreditLimit,
+				MaxOverdueDays: legacy.MaxOverdueDays,
+				CreditPolicy:   legacy.CreditPolicy,
+			}
 		}
 	case models.Vendor:
-		if contact.Address == nil || *contact.Address == "" {
-			errorsMap["address"] = append(errorsMap["address"], "آدرس الزامی است")
+		if contact.Vendor == nil {
+			contact.Vendor = &models.VendorProfile{}
+			if legacy.Address != nil {
+				contact.Vendor.Address = *legacy.Address
+			}
 		}
+	default:
+		errorsMap["type"] = append(errorsMap["type"], "نوع مخاطب نامعتبر است")
+		return nil, false
+	}
+	return roles, true
+}
+
+// requireRole مخاطب پس از ذخیره باید حداقل یک نقش داشته باشد
+func requireRole(contact *models.Contact, errorsMap map[string][]string) {
+	if contact.Shareholder == nil && contact.Customer == nil && contact.Vendor == nil {
+		errorsMap["roles"] = append(errorsMap["roles"], "حداقل یک نقش (سهامدار، مشتری یا فروشنده) الزامی است")
+	}
+}
+
+// ---------------- CREATE ----------------
+func CreateContact(c *fiber.Ctx) error {
+	var contact models.Contact
+	errorsMap := make(map[string][]string)
+
+	if _, ok := parseContact(c, &contact, errorsMap); !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(errorsMap)
 	}
 
+	requireRole(&contact, errorsMap)
+	validateContact(&contact, 0, errorsMap)
+
 	if len(errorsMap) > 0 {
 		return c.Status(fiber.StatusBadRequest).JSON(errorsMap)
 	}
@@ -94,15 +134,24 @@ func GetContacts(c *fiber.Ctx) error {
 	var total int64
 	db := database.DB.Model(&models.Contact{})
 
-	// اعمال فیلتر نوع
+	// اعمال فیلتر نقش (مخاطب چند نقشی در همه فهرست‌های مربوط دیده می‌شود)
 	if contactType != "" {
-		db = db.Where("type = ?", contactType)
+		db = db.Scopes(repositories.WithContactRole(models.ContactType(contactType)))
+	}
+
+	// فیلتر برچسب
+	if tag := c.Query("tag", ""); tag != "" {
+		db = db.Where("EXISTS (SELECT 1 FROM contact_tags JOIN tags ON tags.id = contact_tags.tag_id WHERE contact_tags.contact_id = contacts.id AND tags.name = ?)", tag)
 	}
 
-	// اعمال جستجو
+	// اعمال جستجو (شماره با ارقام فارسی هم پیدا شود)
 	if search != "" {
+		phoneSearch := utils.NormalizeIranianMobile(search)
+		if phoneSearch == "" {
+			phoneSearch = search
+		}
 		db = db.Where("first_name LIKE ? OR last_name LIKE ? OR phone_number LIKE ?",
-			"%"+search+"%", "%"+search+"%", "%"+search+"%")
+			"%"+search+"%", "%"+search+"%", "%"+phoneSearch+"%")
 	}
 
 	// گرفتن تعداد کل نتایج
@@ -113,7 +162,7 @@ func GetContacts(c *fiber.Ctx) error {
 	}
 
 	// گرفتن نتایج با Pagination
-	if err := db.Offset(offset).Limit(limit).Find(&contacts).Error; err != nil {
+	if err := repositories.ContactPreloads(db).Offset(offset).Limit(limit).Find(&contacts).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": []string{"خطا در دریافت لیست مخاطبین"},
 		})
@@ -160,54 +209,47 @@ func UpdateContact(c *fiber.Ctx) error {
 	var updateData models.Contact
 	errorsMap := make(map[string][]string)
 
-	if err := c.BodyParser(&updateData); err != nil {
-		errorsMap["error"] = append(errorsMap["error"], "داده‌های ارسال‌شده معتبر نیستند")
+	roles, ok := parseContact(c, &updateData, errorsMap)
+	if !ok {
 		return c.Status(fiber.StatusBadRequest).JSON(errorsMap)
 	}
 
-	// اعتبارسنجی پایه
-	if updateData.FirstName == "" {
-		errorsMap["first_name"] = append(errorsMap["first_name"], "نام نمی‌تواند خالی باشد")
+	existing, err := repositories.GetContactByID(uint(id))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"id": []string{"مخاطب مورد نظر یافت نشد"},
+		})
 	}
-	if updateData.LastName == "" {
-		errorsMap["last_name"] = append(errorsMap["last_name"], "نام خانوادگی نمی‌تواند خالی باشد")
+
+	// نقشی که کلیدش نیامده دست نمی‌خورد
+	result := updateData
+	if !roles[models.Shareholder] {
+		result.Shareholder = existing.Shareholder
 	}
-	if updateData.PhoneNumber == "" {
-		errorsMap["phone_number"] = append(errorsMap["phone_number"], "شماره تلفن نمی‌تواند خالی باشد")
+	if !roles[models.Customer] {
+		result.Customer = existing.Customer
 	}
-
-	// اعتبارسنجی بر اساس نوع Contact
-	switch updateData.Type {
-	case models.Shareholder:
-		if updateData.SharePercentage == nil {
-			errorsMap["share_percentage"] = append(errorsMap["share_percentage"], "درصد سهم الزامی است")
-		} else if *updateData.SharePercentage > 100 {
-			errorsMap["share_percentage"] = append(errorsMap["share_percentage"], "درصد سهم نمی‌تواند بیشتر از 100 باشد")
-		} else {
-			totalShare := repositories.SumSharePercentageExcludingID(uint(id))
-			if totalShare+*updateData.SharePercentage > 100 {
-				errorsMap["share_percentage"] = append(errorsMap["share_percentage"], "مجموع درصد سهام نمی‌تواند بیشتر از 100 باشد")
-			}
-		}
-	case models.Customer:
-		if updateData.CarType == nil || *updateData.CarType == "" {
-			errorsMap["car_type"] = append(errorsMap["car_type"], "نوع خودرو الزامی است")
-		}
-		if updateData.CarKilometer == nil || *updateData.CarKilometer < 0 {
-			errorsMap["car_kilometer"] = append(errorsMap["car_kilometer"], "کیلومتر خودرو نامعتبر است")
-		}
-	case models.Vendor:
-		if updateData.Address == nil || *updateData.Address == "" {
-			errorsMap["address"] = append(errorsMap["address"], "آدرس الزامی است")
-		}
+	if !roles[models.Vendor] {
+		result.Vendor = existing.Vendor
 	}
+	requireRole(&result, errorsMap)
+	validateContact(&updateData, uint(id), errorsMap)
 
 	if len(errorsMap) > 0 {
 		return c.Status(fiber.StatusBadRequest).JSON(errorsMap)
 	}
 
-	updated, err := repositories.UpdateContact(uint(id), &updateData)
+	updated, err := repositories.UpdateContact(uint(id), &updateData, roles)
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"id": []string{"مخاطب مورد نظر یافت نشد"},
+			})
+		}
+		if errors.Is(err, repositories.ErrShareholderHasCapital) {
+			errorsMap["shareholder"] = append(errorsMap["shareholder"], err.Error())
+			return c.Status(fiber.StatusBadRequest).JSON(errorsMap)
+		}
 		errorsMap["error"] = append(errorsMap["error"], "خطا در بروزرسانی مخاطب")
 		return c.Status(fiber.StatusInternalServerError).JSON(errorsMap)
 	}
@@ -228,7 +270,7 @@ func DeleteContact(c *fiber.Ctx) error {
 	err = repositories.DeleteContact(uint(id))
 	if err != nil {
 		// اگر خطا از نوع استفاده در تراکنش باشد
-		if err.Error() == "این مخاطب در تراکنش‌ها استفاده شده و قابل حذف نیست" {
+		if err.Error() == "این مخاطب در تراکنش‌ها استفاده شده و قابل حذف نیست" || errors.Is(err, repositories.ErrContactHasDividends) {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"error": []string{err.Error()},
 			})
@@ -242,3 +284,153 @@ func DeleteContact(c *fiber.Ctx) error {
 
 	return c.SendStatus(fiber.StatusNoContent)
 }
+
+// validateContact اعتبارسنجی اطلاعات پایه و رکورد هر نقش؛ excludeID برای بروزرسانی
+func validateContact(contact *models.Contact, excludeID uint, errorsMap map[string][]string) {
+	// اعتبارسنجی پایه
+	if contact.FirstName == "" {
+		errorsMap["first_name"] = append(errorsMap["first_name"], "نام نمی‌تواند خالی باشد")
+	}
+	if contact.LastName == "" {
+		errorsMap["last_name"] = append(errorsMap["last_name"], "نام خانوادگی نمی‌تواند خالی باشد")
+	}
+	contact.PhoneNumber = utils.NormalizeIranianMobile(contact.PhoneNumber)
+	if contact.PhoneNumber == "" {
+		errorsMap["phone_number"] = append(errorsMap["phone_number"], "شماره تلفن نمی‌تواند خالی باشد")
+	}
+
+	if contact.NationalID != "" {
+		contact.NationalID = utils.NormalizeDigits(contact.NationalID)
+		if !utils.IsValidNationalID(contact.NationalID) && !utils.IsValidLegalNationalID(contact.NationalID) {
+			errorsMap["national_id"] = append(errorsMap["national_id"], "کد ملی یا شناسه ملی نامعتبر است")
+		}
+	}
+	if contact.EconomicCode != "" {
+		contact.EconomicCode = utils.NormalizeDigits(contact.EconomicCode)
+		if utils.OnlyDigits(contact.EconomicCode) != contact.EconomicCode || len(contact.EconomicCode) < 11 || len(contact.EconomicCode) > 14 {
+			errorsMap["economic_code"] = append(errorsMap["economic_code"], "کد اقتصادی باید ۱۱ تا ۱۴ رقم باشد")
+		}
+	}
+
+	for i := range contact.Phones {
+		number := utils.NormalizeIranianMobile(contact.Phones[i].Number)
+		if number == "" {
+			errorsMap["phones"] = append(errorsMap["phones"], "شماره تلفن نمی‌تواند خالی باشد")
+		}
+		contact.Phones[i].Number = number
+	}
+	for _, addr := range contact.Addresses {
+		if addr.Address == "" {
+			errorsMap["addresses"] = append(errorsMap["addresses"], "آدرس نمی‌تواند خالی باشد")
+		}
+	}
+	for _, tag := range contact.Tags {
+		if tag.Name == "" {
+			errorsMap["tags"] = append(errorsMap["tags"], "نام برچسب نمی‌تواند خالی باشد")
+		}
+	}
+
+	// اعتبارسنجی بر اساس نقش‌ها
+	if sh := contact.Shareholder; sh != nil {
+		if sh.SharePercentage < 0 || sh.SharePercentage > 100 {
+			errorsMap["share_percentage"] = append(errorsMap["share_percentage"], "درصد سهم باید بین ۰ تا ۱۰۰ باشد")
+		} else {
+			totalShare := repositories.SumSharePercentageExcludingID(excludeID) // مجموع سهام موجود
+			if totalShare+sh.SharePercentage > 100 {
+				errorsMap["share_percentage"] = append(errorsMap["share_percentage"], "مجموع درصد سهام نمی‌تواند بیشتر از 100 باشد")
+			}
+		}
+		if sh.Amount < 0 {
+			errorsMap["amount"] = append(errorsMap["amount"], "سرمایه اولیه نمی‌تواند منفی باشد")
+		}
+	}
+
+	if cu := contact.Customer; cu != nil {
+		if cu.CarType == nil || *cu.CarType == "" {
+			errorsMap["car_type"] = append(errorsMap["car_type"], "نوع خودرو الزامی است")
+		}
+		if cu.CarKilometer == nil || *cu.CarKilometer < 0 {
+			errorsMap["car_kilometer"] = append(errorsMap["car_kilometer"], "کیلومتر خودرو نامعتبر است")
+		}
+		validateCreditPolicy(cu, errorsMap)
+	}
+
+	if v := contact.Vendor; v != nil && v.Address == "" {
+		errorsMap["address"] = append(errorsMap["address"], "آدرس الزامی است")
+	}
+}
+
+// validateCreditPolicy اعتبارسنجی سقف اعتبار و سیاست اقساط معوق مشتری
+func validateCreditPolicy(profile *models.CustomerProfile, errorsMap map[string][]string) {
+	if profile.CreditLimit != nil && *profile.CreditLimit < 0 {
+		errorsMap["credit_limit"] = append(errorsMap["credit_limit"], "سقف اعتبار نمی‌تواند منفی باشد")
+	}
+	if profile.MaxOverdueDays != nil && *profile.MaxOverdueDays < 0 {
+		errorsMap["max_overdue_days"] = append(errorsMap["max_overdue_days"], "تعداد روز تاخیر مجاز نمی‌تواند منفی باشد")
+	}
+	switch profile.CreditPolicy {
+	case "", models.CreditPolicyWarn, models.CreditPolicyBlock:
+	default:
+		errorsMap["credit_policy"] = append(errorsMap["credit_policy"], "سیاست اعتبار باید warn یا block باشد")
+	}
+}
+
+// ---------------- DUPLICATES ----------------
+
+// FindDuplicateContactsHandler ?threshold=0.85 حداقل شباهت نام
+func FindDuplicateContactsHandler(c *fiber.Ctx) error {
+	threshold, err := strconv.ParseFloat(c.Query("threshold", "0.85"), 64)
+	if err != nil || threshold <= 0 || threshold > 1 {
+		threshold = 0.85
+	}
+
+	groups, err := repositories.FindDuplicateContacts(threshold, database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": []string{"خطا در جستجوی مخاطبین تکراری"},
+		})
+	}
+	return c.JSON(groups)
+}
+
+// MergeContactsHandler ادغام مخاطب تکراری (duplicate_id) در مخاطب :id
+func MergeContactsHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"id": []string{"شناسه نامعتبر است"},
+		})
+	}
+
+	var body struct {
+		DuplicateID uint `json:"duplicate_id"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.DuplicateID == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"duplicate_id": []string{"شناسه مخاطب تکراری الزامی است"},
+		})
+	}
+
+	var userID *uint
+	if user, err := currentUser(c); err == nil {
+		userID = &user.ID
+	}
+
+	merge, err := repositories.MergeContacts(uint(id), body.DuplicateID, userID, database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": []string{err.Error()},
+		})
+	}
+	return c.JSON(merge)
+}
+
+func GetContactMergesHandler(c *fiber.Ctx) error {
+	merges, err := repositories.GetContactMerges(database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": []string{"خطا در دریافت سوابق ادغام"},
+		})
+	}
+	return c.JSON(merges)
+}
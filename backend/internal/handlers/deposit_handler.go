@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"strconv"
+	"time"
 
 	"github.com/amirqodi/hgm/internal/database"
 	"github.com/amirqodi/hgm/internal/models"
@@ -10,8 +11,28 @@ import (
 	"gorm.io/gorm"
 )
 
+// scopedDeposits ودیعه‌های قابل مشاهده برای کاربر (صندوقدار: فقط تنخواه خودش)
+func scopedDeposits(c *fiber.Ctx) *gorm.DB {
+	if scope := cashHolderScope(c); scope != nil {
+		return database.DB.Where("deposits.cash_holder_id = ?", *scope)
+	}
+	return database.DB
+}
+
+// checkDepositScope صندوقدار فقط ودیعه نقدی تنخواه خودش را ثبت یا ویرایش می‌کند
+func checkDepositScope(c *fiber.Ctx, dep *models.Deposit) error {
+	scope := cashHolderScope(c)
+	if scope == nil {
+		return nil
+	}
+	if dep.MoneySourceType != "cash" || !inCashHolderScope(scope, dep.CashHolderID) {
+		return errOutOfScope
+	}
+	return nil
+}
+
 func GetDepositsHandler(c *fiber.Ctx) error {
-	db := database.DB
+	db := scopedDeposits(c)
 
 	var deposits []models.Deposit
 	if err := db.Preload("Contact").Preload("BankAccount").Preload("CashHolder").Find(&deposits).Error; err != nil {
@@ -41,6 +62,10 @@ func CreateDepositHandler(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "مبلغ باید بیشتر از صفر باشد"})
 	}
 
+	if err := checkDepositScope(c, &dep); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	}
+
 	// ایجاد ودیعه با repository
 	if err := repositories.CreateDeposit(&dep, database.DB); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
@@ -59,15 +84,23 @@ func DeleteDepositHandler(c *fiber.Ctx) error {
 	}
 
 	var dep models.Deposit
-	if err := db.First(&dep, id).Error; err != nil {
+	if err := scopedDeposits(c).First(&dep, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "ودیعه یافت نشد"})
 	}
 
+	if err := checkDepositLock(&dep, db); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
 	// Revert balance
 	if err := repositories.RevertDeposit(&dep, db); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
 
+	if err := repositories.ReleaseDepositMatches(dep.ID, db); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در حذف ودیعه"})
+	}
+
 	// حذف ودیعه
 	if err := db.Delete(&dep).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در حذف ودیعه"})
@@ -86,7 +119,7 @@ func UpdateDepositHandler(c *fiber.Ctx) error {
 	}
 
 	var existing models.Deposit
-	if err := db.First(&existing, id).Error; err != nil {
+	if err := scopedDeposits(c).First(&existing, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "ودیعه یافت نشد"})
 	}
 
@@ -94,11 +127,31 @@ func UpdateDepositHandler(c *fiber.Ctx) error {
 	if err := c.BodyParser(&updated); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
 	}
+	if cashHolderScope(c) != nil {
+		// فیلدهای خالی در Updates تغییر نمی‌کنند؛ منبع نهایی ودیعه بررسی می‌شود
+		final := existing
+		if updated.MoneySourceType != "" {
+			final.MoneySourceType = updated.MoneySourceType
+		}
+		if updated.CashHolderID != nil {
+			final.CashHolderID = updated.CashHolderID
+		}
+		if err := checkDepositScope(c, &final); err != nil {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+
+	if err := checkDepositLock(&existing, db); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
 
 	// Revert previous balance first
 	if err := repositories.RevertDeposit(&existing, db); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
+	if err := repositories.ReleaseDepositMatches(existing.ID, db); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در بروزرسانی ودیعه"})
+	}
 
 	// Save updated deposit
 	updated.ID = existing.ID
@@ -123,7 +176,7 @@ func PayDepositHandler(c *fiber.Ctx) error {
 	}
 
 	var dep models.Deposit
-	if err := db.First(&dep, id).Error; err != nil {
+	if err := scopedDeposits(c).First(&dep, id).Error; err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "ودیعه یافت نشد"})
 	}
 
@@ -138,7 +191,9 @@ func PayDepositHandler(c *fiber.Ctx) error {
 	}
 
 	// تغییر وضعیت به پرداخت شده
+	now := time.Now()
 	dep.Status = "completed"
+	dep.CompletedAt = &now
 	if err := db.Save(&dep).Error; err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در بروزرسانی وضعیت ودیعه"})
 	}
@@ -147,7 +202,7 @@ func PayDepositHandler(c *fiber.Ctx) error {
 }
 
 func GetDepositByIDHandler(c *fiber.Ctx) error {
-	db := database.DB
+	db := scopedDeposits(c)
 
 	idStr := c.Params("id")
 	id, err := strconv.Atoi(idStr)
@@ -166,3 +221,14 @@ func GetDepositByIDHandler(c *fiber.Ctx) error {
 
 	return c.Status(fiber.StatusOK).JSON(dep)
 }
+
+// checkDepositLock ودیعه بانکی ثبت یا تسویه‌شده در دوره تطبیق‌شده قابل تغییر نیست
+func checkDepositLock(dep *models.Deposit, db *gorm.DB) error {
+	if err := repositories.CheckReconciliationLock(db, dep.MoneySourceType, dep.BankAccountID, dep.CreatedAt); err != nil {
+		return err
+	}
+	if dep.CompletedAt != nil {
+		return repositories.CheckReconciliationLock(db, dep.MoneySourceType, dep.BankAccountID, *dep.CompletedAt)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/amirqodi/hgm/internal/database"
+	"github.com/amirqodi/hgm/internal/repositories"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type distributionRequest struct {
+	PeriodStart       string  `json:"period_start"`
+	PeriodEnd         string  `json:"period_end"`
+	DistributePercent float64 `json:"distribute_percent"`
+	Notes             string  `json:"notes"`
+	PayNow            bool    `json:"pay_now"`
+	MoneySourceType   string  `json:"money_source_type"`
+	BankAccountID     *uint   `json:"bank_account_id"`
+	CashHolderID      *uint   `json:"cash_holder_id"`
+}
+
+// ---------------- CREATE ----------------
+func CreateProfitDistributionHandler(c *fiber.Ctx) error {
+	var body distributionRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+
+	from, to, err := parseDateRange(body.PeriodStart, body.PeriodEnd)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	dist, err := repositories.CreateProfitDistribution(repositories.DistributionInput{
+		PeriodStart:       from,
+		PeriodEnd:         to,
+		DistributePercent: body.DistributePercent,
+		Notes:             body.Notes,
+		PayNow:            body.PayNow,
+		MoneySourceType:   body.MoneySourceType,
+		BankAccountID:     body.BankAccountID,
+		CashHolderID:      body.CashHolderID,
+	}, database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(dist)
+}
+
+// ---------------- READ ----------------
+func GetProfitDistributionsHandler(c *fiber.Ctx) error {
+	dists, err := repositories.GetProfitDistributions(database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در دریافت لیست تقسیم سود"})
+	}
+	return c.JSON(dists)
+}
+
+func GetProfitDistributionByIDHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	dist, err := repositories.GetProfitDistributionByID(uint(id), database.DB)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "تقسیم سود یافت نشد"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(dist)
+}
+
+// ---------------- PAY DIVIDEND ----------------
+func PayDividendHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	var payment repositories.DividendPayment
+	if err := c.BodyParser(&payment); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+
+	dividend, err := repositories.PayDividend(uint(id), payment, database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(dividend)
+}
+
+// ---------------- DELETE ----------------
+func DeleteProfitDistributionHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	if err := repositories.DeleteProfitDistribution(uint(id), database.DB); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
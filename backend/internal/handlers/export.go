@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/export"
+	"github.com/amirqodi/hgm/internal/utils"
+	"github.com/gofiber/fiber/v2"
+)
+
+// exportRowLimit سقف ردیف‌های خروجی لیست‌ها (صفحه‌بندی در خروجی فایل نادیده گرفته می‌شود)
+const exportRowLimit = 10000
+
+// wantsExport ?format=xlsx|csv|pdf درخواست خروجی فایل است
+func wantsExport(c *fiber.Ctx) bool {
+	format := c.Query("format")
+	return format != "" && format != "json"
+}
+
+// sendReport بدون format همان JSON را برمی‌گرداند و در غیر این صورت سند ساخته‌شده را به‌صورت فایل می‌فرستد
+func sendReport(c *fiber.Ctx, data any, document func() *export.Document) error {
+	if !wantsExport(c) {
+		return c.JSON(data)
+	}
+
+	body, contentType, fileName, err := export.Render(document(), export.Format(c.Query("format")))
+	if err != nil {
+		if errors.Is(err, export.ErrUnknownFormat) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در ساخت فایل خروجی"})
+	}
+	c.Attachment(fileName)
+	c.Set(fiber.HeaderContentType, contentType)
+	return c.Send(body)
+}
+
+// periodSubtitle بازه [from, to) به فرم «از ... تا ...» شمسی
+func periodSubtitle(from, to time.Time) string {
+	return fmt.Sprintf("از %s تا %s", utils.FormatJalali(from), utils.FormatJalali(to.Add(-time.Second)))
+}
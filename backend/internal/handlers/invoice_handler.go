@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/amirqodi/hgm/internal/config"
+	"github.com/amirqodi/hgm/internal/database"
+	"github.com/amirqodi/hgm/internal/export"
+	"github.com/amirqodi/hgm/internal/models"
+	"github.com/amirqodi/hgm/internal/repositories"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type invoiceRequest struct {
+	Kind             models.InvoiceKind   `json:"kind"` // sales، purchase یا receipt
+	TransactionID    uint                 `json:"transaction_id"`
+	SubTransactionID *uint                `json:"sub_transaction_id"` // برای رسید یک قسط
+	TaxRate          *float64             `json:"tax_rate"`           // خالی = VAT_RATE
+	IssuedAt         string               `json:"issued_at"`
+	Notes            string               `json:"notes"`
+	Items            []models.InvoiceItem `json:"items"` // خالی = از کالا یا دسته تراکنش
+}
+
+// invoiceURL نشانی سند در برنامه برای QR چاپی (PUBLIC_URL یا اولین FRONTEND_URL)
+func invoiceURL(inv *models.Invoice) string {
+	base := config.Get("PUBLIC_URL", "")
+	if base == "" {
+		base, _, _ = strings.Cut(config.Get("FRONTEND_URL", ""), ",")
+	}
+	base = strings.TrimRight(strings.TrimSpace(base), "/")
+	if base == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/invoices/%d", base, inv.ID)
+}
+
+// scopedInvoices فاکتورهای قابل مشاهده برای کاربر (صندوقدار: فقط تراکنش‌های تنخواه خودش)
+func scopedInvoices(c *fiber.Ctx) *gorm.DB {
+	if scope := cashHolderScope(c); scope != nil {
+		return database.DB.Where("invoices.transaction_id IN (?)",
+			database.DB.Model(&models.Transaction{}).Select("id").Where("cash_holder_id = ?", *scope))
+	}
+	return database.DB
+}
+
+// GetInvoicesHandler ?kind=&contact_id=&transaction_id=&fiscal_year=
+func GetInvoicesHandler(c *fiber.Ctx) error {
+	contactID, _ := strconv.Atoi(c.Query("contact_id", "0"))
+	transactionID, _ := strconv.Atoi(c.Query("transaction_id", "0"))
+	fiscalYear, _ := strconv.Atoi(c.Query("fiscal_year", "0"))
+
+	invoices, err := repositories.GetInvoices(repositories.InvoiceFilter{
+		Kind:          models.InvoiceKind(c.Query("kind")),
+		ContactID:     uint(contactID),
+		TransactionID: uint(transactionID),
+		FiscalYear:    fiscalYear,
+	}, scopedInvoices(c))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در دریافت فاکتورها"})
+	}
+	return c.JSON(invoices)
+}
+
+// GetInvoiceByIDHandler ?format=pdf نسخه چاپی فاکتور یا رسید
+func GetInvoiceByIDHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	inv, err := repositories.GetInvoiceByID(uint(id), scopedInvoices(c))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "فاکتور یافت نشد"})
+	}
+	return sendReport(c, inv, func() *export.Document { return invoiceDocument(inv, invoiceURL(inv)) })
+}
+
+func CreateInvoiceHandler(c *fiber.Ctx) error {
+	var body invoiceRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+
+	inv := models.Invoice{
+		Kind:             body.Kind,
+		TransactionID:    body.TransactionID,
+		SubTransactionID: body.SubTransactionID,
+		TaxRate:          repositories.DefaultTaxRate(),
+		Notes:            body.Notes,
+		Items:            body.Items,
+	}
+	if body.TaxRate != nil {
+		inv.TaxRate = *body.TaxRate
+	}
+	if body.IssuedAt != "" {
+		at, _, err := parseDate(body.IssuedAt)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "فرمت تاریخ نامعتبر است"})
+		}
+		inv.IssuedAt = at
+	}
+	if user, err := currentUser(c); err == nil {
+		inv.IssuedByID = &user.ID
+	}
+	if cashHolderScope(c) != nil {
+		trx, err := repositories.GetTransactionByID(inv.TransactionID, scopedTransactions(c))
+		if err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "تراکنش یافت نشد"})
+		}
+		if err := checkTransactionScope(c, trx); err != nil {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+
+	if err := repositories.CreateInvoice(&inv, database.DB); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(inv)
+}
+
+// VoidInvoiceHandler ابطال فاکتور یا رسید؛ شماره آن دوباره استفاده نمی‌شود
+func VoidInvoiceHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+
+	if _, err := repositories.GetInvoiceByID(uint(id), scopedInvoices(c)); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "فاکتور یافت نشد"})
+	}
+
+	var userID *uint
+	if user, err := currentUser(c); err == nil {
+		userID = &user.ID
+	}
+	inv, err := repositories.VoidInvoice(uint(id), strings.TrimSpace(body.Reason), userID, database.DB)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "فاکتور یافت نشد"})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(inv)
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/amirqodi/hgm/internal/database"
+	"github.com/amirqodi/hgm/internal/repositories"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// GetNotificationsHandler ?unread=true&limit=50
+func GetNotificationsHandler(c *fiber.Ctx) error {
+	unread, _ := strconv.ParseBool(c.Query("unread"))
+	limit, err := strconv.Atoi(c.Query("limit", "50"))
+	if err != nil || limit <= 0 {
+		limit = 50
+	}
+
+	list, err := repositories.GetNotifications(unread, limit, database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در دریافت اعلان‌ها"})
+	}
+	return c.JSON(list)
+}
+
+func MarkNotificationReadHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	if err := repositories.MarkNotificationRead(uint(id), database.DB); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "اعلان یافت نشد"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در به‌روزرسانی اعلان"})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func MarkAllNotificationsReadHandler(c *fiber.Ctx) error {
+	if err := repositories.MarkAllNotificationsRead(database.DB); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در به‌روزرسانی اعلان‌ها"})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/amirqodi/hgm/internal/repositories"
+	"github.com/amirqodi/hgm/internal/services"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// isPasswordError خطاهای سیاست و تغییر رمز که به کاربر نمایش داده می‌شوند
+func isPasswordError(err error) bool {
+	return errors.Is(err, services.ErrWeakPassword) ||
+		errors.Is(err, services.ErrWrongPassword) ||
+		errors.Is(err, services.ErrSamePassword)
+}
+
+// ChangePasswordHandler تغییر رمز کاربر جاری؛ سایر دستگاه‌ها خارج می‌شوند
+func ChangePasswordHandler(c *fiber.Ctx) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "کاربر یافت نشد"})
+	}
+	var body changePasswordRequest
+	if err := c.BodyParser(&body); err != nil || body.CurrentPassword == "" || body.NewPassword == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "رمز فعلی و رمز جدید الزامی است"})
+	}
+
+	if err := services.ChangePassword(user.ID, body.CurrentPassword, body.NewPassword, currentSessionID(c)); err != nil {
+		if isPasswordError(err) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در تغییر رمز"})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ResetUserPasswordHandler بازنشانی رمز کاربر توسط مدیر؛ کاربر باید در ورود بعدی رمز را عوض کند
+func ResetUserPasswordHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+	var body struct {
+		Password string `json:"password"`
+	}
+	if err := c.BodyParser(&body); err != nil || body.Password == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "رمز جدید الزامی است"})
+	}
+
+	if err := services.ResetPassword(uint(id), body.Password); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "کاربر یافت نشد"})
+		}
+		if isPasswordError(err) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در بازنشانی رمز"})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func sendLoginHistory(c *fiber.Ctx, filter repositories.LoginAttemptFilter) error {
+	filter.FailedOnly = c.QueryBool("failed")
+	filter.Limit = c.QueryInt("limit", 100)
+	attempts, err := repositories.GetLoginAttempts(filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در دریافت سابقه ورود"})
+	}
+	return c.JSON(attempts)
+}
+
+// GetMyLoginHistoryHandler سابقه ورود کاربر جاری (?failed=true&limit=100)
+func GetMyLoginHistoryHandler(c *fiber.Ctx) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "کاربر یافت نشد"})
+	}
+	return sendLoginHistory(c, repositories.LoginAttemptFilter{Username: user.Username})
+}
+
+// GetLoginHistoryHandler سابقه ورود همه کاربران (?username=&ip=&failed=true&limit=100)
+func GetLoginHistoryHandler(c *fiber.Ctx) error {
+	return sendLoginHistory(c, repositories.LoginAttemptFilter{
+		Username: c.Query("username"),
+		IP:       c.Query("ip"),
+	})
+}
@@ -1,25 +1,110 @@
 package handlers
 
 import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/database"
+	"github.com/amirqodi/hgm/internal/models"
+	"github.com/amirqodi/hgm/internal/repositories"
 	"github.com/amirqodi/hgm/internal/services"
 	"github.com/gofiber/fiber/v2"
 )
 
+// GetPrices آخرین نرخ هر ارز با منبع، زمان و پرچم قدیمی بودن (PRICE_STALE_AFTER)
 func GetPrices(c *fiber.Ctx) error {
-	usd, tether, gold18, err := services.GetLastPrice()
+	status, err := services.GetPriceStatus(database.DB, time.Now(), services.PriceStaleAfter())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error": "failed to fetch or retrieve prices",
+		})
+	}
+	return c.JSON(status)
+}
+
+type manualPriceRequest struct {
+	services.ManualPriceInput
+	Date string `json:"date"` // زمان نرخ، میلادی یا شمسی؛ خالی = اکنون
+}
+
+// CreateManualPrice ثبت دستی نرخ‌ها با برچسب منبع (مثلاً وقتی نواسان در دسترس نیست)
+func CreateManualPrice(c *fiber.Ctx) error {
+	var body manualPriceRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+	if body.Date != "" {
+		at, _, err := parseDate(body.Date)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "فرمت تاریخ نامعتبر است"})
+		}
+		if at.After(time.Now()) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "تاریخ نرخ نمی‌تواند در آینده باشد"})
+		}
+		body.At = &at
+	}
+
+	var userID *uint
+	if user, err := currentUser(c); err == nil {
+		userID = &user.ID
+	}
+
+	price, err := services.SaveManualPrice(database.DB, body.ManualPriceInput, userID)
+	if err != nil {
+		if errors.Is(err, services.ErrNegativePrice) || errors.Is(err, services.ErrEmptyPrice) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در ثبت نرخ"})
+	}
+	return c.Status(fiber.StatusCreated).JSON(price)
+}
+
+// GetPriceHistory ?item=usd&from=&to=&interval=day|week|month (پیش‌فرض ۳۰ روز گذشته، روزانه)
+func GetPriceHistory(c *fiber.Ctx) error {
+	to := time.Now()
+	from := to.AddDate(0, 0, -30)
+	if c.Query("from") != "" || c.Query("to") != "" {
+		var err error
+		if from, to, err = parseDateRange(c.Query("from"), c.Query("to")); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+
+	history, err := services.GetPriceHistory(database.DB, models.Currency(c.Query("item", "usd")), from, to, c.Query("interval"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(history)
+}
+
+// ConvertPrice ?amount=&from=usd&to=irr&date= (پیش‌فرض اکنون)
+func ConvertPrice(c *fiber.Ctx) error {
+	amount, err := strconv.ParseFloat(c.Query("amount"), 64)
 	if err != nil {
-		// اگر خطا بود، آخرین قیمت ذخیره‌شده را برگردان
-		usd, tether, gold18, err = services.GetLastPrice()
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "مبلغ نامعتبر است"})
+	}
+	date := time.Now()
+	if c.Query("date") != "" {
+		parsed, dateOnly, err := parseDate(c.Query("date"))
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{
-				"error": "failed to fetch or retrieve prices",
-			})
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "فرمت تاریخ نامعتبر است"})
 		}
+		// تاریخ بدون ساعت یعنی نرخ پایان همان روز
+		if dateOnly {
+			parsed = parsed.AddDate(0, 0, 1).Add(-time.Second)
+		}
+		date = parsed
 	}
 
-	return c.JSON(fiber.Map{
-		"usd":    usd,
-		"tether": tether,
-		"gold18": gold18,
-	})
+	from := models.Currency(c.Query("from", string(models.CurrencyUSD)))
+	to := models.Currency(c.Query("to", string(models.CurrencyRial)))
+	result, err := services.ConvertPrice(database.DB, amount, from, to, date)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNoRate) || errors.Is(err, repositories.ErrInvalidCurrency) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در تبدیل مبلغ"})
+	}
+	return c.JSON(result)
 }
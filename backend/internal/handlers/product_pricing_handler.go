@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/amirqodi/hgm/internal/database"
+	"github.com/amirqodi/hgm/internal/models"
+	"github.com/amirqodi/hgm/internal/repositories"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type priceChangeReviewRequest struct {
+	IDs []uint `json:"ids"` // خالی = همه پیشنهادهای در انتظار
+}
+
+// GetProductPriceChangesHandler ?status=pending|approved|rejected|superseded|all&product_id= پیش‌نمایش قیمت‌های پیشنهادی
+func GetProductPriceChangesHandler(c *fiber.Ctx) error {
+	productID, _ := strconv.Atoi(c.Query("product_id", "0"))
+	filter := repositories.PriceChangeFilter{
+		ProductID: uint(productID),
+		Status:    models.PriceChangeStatus(c.Query("status", string(models.PriceChangePending))),
+	}
+	if filter.Status == "all" {
+		filter.Status = ""
+	}
+
+	list, err := repositories.GetProductPriceChanges(filter, database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در دریافت تغییرات قیمت"})
+	}
+	return c.JSON(list)
+}
+
+// GetProductPriceHistoryHandler همه تغییرات قیمت فروش یک محصول
+func GetProductPriceHistoryHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+	if _, err := repositories.GetProductServiceByID(uint(id)); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "محصول یا خدمت مورد نظر یافت نشد"})
+	}
+
+	list, err := repositories.GetProductPriceChanges(repositories.PriceChangeFilter{ProductID: uint(id)}, database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در دریافت سابقه قیمت"})
+	}
+	return c.JSON(list)
+}
+
+func ApproveProductPriceChangesHandler(c *fiber.Ctx) error {
+	return reviewProductPriceChanges(c, true)
+}
+
+func RejectProductPriceChangesHandler(c *fiber.Ctx) error {
+	return reviewProductPriceChanges(c, false)
+}
+
+func reviewProductPriceChanges(c *fiber.Ctx, approve bool) error {
+	var body priceChangeReviewRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+		}
+	}
+
+	var userID *uint
+	if user, err := currentUser(c); err == nil {
+		userID = &user.ID
+	}
+
+	list, err := repositories.ReviewProductPriceChanges(body.IDs, approve, userID, database.DB)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "تغییر قیمت یافت نشد"})
+		}
+		if errors.Is(err, repositories.ErrPriceChangeClosed) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در بررسی تغییرات قیمت"})
+	}
+	return c.JSON(list)
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/url"
 	"strconv"
@@ -57,6 +58,10 @@ func CreateProductService(c *fiber.Ctx) error {
 
 	// ذخیره در دیتابیس
 	if err := repositories.CreateProductService(&p); err != nil {
+		if isPricingRuleError(err) {
+			errorsMap["price_unit"] = append(errorsMap["price_unit"], err.Error())
+			return c.Status(fiber.StatusBadRequest).JSON(errorsMap)
+		}
 		errorsMap["error"] = append(errorsMap["error"], "مشکلی در ذخیره‌سازی محصول یا خدمت به وجود آمد")
 		return c.Status(fiber.StatusInternalServerError).JSON(errorsMap)
 	}
@@ -216,8 +221,17 @@ func UpdateProductService(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(errorsMap)
 	}
 
-	updated, err := repositories.UpdateProductService(uint(id), &data)
+	var userID *uint
+	if user, err := currentUser(c); err == nil {
+		userID = &user.ID
+	}
+
+	updated, err := repositories.UpdateProductService(uint(id), &data, userID)
 	if err != nil {
+		if isPricingRuleError(err) {
+			errorsMap["price_unit"] = append(errorsMap["price_unit"], err.Error())
+			return c.Status(fiber.StatusBadRequest).JSON(errorsMap)
+		}
 		errorsMap["error"] = append(errorsMap["error"], "خطا در بروزرسانی محصول یا خدمت")
 		return c.Status(fiber.StatusInternalServerError).JSON(errorsMap)
 	}
@@ -251,6 +265,11 @@ func DeleteProductService(c *fiber.Ctx) error {
 	return c.SendStatus(fiber.StatusNoContent)
 }
 
+// isPricingRuleError خطای قاعده قیمت‌گذاری ارزی یا نبود نرخ برای آن
+func isPricingRuleError(err error) bool {
+	return errors.Is(err, repositories.ErrInvalidPricingRule) || errors.Is(err, repositories.ErrNoRate)
+}
+
 func generateProductCode() string {
 	// مثال: PS- + timestamp به میلی‌ثانیه
 	return fmt.Sprintf("PS-%d", time.Now().UnixNano()/1e6)
@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/amirqodi/hgm/internal/database"
+	"github.com/amirqodi/hgm/internal/models"
+	"github.com/amirqodi/hgm/internal/repositories"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type purchaseOrderItemRequest struct {
+	ProductID    uint    `json:"product_service_id"`
+	Description  string  `json:"description"`
+	Quantity     int64   `json:"quantity"`
+	UnitPrice    float64 `json:"unit_price"` // خالی = قیمت خرید کالا
+	ExpectedDate string  `json:"expected_date"`
+}
+
+type purchaseOrderRequest struct {
+	ContactID    uint                       `json:"contact_id"` // فروشنده
+	OrderDate    string                     `json:"order_date"`
+	ExpectedDate string                     `json:"expected_date"`
+	TaxRate      *float64                   `json:"tax_rate"` // خالی = VAT_RATE
+	Notes        string                     `json:"notes"`
+	Items        []purchaseOrderItemRequest `json:"items"`
+}
+
+func (r *purchaseOrderRequest) toPurchaseOrder() (*models.PurchaseOrder, error) {
+	po := &models.PurchaseOrder{
+		ContactID: r.ContactID,
+		TaxRate:   repositories.DefaultTaxRate(),
+		Notes:     r.Notes,
+	}
+	if r.TaxRate != nil {
+		po.TaxRate = *r.TaxRate
+	}
+	if r.OrderDate != "" {
+		at, _, err := parseDate(r.OrderDate)
+		if err != nil {
+			return nil, errors.New("فرمت تاریخ سفارش نامعتبر است")
+		}
+		po.OrderDate = at
+	}
+	if r.ExpectedDate != "" {
+		at, _, err := parseDate(r.ExpectedDate)
+		if err != nil {
+			return nil, errors.New("فرمت تاریخ پیش‌بینی رسیدن نامعتبر است")
+		}
+		po.ExpectedDate = &at
+	}
+	for _, item := range r.Items {
+		line := models.PurchaseOrderItem{
+			ProductID:   item.ProductID,
+			Description: item.Description,
+			Quantity:    item.Quantity,
+			UnitPrice:   item.UnitPrice,
+		}
+		if item.ExpectedDate != "" {
+			at, _, err := parseDate(item.ExpectedDate)
+			if err != nil {
+				return nil, errors.New("فرمت تاریخ پیش‌بینی رسیدن نامعتبر است")
+			}
+			line.ExpectedDate = &at
+		}
+		po.Items = append(po.Items, line)
+	}
+	return po, nil
+}
+
+func purchaseError(c *fiber.Ctx, err error, notFound string) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": notFound})
+	}
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+}
+
+// GetPurchaseOrdersHandler ?status=&contact_id=
+func GetPurchaseOrdersHandler(c *fiber.Ctx) error {
+	contactID, _ := strconv.Atoi(c.Query("contact_id", "0"))
+	orders, err := repositories.GetPurchaseOrders(repositories.PurchaseOrderFilter{
+		Status:    models.PurchaseOrderStatus(c.Query("status")),
+		ContactID: uint(contactID),
+	}, database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در دریافت سفارش‌های خرید"})
+	}
+	return c.JSON(orders)
+}
+
+func GetPurchaseOrderByIDHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+	po, err := repositories.GetPurchaseOrderByID(uint(id), database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "سفارش خرید یافت نشد"})
+	}
+	return c.JSON(po)
+}
+
+func CreatePurchaseOrderHandler(c *fiber.Ctx) error {
+	var body purchaseOrderRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+	po, err := body.toPurchaseOrder()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if user, err := currentUser(c); err == nil {
+		po.CreatedByID = &user.ID
+	}
+
+	if err := repositories.CreatePurchaseOrder(po, database.DB); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(po)
+}
+
+func UpdatePurchaseOrderHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+	var body purchaseOrderRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+	data, err := body.toPurchaseOrder()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	po, err := repositories.UpdatePurchaseOrder(uint(id), data, database.DB)
+	if err != nil {
+		return purchaseError(c, err, "سفارش خرید یافت نشد")
+	}
+	return c.JSON(po)
+}
+
+func DeletePurchaseOrderHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+	if err := repositories.DeletePurchaseOrder(uint(id), database.DB); err != nil {
+		return purchaseError(c, err, "سفارش خرید یافت نشد")
+	}
+	return c.JSON(fiber.Map{"message": "سفارش خرید حذف شد"})
+}
+
+// SetPurchaseOrderStatusHandler {status: ordered|cancelled|closed}
+func SetPurchaseOrderStatusHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+	var body struct {
+		Status models.PurchaseOrderStatus `json:"status"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+
+	po, err := repositories.SetPurchaseOrderStatus(uint(id), body.Status, database.DB)
+	if err != nil {
+		return purchaseError(c, err, "سفارش خرید یافت نشد")
+	}
+	return c.JSON(po)
+}
+
+// ReceiveGoodsHandler {received_at, notes, items:[{purchase_order_item_id, quantity}]}
+func ReceiveGoodsHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+	var body struct {
+		ReceivedAt string                    `json:"received_at"`
+		Notes      string                    `json:"notes"`
+		Items      []models.GoodsReceiptItem `json:"items"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+
+	gr := &models.GoodsReceipt{Notes: body.Notes, Items: body.Items}
+	if body.ReceivedAt != "" {
+		at, _, err := parseDate(body.ReceivedAt)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "فرمت تاریخ نامعتبر است"})
+		}
+		gr.ReceivedAt = at
+	}
+	if user, err := currentUser(c); err == nil {
+		gr.ReceivedByID = &user.ID
+	}
+
+	if err := repositories.ReceiveGoods(uint(id), gr, database.DB); err != nil {
+		return purchaseError(c, err, "سفارش خرید یافت نشد")
+	}
+	return c.Status(fiber.StatusCreated).JSON(gr)
+}
+
+func DeleteGoodsReceiptHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+	if err := repositories.DeleteGoodsReceipt(uint(id), database.DB); err != nil {
+		return purchaseError(c, err, "رسید انبار یافت نشد")
+	}
+	return c.JSON(fiber.Map{"message": "رسید انبار حذف و موجودی برگشت داده شد"})
+}
+
+// CreateVendorBillHandler {bill_number, bill_date, tax_amount, items:[{purchase_order_item_id, quantity, unit_price}]}
+func CreateVendorBillHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+	var body struct {
+		BillNumber string                  `json:"bill_number"`
+		BillDate   string                  `json:"bill_date"`
+		TaxAmount  *float64                `json:"tax_amount"` // خالی = نرخ مالیات سفارش
+		Notes      string                  `json:"notes"`
+		Items      []models.VendorBillItem `json:"items"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+
+	bill := &models.VendorBill{BillNumber: body.BillNumber, Notes: body.Notes, Items: body.Items}
+	if body.BillDate != "" {
+		at, _, err := parseDate(body.BillDate)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "فرمت تاریخ نامعتبر است"})
+		}
+		bill.BillDate = at
+	}
+
+	if err := repositories.CreateVendorBill(uint(id), bill, body.TaxAmount, database.DB); err != nil {
+		return purchaseError(c, err, "سفارش خرید یافت نشد")
+	}
+	return c.Status(fiber.StatusCreated).JSON(bill)
+}
+
+// GetVendorBillsHandler ?contact_id=&purchase_order_id=&unposted=true
+func GetVendorBillsHandler(c *fiber.Ctx) error {
+	contactID, _ := strconv.Atoi(c.Query("contact_id", "0"))
+	poID, _ := strconv.Atoi(c.Query("purchase_order_id", "0"))
+	bills, err := repositories.GetVendorBills(uint(contactID), uint(poID), c.QueryBool("unposted"), database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در دریافت صورتحساب‌ها"})
+	}
+	return c.JSON(bills)
+}
+
+func GetVendorBillByIDHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+	bill, err := repositories.GetVendorBillByID(uint(id), database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "صورتحساب یافت نشد"})
+	}
+	return c.JSON(bill)
+}
+
+func RematchVendorBillHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+	bill, err := repositories.RematchVendorBill(uint(id), database.DB)
+	if err != nil {
+		return purchaseError(c, err, "صورتحساب یافت نشد")
+	}
+	return c.JSON(bill)
+}
+
+// PostVendorBillHandler ثبت صورتحساب به صورت تراکنش هزینه؛ force برای صورتحساب مغایر فقط مدیر
+func PostVendorBillHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+	var body struct {
+		CategoryID      uint                    `json:"category_id"`
+		MoneySourceType string                  `json:"money_source_type"`
+		BankAccountID   *uint                   `json:"bank_account_id"`
+		CashHolderID    *uint                   `json:"cash_holder_id"`
+		PaymentMethod   string                  `json:"payment_method"`
+		IsPaid          bool                    `json:"is_paid"`
+		TransactionDate string                  `json:"transaction_date"`
+		SubTransactions []models.SubTransaction `json:"sub_transactions"`
+		Notes           string                  `json:"notes"`
+		Force           bool                    `json:"force"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+
+	var userID *uint
+	isManager := false
+	if user, err := currentUser(c); err == nil {
+		userID, isManager = &user.ID, user.IsManager()
+	}
+	if body.Force && !isManager {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "فقط مدیر می‌تواند صورتحساب مغایر را ثبت کند"})
+	}
+
+	trx := &models.Transaction{
+		CategoryID:      body.CategoryID,
+		MoneySourceType: body.MoneySourceType,
+		BankAccountID:   body.BankAccountID,
+		CashHolderID:    body.CashHolderID,
+		PaymentMethod:   body.PaymentMethod,
+		IsPaid:          body.IsPaid,
+		SubTransactions: body.SubTransactions,
+		Notes:           body.Notes,
+	}
+	if body.TransactionDate != "" {
+		at, _, err := parseDate(body.TransactionDate)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "فرمت تاریخ نامعتبر است"})
+		}
+		trx.TransactionDate = &at
+	}
+
+	bill, err := repositories.PostVendorBill(uint(id), trx, body.Force, userID, database.DB)
+	if err != nil {
+		return purchaseError(c, err, "صورتحساب یافت نشد")
+	}
+	return c.JSON(bill)
+}
+
+func DeleteVendorBillHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+	if err := repositories.DeleteVendorBill(uint(id), database.DB); err != nil {
+		return purchaseError(c, err, "صورتحساب یافت نشد")
+	}
+	return c.JSON(fiber.Map{"message": "صورتحساب حذف شد"})
+}
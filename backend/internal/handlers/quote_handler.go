@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/amirqodi/hgm/internal/database"
+	"github.com/amirqodi/hgm/internal/export"
+	"github.com/amirqodi/hgm/internal/models"
+	"github.com/amirqodi/hgm/internal/repositories"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type quoteRequest struct {
+	ContactID  uint               `json:"contact_id"`
+	IssuedAt   string             `json:"issued_at"`
+	ValidUntil string             `json:"valid_until"` // خالی = QUOTE_VALIDITY_DAYS بعد از صدور
+	TaxRate    *float64           `json:"tax_rate"`    // خالی = VAT_RATE
+	Notes      string             `json:"notes"`
+	Items      []models.QuoteItem `json:"items"`
+}
+
+// quoteConvertRequest اطلاعات پرداخت تراکنشی که از پیش‌فاکتور ساخته می‌شود
+type quoteConvertRequest struct {
+	Target          string                  `json:"target"` // transaction، invoice یا work_order
+	CategoryID      uint                    `json:"category_id"`
+	MoneySourceType string                  `json:"money_source_type"`
+	BankAccountID   *uint                   `json:"bank_account_id"`
+	CashHolderID    *uint                   `json:"cash_holder_id"`
+	PaymentMethod   string                  `json:"payment_method"`
+	IsPaid          bool                    `json:"is_paid"`
+	TransactionDate string                  `json:"transaction_date"`
+	SubTransactions []models.SubTransaction `json:"sub_transactions"`
+	Notes           string                  `json:"notes"`
+	CreditOverride  bool                    `json:"credit_override"`
+}
+
+func (r *quoteRequest) toQuote() (*models.Quote, error) {
+	q := &models.Quote{
+		ContactID: r.ContactID,
+		TaxRate:   repositories.DefaultTaxRate(),
+		Notes:     r.Notes,
+		Items:     r.Items,
+	}
+	if r.TaxRate != nil {
+		q.TaxRate = *r.TaxRate
+	}
+	if r.IssuedAt != "" {
+		at, _, err := parseDate(r.IssuedAt)
+		if err != nil {
+			return nil, errors.New("فرمت تاریخ صدور نامعتبر است")
+		}
+		q.IssuedAt = at
+	}
+	if r.ValidUntil != "" {
+		until, dateOnly, err := parseDate(r.ValidUntil)
+		if err != nil {
+			return nil, errors.New("فرمت تاریخ اعتبار نامعتبر است")
+		}
+		if dateOnly {
+			// تا پایان همان روز معتبر است
+			until = until.AddDate(0, 0, 1).Add(-1)
+		}
+		q.ValidUntil = until
+	}
+	return q, nil
+}
+
+// GetQuotesHandler ?status=&contact_id=
+func GetQuotesHandler(c *fiber.Ctx) error {
+	contactID, _ := strconv.Atoi(c.Query("contact_id", "0"))
+	quotes, err := repositories.GetQuotes(repositories.QuoteFilter{
+		Status:    models.QuoteStatus(c.Query("status")),
+		ContactID: uint(contactID),
+	}, database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در دریافت پیش‌فاکتورها"})
+	}
+	return c.JSON(quotes)
+}
+
+// GetQuoteByIDHandler ?format=pdf نسخه چاپی پیش‌فاکتور
+func GetQuoteByIDHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+	q, err := repositories.GetQuoteByID(uint(id), database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "پیش‌فاکتور یافت نشد"})
+	}
+	return sendReport(c, q, func() *export.Document { return quoteDocument(q) })
+}
+
+func CreateQuoteHandler(c *fiber.Ctx) error {
+	var body quoteRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+	q, err := body.toQuote()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if user, err := currentUser(c); err == nil {
+		q.CreatedByID = &user.ID
+	}
+
+	if err := repositories.CreateQuote(q, database.DB); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(q)
+}
+
+func UpdateQuoteHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+	var body quoteRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+	data, err := body.toQuote()
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	q, err := repositories.UpdateQuote(uint(id), data, database.DB)
+	if err != nil {
+		return quoteError(c, err)
+	}
+	return c.JSON(q)
+}
+
+func DeleteQuoteHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+	if err := repositories.DeleteQuote(uint(id), database.DB); err != nil {
+		return quoteError(c, err)
+	}
+	return c.JSON(fiber.Map{"message": "پیش‌فاکتور حذف شد"})
+}
+
+// SetQuoteStatusHandler {status: sent|accepted|rejected}
+func SetQuoteStatusHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+	var body struct {
+		Status models.QuoteStatus `json:"status"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+
+	q, err := repositories.SetQuoteStatus(uint(id), body.Status, database.DB)
+	if err != nil {
+		return quoteError(c, err)
+	}
+	return c.JSON(q)
+}
+
+// ConvertQuoteHandler تبدیل پیش‌فاکتور به تراکنش درآمد، فاکتور فروش یا دستور کار
+func ConvertQuoteHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+	var body quoteConvertRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+
+	trx := &models.Transaction{
+		CategoryID:      body.CategoryID,
+		MoneySourceType: body.MoneySourceType,
+		BankAccountID:   body.BankAccountID,
+		CashHolderID:    body.CashHolderID,
+		PaymentMethod:   body.PaymentMethod,
+		IsPaid:          body.IsPaid,
+		SubTransactions: body.SubTransactions,
+		Notes:           body.Notes,
+		CreditOverride:  body.CreditOverride,
+	}
+	if body.TransactionDate != "" {
+		at, _, err := parseDate(body.TransactionDate)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "فرمت تاریخ نامعتبر است"})
+		}
+		trx.TransactionDate = &at
+	}
+	if err := checkCreditOverride(c, trx); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var userID *uint
+	if user, err := currentUser(c); err == nil {
+		userID = &user.ID
+	}
+	q, err := repositories.ConvertQuote(uint(id), body.Target, trx, userID, database.DB)
+	if err != nil {
+		return quoteError(c, err)
+	}
+	if body.Target == repositories.QuoteToWorkOrder {
+		return c.JSON(fiber.Map{"quote": q, "work_order": q.WorkOrder})
+	}
+	return c.JSON(fiber.Map{"quote": q, "transaction": trx})
+}
+
+func quoteError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "پیش‌فاکتور یافت نشد"})
+	}
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+}
@@ -0,0 +1,676 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/export"
+	"github.com/amirqodi/hgm/internal/models"
+	"github.com/amirqodi/hgm/internal/services"
+	"github.com/amirqodi/hgm/internal/utils"
+)
+
+// ساخت سند خروجی (xlsx، csv و pdf) برای هر گزارش
+
+var transactionTypeLabels = map[string]string{
+	"income":          "درآمد",
+	"expense":         "هزینه",
+	"share":           "آورده سهامدار",
+	"share_reduction": "کاهش سهم",
+}
+
+var paymentMethodLabels = map[string]string{
+	"cash":        "نقدی",
+	"cheque":      "چک",
+	"card":        "کارت",
+	"installment": "اقساطی",
+}
+
+var statementStatusLabels = map[models.StatementLineStatus]string{
+	models.StatementLineUnmatched: "تطبیق‌نشده",
+	models.StatementLineSuggested: "پیشنهاد تطبیق",
+	models.StatementLineMatched:   "تطبیق‌شده",
+	models.StatementLineIgnored:   "نادیده گرفته شده",
+}
+
+var equityMovementLabels = map[string]string{
+	string(models.CapitalContribution): "آورده",
+	string(models.CapitalWithdrawal):   "برداشت",
+	"distribution":                     "سود تخصیصی",
+	"share_change":                     "تغییر درصد سهم",
+}
+
+func label(labels map[string]string, key string) string {
+	if l, ok := labels[key]; ok {
+		return l
+	}
+	return key
+}
+
+func paidLabel(paid bool) string {
+	if paid {
+		return "پرداخت‌شده"
+	}
+	return "پرداخت‌نشده"
+}
+
+func amountTable(title string) export.Table {
+	return export.Table{
+		Title:   title,
+		Columns: []export.Column{{Title: "شرح"}, {Title: "مبلغ", Kind: export.KindAmount}},
+	}
+}
+
+func incomeExpenseDocument(report []services.IncomeExpenseReport, period string, unit models.Currency) *export.Document {
+	table := export.Table{Columns: []export.Column{
+		{Title: "دوره"},
+		{Title: "درآمد", Kind: export.KindAmount},
+		{Title: "هزینه", Kind: export.KindAmount},
+		{Title: "سود خالص", Kind: export.KindAmount},
+	}}
+	var income, expense float64
+	for _, r := range report {
+		table.AddRow(r.Period, r.Income, r.Expense, r.NetProfit)
+		income += r.Income
+		expense += r.Expense
+	}
+	table.AddBoldRow("جمع", income, expense, income-expense)
+
+	periods := map[string]string{"daily": "روزانه", "weekly": "هفتگی", "monthly": "ماهانه"}
+	return &export.Document{
+		Name:     "income-expense",
+		Title:    "گزارش درآمد و هزینه",
+		Subtitle: withUnit(label(periods, period), unit),
+		Tables:   []export.Table{table},
+	}
+}
+
+func latestTransactionsDocument(txs []LatestTransactionDTO) *export.Document {
+	table := export.Table{Columns: []export.Column{
+		{Title: "شناسه", Kind: export.KindNumber},
+		{Title: "تاریخ", Kind: export.KindDate},
+		{Title: "نوع"},
+		{Title: "مبلغ", Kind: export.KindAmount},
+		{Title: "وضعیت"},
+	}}
+	for _, tx := range txs {
+		table.AddRow(tx.ID, tx.TransactionDate, label(transactionTypeLabels, tx.TransactionType), tx.Amount, paidLabel(tx.IsPaid))
+	}
+	return &export.Document{Name: "latest-transactions", Title: "آخرین تراکنش‌ها", Tables: []export.Table{table}}
+}
+
+func totalBalanceDocument(balance services.TotalBalance) *export.Document {
+	table := amountTable("")
+	table.AddRow("موجودی حساب‌های بانکی", balance.BankBalance)
+	table.AddRow("موجودی تنخواه‌داران", balance.CashHolderBalance)
+	table.AddBoldRow("جمع کل", balance.Total)
+	tables := []export.Table{table}
+
+	if len(balance.Foreign) > 0 {
+		foreign := export.Table{Title: "حساب‌های ارزی و طلا", Columns: []export.Column{
+			{Title: "حساب"},
+			{Title: "ارز"},
+			{Title: "مانده", Kind: export.KindNumber},
+			{Title: "ارزش دفتری", Kind: export.KindAmount},
+			{Title: "نرخ روز", Kind: export.KindAmount},
+			{Title: "ارزش روز", Kind: export.KindAmount},
+			{Title: "سود/زیان تسعیرنشده", Kind: export.KindAmount},
+		}}
+		for _, h := range balance.Foreign {
+			foreign.AddRow(h.Name, label(currencyLabels, string(h.Currency)), h.Balance, h.BookValue, h.Rate, h.MarketValue, h.UnrealisedGain)
+		}
+		foreign.AddBoldRow("جمع کل به ارزش روز", nil, nil, nil, nil, balance.MarketTotal, balance.UnrealisedGain)
+		tables = append(tables, foreign)
+	}
+	return &export.Document{Name: "total-balance", Title: "موجودی نقد و بانک", Tables: tables}
+}
+
+var currencyLabels = map[string]string{
+	string(models.CurrencyRial):   "ریال",
+	string(models.CurrencyUSD):    "دلار",
+	string(models.CurrencyTether): "تتر",
+	string(models.CurrencyGold18): "طلای ۱۸ عیار (گرم)",
+	string(models.CurrencyCoin):   "سکه امامی",
+}
+
+// withUnit افزودن واحد گزارش (مثلاً «به دلار») به زیرعنوان گزارش‌های تبدیل‌شده
+func withUnit(subtitle string, unit models.Currency) string {
+	if unit == "" {
+		return subtitle
+	}
+	note := "به " + label(currencyLabels, string(unit))
+	if subtitle == "" {
+		return note
+	}
+	return subtitle + " (" + note + ")"
+}
+
+func balanceSheetDocument(bs *services.BalanceSheet) *export.Document {
+	assets := amountTable("دارایی‌ها")
+	assets.AddRow("حساب‌های بانکی", bs.Assets.BankAccounts)
+	assets.AddRow("تنخواه‌داران", bs.Assets.CashHolders)
+	assets.AddRow("موجودی کالا", bs.Assets.Inventory)
+	assets.AddRow("ودیعه‌های دریافتی", bs.Assets.DepositsReceived)
+	assets.AddRow("حساب‌های دریافتنی", bs.Assets.Receivables)
+	assets.AddBoldRow("جمع دارایی‌ها", bs.TotalAssets)
+
+	liabilities := amountTable("بدهی‌ها")
+	liabilities.AddRow("ودیعه‌های پرداختی", bs.Liabilities.DepositsPaid)
+	liabilities.AddRow("حساب‌های پرداختنی", bs.Liabilities.Payables)
+	liabilities.AddRow("سود سهام پرداختنی", bs.Liabilities.DividendsPayable)
+	liabilities.AddBoldRow("جمع بدهی‌ها", bs.TotalLiab)
+
+	equity := amountTable("حقوق صاحبان سهام")
+	equity.AddRow("سرمایه", bs.Equity.Capital)
+	equity.AddRow("سود انباشته", bs.Equity.RetainedEarnings)
+	equity.AddBoldRow("جمع حقوق صاحبان سهام", bs.TotalEquity)
+	equity.AddBoldRow("جمع بدهی‌ها و حقوق صاحبان سهام", bs.TotalLiab+bs.TotalEquity)
+
+	return &export.Document{
+		Name:     "balance-sheet",
+		Title:    "ترازنامه",
+		Subtitle: withUnit("", bs.Unit),
+		Tables:   []export.Table{assets, liabilities, equity},
+	}
+}
+
+func dashboardSummaryDocument(summary DashboardSummaryDTO) *export.Document {
+	table := amountTable("")
+	table.AddRow("کل درآمد", summary.TotalIncome)
+	table.AddRow("کل هزینه", summary.TotalExpense)
+	table.AddBoldRow("سود خالص", summary.NetProfit)
+	return &export.Document{Name: "summary", Title: "خلاصه عملکرد", Tables: []export.Table{table}}
+}
+
+func equityStatementDocument(st *services.EquityStatement) *export.Document {
+	summary := export.Table{Columns: []export.Column{
+		{Title: "سهامدار"},
+		{Title: "درصد سهم", Kind: export.KindPercent},
+		{Title: "سرمایه ابتدای دوره", Kind: export.KindAmount},
+		{Title: "آورده", Kind: export.KindAmount},
+		{Title: "برداشت", Kind: export.KindAmount},
+		{Title: "سود تخصیصی", Kind: export.KindAmount},
+		{Title: "سود پرداختی", Kind: export.KindAmount},
+		{Title: "سرمایه پایان دوره", Kind: export.KindAmount},
+	}}
+	movements := export.Table{
+		Title: "گردش سرمایه",
+		Columns: []export.Column{
+			{Title: "سهامدار"},
+			{Title: "تاریخ", Kind: export.KindDate},
+			{Title: "نوع"},
+			{Title: "مبلغ", Kind: export.KindAmount},
+			{Title: "درصد سهم", Kind: export.KindPercent},
+			{Title: "توضیحات"},
+		},
+	}
+
+	for _, sh := range st.Shareholders {
+		summary.AddRow(sh.Name, sh.SharePercentage, sh.OpeningCapital, sh.Contributions,
+			sh.Withdrawals, sh.Distributions, sh.DividendsPaid, sh.ClosingCapital)
+		for _, m := range sh.Movements {
+			var amount any
+			if m.Percentage == nil {
+				amount = m.Amount
+			}
+			movements.AddRow(sh.Name, m.Date, label(equityMovementLabels, m.Kind), amount, m.Percentage, m.Description)
+		}
+	}
+	t := st.Totals
+	summary.AddBoldRow("جمع", nil, t.OpeningCapital, t.Contributions, t.Withdrawals, t.Distributions, t.DividendsPaid, t.ClosingCapital)
+
+	return &export.Document{
+		Name:     "equity-statement",
+		Title:    "صورت تغییرات حقوق صاحبان سهام",
+		Subtitle: periodSubtitle(st.From, st.To),
+		Tables:   []export.Table{summary, movements},
+	}
+}
+
+func cashFlowDocument(cf *services.CashFlowStatement) *export.Document {
+	table := export.Table{Columns: []export.Column{
+		{Title: "شرح"},
+		{Title: "ورودی", Kind: export.KindAmount},
+		{Title: "خروجی", Kind: export.KindAmount},
+		{Title: "خالص", Kind: export.KindAmount},
+	}}
+	table.AddBoldRow("موجودی نقد ابتدای دوره", nil, nil, cf.OpeningCash)
+
+	sections := []struct {
+		title   string
+		section services.CashFlowSection
+	}{
+		{"فعالیت‌های عملیاتی", cf.Operating},
+		{"فعالیت‌های سرمایه‌گذاری", cf.Investing},
+		{"فعالیت‌های تامین مالی", cf.Financing},
+	}
+	for _, s := range sections {
+		table.AddBoldRow(s.title)
+		for _, line := range s.section.Lines {
+			table.AddIndentedRow(1, line.Label, line.Inflow, line.Outflow, line.Net)
+		}
+		table.AddBoldRow("خالص جریان نقد "+s.title, s.section.Inflow, s.section.Outflow, s.section.Net)
+	}
+
+	if cf.ExchangeEffect != 0 {
+		table.AddBoldRow("اثر تغییر نرخ ارز و طلا", nil, nil, cf.ExchangeEffect)
+	}
+	table.AddBoldRow("خالص تغییر در موجودی نقد", nil, nil, cf.NetChange)
+	table.AddBoldRow("موجودی نقد پایان دوره", nil, nil, cf.ClosingCash)
+
+	return &export.Document{
+		Name:     "cash-flow",
+		Title:    "صورت جریان وجوه نقد",
+		Subtitle: periodSubtitle(cf.From, cf.To),
+		Tables:   []export.Table{table},
+	}
+}
+
+var plColumnLabels = map[string]string{
+	services.PLCurrent:  "دوره جاری",
+	services.PLPrevious: "دوره قبل",
+	services.PLLastYear: "سال قبل",
+}
+
+func profitLossDocument(pl *services.ProfitLossStatement) *export.Document {
+	columns := []export.Column{{Title: "شرح"}}
+	for _, col := range pl.Columns {
+		columns = append(columns,
+			export.Column{Title: label(plColumnLabels, col.Key), Kind: export.KindAmount},
+			export.Column{Title: "درصد از درآمد", Kind: export.KindPercent},
+		)
+	}
+	table := export.Table{Columns: columns}
+
+	cells := func(name string, amounts, percents map[string]float64) []any {
+		row := []any{name}
+		for _, col := range pl.Columns {
+			row = append(row, amounts[col.Key], percents[col.Key])
+		}
+		return row
+	}
+	var addNodes func(nodes []*services.PLNode, level int)
+	addNodes = func(nodes []*services.PLNode, level int) {
+		for _, node := range nodes {
+			table.AddIndentedRow(level, cells(node.Name, node.Amounts, node.PercentOfRevenue)...)
+			addNodes(node.Children, level+1)
+		}
+	}
+
+	table.AddBoldRow("درآمدها")
+	addNodes(pl.Revenue.Lines, 1)
+	table.AddBoldRow(cells("جمع درآمدها", pl.Revenue.Total, pl.Revenue.PercentOfRevenue)...)
+	table.AddBoldRow("هزینه‌ها")
+	addNodes(pl.Expenses.Lines, 1)
+	table.AddBoldRow(cells("جمع هزینه‌ها", pl.Expenses.Total, pl.Expenses.PercentOfRevenue)...)
+	table.AddBoldRow(cells("سود (زیان) خالص", pl.NetProfit, pl.NetMargin)...)
+
+	current := pl.Columns[0]
+	return &export.Document{
+		Name:     "profit-loss",
+		Title:    "صورت سود و زیان",
+		Subtitle: withUnit(periodSubtitle(current.From, current.To), pl.Unit),
+		Tables:   []export.Table{table},
+	}
+}
+
+func transactionsDocument(trxs []models.Transaction) *export.Document {
+	table := export.Table{Columns: []export.Column{
+		{Title: "شناسه", Kind: export.KindNumber},
+		{Title: "تاریخ", Kind: export.KindDate},
+		{Title: "طرف حساب"},
+		{Title: "دسته‌بندی"},
+		{Title: "نوع"},
+		{Title: "مبلغ", Kind: export.KindAmount},
+		{Title: "روش پرداخت"},
+		{Title: "منبع"},
+		{Title: "وضعیت"},
+		{Title: "توضیحات"},
+	}}
+	for _, trx := range trxs {
+		table.AddRow(
+			trx.ID, transactionDate(&trx),
+			contactName(&trx.Contact),
+			trx.Category.Name,
+			label(transactionTypeLabels, trx.TransactionType),
+			trx.Amount,
+			label(paymentMethodLabels, trx.PaymentMethod),
+			moneySourceName(&trx),
+			paidLabel(trx.IsPaid),
+			trx.Notes,
+		)
+	}
+	return &export.Document{Name: "transactions", Title: "فهرست تراکنش‌ها", Tables: []export.Table{table}}
+}
+
+func bankStatementDocument(stmt *models.BankStatement) *export.Document {
+	table := export.Table{Columns: []export.Column{
+		{Title: "ردیف", Kind: export.KindNumber},
+		{Title: "تاریخ", Kind: export.KindDate},
+		{Title: "شرح"},
+		{Title: "شماره پیگیری"},
+		{Title: "واریز", Kind: export.KindAmount},
+		{Title: "برداشت", Kind: export.KindAmount},
+		{Title: "مانده", Kind: export.KindAmount},
+		{Title: "وضعیت"},
+	}}
+	var credit, debit float64
+	for _, line := range stmt.Lines {
+		var in, out any
+		if line.Amount >= 0 {
+			in = line.Amount
+			credit += line.Amount
+		} else {
+			out = -line.Amount
+			debit -= line.Amount
+		}
+		status := string(line.Status)
+		if l, ok := statementStatusLabels[line.Status]; ok {
+			status = l
+		}
+		table.AddRow(line.RowNumber, line.Date, line.Description, line.Reference, in, out, line.Balance, status)
+	}
+	table.AddBoldRow("جمع", nil, nil, nil, credit, debit, stmt.ClosingBalance)
+
+	subtitle := fmt.Sprintf("از %s تا %s", utils.FormatJalali(stmt.PeriodStart), utils.FormatJalali(stmt.PeriodEnd))
+	if stmt.BankAccount != nil {
+		subtitle = stmt.BankAccount.BankName + " - " + stmt.BankAccount.AccountNumber + " | " + subtitle
+	}
+	return &export.Document{
+		Name:     "bank-statement",
+		Title:    "صورتحساب بانکی",
+		Subtitle: subtitle,
+		Tables:   []export.Table{table},
+	}
+}
+
+func contactName(c *models.Contact) string {
+	if c == nil {
+		return ""
+	}
+	return strings.TrimSpace(c.FirstName + " " + c.LastName)
+}
+
+func moneySourceName(trx *models.Transaction) string {
+	switch {
+	case trx.BankAccount != nil:
+		return trx.BankAccount.BankName
+	case trx.CashHolder != nil:
+		return strings.TrimSpace(trx.CashHolder.FirstName + " " + trx.CashHolder.LastName)
+	}
+	return ""
+}
+
+// contactFields مشخصات طرف حساب در سربرگ اسناد: تلفن، شناسه ملی، کد اقتصادی، نشانی و خودرو
+func contactFields(c *models.Contact) []export.Field {
+	if c == nil {
+		return nil
+	}
+	var fields []export.Field
+	if c.PhoneNumber != "" {
+		fields = append(fields, export.Field{Label: "تلفن", Value: c.PhoneNumber})
+	}
+	if c.NationalID != "" {
+		fields = append(fields, export.Field{Label: "کد/شناسه ملی", Value: c.NationalID})
+	}
+	if c.EconomicCode != "" {
+		fields = append(fields, export.Field{Label: "کد اقتصادی", Value: c.EconomicCode})
+	}
+	if len(c.Addresses) > 0 {
+		fields = append(fields, export.Field{Label: "نشانی", Value: c.Addresses[0].Address})
+	} else if c.Vendor != nil && c.Vendor.Address != "" {
+		fields = append(fields, export.Field{Label: "نشانی", Value: c.Vendor.Address})
+	}
+	if car := c.Customer; car != nil && car.CarType != nil && *car.CarType != "" {
+		fields = append(fields, export.Field{Label: "خودرو", Value: *car.CarType})
+		if car.CarKilometer != nil {
+			fields = append(fields, export.Field{Label: "کارکرد (کیلومتر)", Value: fmt.Sprint(*car.CarKilometer)})
+		}
+	}
+	return fields
+}
+
+// invoiceDocument فاکتور یا رسید چاپی با مشخصات طرف حساب، خودرو، اقلام، مالیات، پرداخت‌ها و اقساط
+func invoiceDocument(inv *models.Invoice, qrURL string) *export.Document {
+	trx := inv.Transaction
+	if trx == nil {
+		trx = &models.Transaction{}
+	}
+	income := trx.TransactionType != "expense"
+
+	doc := &export.Document{Name: "invoice-" + inv.Number, QRCode: qrURL}
+	party := "خریدار"
+	switch inv.Kind {
+	case models.InvoiceSales:
+		doc.Title = "فاکتور فروش"
+	case models.InvoicePurchase:
+		doc.Title = "فاکتور خرید"
+		party = "فروشنده"
+	default:
+		doc.Name = "receipt-" + inv.Number
+		doc.Title, party = "رسید دریافت وجه", "پرداخت‌کننده"
+		if !income {
+			doc.Title, party = "رسید پرداخت وجه", "دریافت‌کننده"
+		}
+	}
+
+	doc.Fields = []export.Field{
+		{Label: "شماره", Value: inv.Number},
+		{Label: "تاریخ", Value: utils.FormatJalali(inv.IssuedAt)},
+		{Label: party, Value: contactName(inv.Contact)},
+		{Label: "شماره تراکنش", Value: fmt.Sprint(inv.TransactionID)},
+	}
+	doc.Fields = append(doc.Fields, contactFields(inv.Contact)...)
+	if inv.Status == models.InvoiceVoid {
+		doc.Watermark = "باطل شد"
+		doc.Fields = append(doc.Fields, export.Field{Label: "علت ابطال", Value: inv.VoidReason})
+	}
+
+	if inv.Kind == models.InvoiceReceipt {
+		doc.Fields = append(doc.Fields,
+			export.Field{Label: "روش پرداخت", Value: label(paymentMethodLabels, trx.PaymentMethod)},
+			export.Field{Label: "حساب / صندوق", Value: moneySourceName(trx)},
+		)
+		table := amountTable("")
+		if sub := inv.SubTransaction; sub != nil {
+			desc := "قسط"
+			if sub.DueDate != nil {
+				desc += " با سررسید " + utils.FormatJalali(*sub.DueDate)
+			}
+			table.AddRow(desc, inv.Total)
+		} else {
+			table.AddRow(label(transactionTypeLabels, trx.TransactionType), inv.Total)
+		}
+		table.AddBoldRow("جمع", inv.Total)
+		doc.Tables = []export.Table{table}
+		if inv.Notes != "" {
+			doc.Fields = append(doc.Fields, export.Field{Label: "توضیحات", Value: inv.Notes})
+		}
+		return doc
+	}
+
+	items := export.Table{Columns: []export.Column{
+		{Title: "ردیف", Kind: export.KindNumber},
+		{Title: "شرح کالا / خدمت"},
+		{Title: "تعداد", Kind: export.KindNumber},
+		{Title: "مبلغ واحد", Kind: export.KindAmount},
+		{Title: "مبلغ کل", Kind: export.KindAmount},
+	}}
+	for i, item := range inv.Items {
+		items.AddRow(i+1, item.Description, item.Quantity, item.UnitPrice, item.Total)
+	}
+
+	// پرداخت‌ها: کل مبلغ تراکنش نقدی یا اقساط پرداخت‌شده
+	payments := export.Table{
+		Title: "پرداخت‌ها",
+		Columns: []export.Column{
+			{Title: "تاریخ", Kind: export.KindDate},
+			{Title: "شرح"},
+			{Title: "حساب / صندوق"},
+			{Title: "مبلغ", Kind: export.KindAmount},
+		},
+	}
+	schedule := export.Table{
+		Title: "جدول اقساط",
+		Columns: []export.Column{
+			{Title: "قسط", Kind: export.KindNumber},
+			{Title: "سررسید", Kind: export.KindDate},
+			{Title: "مبلغ", Kind: export.KindAmount},
+			{Title: "وضعیت"},
+			{Title: "تاریخ پرداخت", Kind: export.KindDate},
+		},
+	}
+	var paid float64
+	if len(trx.SubTransactions) == 0 {
+		if trx.IsPaid {
+			paid = trx.Amount
+			payments.AddRow(transactionDate(trx), label(paymentMethodLabels, trx.PaymentMethod), moneySourceName(trx), trx.Amount)
+		}
+	}
+	for i, sub := range trx.SubTransactions {
+		schedule.AddRow(i+1, sub.DueDate, sub.Amount, paidLabel(sub.IsPaid), sub.PaidAt)
+		if sub.IsPaid {
+			paid += sub.Amount
+			payments.AddRow(sub.PaidAt, fmt.Sprintf("قسط %d", i+1), moneySourceName(trx), sub.Amount)
+		}
+	}
+
+	totals := amountTable("")
+	totals.AddRow("جمع اقلام", inv.Subtotal)
+	totals.AddRow(fmt.Sprintf("مالیات بر ارزش افزوده (%g%%)", inv.TaxRate), inv.TaxAmount)
+	totals.AddBoldRow("مبلغ قابل پرداخت", inv.Total)
+	totals.AddRow("پرداخت‌شده", paid)
+	totals.AddBoldRow("مانده", inv.Total-paid)
+
+	doc.Tables = []export.Table{items, totals, payments}
+	if len(trx.SubTransactions) > 0 {
+		doc.Tables = append(doc.Tables, schedule)
+	}
+	if inv.Notes != "" {
+		doc.Fields = append(doc.Fields, export.Field{Label: "توضیحات", Value: inv.Notes})
+	}
+	return doc
+}
+
+func transactionDate(trx *models.Transaction) time.Time {
+	if trx.TransactionDate != nil {
+		return *trx.TransactionDate
+	}
+	return trx.CreatedAt
+}
+
+var quoteStatusLabels = map[string]string{
+	string(models.QuoteDraft):    "پیش‌نویس",
+	string(models.QuoteSent):     "ارسال‌شده",
+	string(models.QuoteAccepted): "پذیرفته‌شده",
+	string(models.QuoteRejected): "ردشده",
+	string(models.QuoteExpired):  "منقضی",
+}
+
+// quoteDocument پیش‌فاکتور (برآورد هزینه) چاپی برای مشتری
+func quoteDocument(q *models.Quote) *export.Document {
+	doc := &export.Document{
+		Name:  "quote-" + q.Number,
+		Title: "پیش‌فاکتور",
+		Fields: []export.Field{
+			{Label: "شماره", Value: q.Number},
+			{Label: "تاریخ", Value: utils.FormatJalali(q.IssuedAt)},
+			{Label: "معتبر تا", Value: utils.FormatJalali(q.ValidUntil)},
+			{Label: "مشتری", Value: contactName(q.Contact)},
+			{Label: "وضعیت", Value: label(quoteStatusLabels, string(q.Status))},
+		},
+	}
+	doc.Fields = append(doc.Fields, contactFields(q.Contact)...)
+	if q.Status == models.QuoteExpired || q.Status == models.QuoteRejected {
+		doc.Watermark = label(quoteStatusLabels, string(q.Status))
+	}
+
+	items := export.Table{Columns: []export.Column{
+		{Title: "ردیف", Kind: export.KindNumber},
+		{Title: "شرح کالا / خدمت"},
+		{Title: "تعداد", Kind: export.KindNumber},
+		{Title: "مبلغ واحد", Kind: export.KindAmount},
+		{Title: "مبلغ کل", Kind: export.KindAmount},
+	}}
+	for i, item := range q.Items {
+		items.AddRow(i+1, item.Description, item.Quantity, item.UnitPrice, item.Total)
+	}
+
+	totals := amountTable("")
+	totals.AddRow("جمع اقلام", q.Subtotal)
+	totals.AddRow(fmt.Sprintf("مالیات بر ارزش افزوده (%g%%)", q.TaxRate), q.TaxAmount)
+	totals.AddBoldRow("مبلغ کل برآورد", q.Total)
+
+	doc.Tables = []export.Table{items, totals}
+	if q.Notes != "" {
+		doc.Fields = append(doc.Fields, export.Field{Label: "توضیحات", Value: q.Notes})
+	}
+	return doc
+}
+
+func quoteConversionDocument(r *services.QuoteConversionReport) *export.Document {
+	table := export.Table{Columns: []export.Column{
+		{Title: "ماه"},
+		{Title: "تعداد", Kind: export.KindNumber},
+		{Title: "مبلغ پیش‌فاکتورها", Kind: export.KindAmount},
+		{Title: "باز", Kind: export.KindNumber},
+		{Title: "پذیرفته", Kind: export.KindNumber},
+		{Title: "رد", Kind: export.KindNumber},
+		{Title: "منقضی", Kind: export.KindNumber},
+		{Title: "تبدیل‌شده", Kind: export.KindNumber},
+		{Title: "مبلغ تبدیل‌شده", Kind: export.KindAmount},
+		{Title: "نرخ موفقیت", Kind: export.KindPercent},
+		{Title: "نرخ تبدیل", Kind: export.KindPercent},
+	}}
+	add := func(row services.QuoteConversionRow, bold bool) {
+		cells := []any{row.Period, row.Quoted, row.QuotedAmount, row.Open, row.Won, row.Lost, row.Expired,
+			row.Converted, row.ConvertedAmount, row.WinRate, row.ConversionRate}
+		if bold {
+			table.AddBoldRow(cells...)
+		} else {
+			table.AddRow(cells...)
+		}
+	}
+	for _, row := range r.Rows {
+		add(row, false)
+	}
+	add(r.Total, true)
+
+	return &export.Document{
+		Name:     "quote-conversion",
+		Title:    "گزارش تبدیل پیش‌فاکتور به فروش",
+		Subtitle: periodSubtitle(r.From, r.To),
+		Tables:   []export.Table{table},
+	}
+}
+
+func openPurchaseOrdersDocument(r *services.OpenPurchaseOrdersReport) *export.Document {
+	table := export.Table{Columns: []export.Column{
+		{Title: "شماره سفارش"},
+		{Title: "فروشنده"},
+		{Title: "کالا"},
+		{Title: "تاریخ سفارش", Kind: export.KindDate},
+		{Title: "پیش‌بینی رسیدن", Kind: export.KindDate},
+		{Title: "سفارش", Kind: export.KindNumber},
+		{Title: "دریافت", Kind: export.KindNumber},
+		{Title: "باقی‌مانده", Kind: export.KindNumber},
+		{Title: "مبلغ باقی‌مانده", Kind: export.KindAmount},
+		{Title: "روز تاخیر", Kind: export.KindNumber},
+	}}
+	for _, line := range r.Lines {
+		var late any
+		if line.DaysLate > 0 {
+			late = line.DaysLate
+		}
+		table.AddRow(line.Number, line.Vendor, line.Description, line.OrderDate, line.ExpectedDate,
+			line.Ordered, line.Received, line.Remaining, line.RemainingAmount, late)
+	}
+	table.AddBoldRow("جمع", nil, nil, nil, nil, nil, nil, nil, r.RemainingAmount, nil)
+
+	return &export.Document{
+		Name:   "open-purchase-orders",
+		Title:  "سفارش‌های خرید باز",
+		Tables: []export.Table{table},
+	}
+}
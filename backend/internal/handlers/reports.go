@@ -1,12 +1,17 @@
 package handlers
 
 import (
+	"errors"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/amirqodi/hgm/internal/database"
+	"github.com/amirqodi/hgm/internal/export"
 	"github.com/amirqodi/hgm/internal/models"
+	"github.com/amirqodi/hgm/internal/repositories"
 	"github.com/amirqodi/hgm/internal/services"
+	"github.com/amirqodi/hgm/internal/utils"
 	"github.com/gofiber/fiber/v2"
 )
 
@@ -26,11 +31,15 @@ type DashboardSummaryDTO struct {
 
 func GetIncomeExpenseReportHandler(c *fiber.Ctx) error {
 	period := c.Query("period", "monthly")
-	report, err := services.GetIncomeExpenseReport(database.DB, period)
+	conv, err := reportUnit(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	report, err := services.GetIncomeExpenseReport(database.DB, period, conv)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
-	return c.JSON(report)
+	return sendReport(c, report, func() *export.Document { return incomeExpenseDocument(report, period, conv.Currency()) })
 }
 
 func GetLatestTransactionsHandler(c *fiber.Ctx) error {
@@ -50,7 +59,7 @@ func GetLatestTransactionsHandler(c *fiber.Ctx) error {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
-	return c.JSON(txs)
+	return sendReport(c, txs, func() *export.Document { return latestTransactionsDocument(txs) })
 }
 
 func GetTotalBalanceHandler(c *fiber.Ctx) error {
@@ -58,15 +67,19 @@ func GetTotalBalanceHandler(c *fiber.Ctx) error {
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
-	return c.JSON(result)
+	return sendReport(c, result, func() *export.Document { return totalBalanceDocument(result) })
 }
 
 func GetBalanceSheetHandler(c *fiber.Ctx) error {
-	result, err := services.GetBalanceSheet(database.DB)
+	conv, err := reportUnit(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	result, err := services.GetBalanceSheet(database.DB, conv)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
-	return c.JSON(result)
+	return sendReport(c, result, func() *export.Document { return balanceSheetDocument(result) })
 }
 
 func GetDashboardSummaryHandler(c *fiber.Ctx) error {
@@ -86,8 +99,125 @@ func GetDashboardSummaryHandler(c *fiber.Ctx) error {
 		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
 	}
 
+	// سود/کارمزد بانکی و اضافه/کسری صندوق
+	otherIncome, otherExpense, err := repositories.OtherIncomeExpense(database.DB, nil, nil)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	summary.TotalIncome += otherIncome
+	summary.TotalExpense += otherExpense
+
 	// سود خالص
 	summary.NetProfit = summary.TotalIncome - summary.TotalExpense
 
-	return c.JSON(summary)
+	return sendReport(c, summary, func() *export.Document { return dashboardSummaryDocument(summary) })
+}
+
+// GetEquityStatementHandler ?from=2025-03-21&to=2026-03-20
+func GetEquityStatementHandler(c *fiber.Ctx) error {
+	from, to, err := parseDateRange(c.Query("from"), c.Query("to"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	result, err := services.GetEquityStatement(database.DB, from, to)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return sendReport(c, result, func() *export.Document { return equityStatementDocument(result) })
+}
+
+// GetCashFlowReportHandler ?from=1404/01/01&to=1404/12/29
+func GetCashFlowReportHandler(c *fiber.Ctx) error {
+	from, to, err := parseDateRange(c.Query("from"), c.Query("to"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	result, err := services.GetCashFlowStatement(database.DB, from, to)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return sendReport(c, result, func() *export.Document { return cashFlowDocument(result) })
+}
+
+// GetProfitLossHandler ?from=1404/01/01&to=1404/06/31&compare=previous,last_year
+func GetProfitLossHandler(c *fiber.Ctx) error {
+	from, to, err := parseDateRange(c.Query("from"), c.Query("to"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	columns, err := services.ProfitLossColumns(from, to, strings.Split(c.Query("compare"), ","))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	conv, err := reportUnit(c)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	result, err := services.GetProfitLossStatement(database.DB, columns, conv)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return sendReport(c, result, func() *export.Document { return profitLossDocument(result) })
+}
+
+// GetQuoteConversionReportHandler ?from=&to= نرخ تبدیل پیش‌فاکتور به فروش
+func GetQuoteConversionReportHandler(c *fiber.Ctx) error {
+	from, to, err := parseDateRange(c.Query("from"), c.Query("to"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	result, err := services.GetQuoteConversionReport(database.DB, from, to)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return sendReport(c, result, func() *export.Document { return quoteConversionDocument(result) })
+}
+
+// GetOpenPurchaseOrdersHandler ?vendor_id= اقلام دریافت‌نشده سفارش‌های خرید با تاریخ پیش‌بینی رسیدن
+func GetOpenPurchaseOrdersHandler(c *fiber.Ctx) error {
+	vendorID, _ := strconv.Atoi(c.Query("vendor_id", "0"))
+	result, err := services.GetOpenPurchaseOrders(database.DB, uint(vendorID), time.Now())
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+	}
+	return sendReport(c, result, func() *export.Document { return openPurchaseOrdersDocument(result) })
+}
+
+// reportUnit پارامتر unit=usd|gold18|tether برای گزارش با حذف اثر تورم؛ بدون آن مبدل nil است
+func reportUnit(c *fiber.Ctx) (*services.UnitConverter, error) {
+	return services.NewUnitConverter(database.DB, models.Currency(c.Query("unit")))
+}
+
+// parseDate تاریخ میلادی (2006-01-02 یا RFC3339) یا شمسی (1403/05/12) را می‌پذیرد
+func parseDate(value string) (time.Time, bool, error) {
+	return utils.ParseDate(value)
+}
+
+// parseDateRange بازه [from, to) را برمی‌گرداند؛ اگر to فقط تاریخ باشد کل همان روز شامل می‌شود
+func parseDateRange(fromStr, toStr string) (time.Time, time.Time, error) {
+	if fromStr == "" || toStr == "" {
+		return time.Time{}, time.Time{}, errors.New("تاریخ شروع و پایان الزامی است")
+	}
+
+	from, _, err := parseDate(fromStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("فرمت تاریخ شروع نامعتبر است")
+	}
+	to, dateOnly, err := parseDate(toStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("فرمت تاریخ پایان نامعتبر است")
+	}
+	if dateOnly {
+		to = to.AddDate(0, 0, 1)
+	}
+	if !to.After(from) {
+		return time.Time{}, time.Time{}, errors.New("تاریخ پایان باید بعد از تاریخ شروع باشد")
+	}
+	return from, to, nil
 }
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/database"
+	"github.com/amirqodi/hgm/internal/repositories"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type revaluationRequest struct {
+	Date string `json:"date"` // پایان دوره، میلادی یا شمسی؛ خالی = اکنون
+}
+
+// GetRevaluationsHandler ?from=&to=
+func GetRevaluationsHandler(c *fiber.Ctx) error {
+	var filter repositories.RevaluationFilter
+	if c.Query("from") != "" || c.Query("to") != "" {
+		from, to, err := parseDateRange(c.Query("from"), c.Query("to"))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		filter.From, filter.To = &from, &to
+	}
+
+	list, err := repositories.GetRevaluations(filter, database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در دریافت تسعیرها"})
+	}
+	return c.JSON(list)
+}
+
+// CreateRevaluationHandler تسعیر همه حساب‌های ارزی و طلا به نرخ پایان دوره
+func CreateRevaluationHandler(c *fiber.Ctx) error {
+	var body revaluationRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+
+	date := time.Now()
+	if body.Date != "" {
+		parsed, dateOnly, err := parseDate(body.Date)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "فرمت تاریخ نامعتبر است"})
+		}
+		// تاریخ بدون ساعت یعنی پایان همان روز
+		if dateOnly {
+			parsed = parsed.AddDate(0, 0, 1).Add(-time.Second)
+		}
+		if parsed.Before(date) {
+			date = parsed
+		}
+	}
+
+	var userID *uint
+	if user, err := currentUser(c); err == nil {
+		userID = &user.ID
+	}
+
+	list, err := repositories.RevalueAccounts(date, userID, database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(list)
+}
+
+func DeleteRevaluationHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	if err := repositories.DeleteRevaluation(uint(id), database.DB); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "رکورد یافت نشد"})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// isCurrencyError خطاهای ارز و نرخ حساب بانکی یا تنخواه که به کاربر نمایش داده می‌شوند
+func isCurrencyError(err error) bool {
+	return errors.Is(err, repositories.ErrNoRate) ||
+		errors.Is(err, repositories.ErrInvalidCurrency) ||
+		errors.Is(err, repositories.ErrCurrencyInUse)
+}
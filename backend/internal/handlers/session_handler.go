@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/amirqodi/hgm/internal/database"
+	"github.com/amirqodi/hgm/internal/models"
+	"github.com/amirqodi/hgm/internal/repositories"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// sessionResponse نشست با پرچم نشست جاری
+type sessionResponse struct {
+	models.Session
+	Current bool `json:"current"`
+}
+
+// currentSessionID نشست توکن دسترسی درخواست (از JWTProtected)
+func currentSessionID(c *fiber.Ctx) uint {
+	id, _ := c.Locals("session_id").(uint)
+	return id
+}
+
+// RefreshTokenHandler صدور توکن دسترسی جدید با توکن تازه‌سازی؛ توکن تازه‌سازی هم عوض می‌شود
+func RefreshTokenHandler(c *fiber.Ctx) error {
+	var body refreshRequest
+	if err := c.BodyParser(&body); err != nil || body.RefreshToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "توکن تازه‌سازی الزامی است"})
+	}
+
+	session, user, refreshToken, err := repositories.RotateSession(body.RefreshToken)
+	if err != nil {
+		if errors.Is(err, repositories.ErrSessionInvalid) || errors.Is(err, repositories.ErrRefreshTokenReused) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در تازه‌سازی نشست"})
+	}
+	return sendTokens(c, user, session, refreshToken)
+}
+
+// LogoutHandler خروج از نشست جاری
+func LogoutHandler(c *fiber.Ctx) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "کاربر یافت نشد"})
+	}
+	if err := repositories.RevokeSession(currentSessionID(c), user.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در خروج"})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// LogoutAllHandler خروج از همه دستگاه‌ها (از جمله نشست جاری)
+func LogoutAllHandler(c *fiber.Ctx) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "کاربر یافت نشد"})
+	}
+	if err := repositories.RevokeUserSessions(user.ID, database.DB); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در خروج"})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func sendSessions(c *fiber.Ctx, userID uint) error {
+	sessions, err := repositories.GetUserSessions(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در دریافت نشست‌ها"})
+	}
+	current := currentSessionID(c)
+	list := make([]sessionResponse, len(sessions))
+	for i, s := range sessions {
+		list[i] = sessionResponse{Session: s, Current: s.ID == current}
+	}
+	return c.JSON(list)
+}
+
+// GetMySessionsHandler نشست‌های فعال کاربر جاری
+func GetMySessionsHandler(c *fiber.Ctx) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "کاربر یافت نشد"})
+	}
+	return sendSessions(c, user.ID)
+}
+
+// RevokeMySessionHandler خروج یکی از دستگاه‌های کاربر جاری
+func RevokeMySessionHandler(c *fiber.Ctx) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "کاربر یافت نشد"})
+	}
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+	if err := repositories.RevokeSession(uint(id), user.ID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "نشست یافت نشد"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در ابطال نشست"})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetUserSessionsHandler نشست‌های فعال یک کاربر (مدیریت کاربران)
+func GetUserSessionsHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+	if _, err := repositories.FindByID(uint(id)); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "کاربر یافت نشد"})
+	}
+	return sendSessions(c, uint(id))
+}
+
+// RevokeUserSessionsHandler خروج اجباری کاربر از همه دستگاه‌ها
+func RevokeUserSessionsHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+	if _, err := repositories.FindByID(uint(id)); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "کاربر یافت نشد"})
+	}
+	if err := repositories.RevokeUserSessions(uint(id), database.DB); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در ابطال نشست‌ها"})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/database"
+	"github.com/amirqodi/hgm/internal/models"
+	"github.com/amirqodi/hgm/internal/repositories"
+	"github.com/gofiber/fiber/v2"
+)
+
+type capitalEventRequest struct {
+	Type            models.CapitalEventType `json:"type"`
+	Amount          float64                 `json:"amount"`
+	Date            string                  `json:"date"`
+	MoneySourceType string                  `json:"money_source_type"`
+	BankAccountID   *uint                   `json:"bank_account_id"`
+	CashHolderID    *uint                   `json:"cash_holder_id"`
+	Notes           string                  `json:"notes"`
+}
+
+type shareChangeRequest struct {
+	Percentage    float64 `json:"percentage"`
+	EffectiveFrom string  `json:"effective_from"`
+}
+
+// ---------------- CAPITAL EVENTS ----------------
+func GetCapitalEventsHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	events, err := repositories.GetCapitalEvents(uint(id), database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در دریافت تاریخچه سرمایه"})
+	}
+	return c.JSON(events)
+}
+
+func CreateCapitalEventHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	var body capitalEventRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+
+	date := time.Now()
+	if body.Date != "" {
+		if date, _, err = parseDate(body.Date); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "فرمت تاریخ نامعتبر است"})
+		}
+	}
+
+	event, err := repositories.CreateCapitalEvent(uint(id), repositories.CapitalEventInput{
+		Type:            body.Type,
+		Amount:          body.Amount,
+		Date:            date,
+		MoneySourceType: body.MoneySourceType,
+		BankAccountID:   body.BankAccountID,
+		CashHolderID:    body.CashHolderID,
+		Notes:           body.Notes,
+	}, database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(event)
+}
+
+func DeleteCapitalEventHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	if err := repositories.DeleteCapitalEvent(uint(id), database.DB); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ---------------- SHARE PERCENTAGE HISTORY ----------------
+func GetShareHistoryHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	changes, err := repositories.GetShareHistory(uint(id), database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در دریافت تاریخچه سهام"})
+	}
+	return c.JSON(changes)
+}
+
+func CreateShareChangeHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	var body shareChangeRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+
+	effectiveFrom := time.Now()
+	if body.EffectiveFrom != "" {
+		if effectiveFrom, _, err = parseDate(body.EffectiveFrom); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "فرمت تاریخ نامعتبر است"})
+		}
+	}
+
+	change, err := repositories.RecordShareChange(uint(id), body.Percentage, effectiveFrom, "manual", database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.Status(fiber.StatusCreated).JSON(change)
+}
+
+// GetShareSplitHandler ?date=2025-03-21 درصد سهام مؤثر در یک تاریخ
+func GetShareSplitHandler(c *fiber.Ctx) error {
+	at := time.Now()
+	if dateStr := c.Query("date"); dateStr != "" {
+		var err error
+		if at, _, err = parseDate(dateStr); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "فرمت تاریخ نامعتبر است"})
+		}
+	}
+
+	splits, err := repositories.ShareSplitAt(at, database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(splits)
+}
+
+// RecomputeSharesHandler بازمحاسبه درصد سهام از روی سرمایه آورده‌شده
+func RecomputeSharesHandler(c *fiber.Ctx) error {
+	var body struct {
+		EffectiveFrom string `json:"effective_from"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+
+	effectiveFrom := time.Now()
+	if body.EffectiveFrom != "" {
+		var err error
+		if effectiveFrom, _, err = parseDate(body.EffectiveFrom); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "فرمت تاریخ نامعتبر است"})
+		}
+	}
+
+	changes, err := repositories.RecomputeSharesFromCapital(effectiveFrom, database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(changes)
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/amirqodi/hgm/internal/database"
+	"github.com/amirqodi/hgm/internal/export"
 	"github.com/amirqodi/hgm/internal/models"
 	"github.com/amirqodi/hgm/internal/repositories"
 	"github.com/gofiber/fiber/v2"
@@ -28,11 +29,19 @@ func CreateTransaction(c *fiber.Ctx) error {
 	if amount, err := strconv.ParseFloat(c.FormValue("amount"), 64); err == nil {
 		trx.Amount = amount
 	}
+	// حساب ارزی یا طلا: مقدار به واحد حساب و نرخ اختیاری (پیش‌فرض نرخ تاریخ تراکنش)
+	if foreign, err := strconv.ParseFloat(c.FormValue("foreign_amount"), 64); err == nil {
+		trx.ForeignAmount = foreign
+	}
+	if rate, err := strconv.ParseFloat(c.FormValue("rate"), 64); err == nil {
+		trx.Rate = rate
+	}
 	trx.TransactionType = c.FormValue("transaction_type")
 	trx.PaymentMethod = c.FormValue("payment_method")
 	trx.MoneySourceType = c.FormValue("money_source_type")
 	trx.Notes = c.FormValue("notes")
 	trx.IsPaid, _ = strconv.ParseBool(c.FormValue("is_paid"))
+	trx.CreditOverride, _ = strconv.ParseBool(c.FormValue("credit_override"))
 
 	// Optional IDs
 	if bankID := c.FormValue("bank_account_id"); bankID != "" {
@@ -85,6 +94,16 @@ func CreateTransaction(c *fiber.Ctx) error {
 		files = form.File["attachments"]
 	}
 
+	// -------- Credit override (manager only) --------
+	if err := checkCreditOverride(c, trx); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	// -------- Cashier: only their own cash holder --------
+	if err := checkTransactionScope(c, trx); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	}
+
 	// -------- Save transaction using repository --------
 	if err := repositories.CreateTransaction(trx, files, database.DB); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
@@ -93,6 +112,38 @@ func CreateTransaction(c *fiber.Ctx) error {
 	return c.JSON(trx)
 }
 
+// checkCreditOverride فقط مدیر اجازه نادیده گرفتن سقف اعتبار مشتری را دارد
+func checkCreditOverride(c *fiber.Ctx, trx *models.Transaction) error {
+	if !trx.CreditOverride {
+		return nil
+	}
+	user, err := currentUser(c)
+	if err != nil || !user.IsManager() {
+		return errors.New("فقط مدیر می‌تواند محدودیت اعتبار مشتری را نادیده بگیرد")
+	}
+	return nil
+}
+
+// checkTransactionScope صندوقدار فقط با تنخواه خودش تراکنش ثبت یا ویرایش می‌کند
+func checkTransactionScope(c *fiber.Ctx, trx *models.Transaction) error {
+	scope := cashHolderScope(c)
+	if scope == nil {
+		return nil
+	}
+	if trx.MoneySourceType != "cash" || !inCashHolderScope(scope, trx.CashHolderID) {
+		return errOutOfScope
+	}
+	return nil
+}
+
+// scopedTransactions تراکنش‌های قابل مشاهده برای کاربر (صندوقدار: فقط تنخواه خودش)
+func scopedTransactions(c *fiber.Ctx) *gorm.DB {
+	if scope := cashHolderScope(c); scope != nil {
+		return database.DB.Where("transactions.cash_holder_id = ?", *scope)
+	}
+	return database.DB
+}
+
 // Helper to convert uint to *uint
 func uintPtr(u uint) *uint {
 	return &u
@@ -117,8 +168,13 @@ func GetTransactions(c *fiber.Ctx) error {
 		pageSize = 10
 	}
 
+	// خروجی فایل همه تراکنش‌های فیلترشده را شامل می‌شود
+	if wantsExport(c) {
+		page, pageSize = 1, exportRowLimit
+	}
+
 	trxs, total, totalPages, err := repositories.GetTransactionsWithPagination(
-		database.DB, page, pageSize, search, startDate, endDate,
+		scopedTransactions(c), page, pageSize, search, startDate, endDate,
 	)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -126,20 +182,20 @@ func GetTransactions(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.JSON(fiber.Map{
+	return sendReport(c, fiber.Map{
 		"results":    trxs,
 		"count":      total,
 		"page":       page,
 		"page_size":  pageSize,
 		"totalPages": totalPages,
-	})
+	}, func() *export.Document { return transactionsDocument(trxs) })
 }
 
 func GetTransactionByID(c *fiber.Ctx) error {
 
 	id, _ := strconv.Atoi(c.Params("id"))
 
-	trx, err := repositories.GetTransactionByID(uint(id), database.DB)
+	trx, err := repositories.GetTransactionByID(uint(id), scopedTransactions(c))
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "transaction not found"})
@@ -159,6 +215,19 @@ func UpdateTransaction(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid request"})
 	}
 
+	if err := checkCreditOverride(c, &trx); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if cashHolderScope(c) != nil {
+		if _, err := repositories.GetTransactionByID(uint(id), scopedTransactions(c)); err != nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "transaction not found"})
+		}
+		if err := checkTransactionScope(c, &trx); err != nil {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+
 	if err := repositories.UpdateTransaction(uint(id), &trx, database.DB); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
@@ -172,6 +241,9 @@ func DeleteTransaction(c *fiber.Ctx) error {
 	id, _ := strconv.Atoi(c.Params("id"))
 
 	if err := repositories.DeleteTransaction(uint(id), database.DB); err != nil {
+		if errors.Is(err, repositories.ErrReconciliationLocked) || errors.Is(err, repositories.ErrInvoiceIssued) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
 
@@ -190,6 +262,10 @@ func PaySubTransaction(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "sub-transaction not found"})
 	}
 
+	if _, err := repositories.GetTransactionByID(sub.TransactionID, scopedTransactions(c)); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "sub-transaction not found"})
+	}
+
 	if sub.IsPaid {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "sub-transaction already paid"})
 	}
@@ -239,14 +315,18 @@ func GetUpcomingSubTransactions(c *fiber.Ctx) error {
 		limit = 10
 	}
 
+	// صندوقدار فقط اقساط تراکنش‌های تنخواه خودش را می‌بیند
+	upcoming := database.DB.Where("is_paid = ? AND due_date <= ?", false, twoDaysLater)
+	if scope := cashHolderScope(c); scope != nil {
+		upcoming = upcoming.Where("transaction_id IN (?)",
+			database.DB.Model(&models.Transaction{}).Select("id").Where("cash_holder_id = ?", *scope))
+	}
+
 	var total int64
-	database.DB.Model(&models.SubTransaction{}).
-		Where("is_paid = ? AND due_date <= ?", false, twoDaysLater).
-		Count(&total)
+	upcoming.Session(&gorm.Session{}).Model(&models.SubTransaction{}).Count(&total)
 
 	var subTransactions []models.SubTransaction
-	err = database.DB.
-		Where("is_paid = ? AND due_date <= ?", false, twoDaysLater).
+	err = upcoming.Session(&gorm.Session{}).
 		Order("due_date ASC").
 		Limit(limit).
 		Find(&subTransactions).Error
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/amirqodi/hgm/internal/models"
+	"github.com/amirqodi/hgm/internal/repositories"
+	"github.com/amirqodi/hgm/internal/services"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type twoFactorCodeRequest struct {
+	Code     string `json:"code"`     // کد شش رقمی برنامه احراز هویت یا کد بازیابی
+	Password string `json:"password"` // فقط برای غیرفعال‌سازی
+}
+
+type loginTwoFactorRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+}
+
+// isTwoFactorError خطاهای ورود دومرحله‌ای که به کاربر نمایش داده می‌شوند
+func isTwoFactorError(err error) bool {
+	return errors.Is(err, services.ErrTwoFactorNotPending) ||
+		errors.Is(err, services.ErrTwoFactorEnabled) ||
+		errors.Is(err, services.ErrTwoFactorDisabled) ||
+		errors.Is(err, services.ErrInvalidTwoFactorCode) ||
+		errors.Is(err, services.ErrTwoFactorMandatory) ||
+		errors.Is(err, services.ErrWrongPassword)
+}
+
+// LoginTwoFactorHandler مرحله دوم ورود با توکن مرحله اول و کد برنامه احراز هویت یا کد بازیابی
+func LoginTwoFactorHandler(c *fiber.Ctx) error {
+	var body loginTwoFactorRequest
+	if err := c.BodyParser(&body); err != nil || body.ChallengeToken == "" || body.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "کد ورود دومرحله‌ای الزامی است"})
+	}
+
+	user, err := services.ChallengeUser(body.ChallengeToken)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	// کد نادرست هم مثل رمز نادرست در قفل موقت شمرده می‌شود
+	client := services.LoginClient{IP: c.IP(), UserAgent: c.Get(fiber.HeaderUserAgent)}
+	wait, err := services.CheckLoginLockout(user.Username, client, services.LoginLimitsFromEnv())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در بررسی ورود"})
+	}
+	if wait > 0 {
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": lockedOut(c, wait)})
+	}
+
+	if err := services.CompleteLogin(user, body.Code, client); err != nil {
+		if errors.Is(err, services.ErrInvalidTwoFactorCode) {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در بررسی کد"})
+	}
+
+	session, refreshToken, err := repositories.CreateSession(user.ID, client.UserAgent, client.IP)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در ایجاد نشست"})
+	}
+	return sendTokens(c, user, session, refreshToken)
+}
+
+// GetTwoFactorStatusHandler وضعیت ورود دومرحله‌ای کاربر جاری
+func GetTwoFactorStatusHandler(c *fiber.Ctx) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "کاربر یافت نشد"})
+	}
+	status, err := services.GetTwoFactorStatus(user)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در دریافت وضعیت ورود دومرحله‌ای"})
+	}
+	return c.JSON(status)
+}
+
+// SetupTwoFactorHandler کلید جدید با نشانی otpauth و QR برای اسکن در برنامه احراز هویت
+func SetupTwoFactorHandler(c *fiber.Ctx) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "کاربر یافت نشد"})
+	}
+	setup, err := services.SetupTwoFactor(user)
+	if err != nil {
+		if isTwoFactorError(err) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در راه‌اندازی ورود دومرحله‌ای"})
+	}
+	return c.JSON(setup)
+}
+
+// EnableTwoFactorHandler فعال‌سازی با اولین کد؛ کدهای بازیابی یک بار برگردانده می‌شوند
+func EnableTwoFactorHandler(c *fiber.Ctx) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "کاربر یافت نشد"})
+	}
+	var body twoFactorCodeRequest
+	if err := c.BodyParser(&body); err != nil || body.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "کد الزامی است"})
+	}
+
+	codes, err := services.EnableTwoFactor(user, body.Code, currentSessionID(c))
+	if err != nil {
+		if isTwoFactorError(err) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در فعال‌سازی ورود دومرحله‌ای"})
+	}
+	return c.JSON(fiber.Map{"enabled": true, "recovery_codes": codes})
+}
+
+// DisableTwoFactorHandler غیرفعال‌سازی با رمز عبور و کد
+func DisableTwoFactorHandler(c *fiber.Ctx) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "کاربر یافت نشد"})
+	}
+	var body twoFactorCodeRequest
+	if err := c.BodyParser(&body); err != nil || body.Code == "" || body.Password == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "رمز عبور و کد الزامی است"})
+	}
+
+	if err := services.DisableTwoFactor(user, body.Password, body.Code); err != nil {
+		if isTwoFactorError(err) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در غیرفعال‌سازی ورود دومرحله‌ای"})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// RegenerateRecoveryCodesHandler کدهای بازیابی جدید؛ کدهای قبلی باطل می‌شوند
+func RegenerateRecoveryCodesHandler(c *fiber.Ctx) error {
+	user, err := currentUser(c)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "کاربر یافت نشد"})
+	}
+	var body twoFactorCodeRequest
+	if err := c.BodyParser(&body); err != nil || body.Code == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "کد الزامی است"})
+	}
+
+	codes, err := services.RegenerateRecoveryCodes(user, body.Code)
+	if err != nil {
+		if isTwoFactorError(err) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در ساخت کدهای بازیابی"})
+	}
+	return c.JSON(fiber.Map{"recovery_codes": codes})
+}
+
+// ResetUserTwoFactorHandler غیرفعال‌سازی ورود دومرحله‌ای کاربر توسط مدیر (گم شدن گوشی و کدها)
+func ResetUserTwoFactorHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+	if err := services.ResetTwoFactor(uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "کاربر یافت نشد"})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در بازنشانی ورود دومرحله‌ای"})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+type twoFactorPolicyRequest struct {
+	RequiredRoles []models.UserRole `json:"required_roles"`
+}
+
+// GetTwoFactorPolicyHandler نقش‌هایی که ورود دومرحله‌ای برایشان اجباری است
+func GetTwoFactorPolicyHandler(c *fiber.Ctx) error {
+	roles, err := repositories.TwoFactorRequiredRoles()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در دریافت تنظیمات"})
+	}
+	return c.JSON(twoFactorPolicyRequest{RequiredRoles: roles})
+}
+
+// UpdateTwoFactorPolicyHandler اجباری کردن ورود دومرحله‌ای برای نقش‌ها (مثلاً owner و accountant)؛
+// کاربران این نقش‌ها تا فعال‌سازی فقط به مسیرهای /auth دسترسی دارند
+func UpdateTwoFactorPolicyHandler(c *fiber.Ctx) error {
+	var body twoFactorPolicyRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+	if err := repositories.SetTwoFactorRequiredRoles(body.RequiredRoles); err != nil {
+		if errors.Is(err, repositories.ErrInvalidRole) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در ذخیره تنظیمات"})
+	}
+	return GetTwoFactorPolicyHandler(c)
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/amirqodi/hgm/internal/models"
+	"github.com/amirqodi/hgm/internal/repositories"
+	"github.com/amirqodi/hgm/internal/services"
+	"github.com/amirqodi/hgm/internal/utils"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+type userRequest struct {
+	Username     string          `json:"username"`
+	Password     string          `json:"password"` // در ویرایش خالی = بدون تغییر
+	Role         models.UserRole `json:"role"`
+	Active       *bool           `json:"active"`
+	CashHolderID *uint           `json:"cash_holder_id"`
+}
+
+func (r *userRequest) toModel() *models.User {
+	user := &models.User{
+		Username:     strings.TrimSpace(r.Username),
+		Role:         r.Role,
+		Active:       true,
+		CashHolderID: r.CashHolderID,
+	}
+	if r.Active != nil {
+		user.Active = *r.Active
+	}
+	return user
+}
+
+// isUserError خطاهای اعتبارسنجی کاربر که به کاربر نمایش داده می‌شوند
+func isUserError(err error) bool {
+	return errors.Is(err, repositories.ErrLastOwner) ||
+		errors.Is(err, repositories.ErrUsernameTaken) ||
+		errors.Is(err, repositories.ErrCashierNeedsHolder) ||
+		errors.Is(err, repositories.ErrInvalidRole) ||
+		errors.Is(err, repositories.ErrCashHolderMissing)
+}
+
+// GetMeHandler کاربر جاری با نقش و سطح دسترسی به هر بخش
+func GetMeHandler(c *fiber.Ctx) error {
+	user, err := currentUser(c)
+	if err != nil || !user.Active {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "کاربر یافت نشد یا غیرفعال است"})
+	}
+	return c.JSON(fiber.Map{
+		"user_id":              user.ID,
+		"username":             user.Username,
+		"role":                 user.Role,
+		"cash_holder_id":       user.CashHolderID,
+		"permissions":          models.RolePermissions[user.Role],
+		"must_change_password": user.MustChangePassword,
+	})
+}
+
+func GetUsersHandler(c *fiber.Ctx) error {
+	users, err := repositories.GetUsers()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در دریافت کاربران"})
+	}
+	return c.JSON(users)
+}
+
+func CreateUserHandler(c *fiber.Ctx) error {
+	var body userRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+	if strings.TrimSpace(body.Username) == "" || body.Password == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "نام کاربری و رمز عبور الزامی است"})
+	}
+
+	user := body.toModel()
+	if err := services.ValidatePassword(body.Password, user.Username); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	hashed, err := utils.HashPassword(body.Password)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در ذخیره رمز عبور"})
+	}
+	user.Password = hashed
+
+	if err := repositories.CreateManagedUser(user); err != nil {
+		if isUserError(err) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در ایجاد کاربر"})
+	}
+	return c.Status(fiber.StatusCreated).JSON(user)
+}
+
+func UpdateUserHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+
+	var body userRequest
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+	if strings.TrimSpace(body.Username) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "نام کاربری الزامی است"})
+	}
+
+	var hashed string
+	if body.Password != "" {
+		if err := services.ValidatePassword(body.Password, strings.TrimSpace(body.Username)); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		if hashed, err = utils.HashPassword(body.Password); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در ذخیره رمز عبور"})
+		}
+	}
+
+	user, err := repositories.UpdateManagedUser(uint(id), body.toModel(), hashed)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "کاربر یافت نشد"})
+		}
+		if isUserError(err) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در ویرایش کاربر"})
+	}
+	return c.JSON(user)
+}
+
+func DeleteUserHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+	if me, err := currentUser(c); err == nil && me.ID == uint(id) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "نمی‌توانید حساب کاربری خودتان را حذف کنید"})
+	}
+
+	if err := repositories.DeleteManagedUser(uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "کاربر یافت نشد"})
+		}
+		if isUserError(err) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در حذف کاربر"})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/amirqodi/hgm/internal/database"
+	"github.com/amirqodi/hgm/internal/models"
+	"github.com/amirqodi/hgm/internal/repositories"
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// GetWorkOrdersHandler ?status=&contact_id=
+func GetWorkOrdersHandler(c *fiber.Ctx) error {
+	contactID, _ := strconv.Atoi(c.Query("contact_id", "0"))
+	orders, err := repositories.GetWorkOrders(repositories.WorkOrderFilter{
+		Status:    models.WorkOrderStatus(c.Query("status")),
+		ContactID: uint(contactID),
+	}, database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در دریافت دستورهای کار"})
+	}
+	return c.JSON(orders)
+}
+
+func GetWorkOrderByIDHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+	wo, err := repositories.GetWorkOrderByID(uint(id), database.DB)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "دستور کار یافت نشد"})
+	}
+	return c.JSON(wo)
+}
+
+// SetWorkOrderStatusHandler done یا cancelled
+func SetWorkOrderStatusHandler(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "آیدی نامعتبر است"})
+	}
+	var body struct {
+		Status models.WorkOrderStatus `json:"status"`
+	}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "داده‌ها معتبر نیست"})
+	}
+
+	wo, err := repositories.SetWorkOrderStatus(uint(id), body.Status, database.DB)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "دستور کار یافت نشد"})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(wo)
+}
@@ -0,0 +1,61 @@
+package middlewares
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/amirqodi/hgm/internal/models"
+	"github.com/amirqodi/hgm/internal/repositories"
+)
+
+// methodAccess سطح دسترسی لازم برای هر متد HTTP
+func methodAccess(method string) models.Access {
+	switch method {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+		return models.AccessRead
+	case fiber.MethodDelete:
+		return models.AccessDelete
+	default:
+		return models.AccessWrite
+	}
+}
+
+// Authorize پس از JWTProtected؛ نقش کاربر (از دیتابیس، تا تغییر نقش فوراً اعمال شود) باید
+// به resource دسترسی متناسب با متد درخواست داشته باشد. کاربری که باید رمزش را عوض کند یا ورود
+// دومرحله‌ای اجباری را فعال نکرده فقط به مسیرهای /auth دسترسی دارد. کاربر در c.Locals("user") ذخیره می‌شود.
+func Authorize(resource models.Resource) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, ok := c.Locals("user_id").(float64)
+		if !ok {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid or expired token"})
+		}
+		user, err := repositories.FindByID(uint(id))
+		if err != nil || !user.Active {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "کاربر یافت نشد یا غیرفعال است"})
+		}
+
+		if user.MustChangePassword {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":                "ابتدا رمز عبور خود را تغییر دهید",
+				"must_change_password": true,
+			})
+		}
+		if !user.TwoFactorEnabled {
+			required, err := repositories.IsTwoFactorRequired(user.Role)
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "خطا در بررسی دسترسی"})
+			}
+			if required {
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+					"error":                     "ورود دومرحله‌ای برای نقش شما اجباری است؛ ابتدا آن را فعال کنید",
+					"two_factor_setup_required": true,
+				})
+			}
+		}
+		if !user.Can(resource, methodAccess(c.Method())) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "شما به این بخش دسترسی ندارید"})
+		}
+
+		c.Locals("user", user)
+		return c.Next()
+	}
+}
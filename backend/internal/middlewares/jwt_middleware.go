@@ -4,8 +4,8 @@ import (
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 
+	"github.com/amirqodi/hgm/internal/repositories"
 	"github.com/amirqodi/hgm/internal/utils"
 )
 
@@ -21,19 +21,23 @@ func JWTProtected() fiber.Handler {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid token format"})
 		}
 
-		token, err := jwt.Parse(parts[1], func(token *jwt.Token) (interface{}, error) {
-			return utils.JwtSecret(), nil
-		})
+		// ParseJWT متد امضا را هم بررسی می‌کند
+		claims, err := utils.ParseJWT(parts[1])
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid or expired token"})
+		}
 
-		if err != nil || !token.Valid {
+		// توکن باید به نشستی فعال تعلق داشته باشد تا خروج و ابطال نشست فوراً اعمال شود
+		userID, _ := (*claims)["user_id"].(float64)
+		sessionID, ok := (*claims)["sid"].(float64)
+		if !ok || repositories.ValidateSession(uint(sessionID), uint(userID)) != nil {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid or expired token"})
 		}
 
 		// save claims for later use
-		if claims, ok := token.Claims.(jwt.MapClaims); ok {
-			c.Locals("user_id", claims["user_id"])
-			c.Locals("username", claims["username"])
-		}
+		c.Locals("user_id", (*claims)["user_id"])
+		c.Locals("username", (*claims)["username"])
+		c.Locals("session_id", uint(sessionID))
 
 		return c.Next()
 	}
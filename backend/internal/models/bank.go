@@ -8,8 +8,22 @@ type BankAccount struct {
 	AccountNumber string  `gorm:"size:32;not null;unique" json:"account_number"`
 	CardNumber    string  `gorm:"size:16;not null;unique" json:"card_number"`
 	IBAN          string  `gorm:"size:26;not null;unique" json:"iban"`
-	Balance       float64 `gorm:"not null;default:0" json:"balance"`
+	Balance       float64 `gorm:"not null;default:0" json:"balance"` // به واحد Currency
+
+	// حساب ارزی یا طلا: BookValue ارزش دفتری مانده به واحد دفتر است
+	Currency  Currency `gorm:"size:10;not null;default:irr" json:"currency"`
+	BookValue float64  `gorm:"not null;default:0" json:"book_value"`
+
+	Warnings []string `gorm:"-" json:"warnings,omitempty"` // هشدارهای اعتبارسنجی (مثل ناهمخوانی حساب و شبا)
 
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
+
+// CarryingValue ارزش مانده به واحد دفتر
+func (b BankAccount) CarryingValue() float64 {
+	if b.Currency.IsForeign() {
+		return b.BookValue
+	}
+	return b.Balance
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+type BankChargeKind string
+
+const (
+	BankChargeFee      BankChargeKind = "fee"      // کارمزد، پیامک، آبونمان (هزینه)
+	BankChargeInterest BankChargeKind = "interest" // سود سپرده (درآمد)
+)
+
+// BankCharge کسر یا واریز مستقیم بانک که مخاطب ندارد و مستقیماً موجودی حساب را تغییر می‌دهد
+type BankCharge struct {
+	ID              uint           `gorm:"primaryKey" json:"id"`
+	BankAccountID   uint           `gorm:"index" json:"bank_account_id"`
+	BankAccount     *BankAccount   `json:"bank_account,omitempty"`
+	Kind            BankChargeKind `gorm:"size:20;index" json:"kind"`
+	CategoryID      *uint          `json:"category_id,omitempty"`
+	Category        *Category      `json:"category,omitempty"`
+	Amount          float64        `json:"amount"` // همیشه مثبت؛ جهت از Kind مشخص می‌شود
+	Date            time.Time      `gorm:"index" json:"date"`
+	Description     string         `json:"description,omitempty"`
+	StatementLineID *uint          `gorm:"index" json:"statement_line_id,omitempty"` // ایجادشده از ردیف صورتحساب
+	RuleID          *uint          `gorm:"index" json:"rule_id,omitempty"`           // ایجادشده توسط قاعده ماهانه
+	CreatedByID     *uint          `json:"created_by_id,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// BankChargeRule کارمزد یا سود ثابت ماهانه که در روز مشخصی از ماه شمسی ثبت می‌شود
+type BankChargeRule struct {
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	BankAccountID uint           `gorm:"index" json:"bank_account_id"`
+	BankAccount   *BankAccount   `json:"bank_account,omitempty"`
+	Kind          BankChargeKind `gorm:"size:20" json:"kind"`
+	CategoryID    *uint          `json:"category_id,omitempty"`
+	Amount        float64        `json:"amount"`
+	DayOfMonth    int            `json:"day_of_month"` // ۱ تا ۲۹ (روز ماه شمسی)
+	Description   string         `json:"description,omitempty"`
+	IsActive      bool           `json:"is_active"`
+	LastPosted    string         `gorm:"size:7" json:"last_posted,omitempty"` // آخرین ماه ثبت‌شده، مثل 1404/07
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,100 @@
+package models
+
+import "time"
+
+type StatementLineStatus string
+
+const (
+	StatementLineUnmatched StatementLineStatus = "unmatched" // بدون تطبیق
+	StatementLineSuggested StatementLineStatus = "suggested" // تطبیق خودکار، در انتظار تایید
+	StatementLineMatched   StatementLineStatus = "matched"   // تایید شده
+	StatementLineIgnored   StatementLineStatus = "ignored"   // نادیده گرفته شده
+)
+
+// نوع رکورد دفتری تطبیق‌داده‌شده با ردیف صورتحساب
+const (
+	MatchTransaction       = "transaction"        // تراکنش نقدی
+	MatchSubTransaction    = "sub_transaction"    // قسط پرداخت‌شده
+	MatchDeposit           = "deposit"            // ثبت ودیعه
+	MatchDepositSettlement = "deposit_settlement" // تسویه ودیعه
+	MatchBankCharge        = "bank_charge"        // کارمزد یا سود بانکی
+)
+
+// StatementColumnMapping نگاشت ستون‌های فایل خروجی هر بانک.
+// ستون‌ها با عنوان سطر سرتیتر یا شماره ستون (از ۱) مشخص می‌شوند.
+type StatementColumnMapping struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	Name      string `gorm:"size:100;not null;unique" json:"name"`
+	BankName  string `gorm:"size:100" json:"bank_name"`
+	Delimiter string `gorm:"size:1" json:"delimiter,omitempty"` // برای CSV، پیش‌فرض ","
+	SkipRows  int    `json:"skip_rows"`                         // ردیف‌های قبل از سرتیتر
+
+	DateColumn        string `json:"date_column"`
+	TimeColumn        string `json:"time_column,omitempty"`
+	DescriptionColumn string `json:"description_column,omitempty"`
+	ReferenceColumn   string `json:"reference_column,omitempty"`
+	DebitColumn       string `json:"debit_column,omitempty"`  // برداشت
+	CreditColumn      string `json:"credit_column,omitempty"` // واریز
+	AmountColumn      string `json:"amount_column,omitempty"` // مبلغ علامت‌دار (در صورت نبود بدهکار/بستانکار)
+	BalanceColumn     string `json:"balance_column,omitempty"`
+
+	IsPreset  bool      `json:"is_preset"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// BankStatement یک فایل صورتحساب واردشده برای یک حساب بانکی
+type BankStatement struct {
+	ID             uint                    `gorm:"primaryKey" json:"id"`
+	BankAccountID  uint                    `gorm:"index" json:"bank_account_id"`
+	BankAccount    *BankAccount            `json:"bank_account,omitempty"`
+	MappingID      uint                    `json:"mapping_id"`
+	Mapping        *StatementColumnMapping `json:"mapping,omitempty"`
+	FileName       string                  `json:"file_name"`
+	PeriodStart    time.Time               `json:"period_start"`
+	PeriodEnd      time.Time               `json:"period_end"`
+	ClosingBalance *float64                `json:"closing_balance,omitempty"` // مانده آخرین ردیف طبق بانک
+	LineCount      int                     `json:"line_count"`
+	SkippedLines   int                     `json:"skipped_lines"` // ردیف‌های تکراری که قبلاً وارد شده بودند
+	ImportedByID   *uint                   `json:"imported_by_id,omitempty"`
+
+	Lines []BankStatementLine `gorm:"foreignKey:StatementID;constraint:OnDelete:CASCADE" json:"lines,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BankStatementLine یک ردیف صورتحساب؛ Amount مثبت = واریز، منفی = برداشت
+type BankStatementLine struct {
+	ID            uint                `gorm:"primaryKey" json:"id"`
+	StatementID   uint                `gorm:"index" json:"statement_id"`
+	BankAccountID uint                `gorm:"index" json:"bank_account_id"`
+	RowNumber     int                 `json:"row_number"`
+	Date          time.Time           `gorm:"index" json:"date"`
+	Description   string              `json:"description,omitempty"`
+	Reference     string              `gorm:"size:64" json:"reference,omitempty"`
+	Amount        float64             `json:"amount"`
+	Balance       *float64            `json:"balance,omitempty"`
+	Fingerprint   string              `gorm:"size:64;uniqueIndex" json:"-"` // جلوگیری از ورود دوباره یک ردیف
+	Status        StatementLineStatus `gorm:"size:20;index" json:"status"`
+
+	// Matched book entry
+	MatchType   string     `gorm:"size:30" json:"match_type,omitempty"`
+	MatchID     *uint      `json:"match_id,omitempty"`
+	MatchScore  float64    `json:"match_score,omitempty"`
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ReconciliationLock قفل دوره تطبیق‌شده؛ تراکنش‌های بانکی تا LockedUntil قابل ثبت، ویرایش یا حذف نیستند
+type ReconciliationLock struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	BankAccountID    uint      `gorm:"index" json:"bank_account_id"`
+	LockedUntil      time.Time `json:"locked_until"`
+	StatementBalance *float64  `json:"statement_balance,omitempty"` // مانده بانک در پایان دوره
+	LockedByID       *uint     `json:"locked_by_id,omitempty"`
+	Notes            string    `json:"notes,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
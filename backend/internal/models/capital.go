@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+type CapitalEventType string
+
+const (
+	CapitalContribution CapitalEventType = "contribution" // آورده سرمایه
+	CapitalWithdrawal   CapitalEventType = "withdrawal"   // برداشت سرمایه
+)
+
+// CapitalEvent هر آورده یا برداشت سرمایه سهامدار به‌صورت یک رکورد مستقل
+type CapitalEvent struct {
+	ID        uint             `gorm:"primaryKey" json:"id"`
+	ContactID uint             `gorm:"index" json:"contact_id"`
+	Contact   Contact          `json:"contact"`
+	Type      CapitalEventType `gorm:"size:20" json:"type"`
+	Amount    float64          `json:"amount"`
+	Date      time.Time        `json:"date"`
+
+	// Linked money movement (transaction or direct bank/cash movement)
+	TransactionID   *uint        `gorm:"index" json:"transaction_id,omitempty"`
+	MoneySourceType string       `json:"money_source_type,omitempty"` // "bank" or "cash" or "" (مانده اولیه)
+	BankAccountID   *uint        `json:"bank_account_id,omitempty"`
+	BankAccount     *BankAccount `json:"bank_account,omitempty"`
+	CashHolderID    *uint        `json:"cash_holder_id,omitempty"`
+	CashHolder      *CashHolder  `json:"cash_holder,omitempty"`
+	Notes           string       `json:"notes,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SharePercentageChange تغییر درصد سهم با تاریخ اعمال، برای استفاده در تقسیم سود دوره‌های گذشته
+type SharePercentageChange struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	ContactID     uint      `gorm:"index" json:"contact_id"`
+	Percentage    float64   `json:"percentage"`
+	EffectiveFrom time.Time `gorm:"index" json:"effective_from"`
+	Reason        string    `json:"reason,omitempty"` // manual, recompute, migration
+
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+type CashUnit string
+
+const (
+	CashUnitRial  CashUnit = "rial"
+	CashUnitToman CashUnit = "toman"
+)
+
+type CashCountStatus string
+
+const (
+	CashCountDraft  CashCountStatus = "draft"  // در حال شمارش
+	CashCountPosted CashCountStatus = "posted" // نهایی؛ کسری/اضافه ثبت شده
+)
+
+// RialDenominations ایران‌چک، اسکناس و سکه‌های رایج به ریال (در شمارش تومانی بر ۱۰ تقسیم می‌شوند)
+var RialDenominations = []float64{1000000, 500000, 100000, 50000, 20000, 10000, 5000, 2000, 1000, 500, 250}
+
+// CashCount یک جلسه شمارش صندوق تنخواه‌دار؛ مبالغ Counted/System/Difference به واحد دفتر هستند
+type CashCount struct {
+	ID           uint            `gorm:"primaryKey" json:"id"`
+	CashHolderID uint            `gorm:"index" json:"cash_holder_id"`
+	CashHolder   *CashHolder     `json:"cash_holder,omitempty"`
+	Unit         CashUnit        `gorm:"size:10" json:"unit"` // واحد اسکناس‌های شمرده‌شده
+	Status       CashCountStatus `gorm:"size:10;index" json:"status"`
+	CountedAt    time.Time       `json:"counted_at"`
+	CountedByID  *uint           `json:"counted_by_id,omitempty"`
+	Notes        string          `json:"notes,omitempty"`
+
+	Items []CashCountItem `gorm:"foreignKey:CashCountID;constraint:OnDelete:CASCADE" json:"items,omitempty"`
+
+	CountedTotal  float64    `json:"counted_total"`
+	SystemBalance float64    `json:"system_balance"` // موجودی دفتری هنگام نهایی‌سازی
+	Difference    float64    `json:"difference"`     // مثبت = اضافه صندوق، منفی = کسری صندوق
+	PostedAt      *time.Time `json:"posted_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CashCountItem تعداد هر اسکناس یا سکه؛ Denomination به واحد Unit شمارش است
+type CashCountItem struct {
+	ID           uint    `gorm:"primaryKey" json:"id"`
+	CashCountID  uint    `gorm:"index" json:"cash_count_id"`
+	Denomination float64 `json:"denomination"`
+	Quantity     int     `json:"quantity"`
+	Total        float64 `json:"total"`
+}
+
+// CashHandover تحویل صندوق از یک تنخواه‌دار به دیگری؛ رکورد امضاشده و غیرقابل ویرایش است
+type CashHandover struct {
+	ID               uint        `gorm:"primaryKey" json:"id"`
+	FromCashHolderID uint        `gorm:"index" json:"from_cash_holder_id"`
+	FromCashHolder   *CashHolder `json:"from_cash_holder,omitempty"`
+	ToCashHolderID   uint        `gorm:"index" json:"to_cash_holder_id"`
+	ToCashHolder     *CashHolder `json:"to_cash_holder,omitempty"`
+	CashCountID      *uint       `json:"cash_count_id,omitempty"` // شمارش تحویل‌دهنده هنگام تحویل
+	Amount           float64     `json:"amount"`
+	HandedOverAt     time.Time   `json:"handed_over_at"`
+	RecordedByID     *uint       `json:"recorded_by_id,omitempty"`
+	Notes            string      `json:"notes,omitempty"`
+	Signature        string      `gorm:"size:64" json:"signature"` // HMAC-SHA256 فیلدهای بالا
+
+	CreatedAt time.Time `json:"created_at"`
+}
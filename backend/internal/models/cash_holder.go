@@ -7,8 +7,20 @@ type CashHolder struct {
 	FirstName   string  `gorm:"size:100;not null" json:"first_name"`
 	LastName    string  `gorm:"size:100;not null" json:"last_name"`
 	PhoneNumber string  `gorm:"size:11;not null;unique" json:"phone_number"`
-	Balance     float64 `gorm:"not null;default:0" json:"balance"`
+	Balance     float64 `gorm:"not null;default:0" json:"balance"` // به واحد Currency
+
+	// تنخواه ارزی یا طلا: BookValue ارزش دفتری مانده به واحد دفتر است
+	Currency  Currency `gorm:"size:10;not null;default:irr" json:"currency"`
+	BookValue float64  `gorm:"not null;default:0" json:"book_value"`
 
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
+
+// CarryingValue ارزش مانده به واحد دفتر
+func (h CashHolder) CarryingValue() float64 {
+	if h.Currency.IsForeign() {
+		return h.BookValue
+	}
+	return h.Balance
+}
@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type ContactType string
 
@@ -10,24 +14,128 @@ const (
 	Vendor      ContactType = "vendor"
 )
 
+// CreditPolicy رفتار سیستم هنگام عبور مشتری از سقف اعتبار یا داشتن قسط معوق
+type CreditPolicy string
+
+const (
+	CreditPolicyWarn  CreditPolicy = "warn"  // فقط هشدار
+	CreditPolicyBlock CreditPolicy = "block" // جلوگیری از ثبت تراکنش
+)
+
+// Contact یک شخص یا شرکت؛ می‌تواند هم‌زمان چند نقش (سهامدار، مشتری، فروشنده) داشته باشد.
+// نقش‌ها از روی وجود رکورد نقش (Shareholder/Customer/Vendor) مشخص می‌شوند.
 type Contact struct {
-	ID          uint        `gorm:"primaryKey" json:"id"`
-	FirstName   string      `json:"first_name"`
-	LastName    string      `json:"last_name"`
-	PhoneNumber string      `json:"phone_number"`
-	Type        ContactType `json:"type"`
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	FirstName    string `json:"first_name"`
+	LastName     string `json:"last_name"`
+	PhoneNumber  string `json:"phone_number"`                           // شماره اصلی
+	NationalID   string `gorm:"size:11" json:"national_id,omitempty"`   // کد ملی (۱۰ رقم) یا شناسه ملی شرکت (۱۱ رقم)
+	EconomicCode string `gorm:"size:14" json:"economic_code,omitempty"` // کد اقتصادی برای صورتحساب مالیاتی
+
+	// Role sub-records
+	Shareholder *ShareholderProfile `gorm:"constraint:OnDelete:CASCADE" json:"shareholder,omitempty"`
+	Customer    *CustomerProfile    `gorm:"constraint:OnDelete:CASCADE" json:"customer,omitempty"`
+	Vendor      *VendorProfile      `gorm:"constraint:OnDelete:CASCADE" json:"vendor,omitempty"`
+	Roles       []ContactType       `gorm:"-" json:"roles,omitempty"`
 
-	// Shareholder fields
-	SharePercentage *float64 `json:"share_percentage,omitempty"`
-	Amount          *float64 `json:"amount,omitempty"`
+	Phones    []ContactPhone   `gorm:"constraint:OnDelete:CASCADE" json:"phones,omitempty"`
+	Addresses []ContactAddress `gorm:"constraint:OnDelete:CASCADE" json:"addresses,omitempty"`
+	Tags      []Tag            `gorm:"many2many:contact_tags" json:"tags,omitempty"`
 
-	// Customer fields
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// ShareholderProfile اطلاعات نقش سهامدار
+type ShareholderProfile struct {
+	ID              uint    `gorm:"primaryKey" json:"-"`
+	ContactID       uint    `gorm:"uniqueIndex" json:"-"`
+	SharePercentage float64 `json:"share_percentage"`
+	Amount          float64 `json:"amount"` // سرمایه خالص (از روی رویدادهای سرمایه محاسبه می‌شود)
+}
+
+// CustomerProfile اطلاعات نقش مشتری (خودرو و سیاست اعتبار)
+type CustomerProfile struct {
+	ID           uint    `gorm:"primaryKey" json:"-"`
+	ContactID    uint    `gorm:"uniqueIndex" json:"-"`
 	CarType      *string `json:"car_type,omitempty"`
 	CarKilometer *int    `json:"car_kilometer,omitempty"`
 
-	// Vendor field
-	Address *string `json:"address,omitempty"`
+	// Credit policy (optional)
+	CreditLimit    *float64     `json:"credit_limit,omitempty"`
+	MaxOverdueDays *int         `json:"max_overdue_days,omitempty"`
+	CreditPolicy   CreditPolicy `json:"credit_policy,omitempty"` // "warn" or "block" (default)
+}
 
-	CreatedAt time.Time
-	UpdatedAt time.Time
+// VendorProfile اطلاعات نقش فروشنده
+type VendorProfile struct {
+	ID        uint   `gorm:"primaryKey" json:"-"`
+	ContactID uint   `gorm:"uniqueIndex" json:"-"`
+	Address   string `json:"address"`
+}
+
+type ContactPhone struct {
+	ID        uint   `gorm:"primaryKey" json:"id"`
+	ContactID uint   `gorm:"index" json:"-"`
+	Number    string `json:"number"`
+	Label     string `json:"label,omitempty"` // mobile, home, work, ...
+}
+
+type ContactAddress struct {
+	ID         uint   `gorm:"primaryKey" json:"id"`
+	ContactID  uint   `gorm:"index" json:"-"`
+	Label      string `json:"label,omitempty"`
+	Address    string `json:"address"`
+	City       string `json:"city,omitempty"`
+	PostalCode string `gorm:"size:10" json:"postal_code,omitempty"`
+}
+
+type Tag struct {
+	ID   uint   `gorm:"primaryKey" json:"id"`
+	Name string `gorm:"size:50;not null;unique" json:"name"`
+}
+
+// HasRole بررسی نقش مخاطب (نیازمند preload رکوردهای نقش)
+func (c *Contact) HasRole(role ContactType) bool {
+	switch role {
+	case Shareholder:
+		return c.Shareholder != nil
+	case Customer:
+		return c.Customer != nil
+	case Vendor:
+		return c.Vendor != nil
+	}
+	return false
+}
+
+func (c *Contact) syncRoles() {
+	c.Roles = nil
+	for _, role := range []ContactType{Shareholder, Customer, Vendor} {
+		if c.HasRole(role) {
+			c.Roles = append(c.Roles, role)
+		}
+	}
+}
+
+func (c *Contact) AfterFind(tx *gorm.DB) error {
+	c.syncRoles()
+	return nil
+}
+
+func (c *Contact) AfterSave(tx *gorm.DB) error {
+	c.syncRoles()
+	return nil
+}
+
+// ContactMerge سابقه ادغام مخاطب تکراری در مخاطب اصلی (برای حسابرسی)
+type ContactMerge struct {
+	ID                uint   `gorm:"primaryKey" json:"id"`
+	SurvivorID        uint   `gorm:"index" json:"survivor_id"`
+	DuplicateID       uint   `json:"duplicate_id"`
+	DuplicateSnapshot string `gorm:"type:text" json:"duplicate_snapshot"` // JSON مخاطب حذف‌شده
+	MovedTransactions int64  `json:"moved_transactions"`
+	MovedDeposits     int64  `json:"moved_deposits"`
+	MergedByUserID    *uint  `json:"merged_by_user_id,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
 }
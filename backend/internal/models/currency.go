@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// Currency واحد پول یا کالای حساب بانکی و تنخواه
+type Currency string
+
+const (
+	CurrencyRial   Currency = "irr"    // واحد دفتر (ریال یا تومان مطابق BOOK_UNIT)
+	CurrencyUSD    Currency = "usd"    // دلار
+	CurrencyTether Currency = "tether" // تتر
+	CurrencyGold18 Currency = "gold18" // گرم طلای ۱۸ عیار
+	CurrencyCoin   Currency = "coin"   // سکه امامی (عدد)
+)
+
+// Currencies ارزها و کالاهای قابل نگهداری؛ نرخ آن‌ها از جدول Price خوانده می‌شود
+var Currencies = []Currency{CurrencyRial, CurrencyUSD, CurrencyTether, CurrencyGold18, CurrencyCoin}
+
+func (c Currency) Valid() bool {
+	for _, v := range Currencies {
+		if c == v {
+			return true
+		}
+	}
+	return false
+}
+
+// IsForeign مانده به واحد دیگری غیر از واحد دفتر نگهداری می‌شود
+func (c Currency) IsForeign() bool {
+	return c != "" && c != CurrencyRial
+}
+
+// Revaluation تسعیر پایان دوره یک حساب ارزی یا طلا؛ ارزش دفتری به نرخ روز می‌رسد
+// و اختلاف به‌عنوان سود/زیان تحقق‌نیافته در سود و زیان دوره ثبت می‌شود
+type Revaluation struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	Date            time.Time `gorm:"index" json:"date"`
+	MoneySourceType string    `gorm:"size:10" json:"money_source_type"` // "bank" or "cash"
+	BankAccountID   *uint     `gorm:"index" json:"bank_account_id,omitempty"`
+	CashHolderID    *uint     `gorm:"index" json:"cash_holder_id,omitempty"`
+	Currency        Currency  `gorm:"size:10" json:"currency"`
+	Balance         float64   `json:"balance"`           // مانده به واحد ارز
+	Rate            float64   `json:"rate"`              // نرخ هر واحد به واحد دفتر
+	BookValueBefore float64   `json:"book_value_before"` // ارزش دفتری پیش از تسعیر
+	BookValueAfter  float64   `json:"book_value_after"`  // مانده × نرخ
+	Gain            float64   `json:"gain"`              // مثبت = سود، منفی = زیان
+	CreatedByID     *uint     `json:"created_by_id,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -27,6 +27,8 @@ type Deposit struct {
 	Amount float64     `json:"amount"`
 	Notes  string      `json:"notes,omitempty"`
 
+	CompletedAt *time.Time `json:"completed_at,omitempty"` // زمان تسویه
+
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+type DividendStatus string
+
+const (
+	DividendPayable DividendStatus = "payable" // سود تقسیم‌شده پرداخت‌نشده
+	DividendPaid    DividendStatus = "paid"
+)
+
+// ProfitDistribution تقسیم سود خالص یک دوره مالی بین سهامداران
+type ProfitDistribution struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+
+	Income            float64 `json:"income"`
+	Expense           float64 `json:"expense"`
+	NetProfit         float64 `json:"net_profit"`
+	DistributePercent float64 `json:"distribute_percent"` // درصدی از سود خالص که تقسیم می‌شود
+	DistributedAmount float64 `json:"distributed_amount"`
+	Notes             string  `json:"notes,omitempty"`
+
+	Dividends []Dividend `gorm:"foreignKey:DistributionID;constraint:OnDelete:CASCADE" json:"dividends,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Dividend سهم هر سهامدار از یک تقسیم سود
+type Dividend struct {
+	ID              uint           `gorm:"primaryKey" json:"id"`
+	DistributionID  uint           `json:"distribution_id"`
+	ContactID       uint           `json:"contact_id"`
+	Contact         Contact        `json:"contact"`
+	SharePercentage float64        `json:"share_percentage"`
+	Amount          float64        `json:"amount"`
+	Status          DividendStatus `json:"status"`
+
+	// Money source used for payment
+	MoneySourceType string       `json:"money_source_type,omitempty"` // "bank" or "cash"
+	BankAccountID   *uint        `json:"bank_account_id,omitempty"`
+	BankAccount     *BankAccount `json:"bank_account,omitempty"`
+	CashHolderID    *uint        `json:"cash_holder_id,omitempty"`
+	CashHolder      *CashHolder  `json:"cash_holder,omitempty"`
+	PaidAt          *time.Time   `json:"paid_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,70 @@
+package models
+
+import "time"
+
+type InvoiceKind string
+
+const (
+	InvoiceSales    InvoiceKind = "sales"    // فاکتور فروش
+	InvoicePurchase InvoiceKind = "purchase" // فاکتور خرید
+	InvoiceReceipt  InvoiceKind = "receipt"  // رسید دریافت یا پرداخت وجه
+)
+
+type InvoiceStatus string
+
+const (
+	InvoiceIssued InvoiceStatus = "issued"
+	InvoiceVoid   InvoiceStatus = "void" // باطل‌شده؛ شماره آن دوباره استفاده نمی‌شود
+)
+
+// DocumentSequence آخرین شماره هر نوع سند در هر سال مالی (شمسی).
+// شماره فقط داخل همان تراکنش دیتابیسی که سند را ثبت می‌کند افزایش می‌یابد، پس شماره‌ها بدون فاصله‌اند.
+type DocumentSequence struct {
+	ID         uint        `gorm:"primaryKey" json:"id"`
+	Kind       InvoiceKind `gorm:"size:20;uniqueIndex:idx_document_sequence" json:"kind"`
+	FiscalYear int         `gorm:"uniqueIndex:idx_document_sequence" json:"fiscal_year"`
+	LastNumber int         `json:"last_number"`
+}
+
+// Invoice فاکتور یا رسید چاپی صادرشده برای یک تراکنش؛ حذف نمی‌شود و فقط باطل می‌شود
+type Invoice struct {
+	ID         uint        `gorm:"primaryKey" json:"id"`
+	Kind       InvoiceKind `gorm:"size:20;index" json:"kind"`
+	FiscalYear int         `json:"fiscal_year"`
+	Sequence   int         `json:"sequence"`
+	Number     string      `gorm:"size:20;uniqueIndex" json:"number"` // مثلاً S-1404-00012
+
+	TransactionID    uint            `gorm:"index" json:"transaction_id"`
+	Transaction      *Transaction    `json:"transaction,omitempty"`
+	SubTransactionID *uint           `gorm:"index" json:"sub_transaction_id,omitempty"` // رسید یک قسط
+	SubTransaction   *SubTransaction `json:"sub_transaction,omitempty"`
+	ContactID        uint            `gorm:"index" json:"contact_id"`
+	Contact          *Contact        `json:"contact,omitempty"`
+
+	IssuedAt   time.Time     `json:"issued_at"`
+	IssuedByID *uint         `json:"issued_by_id,omitempty"`
+	Items      []InvoiceItem `gorm:"foreignKey:InvoiceID;constraint:OnDelete:CASCADE" json:"items,omitempty"`
+	Subtotal   float64       `json:"subtotal"`
+	TaxRate    float64       `json:"tax_rate"` // درصد مالیات بر ارزش افزوده
+	TaxAmount  float64       `json:"tax_amount"`
+	Total      float64       `json:"total"`
+	Notes      string        `json:"notes,omitempty"`
+
+	Status     InvoiceStatus `gorm:"size:10;index" json:"status"`
+	VoidedAt   *time.Time    `json:"voided_at,omitempty"`
+	VoidedByID *uint         `json:"voided_by_id,omitempty"`
+	VoidReason string        `json:"void_reason,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type InvoiceItem struct {
+	ID          uint    `gorm:"primaryKey" json:"id"`
+	InvoiceID   uint    `gorm:"index" json:"invoice_id"`
+	ProductID   *uint   `json:"product_id,omitempty"`
+	Description string  `json:"description"`
+	Quantity    float64 `json:"quantity"`
+	UnitPrice   float64 `json:"unit_price"` // بدون مالیات
+	Total       float64 `json:"total"`
+}
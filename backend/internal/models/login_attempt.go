@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+type LoginResult string
+
+const (
+	LoginSuccess     LoginResult = "success"
+	LoginUnknownUser LoginResult = "unknown_user" // نام کاربری وجود ندارد
+	LoginBadPassword LoginResult = "bad_password"
+	LoginInactive    LoginResult = "inactive"    // حساب غیرفعال
+	LoginLocked      LoginResult = "locked"      // تلاش در زمان قفل موقت؛ در شمارش تلاش‌های ناموفق حساب نمی‌شود
+	LoginPasswordOK  LoginResult = "password_ok" // رمز درست؛ در انتظار کد ورود دومرحله‌ای
+	LoginBadCode     LoginResult = "bad_code"    // کد ورود دومرحله‌ای یا کد بازیابی نادرست
+)
+
+// LoginAttempt سابقه ورود؛ قفل موقت بر اساس تلاش‌های ناموفق همین جدول محاسبه می‌شود
+type LoginAttempt struct {
+	ID        uint        `gorm:"primaryKey" json:"id"`
+	Username  string      `gorm:"index" json:"username"`
+	UserID    *uint       `gorm:"index" json:"user_id,omitempty"`
+	IP        string      `gorm:"size:64;index" json:"ip"`
+	UserAgent string      `json:"user_agent"`
+	Result    LoginResult `gorm:"size:20" json:"result"`
+	CreatedAt time.Time   `gorm:"index" json:"created_at"`
+}
+
+// FailedLoginResults تلاش‌های ناموفقی که در قفل موقت شمرده می‌شوند
+var FailedLoginResults = []LoginResult{LoginUnknownUser, LoginBadPassword, LoginBadCode}
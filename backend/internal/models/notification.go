@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+type NotificationKind string
+
+const (
+	NotificationPriceMove        NotificationKind = "price_move"         // تغییر نرخ بیش از آستانه PRICE_ALERTS
+	NotificationPriceFetchFailed NotificationKind = "price_fetch_failed" // دریافت خودکار نرخ ناموفق بود
+	NotificationProductPrices    NotificationKind = "product_prices"     // قیمت‌های پیشنهادی کالاها در انتظار تأیید
+)
+
+// Notification اعلان داخلی برنامه برای کاربران
+type Notification struct {
+	ID        uint             `gorm:"primaryKey" json:"id"`
+	Kind      NotificationKind `gorm:"size:30;index" json:"kind"`
+	Title     string           `json:"title"`
+	Message   string           `json:"message"`
+	ReadAt    *time.Time       `gorm:"index" json:"read_at,omitempty"`
+	CreatedAt time.Time        `json:"created_at"`
+}
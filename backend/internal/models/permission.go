@@ -0,0 +1,99 @@
+package models
+
+// Resource گروهی از مسیرهای API که با یک سطح دسترسی کنترل می‌شوند
+type Resource string
+
+const (
+	ResourceContacts      Resource = "contacts"
+	ResourceShareholders  Resource = "shareholders" // سهامداران و تقسیم سود
+	ResourceBanking       Resource = "banking"      // حساب‌ها، صورتحساب‌ها و کارمزدهای بانکی، تسعیر
+	ResourceCashHolders   Resource = "cash_holders" // تعریف تنخواه‌داران
+	ResourceCashCounts    Resource = "cash_counts"  // شمارش و تحویل صندوق
+	ResourceProducts      Resource = "products"     // کالا، خدمت و دسته‌بندی
+	ResourceTransactions  Resource = "transactions" // تراکنش، فاکتور و ودیعه
+	ResourceQuotes        Resource = "quotes"
+	ResourcePurchasing    Resource = "purchasing" // سفارش خرید، رسید و صورتحساب فروشنده
+	ResourceReports       Resource = "reports"
+	ResourcePrices        Resource = "prices"
+	ResourceNotifications Resource = "notifications"
+	ResourceUsers         Resource = "users"
+)
+
+var Resources = []Resource{
+	ResourceContacts, ResourceShareholders, ResourceBanking, ResourceCashHolders, ResourceCashCounts,
+	ResourceProducts, ResourceTransactions, ResourceQuotes, ResourcePurchasing, ResourceReports,
+	ResourcePrices, ResourceNotifications, ResourceUsers,
+}
+
+// Access سطح دسترسی؛ هر سطح سطوح پایین‌تر را هم شامل می‌شود
+type Access int
+
+const (
+	AccessNone   Access = iota
+	AccessRead          // GET
+	AccessWrite         // POST و PUT
+	AccessDelete        // DELETE
+)
+
+func (a Access) String() string {
+	switch a {
+	case AccessRead:
+		return "read"
+	case AccessWrite:
+		return "write"
+	case AccessDelete:
+		return "delete"
+	}
+	return "none"
+}
+
+func (a Access) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// fullAccess همه منابع با سطح داده‌شده
+func fullAccess(access Access) map[Resource]Access {
+	m := make(map[Resource]Access, len(Resources))
+	for _, r := range Resources {
+		m[r] = access
+	}
+	return m
+}
+
+func withAccess(base map[Resource]Access, overrides map[Resource]Access) map[Resource]Access {
+	for r, a := range overrides {
+		base[r] = a
+	}
+	return base
+}
+
+// RolePermissions سطح دسترسی هر نقش به هر منبع
+var RolePermissions = map[UserRole]map[Resource]Access{
+	RoleOwner: fullAccess(AccessDelete),
+	RoleAccountant: withAccess(fullAccess(AccessDelete), map[Resource]Access{
+		ResourceShareholders: AccessRead,
+		ResourceUsers:        AccessNone,
+	}),
+	RoleCashier: {
+		ResourceContacts:      AccessWrite,
+		ResourceCashHolders:   AccessRead,
+		ResourceCashCounts:    AccessWrite,
+		ResourceProducts:      AccessRead,
+		ResourceTransactions:  AccessWrite, // فقط تراکنش، فاکتور و ودیعه تنخواه خودش
+		ResourceQuotes:        AccessRead,
+		ResourcePrices:        AccessRead,
+		ResourceNotifications: AccessWrite,
+	},
+	RoleMechanic: {
+		ResourceContacts:      AccessWrite,
+		ResourceProducts:      AccessRead,
+		ResourceQuotes:        AccessWrite,
+		ResourcePurchasing:    AccessRead,
+		ResourcePrices:        AccessRead,
+		ResourceNotifications: AccessWrite,
+	},
+	RoleReadOnly: withAccess(fullAccess(AccessRead), map[Resource]Access{
+		ResourceUsers:         AccessNone,
+		ResourceNotifications: AccessWrite, // علامت‌گذاری اعلان به‌عنوان خوانده‌شده
+	}),
+}
@@ -3,9 +3,41 @@ package models
 import "time"
 
 type Price struct {
-	ID        uint    `gorm:"primaryKey"`
-	Dollar    float64 `gorm:"not null"`
-	Tether    float64 `gorm:"not null"`
-	Gold18    float64 `gorm:"not null"`
-	CreatedAt time.Time
+	ID          uint    `gorm:"primaryKey"`
+	Dollar      float64 `gorm:"not null"`
+	Tether      float64 `gorm:"not null"`
+	Gold18      float64 `gorm:"not null"`
+	Coin        float64 `gorm:"not null;default:0"` // سکه امامی
+	Source      string  `gorm:"size:50"`            // نام PRICE_PROVIDER یا برچسب ورود دستی (مثل نام صرافی)
+	CreatedByID *uint   // کاربر ثبت‌کننده نرخ دستی
+	CreatedAt   time.Time
+}
+
+// Rate نرخ ثبت‌شده برای ارز داده‌شده (به واحد PRICE_UNIT)؛ صفر یعنی نرخی ثبت نشده
+func (p Price) Rate(c Currency) float64 {
+	switch c {
+	case CurrencyUSD:
+		return p.Dollar
+	case CurrencyTether:
+		return p.Tether
+	case CurrencyGold18:
+		return p.Gold18
+	case CurrencyCoin:
+		return p.Coin
+	}
+	return 0
+}
+
+// SetRate مقدار نرخ ارز داده‌شده را تنظیم می‌کند
+func (p *Price) SetRate(c Currency, v float64) {
+	switch c {
+	case CurrencyUSD:
+		p.Dollar = v
+	case CurrencyTether:
+		p.Tether = v
+	case CurrencyGold18:
+		p.Gold18 = v
+	case CurrencyCoin:
+		p.Coin = v
+	}
 }
@@ -10,6 +10,51 @@ type ProductService struct {
 	BuyingPrice  *float64 `json:"buying_price,omitempty"` // optional for services
 	Stock        *int64   `json:"stock,omitempty"`        // optional for service-type products
 
+	// قاعده قیمت‌گذاری ارزی: SellingPrice = BasePrice × نرخ PriceUnit × (۱ + Markup٪)، گردشده به مضرب RoundTo.
+	// PriceUnit خالی یا irr یعنی قیمت فروش دستی است.
+	PriceUnit Currency `gorm:"size:10" json:"price_unit,omitempty"`
+	BasePrice *float64 `json:"base_price,omitempty"` // به واحد PriceUnit
+	Markup    float64  `json:"markup"`               // درصد سود روی بهای ارزی
+	RoundTo   float64  `json:"round_to,omitempty"`   // مثلاً ۱۰۰۰؛ صفر = گرد به عدد صحیح
+
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
+
+// HasPricingRule قیمت فروش از نرخ ارز یا طلا محاسبه می‌شود
+func (p *ProductService) HasPricingRule() bool {
+	return p.PriceUnit.IsForeign() && p.BasePrice != nil && *p.BasePrice > 0
+}
+
+type PriceChangeStatus string
+
+const (
+	PriceChangePending    PriceChangeStatus = "pending"    // در انتظار تأیید
+	PriceChangeApproved   PriceChangeStatus = "approved"   // اعمال‌شده
+	PriceChangeRejected   PriceChangeStatus = "rejected"   // ردشده
+	PriceChangeSuperseded PriceChangeStatus = "superseded" // با پیشنهاد نرخ جدیدتر جایگزین شد
+)
+
+type PriceChangeReason string
+
+const (
+	PriceChangeRate   PriceChangeReason = "rate"   // ثبت نرخ جدید ارز یا طلا
+	PriceChangeManual PriceChangeReason = "manual" // ویرایش محصول یا قاعده قیمت‌گذاری
+)
+
+// ProductPriceChange پیشنهاد یا سابقه تغییر قیمت فروش یک محصول
+type ProductPriceChange struct {
+	ID               uint              `gorm:"primaryKey" json:"id"`
+	ProductServiceID uint              `gorm:"index" json:"product_service_id"`
+	ProductService   *ProductService   `json:"product_service,omitempty"`
+	Reason           PriceChangeReason `gorm:"size:20" json:"reason"`
+	Status           PriceChangeStatus `gorm:"size:20;index" json:"status"`
+	PriceID          *uint             `json:"price_id,omitempty"` // ردیف نرخی که پیشنهاد از آن ساخته شد
+	PriceUnit        Currency          `gorm:"size:10" json:"price_unit,omitempty"`
+	Rate             float64           `json:"rate,omitempty"` // نرخ هر واحد به واحد دفتر
+	OldPrice         float64           `json:"old_price"`
+	NewPrice         float64           `json:"new_price"`
+	ReviewedByID     *uint             `json:"reviewed_by_id,omitempty"`
+	ReviewedAt       *time.Time        `json:"reviewed_at,omitempty"`
+	CreatedAt        time.Time         `json:"created_at"`
+}
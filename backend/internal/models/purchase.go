@@ -0,0 +1,132 @@
+package models
+
+import "time"
+
+type PurchaseOrderStatus string
+
+const (
+	PODraft             PurchaseOrderStatus = "draft"
+	POOrdered           PurchaseOrderStatus = "ordered"            // برای فروشنده ارسال شده
+	POPartiallyReceived PurchaseOrderStatus = "partially_received" // بخشی از اقلام رسیده
+	POReceived          PurchaseOrderStatus = "received"           // همه اقلام رسیده
+	POClosed            PurchaseOrderStatus = "closed"             // باقی‌مانده دیگر انتظار نمی‌رود
+	POCancelled         PurchaseOrderStatus = "cancelled"
+)
+
+// انواع سند خرید در DocumentSequence
+const (
+	DocumentPurchaseOrder InvoiceKind = "purchase_order"
+	DocumentGoodsReceipt  InvoiceKind = "goods_receipt"
+)
+
+// PurchaseOrder سفارش خرید به فروشنده؛ موجودی کالا فقط با ثبت رسید انبار (GoodsReceipt) افزایش می‌یابد
+type PurchaseOrder struct {
+	ID         uint   `gorm:"primaryKey" json:"id"`
+	FiscalYear int    `json:"fiscal_year"`
+	Sequence   int    `json:"sequence"`
+	Number     string `gorm:"size:20;uniqueIndex" json:"number"` // مثلاً PO-1404-00003
+
+	ContactID uint     `gorm:"index" json:"contact_id"` // فروشنده
+	Contact   *Contact `json:"contact,omitempty"`
+
+	OrderDate    time.Time           `json:"order_date"`
+	ExpectedDate *time.Time          `json:"expected_date,omitempty"` // تاریخ پیش‌بینی رسیدن کالا
+	Status       PurchaseOrderStatus `gorm:"size:20;index" json:"status"`
+	Items        []PurchaseOrderItem `gorm:"foreignKey:PurchaseOrderID;constraint:OnDelete:CASCADE" json:"items,omitempty"`
+	Subtotal     float64             `json:"subtotal"`
+	TaxRate      float64             `json:"tax_rate"`
+	TaxAmount    float64             `json:"tax_amount"`
+	Total        float64             `json:"total"`
+	Notes        string              `json:"notes,omitempty"`
+
+	CreatedByID *uint      `json:"created_by_id,omitempty"`
+	OrderedAt   *time.Time `json:"ordered_at,omitempty"`
+	ClosedAt    *time.Time `json:"closed_at,omitempty"`
+
+	Receipts []GoodsReceipt `gorm:"foreignKey:PurchaseOrderID" json:"receipts,omitempty"`
+	Bills    []VendorBill   `gorm:"foreignKey:PurchaseOrderID" json:"bills,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type PurchaseOrderItem struct {
+	ID              uint            `gorm:"primaryKey" json:"id"`
+	PurchaseOrderID uint            `gorm:"index" json:"purchase_order_id"`
+	ProductID       uint            `json:"product_service_id"`
+	Product         *ProductService `json:"product,omitempty"`
+	Description     string          `json:"description"`
+	Quantity        int64           `json:"quantity"`
+	UnitPrice       float64         `json:"unit_price"` // بدون مالیات
+	Total           float64         `json:"total"`
+	ExpectedDate    *time.Time      `json:"expected_date,omitempty"` // خالی = تاریخ سفارش
+
+	ReceivedQty int64 `json:"received_qty"` // جمع رسیدهای انبار
+	BilledQty   int64 `json:"billed_qty"`   // جمع صورتحساب‌های فروشنده
+}
+
+// GoodsReceipt رسید انبار؛ ثبت آن موجودی کالا را افزایش می‌دهد
+type GoodsReceipt struct {
+	ID              uint               `gorm:"primaryKey" json:"id"`
+	FiscalYear      int                `json:"fiscal_year"`
+	Sequence        int                `json:"sequence"`
+	Number          string             `gorm:"size:20;uniqueIndex" json:"number"` // مثلاً GR-1404-00010
+	PurchaseOrderID uint               `gorm:"index" json:"purchase_order_id"`
+	ReceivedAt      time.Time          `json:"received_at"`
+	ReceivedByID    *uint              `json:"received_by_id,omitempty"`
+	Notes           string             `json:"notes,omitempty"`
+	Items           []GoodsReceiptItem `gorm:"foreignKey:GoodsReceiptID;constraint:OnDelete:CASCADE" json:"items"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type GoodsReceiptItem struct {
+	ID                  uint  `gorm:"primaryKey" json:"id"`
+	GoodsReceiptID      uint  `gorm:"index" json:"goods_receipt_id"`
+	PurchaseOrderItemID uint  `gorm:"index" json:"purchase_order_item_id"`
+	ProductID           uint  `json:"product_service_id"`
+	Quantity            int64 `json:"quantity"`
+}
+
+type BillMatchStatus string
+
+const (
+	BillMatched   BillMatchStatus = "matched"   // تعداد و قیمت با سفارش و رسید انبار می‌خواند
+	BillException BillMatchStatus = "exception" // مغایرت؛ ثبت فقط با تایید مدیر
+)
+
+// VendorBill صورتحساب فروشنده که با سفارش خرید و رسید انبار تطبیق سه‌طرفه داده می‌شود.
+// با ثبت (Post) یک تراکنش هزینه بدون کالا ساخته می‌شود تا موجودی دوباره اضافه نشود.
+type VendorBill struct {
+	ID              uint             `gorm:"primaryKey" json:"id"`
+	PurchaseOrderID uint             `gorm:"index" json:"purchase_order_id"`
+	ContactID       uint             `gorm:"index" json:"contact_id"`
+	BillNumber      string           `gorm:"size:50" json:"bill_number"` // شماره فاکتور فروشنده
+	BillDate        time.Time        `json:"bill_date"`
+	Items           []VendorBillItem `gorm:"foreignKey:VendorBillID;constraint:OnDelete:CASCADE" json:"items"`
+	Subtotal        float64          `json:"subtotal"`
+	TaxAmount       float64          `json:"tax_amount"`
+	Total           float64          `json:"total"`
+	Notes           string           `json:"notes,omitempty"`
+
+	MatchStatus BillMatchStatus `gorm:"size:20" json:"match_status"`
+	MatchIssues []string        `gorm:"serializer:json" json:"match_issues,omitempty"`
+
+	TransactionID *uint        `gorm:"index" json:"transaction_id,omitempty"` // تراکنش هزینه پس از ثبت
+	Transaction   *Transaction `json:"transaction,omitempty"`
+	PostedAt      *time.Time   `json:"posted_at,omitempty"`
+	PostedByID    *uint        `json:"posted_by_id,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type VendorBillItem struct {
+	ID                  uint    `gorm:"primaryKey" json:"id"`
+	VendorBillID        uint    `gorm:"index" json:"vendor_bill_id"`
+	PurchaseOrderItemID uint    `json:"purchase_order_item_id"`
+	Description         string  `json:"description"`
+	Quantity            int64   `json:"quantity"`
+	UnitPrice           float64 `json:"unit_price"`
+	Total               float64 `json:"total"`
+}
@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+type QuoteStatus string
+
+const (
+	QuoteDraft    QuoteStatus = "draft"
+	QuoteSent     QuoteStatus = "sent"     // برای مشتری ارسال شده
+	QuoteAccepted QuoteStatus = "accepted" // مشتری پذیرفته
+	QuoteRejected QuoteStatus = "rejected"
+	QuoteExpired  QuoteStatus = "expired" // تاریخ اعتبار گذشته و پاسخی نگرفته
+)
+
+// DocumentQuote نوع سند پیش‌فاکتور در DocumentSequence (شماره‌ها جدا از فاکتورها)
+const DocumentQuote InvoiceKind = "quote"
+
+// Quote پیش‌فاکتور (برآورد هزینه تعمیر) که بعد از پذیرش به تراکنش یا فاکتور فروش تبدیل می‌شود
+type Quote struct {
+	ID         uint   `gorm:"primaryKey" json:"id"`
+	FiscalYear int    `json:"fiscal_year"`
+	Sequence   int    `json:"sequence"`
+	Number     string `gorm:"size:20;uniqueIndex" json:"number"` // مثلاً Q-1404-00007
+
+	ContactID uint     `gorm:"index" json:"contact_id"`
+	Contact   *Contact `json:"contact,omitempty"`
+
+	IssuedAt   time.Time   `json:"issued_at"`
+	ValidUntil time.Time   `json:"valid_until"`
+	Status     QuoteStatus `gorm:"size:10;index" json:"status"`
+	Items      []QuoteItem `gorm:"foreignKey:QuoteID;constraint:OnDelete:CASCADE" json:"items,omitempty"`
+	Subtotal   float64     `json:"subtotal"`
+	TaxRate    float64     `json:"tax_rate"` // درصد مالیات بر ارزش افزوده
+	TaxAmount  float64     `json:"tax_amount"`
+	Total      float64     `json:"total"`
+	Notes      string      `json:"notes,omitempty"`
+
+	CreatedByID *uint      `json:"created_by_id,omitempty"`
+	SentAt      *time.Time `json:"sent_at,omitempty"`
+	DecidedAt   *time.Time `json:"decided_at,omitempty"` // زمان پذیرش یا رد
+
+	// تبدیل: تراکنش‌های ساخته‌شده (هر کالای انباری یک تراکنش و بقیه اقلام یک تراکنش) و در صورت
+	// درخواست فاکتور فروش هر کدام؛ TransactionID و InvoiceID اولین آن‌ها هستند
+	ConvertedAt   *time.Time    `json:"converted_at,omitempty"`
+	TransactionID *uint         `gorm:"index" json:"transaction_id,omitempty"`
+	Transaction   *Transaction  `json:"transaction,omitempty"`
+	Transactions  []Transaction `gorm:"foreignKey:QuoteID" json:"transactions,omitempty"`
+	InvoiceID     *uint         `json:"invoice_id,omitempty"`
+	Invoice       *Invoice      `json:"invoice,omitempty"`
+
+	// دستور کار باز شده برای انجام کار (پیش از تبدیل به تراکنش)
+	WorkOrderID *uint      `json:"work_order_id,omitempty"`
+	WorkOrder   *WorkOrder `json:"work_order,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type QuoteItem struct {
+	ID          uint            `gorm:"primaryKey" json:"id"`
+	QuoteID     uint            `gorm:"index" json:"quote_id"`
+	ProductID   *uint           `json:"product_service_id,omitempty"`
+	Product     *ProductService `json:"product,omitempty"`
+	Description string          `json:"description"`
+	Quantity    float64         `json:"quantity"`
+	UnitPrice   float64         `json:"unit_price"` // بدون مالیات
+	Total       float64         `json:"total"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// RecoveryCode کد یک‌بارمصرف بازیابی ورود دومرحله‌ای (وقتی برنامه احراز هویت در دسترس نیست)؛
+// فقط هش کد ذخیره می‌شود
+type RecoveryCode struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"`
+	CodeHash  string     `gorm:"size:64;not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
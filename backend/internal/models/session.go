@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Session نشست ورود یک دستگاه؛ توکن تازه‌سازی فقط به شکل هش ذخیره می‌شود و با هر
+// تازه‌سازی عوض می‌شود. ابطال نشست، توکن‌های دسترسی صادرشده برای آن را هم باطل می‌کند.
+type Session struct {
+	ID     uint `gorm:"primaryKey" json:"id"`
+	UserID uint `gorm:"index;not null" json:"user_id"`
+	// TokenHash هش sha256 توکن تازه‌سازی فعلی
+	TokenHash string `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	// PreviousHash هش توکن قبلی؛ استفاده دوباره از آن یعنی توکن دزدیده شده و نشست باطل می‌شود
+	PreviousHash string     `gorm:"size:64;index" json:"-"`
+	UserAgent    string     `json:"user_agent"`
+	IP           string     `gorm:"size:64" json:"ip"`
+	CreatedAt    time.Time  `json:"created_at"`
+	LastUsedAt   time.Time  `json:"last_used_at"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	RevokedAt    *time.Time `gorm:"index" json:"revoked_at,omitempty"`
+}
+
+// Active نشست باطل نشده و منقضی نشده است
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
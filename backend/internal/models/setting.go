@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// کلیدهای تنظیمات برنامه که از طریق API تغییر می‌کنند
+const (
+	SettingTwoFactorRoles = "two_factor_roles" // نقش‌هایی که ورود دومرحله‌ای برایشان اجباری است (جداشده با کاما)
+)
+
+// Setting تنظیمات کلید/مقدار برنامه
+type Setting struct {
+	Key       string    `gorm:"primaryKey;size:100" json:"key"`
+	Value     string    `json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
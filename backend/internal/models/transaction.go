@@ -12,7 +12,8 @@ type Transaction struct {
 	Category   Category        `json:"category"`
 	ProductID  *uint           `json:"product_service_id,omitempty"`
 	Product    *ProductService `json:"product,omitempty"`
-	Quantity   uint            `json:"quantity"` // تعداد محصول
+	Quantity   uint            `json:"quantity"`                        // تعداد محصول
+	QuoteID    *uint           `gorm:"index" json:"quote_id,omitempty"` // پیش‌فاکتوری که تراکنش از تبدیل آن ساخته شده
 
 	// Money source (bank or cash)
 	MoneySourceType string       `json:"money_source_type"` // "bank" or "cash"
@@ -28,6 +29,11 @@ type Transaction struct {
 	IsPaid          bool       `json:"is_paid"`
 	TransactionDate *time.Time `json:"transaction_date"`
 
+	// حساب ارزی یا طلا: Amount معادل ریالی ForeignAmount به نرخ تاریخ تراکنش است
+	Currency      Currency `gorm:"size:10" json:"currency,omitempty"`
+	ForeignAmount float64  `json:"foreign_amount,omitempty"`
+	Rate          float64  `json:"rate,omitempty"` // نرخ هر واحد به واحد دفتر
+
 	// Installment / sub-transactions
 	SubTransactions []SubTransaction `json:"sub_transactions,omitempty"`
 
@@ -35,6 +41,10 @@ type Transaction struct {
 	Notes       string                  `json:"notes,omitempty"`
 	Attachments []TransactionAttachment `json:"attachments,omitempty"`
 
+	// Credit check (not persisted)
+	CreditOverride bool     `gorm:"-" json:"credit_override,omitempty"` // فقط برای مدیر
+	Warnings       []string `gorm:"-" json:"warnings,omitempty"`
+
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -45,6 +55,7 @@ type SubTransaction struct {
 	Amount        float64    `json:"amount"`
 	DueDate       *time.Time `json:"due_date,omitempty"`
 	IsPaid        bool       `json:"is_paid"`
+	PaidAt        *time.Time `json:"paid_at,omitempty"` // زمان پرداخت قسط
 }
 
 type TransactionAttachment struct {
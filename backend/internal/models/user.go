@@ -2,10 +2,60 @@ package models
 
 import "time"
 
+type UserRole string
+
+const (
+	RoleOwner      UserRole = "owner"      // مالک: دسترسی کامل و مدیریت کاربران
+	RoleAccountant UserRole = "accountant" // حسابدار: همه امور مالی به جز کاربران و تقسیم سود
+	RoleCashier    UserRole = "cashier"    // صندوقدار: فقط تنخواه خودش، ثبت تراکنش و شمارش صندوق
+	RoleMechanic   UserRole = "mechanic"   // مکانیک: پیش‌فاکتور، مشتری و مشاهده کالا
+	RoleReadOnly   UserRole = "read_only"  // فقط مشاهده
+)
+
+// LegacyRoles نقش‌های پیش از کنترل دسترسی که در Seed به نقش‌های فعلی تبدیل می‌شوند
+var LegacyRoles = map[UserRole]UserRole{
+	"manager": RoleOwner,
+	"user":    RoleAccountant,
+}
+
+var Roles = []UserRole{RoleOwner, RoleAccountant, RoleCashier, RoleMechanic, RoleReadOnly}
+
+func (r UserRole) Valid() bool {
+	for _, v := range Roles {
+		if r == v {
+			return true
+		}
+	}
+	return false
+}
+
 type User struct {
-	ID        uint   `gorm:"primaryKey" json:"id"`
-	Username  string `gorm:"unique;not null" json:"username"`
-	Password  string `gorm:"not null" json:"-"`
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID       uint     `gorm:"primaryKey" json:"id"`
+	Username string   `gorm:"unique;not null" json:"username"`
+	Password string   `gorm:"not null" json:"-"`
+	Role     UserRole `gorm:"size:20" json:"role"`
+	Active   bool     `gorm:"not null;default:true" json:"active"`
+	// CashHolderID تنخواه صندوقدار؛ صندوقدار فقط همین تنخواه و تراکنش‌های آن را می‌بیند
+	CashHolderID *uint       `json:"cash_holder_id,omitempty"`
+	CashHolder   *CashHolder `json:"cash_holder,omitempty"`
+	// MustChangePassword پس از بازنشانی رمز توسط مدیر یا رمز پیش‌فرض؛ تا تغییر رمز فقط /auth در دسترس است
+	MustChangePassword bool       `gorm:"not null;default:false" json:"must_change_password"`
+	PasswordChangedAt  *time.Time `json:"password_changed_at,omitempty"`
+	// TOTPSecret کلید ورود دومرحله‌ای؛ پیش از فعال‌سازی، کلید در حال ثبت است
+	TOTPSecret string `json:"-"`
+	// TOTPLastStep آخرین بازه کد استفاده‌شده تا یک کد دو بار پذیرفته نشود
+	TOTPLastStep     int64 `gorm:"not null;default:0" json:"-"`
+	TwoFactorEnabled bool  `gorm:"not null;default:false" json:"two_factor_enabled"`
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+// IsManager مالک می‌تواند محدودیت‌ها (مثل سقف اعتبار مشتری) را نادیده بگیرد
+func (u *User) IsManager() bool {
+	return u.Role == RoleOwner
+}
+
+// Can سطح دسترسی نقش کاربر به منبع حداقل access باشد
+func (u *User) Can(resource Resource, access Access) bool {
+	return u.Active && RolePermissions[u.Role][resource] >= access
 }
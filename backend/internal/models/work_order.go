@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+type WorkOrderStatus string
+
+const (
+	WorkOrderOpen      WorkOrderStatus = "open" // کار در دست انجام
+	WorkOrderDone      WorkOrderStatus = "done"
+	WorkOrderCancelled WorkOrderStatus = "cancelled"
+)
+
+// DocumentWorkOrder نوع سند دستور کار در DocumentSequence
+const DocumentWorkOrder InvoiceKind = "work_order"
+
+// WorkOrder دستور کار تعمیرگاه که از پیش‌فاکتور پذیرفته‌شده ساخته می‌شود؛ اقلام و مبلغ همان اقلام
+// پیش‌فاکتور است و پس از انجام کار، پیش‌فاکتور به تراکنش یا فاکتور فروش تبدیل می‌شود
+type WorkOrder struct {
+	ID         uint   `gorm:"primaryKey" json:"id"`
+	FiscalYear int    `json:"fiscal_year"`
+	Sequence   int    `json:"sequence"`
+	Number     string `gorm:"size:20;uniqueIndex" json:"number"` // مثلاً WO-1404-00003
+
+	QuoteID   uint     `gorm:"index" json:"quote_id"`
+	Quote     *Quote   `json:"quote,omitempty"`
+	ContactID uint     `gorm:"index" json:"contact_id"`
+	Contact   *Contact `json:"contact,omitempty"`
+
+	Status      WorkOrderStatus `gorm:"size:10;index" json:"status"`
+	Notes       string          `json:"notes,omitempty"`
+	OpenedAt    time.Time       `json:"opened_at"`
+	ClosedAt    *time.Time      `json:"closed_at,omitempty"` // زمان انجام یا لغو
+	CreatedByID *uint           `json:"created_by_id,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -9,6 +9,9 @@ import (
 
 // Create
 func CreateBankAccount(account *models.BankAccount) error {
+	if err := prepareAccountCurrency(&account.Currency, account.Balance, &account.BookValue); err != nil {
+		return err
+	}
 	return database.DB.Create(account).Error
 }
 
@@ -35,12 +38,29 @@ func UpdateBankAccount(id uint, data *models.BankAccount) (models.BankAccount, e
 	if err := database.DB.First(&account, id).Error; err != nil {
 		return account, err
 	}
+	bookValue, err := updatedBookValue("bank_account_id", id, account.Currency, account.Balance, account.BookValue, data.Currency, data.Balance)
+	if err != nil {
+		return account, err
+	}
+	data.BookValue = 0 // ارزش دفتری فقط از روی مانده و تسعیر تعیین می‌شود
 	if err := database.DB.Model(&account).Updates(data).Error; err != nil {
 		return account, err
 	}
+	if err := database.DB.Model(&account).Update("book_value", bookValue).Error; err != nil {
+		return account, err
+	}
 	return account, nil
 }
 
+// BankAccountFieldTaken بررسی تکراری بودن شبا، کارت یا شماره حساب
+func BankAccountFieldTaken(column, value string, excludeID uint) bool {
+	var count int64
+	database.DB.Model(&models.BankAccount{}).
+		Where(column+" = ? AND id <> ?", value, excludeID).
+		Count(&count)
+	return count > 0
+}
+
 // Delete
 func DeleteBankAccount(id uint) error {
 	var count int64
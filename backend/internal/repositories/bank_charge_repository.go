@@ -0,0 +1,446 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/amirqodi/hgm/internal/models"
+	"github.com/amirqodi/hgm/internal/utils"
+	ptime "github.com/yaa110/go-persian-calendar"
+	"gorm.io/gorm"
+)
+
+// کلمات کلیدی شرح ردیف صورتحساب برای تشخیص خودکار کارمزد و سود
+var chargeKeywords = map[models.BankChargeKind][]string{
+	models.BankChargeFee:      {"کارمزد", "پیامک", "اس ام اس", "sms", "آبونمان", "حق اشتراک", "هزینه خدمات", "fee"},
+	models.BankChargeInterest: {"سود", "interest"},
+}
+
+// BankChargeFilter فیلترهای فهرست کارمزد و سود
+type BankChargeFilter struct {
+	BankAccountID uint
+	Kind          models.BankChargeKind
+	From, To      *time.Time
+}
+
+// chargeDelta اثر کارمزد (برداشت) یا سود (واریز) بر موجودی حساب
+func chargeDelta(kind models.BankChargeKind, amount float64) float64 {
+	if kind == models.BankChargeFee {
+		return -amount
+	}
+	return amount
+}
+
+func validateBankCharge(charge *models.BankCharge, db *gorm.DB) error {
+	if charge.Kind != models.BankChargeFee && charge.Kind != models.BankChargeInterest {
+		return errors.New("نوع باید fee یا interest باشد")
+	}
+	if charge.Amount <= 0 {
+		return errors.New("مبلغ باید بیشتر از صفر باشد")
+	}
+	if charge.Date.IsZero() {
+		return errors.New("تاریخ الزامیست")
+	}
+	if charge.CategoryID != nil {
+		if err := db.First(&models.Category{}, *charge.CategoryID).Error; err != nil {
+			return errors.New("دسته‌بندی یافت نشد")
+		}
+	}
+	return nil
+}
+
+// ---------------- CHARGES ----------------
+
+// CreateBankCharge ثبت کارمزد یا سود و اعمال آن بر موجودی حساب بانکی
+func CreateBankCharge(charge *models.BankCharge, db *gorm.DB) error {
+	if err := validateBankCharge(charge, db); err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := CheckReconciliationLock(tx, "bank", &charge.BankAccountID, charge.Date); err != nil {
+			return err
+		}
+		if err := moveMoney(tx, "bank", &charge.BankAccountID, nil, chargeDelta(charge.Kind, charge.Amount)); err != nil {
+			return err
+		}
+		charge.ID = 0
+		return tx.Create(charge).Error
+	})
+}
+
+// UpdateBankCharge ویرایش کارمزد یا سود؛ اثر قبلی برگردانده و اثر جدید اعمال می‌شود
+func UpdateBankCharge(id uint, input *models.BankCharge, db *gorm.DB) (*models.BankCharge, error) {
+	if err := validateBankCharge(input, db); err != nil {
+		return nil, err
+	}
+
+	var charge models.BankCharge
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&charge, id).Error; err != nil {
+			return err
+		}
+		if err := CheckReconciliationLock(tx, "bank", &charge.BankAccountID, charge.Date); err != nil {
+			return err
+		}
+		if err := CheckReconciliationLock(tx, "bank", &input.BankAccountID, input.Date); err != nil {
+			return err
+		}
+
+		moneyChanged := charge.BankAccountID != input.BankAccountID || charge.Kind != input.Kind || charge.Amount != input.Amount
+		if moneyChanged {
+			if err := moveMoney(tx, "bank", &charge.BankAccountID, nil, -chargeDelta(charge.Kind, charge.Amount)); err != nil {
+				return err
+			}
+			if err := moveMoney(tx, "bank", &input.BankAccountID, nil, chargeDelta(input.Kind, input.Amount)); err != nil {
+				return err
+			}
+			if err := releaseStatementMatches(tx, []string{models.MatchBankCharge}, []uint{charge.ID}); err != nil {
+				return err
+			}
+			charge.StatementLineID = nil
+		}
+
+		charge.BankAccountID = input.BankAccountID
+		charge.Kind = input.Kind
+		charge.CategoryID = input.CategoryID
+		charge.Amount = input.Amount
+		charge.Date = input.Date
+		charge.Description = input.Description
+		charge.BankAccount = nil
+		charge.Category = nil
+		return tx.Save(&charge).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &charge, nil
+}
+
+// DeleteBankCharge حذف کارمزد یا سود و برگرداندن اثر آن بر موجودی
+func DeleteBankCharge(id uint, db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var charge models.BankCharge
+		if err := tx.First(&charge, id).Error; err != nil {
+			return err
+		}
+		if err := CheckReconciliationLock(tx, "bank", &charge.BankAccountID, charge.Date); err != nil {
+			return err
+		}
+		if err := moveMoney(tx, "bank", &charge.BankAccountID, nil, -chargeDelta(charge.Kind, charge.Amount)); err != nil {
+			return err
+		}
+		if err := releaseStatementMatches(tx, []string{models.MatchBankCharge}, []uint{charge.ID}); err != nil {
+			return err
+		}
+		return tx.Delete(&charge).Error
+	})
+}
+
+func GetBankCharges(filter BankChargeFilter, db *gorm.DB) ([]models.BankCharge, error) {
+	var charges []models.BankCharge
+	query := db.Preload("BankAccount").Preload("Category").Order("date DESC, id DESC")
+	if filter.BankAccountID > 0 {
+		query = query.Where("bank_account_id = ?", filter.BankAccountID)
+	}
+	if filter.Kind != "" {
+		query = query.Where("kind = ?", filter.Kind)
+	}
+	if filter.From != nil {
+		query = query.Where("date >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("date < ?", *filter.To)
+	}
+	err := query.Find(&charges).Error
+	return charges, err
+}
+
+func GetBankChargeByID(id uint, db *gorm.DB) (*models.BankCharge, error) {
+	var charge models.BankCharge
+	if err := db.Preload("BankAccount").Preload("Category").First(&charge, id).Error; err != nil {
+		return nil, err
+	}
+	return &charge, nil
+}
+
+// SumBankCharges جمع سود (درآمد) و کارمزد (هزینه) بانکی در بازه [from, to)؛ بازه خالی یعنی همه
+func SumBankCharges(db *gorm.DB, from, to *time.Time) (interest, fees float64, err error) {
+	scope := func(kind models.BankChargeKind) *gorm.DB {
+		q := db.Model(&models.BankCharge{}).Where("kind = ?", kind)
+		if from != nil {
+			q = q.Where("date >= ?", *from)
+		}
+		if to != nil {
+			q = q.Where("date < ?", *to)
+		}
+		return q.Select("COALESCE(SUM(amount),0)")
+	}
+	if err = scope(models.BankChargeInterest).Scan(&interest).Error; err != nil {
+		return
+	}
+	err = scope(models.BankChargeFee).Scan(&fees).Error
+	return
+}
+
+// ---------------- STATEMENT ----------------
+
+// DetectChargeKind نوع کارمزد یا سود از روی شرح و جهت ردیف صورتحساب؛ خالی یعنی تشخیص داده نشد
+func DetectChargeKind(description string, amount float64) models.BankChargeKind {
+	// مقایسه کلمه به کلمه تا مثلاً نام «سودابه» سود تشخیص داده نشود
+	text := " " + strings.Join(strings.FieldsFunc(strings.ToLower(utils.NormalizePersianName(description)), isSeparator), " ") + " "
+	if strings.TrimSpace(text) == "" {
+		return ""
+	}
+
+	kind := models.BankChargeFee
+	if amount > 0 {
+		kind = models.BankChargeInterest
+	}
+	for _, keyword := range chargeKeywords[kind] {
+		if strings.Contains(text, " "+utils.NormalizePersianName(keyword)+" ") {
+			return kind
+		}
+	}
+	return ""
+}
+
+func isSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+// CreateChargeFromLine ثبت کارمزد یا سود از روی ردیف صورتحساب و تطبیق ردیف با آن
+func CreateChargeFromLine(lineID uint, charge *models.BankCharge, db *gorm.DB) (*models.BankStatementLine, error) {
+	var line models.BankStatementLine
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&line, lineID).Error; err != nil {
+			return errors.New("ردیف صورتحساب یافت نشد")
+		}
+		if line.Status == models.StatementLineMatched || line.Status == models.StatementLineIgnored {
+			return errors.New("این ردیف قبلاً تعیین وضعیت شده است")
+		}
+		return chargeFromLine(&line, charge, tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &line, nil
+}
+
+func chargeFromLine(line *models.BankStatementLine, charge *models.BankCharge, tx *gorm.DB) error {
+	if charge.Kind == "" {
+		charge.Kind = models.BankChargeFee
+		if line.Amount > 0 {
+			charge.Kind = models.BankChargeInterest
+		}
+	}
+	if chargeDelta(charge.Kind, 1)*line.Amount < 0 {
+		return errors.New("نوع کارمزد/سود با جهت ردیف صورتحساب همخوانی ندارد")
+	}
+
+	lineID := line.ID
+	charge.BankAccountID = line.BankAccountID
+	charge.Amount = math.Abs(line.Amount)
+	charge.Date = line.Date
+	charge.StatementLineID = &lineID
+	if charge.Description == "" {
+		charge.Description = strings.TrimSpace(line.Description + " " + line.Reference)
+	}
+	if err := CreateBankCharge(charge, tx); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	line.Status = models.StatementLineMatched
+	line.MatchType = models.MatchBankCharge
+	line.MatchID = &charge.ID
+	line.MatchScore = 1
+	line.ConfirmedAt = &now
+	return tx.Save(line).Error
+}
+
+// DetectStatementCharges برای ردیف‌های بدون تطبیق که شرح آن‌ها کارمزد یا سود است رکورد ثبت می‌کند.
+// ردیف‌هایی که در دوره قفل‌شده هستند یا ثبتشان خطا دارد (مثلاً موجودی ناکافی) بدون تغییر می‌مانند.
+func DetectStatementCharges(statementID uint, db *gorm.DB) (int, error) {
+	var lines []models.BankStatementLine
+	if err := db.Where("statement_id = ? AND status = ?", statementID, models.StatementLineUnmatched).
+		Order("date ASC, row_number ASC").Find(&lines).Error; err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for i := range lines {
+		line := &lines[i]
+		kind := DetectChargeKind(line.Description, line.Amount)
+		if kind == "" {
+			continue
+		}
+
+		charge := models.BankCharge{Kind: kind}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return chargeFromLine(line, &charge, tx)
+		})
+		if err != nil {
+			continue
+		}
+		created++
+	}
+	return created, nil
+}
+
+// ---------------- RULES ----------------
+
+func validateBankChargeRule(rule *models.BankChargeRule, db *gorm.DB) error {
+	if rule.Kind != models.BankChargeFee && rule.Kind != models.BankChargeInterest {
+		return errors.New("نوع باید fee یا interest باشد")
+	}
+	if rule.Amount <= 0 {
+		return errors.New("مبلغ باید بیشتر از صفر باشد")
+	}
+	// اسفند در سال‌های غیرکبیسه ۲۹ روز دارد
+	if rule.DayOfMonth < 1 || rule.DayOfMonth > 29 {
+		return errors.New("روز ماه باید بین ۱ تا ۲۹ باشد")
+	}
+	if err := db.First(&models.BankAccount{}, rule.BankAccountID).Error; err != nil {
+		return errors.New("حساب بانکی یافت نشد")
+	}
+	if rule.CategoryID != nil {
+		if err := db.First(&models.Category{}, *rule.CategoryID).Error; err != nil {
+			return errors.New("دسته‌بندی یافت نشد")
+		}
+	}
+	return nil
+}
+
+func CreateBankChargeRule(rule *models.BankChargeRule, db *gorm.DB) error {
+	if err := validateBankChargeRule(rule, db); err != nil {
+		return err
+	}
+	rule.ID = 0
+	rule.LastPosted = ""
+	return db.Create(rule).Error
+}
+
+func UpdateBankChargeRule(id uint, input *models.BankChargeRule, db *gorm.DB) (*models.BankChargeRule, error) {
+	var rule models.BankChargeRule
+	if err := db.First(&rule, id).Error; err != nil {
+		return nil, err
+	}
+	if err := validateBankChargeRule(input, db); err != nil {
+		return nil, err
+	}
+
+	rule.BankAccountID = input.BankAccountID
+	rule.Kind = input.Kind
+	rule.CategoryID = input.CategoryID
+	rule.Amount = input.Amount
+	rule.DayOfMonth = input.DayOfMonth
+	rule.Description = input.Description
+	rule.IsActive = input.IsActive
+	if err := db.Save(&rule).Error; err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// DeleteBankChargeRule حذف قاعده؛ کارمزدهای ثبت‌شده قبلی باقی می‌مانند
+func DeleteBankChargeRule(id uint, db *gorm.DB) error {
+	result := db.Delete(&models.BankChargeRule{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func GetBankChargeRules(bankAccountID uint, db *gorm.DB) ([]models.BankChargeRule, error) {
+	var rules []models.BankChargeRule
+	query := db.Preload("BankAccount").Order("id ASC")
+	if bankAccountID > 0 {
+		query = query.Where("bank_account_id = ?", bankAccountID)
+	}
+	err := query.Find(&rules).Error
+	return rules, err
+}
+
+// PostDueBankCharges کارمزدهای ماهانه‌ای که تا now سررسید شده‌اند ثبت می‌کند (ماه‌های جاافتاده هم جبران می‌شوند).
+// ماه‌های قفل‌شده بدون ثبت رد می‌شوند؛ در صورت خطای دیگر ثبت آن قاعده در اجرای بعدی دوباره امتحان می‌شود.
+func PostDueBankCharges(now time.Time, db *gorm.DB) (int, []error) {
+	var rules []models.BankChargeRule
+	if err := db.Where("is_active = ?", true).Find(&rules).Error; err != nil {
+		return 0, []error{err}
+	}
+
+	posted := 0
+	var errs []error
+	for i := range rules {
+		rule := &rules[i]
+		year, month := nextRulePeriod(rule)
+
+		for {
+			due := ptime.Date(year, ptime.Month(month), rule.DayOfMonth, 0, 0, 0, 0, time.Local).Time()
+			if due.After(now) {
+				break
+			}
+			period := fmt.Sprintf("%04d/%02d", year, month)
+
+			// ثبت کارمزد و پیشروی last_posted با هم؛ وگرنه خطای دوم به ثبت دوباره همان ماه می‌انجامد
+			created := false
+			err := db.Transaction(func(tx *gorm.DB) error {
+				// ماه ایجاد قاعده فقط اگر روز سررسید بعد از ایجاد باشد
+				if rule.LastPosted != "" || !due.Before(startOfDay(rule.CreatedAt)) {
+					charge := models.BankCharge{
+						BankAccountID: rule.BankAccountID,
+						Kind:          rule.Kind,
+						CategoryID:    rule.CategoryID,
+						Amount:        rule.Amount,
+						Date:          due,
+						Description:   rule.Description,
+						RuleID:        &rule.ID,
+					}
+					err := CreateBankCharge(&charge, tx)
+					if err != nil && !errors.Is(err, ErrReconciliationLocked) {
+						return fmt.Errorf("قاعده %d (%s): %w", rule.ID, period, err)
+					}
+					created = err == nil
+				}
+				return tx.Model(&models.BankChargeRule{}).Where("id = ?", rule.ID).Update("last_posted", period).Error
+			})
+			if err != nil {
+				errs = append(errs, err)
+				break
+			}
+			if created {
+				posted++
+			}
+			rule.LastPosted = period
+			if month++; month > 12 {
+				year, month = year+1, 1
+			}
+		}
+	}
+	return posted, errs
+}
+
+// nextRulePeriod اولین ماه شمسی که هنوز برای قاعده ثبت نشده است
+func nextRulePeriod(rule *models.BankChargeRule) (int, int) {
+	var year, month int
+	if _, err := fmt.Sscanf(rule.LastPosted, "%d/%d", &year, &month); err == nil {
+		if month++; month > 12 {
+			year, month = year+1, 1
+		}
+		return year, month
+	}
+	created := ptime.New(rule.CreatedAt)
+	return created.Year(), int(created.Month())
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,856 @@
+package repositories
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/models"
+	"gorm.io/gorm"
+)
+
+// حداقل امتیاز برای پیشنهاد خودکار تطبیق
+const autoMatchThreshold = 0.5
+
+// MatchCandidate یک رکورد دفتری که می‌تواند با ردیف صورتحساب تطبیق داده شود
+type MatchCandidate struct {
+	MatchType   string    `json:"match_type"`
+	MatchID     uint      `json:"match_id"`
+	Date        time.Time `json:"date"`
+	Amount      float64   `json:"amount"` // علامت‌دار: مثبت = واریز به حساب
+	Description string    `json:"description,omitempty"`
+	Score       float64   `json:"score"`
+}
+
+// ReconciliationSummary وضعیت تطبیق یک حساب بانکی
+type ReconciliationSummary struct {
+	BankAccountID    uint             `json:"bank_account_id"`
+	BookBalance      float64          `json:"book_balance"`
+	StatementBalance *float64         `json:"statement_balance,omitempty"`
+	StatementDate    *time.Time       `json:"statement_date,omitempty"`
+	Difference       *float64         `json:"difference,omitempty"` // مانده بانک منهای مانده دفتر
+	LockedUntil      *time.Time       `json:"locked_until,omitempty"`
+	UnmatchedLines   int64            `json:"unmatched_lines"`
+	SuggestedLines   int64            `json:"suggested_lines"`
+	UnmatchedEntries []MatchCandidate `json:"unmatched_entries"` // رکوردهای دفتری بدون ردیف صورتحساب
+}
+
+// ---------------- RECONCILIATION LOCK ----------------
+
+// ErrReconciliationLocked تلاش برای تغییر تراکنش بانکی در دوره قفل‌شده
+var ErrReconciliationLocked = errors.New("این دوره حساب بانکی تطبیق و قفل شده است و قابل تغییر نیست")
+
+// LockedUntil آخرین تاریخ قفل‌شده حساب بانکی (nil یعنی قفلی وجود ندارد)
+func LockedUntil(bankAccountID uint, db *gorm.DB) (*time.Time, error) {
+	var lock models.ReconciliationLock
+	err := db.Where("bank_account_id = ?", bankAccountID).Order("locked_until DESC").First(&lock).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &lock.LockedUntil, nil
+}
+
+// CheckReconciliationLock اگر جابجایی پول بانکی در تاریخ at در دوره قفل‌شده باشد خطا برمی‌گرداند
+func CheckReconciliationLock(db *gorm.DB, sourceType string, bankAccountID *uint, at time.Time) error {
+	if sourceType != "bank" || bankAccountID == nil {
+		return nil
+	}
+	until, err := LockedUntil(*bankAccountID, db)
+	if err != nil {
+		return err
+	}
+	if until != nil && !at.After(*until) {
+		return ErrReconciliationLocked
+	}
+	return nil
+}
+
+func transactionDate(trx *models.Transaction) time.Time {
+	if trx.TransactionDate != nil {
+		return *trx.TransactionDate
+	}
+	if !trx.CreatedAt.IsZero() {
+		return trx.CreatedAt
+	}
+	return time.Now()
+}
+
+// LockReconciliation قفل دوره تا until؛ همه ردیف‌های صورتحساب تا این تاریخ باید تطبیق یا نادیده گرفته شده باشند
+func LockReconciliation(bankAccountID uint, until time.Time, userID *uint, notes string, db *gorm.DB) (*models.ReconciliationLock, error) {
+	var lock models.ReconciliationLock
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&models.BankAccount{}, bankAccountID).Error; err != nil {
+			return errors.New("حساب بانکی یافت نشد")
+		}
+
+		current, err := LockedUntil(bankAccountID, tx)
+		if err != nil {
+			return err
+		}
+		if current != nil && !until.After(*current) {
+			return errors.New("تاریخ قفل باید بعد از آخرین قفل باشد")
+		}
+
+		var open int64
+		if err := tx.Model(&models.BankStatementLine{}).
+			Where("bank_account_id = ? AND date <= ? AND status IN ?", bankAccountID, until,
+				[]models.StatementLineStatus{models.StatementLineUnmatched, models.StatementLineSuggested}).
+			Count(&open).Error; err != nil {
+			return err
+		}
+		if open > 0 {
+			return fmt.Errorf("%d ردیف صورتحساب تا این تاریخ هنوز تایید نشده است", open)
+		}
+
+		lock = models.ReconciliationLock{
+			BankAccountID: bankAccountID,
+			LockedUntil:   until,
+			LockedByID:    userID,
+			Notes:         notes,
+		}
+
+		var last models.BankStatementLine
+		err = tx.Where("bank_account_id = ? AND date <= ? AND balance IS NOT NULL", bankAccountID, until).
+			Order("date DESC, id DESC").First(&last).Error
+		if err == nil {
+			lock.StatementBalance = last.Balance
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		return tx.Create(&lock).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &lock, nil
+}
+
+func GetReconciliationLocks(bankAccountID uint, db *gorm.DB) ([]models.ReconciliationLock, error) {
+	var locks []models.ReconciliationLock
+	err := db.Where("bank_account_id = ?", bankAccountID).Order("locked_until DESC").Find(&locks).Error
+	return locks, err
+}
+
+// ---------------- IMPORT ----------------
+
+// ImportBankStatement ذخیره ردیف‌های صورتحساب (ردیف‌های تکراری قبلی رد می‌شوند) و تطبیق خودکار.
+// با detectCharges ردیف‌های کارمزد و سود که با رکوردی تطبیق نخوردند مستقیماً ثبت می‌شوند.
+func ImportBankStatement(stmt *models.BankStatement, lines []models.BankStatementLine, toleranceDays int, detectCharges bool, db *gorm.DB) error {
+	if len(lines) == 0 {
+		return errors.New("هیچ ردیفی برای ورود وجود ندارد")
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&models.BankAccount{}, stmt.BankAccountID).Error; err != nil {
+			return errors.New("حساب بانکی یافت نشد")
+		}
+
+		// ردیف‌های کاملاً یکسان در یک فایل با شماره تکرار از هم جدا می‌شوند
+		seen := map[string]int{}
+		var fresh []models.BankStatementLine
+		for _, line := range lines {
+			key := lineKey(stmt.BankAccountID, line)
+			seen[key]++
+			fingerprint := fingerprintOf(fmt.Sprintf("%s|%d", key, seen[key]))
+
+			var exists int64
+			if err := tx.Model(&models.BankStatementLine{}).Where("fingerprint = ?", fingerprint).Count(&exists).Error; err != nil {
+				return err
+			}
+			if exists > 0 {
+				stmt.SkippedLines++
+				continue
+			}
+
+			line.ID = 0
+			line.BankAccountID = stmt.BankAccountID
+			line.Fingerprint = fingerprint
+			line.Status = models.StatementLineUnmatched
+			fresh = append(fresh, line)
+		}
+
+		if len(fresh) == 0 {
+			return errors.New("همه ردیف‌های این فایل قبلاً وارد شده‌اند")
+		}
+
+		// دوره و مانده پایانی صورتحساب
+		stmt.PeriodStart, stmt.PeriodEnd = fresh[0].Date, fresh[0].Date
+		var closing *models.BankStatementLine
+		ascending := !lines[len(lines)-1].Date.Before(lines[0].Date)
+		for i := range fresh {
+			line := &fresh[i]
+			if line.Date.Before(stmt.PeriodStart) {
+				stmt.PeriodStart = line.Date
+			}
+			if line.Date.After(stmt.PeriodEnd) {
+				stmt.PeriodEnd = line.Date
+			}
+			if line.Balance == nil {
+				continue
+			}
+			if closing == nil || line.Date.After(closing.Date) || (line.Date.Equal(closing.Date) && ascending) {
+				closing = line
+			}
+		}
+		if closing != nil {
+			stmt.ClosingBalance = closing.Balance
+		}
+		stmt.LineCount = len(fresh)
+		stmt.Lines = nil
+
+		if err := tx.Create(stmt).Error; err != nil {
+			return err
+		}
+		for i := range fresh {
+			fresh[i].StatementID = stmt.ID
+		}
+		if err := tx.CreateInBatches(&fresh, 100).Error; err != nil {
+			return err
+		}
+
+		if _, err := AutoMatchStatement(stmt.ID, toleranceDays, tx); err != nil {
+			return err
+		}
+		if detectCharges {
+			_, err := DetectStatementCharges(stmt.ID, tx)
+			return err
+		}
+		return nil
+	})
+}
+
+func lineKey(bankAccountID uint, line models.BankStatementLine) string {
+	balance := ""
+	if line.Balance != nil {
+		balance = fmt.Sprintf("%.2f", *line.Balance)
+	}
+	return fmt.Sprintf("%d|%s|%.2f|%s|%s|%s", bankAccountID, line.Date.Format(time.RFC3339),
+		line.Amount, line.Reference, strings.TrimSpace(line.Description), balance)
+}
+
+func fingerprintOf(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// ---------------- READ ----------------
+
+func GetBankStatements(bankAccountID uint, db *gorm.DB) ([]models.BankStatement, error) {
+	var statements []models.BankStatement
+	query := db.Preload("BankAccount").Preload("Mapping").Order("id DESC")
+	if bankAccountID != 0 {
+		query = query.Where("bank_account_id = ?", bankAccountID)
+	}
+	err := query.Find(&statements).Error
+	return statements, err
+}
+
+func GetBankStatementByID(id uint, db *gorm.DB) (*models.BankStatement, error) {
+	var stmt models.BankStatement
+	err := db.Preload("BankAccount").Preload("Mapping").
+		Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("date ASC, row_number ASC") }).
+		First(&stmt, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &stmt, nil
+}
+
+// DeleteBankStatement حذف صورتحساب واردشده (در دوره قفل‌شده مجاز نیست)
+func DeleteBankStatement(id uint, db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var stmt models.BankStatement
+		if err := tx.First(&stmt, id).Error; err != nil {
+			return err
+		}
+		bankID := stmt.BankAccountID
+		if err := CheckReconciliationLock(tx, "bank", &bankID, stmt.PeriodStart); err != nil {
+			return err
+		}
+		if err := tx.Where("statement_id = ?", stmt.ID).Delete(&models.BankStatementLine{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&stmt).Error
+	})
+}
+
+// ---------------- MATCHING ----------------
+
+// AutoMatchStatement برای ردیف‌های بدون تطبیق بهترین رکورد دفتری را پیشنهاد می‌دهد
+func AutoMatchStatement(statementID uint, toleranceDays int, db *gorm.DB) (int, error) {
+	var lines []models.BankStatementLine
+	if err := db.Where("statement_id = ? AND status = ?", statementID, models.StatementLineUnmatched).
+		Order("date ASC, row_number ASC").Find(&lines).Error; err != nil {
+		return 0, err
+	}
+
+	matched := 0
+	for i := range lines {
+		line := &lines[i]
+		candidates, err := FindMatchCandidates(line, toleranceDays, db)
+		if err != nil {
+			return matched, err
+		}
+		if len(candidates) == 0 || candidates[0].Score < autoMatchThreshold {
+			continue
+		}
+		// دو گزینه با امتیاز برابر: انتخاب به کاربر واگذار می‌شود
+		if len(candidates) > 1 && candidates[1].Score == candidates[0].Score {
+			continue
+		}
+
+		best := candidates[0]
+		line.Status = models.StatementLineSuggested
+		line.MatchType = best.MatchType
+		line.MatchID = &best.MatchID
+		line.MatchScore = best.Score
+		if err := db.Save(line).Error; err != nil {
+			return matched, err
+		}
+		matched++
+	}
+	return matched, nil
+}
+
+// FindMatchCandidates رکوردهای دفتری هم‌مبلغ در بازه ±toleranceDays روز که هنوز به ردیف دیگری وصل نشده‌اند
+func FindMatchCandidates(line *models.BankStatementLine, toleranceDays int, db *gorm.DB) ([]MatchCandidate, error) {
+	if toleranceDays < 0 {
+		toleranceDays = 0
+	}
+	from := line.Date.AddDate(0, 0, -toleranceDays-1)
+	to := line.Date.AddDate(0, 0, toleranceDays+1)
+
+	entries, err := bankBookEntries(line.BankAccountID, from, to, db)
+	if err != nil {
+		return nil, err
+	}
+
+	taken, err := takenMatches(line.BankAccountID, line.ID, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []MatchCandidate
+	for _, entry := range entries {
+		if taken[entry.MatchType+fmt.Sprint(entry.MatchID)] {
+			continue
+		}
+		if math.Abs(entry.Amount-line.Amount) >= 0.5 {
+			continue
+		}
+
+		days := math.Abs(dayDiff(entry.Date, line.Date))
+		if days > float64(toleranceDays) {
+			continue
+		}
+		dateScore := 1 - days/float64(toleranceDays+1)
+
+		refScore := 0.0
+		if line.Reference != "" && strings.Contains(entry.Description, line.Reference) {
+			refScore = 1
+		}
+
+		entry.Score = math.Round((0.7*dateScore+0.3*refScore)*100) / 100
+		candidates = append(candidates, entry)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	return candidates, nil
+}
+
+func dayDiff(a, b time.Time) float64 {
+	dayA := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	dayB := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return dayA.Sub(dayB).Hours() / 24
+}
+
+// takenMatches رکوردهای دفتری که قبلاً به ردیف دیگری از همین حساب وصل شده‌اند
+func takenMatches(bankAccountID, exceptLineID uint, db *gorm.DB) (map[string]bool, error) {
+	var lines []models.BankStatementLine
+	if err := db.Select("match_type", "match_id").
+		Where("bank_account_id = ? AND id <> ? AND match_id IS NOT NULL AND status IN ?", bankAccountID, exceptLineID,
+			[]models.StatementLineStatus{models.StatementLineMatched, models.StatementLineSuggested}).
+		Find(&lines).Error; err != nil {
+		return nil, err
+	}
+
+	taken := make(map[string]bool, len(lines))
+	for _, l := range lines {
+		taken[l.MatchType+fmt.Sprint(*l.MatchID)] = true
+	}
+	return taken, nil
+}
+
+// bankBookEntries همه جابجایی‌های ثبت‌شده در دفتر برای حساب بانکی در بازه [from, to)
+func bankBookEntries(bankAccountID uint, from, to time.Time, db *gorm.DB) ([]MatchCandidate, error) {
+	var entries []MatchCandidate
+
+	// تراکنش‌های نقدی (بدون قسط)
+	var trxs []models.Transaction
+	if err := db.Where("money_source_type = ? AND bank_account_id = ? AND is_paid = ?", "bank", bankAccountID, true).
+		Where("id NOT IN (?)", db.Model(&models.SubTransaction{}).Select("transaction_id")).
+		Where("COALESCE(transaction_date, created_at) >= ? AND COALESCE(transaction_date, created_at) < ?", from, to).
+		Find(&trxs).Error; err != nil {
+		return nil, err
+	}
+	for _, trx := range trxs {
+		entries = append(entries, MatchCandidate{
+			MatchType:   models.MatchTransaction,
+			MatchID:     trx.ID,
+			Date:        transactionDate(&trx),
+			Amount:      transactionSign(trx.TransactionType) * trx.Amount,
+			Description: trx.Notes,
+		})
+	}
+
+	// اقساط پرداخت‌شده
+	type subRow struct {
+		ID              uint
+		Amount          float64
+		PaidAt          time.Time
+		TransactionType string
+		Notes           string
+	}
+	var subs []subRow
+	if err := db.Model(&models.SubTransaction{}).
+		Select("sub_transactions.id, sub_transactions.amount, sub_transactions.paid_at, transactions.transaction_type, transactions.notes").
+		Joins("JOIN transactions ON transactions.id = sub_transactions.transaction_id").
+		Where("transactions.money_source_type = ? AND transactions.bank_account_id = ?", "bank", bankAccountID).
+		Where("sub_transactions.is_paid = ? AND sub_transactions.paid_at >= ? AND sub_transactions.paid_at < ?", true, from, to).
+		Scan(&subs).Error; err != nil {
+		return nil, err
+	}
+	for _, sub := range subs {
+		entries = append(entries, MatchCandidate{
+			MatchType:   models.MatchSubTransaction,
+			MatchID:     sub.ID,
+			Date:        sub.PaidAt,
+			Amount:      transactionSign(sub.TransactionType) * sub.Amount,
+			Description: sub.Notes,
+		})
+	}
+
+	// ودیعه‌ها: ثبت و تسویه
+	var deposits []models.Deposit
+	if err := db.Where("money_source_type = ? AND bank_account_id = ?", "bank", bankAccountID).
+		Where("(created_at >= ? AND created_at < ?) OR (completed_at >= ? AND completed_at < ?)", from, to, from, to).
+		Find(&deposits).Error; err != nil {
+		return nil, err
+	}
+	for _, dep := range deposits {
+		sign := 1.0
+		if dep.Type == models.DepositReceived {
+			sign = -1
+		}
+		if !dep.CreatedAt.Before(from) && dep.CreatedAt.Before(to) {
+			entries = append(entries, MatchCandidate{
+				MatchType:   models.MatchDeposit,
+				MatchID:     dep.ID,
+				Date:        dep.CreatedAt,
+				Amount:      sign * dep.Amount,
+				Description: dep.Notes,
+			})
+		}
+		if dep.CompletedAt != nil && !dep.CompletedAt.Before(from) && dep.CompletedAt.Before(to) {
+			entries = append(entries, MatchCandidate{
+				MatchType:   models.MatchDepositSettlement,
+				MatchID:     dep.ID,
+				Date:        *dep.CompletedAt,
+				Amount:      -sign * dep.Amount,
+				Description: dep.Notes,
+			})
+		}
+	}
+
+	// کارمزد و سود بانکی (مثلاً ثبت‌شده توسط قاعده ماهانه)
+	var charges []models.BankCharge
+	if err := db.Where("bank_account_id = ? AND date >= ? AND date < ?", bankAccountID, from, to).
+		Find(&charges).Error; err != nil {
+		return nil, err
+	}
+	for _, charge := range charges {
+		entries = append(entries, MatchCandidate{
+			MatchType:   models.MatchBankCharge,
+			MatchID:     charge.ID,
+			Date:        charge.Date,
+			Amount:      chargeDelta(charge.Kind, charge.Amount),
+			Description: charge.Description,
+		})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Date.Before(entries[j].Date) })
+	return entries, nil
+}
+
+// transactionSign اثر نوع تراکنش بر موجودی حساب (مطابق adjustBalanceAndStock)
+func transactionSign(transactionType string) float64 {
+	if transactionType == "expense" {
+		return -1
+	}
+	return 1
+}
+
+// ConfirmStatementLine تایید تطبیق پیشنهادی یا تطبیق دستی با رکورد دفتری مشخص
+func ConfirmStatementLine(lineID uint, matchType string, matchID *uint, db *gorm.DB) (*models.BankStatementLine, error) {
+	var line models.BankStatementLine
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&line, lineID).Error; err != nil {
+			return errors.New("ردیف صورتحساب یافت نشد")
+		}
+		if err := CheckReconciliationLock(tx, "bank", &line.BankAccountID, line.Date); err != nil {
+			return err
+		}
+
+		if matchType == "" {
+			if line.Status != models.StatementLineSuggested || line.MatchID == nil {
+				return errors.New("برای این ردیف تطبیق پیشنهادی وجود ندارد")
+			}
+		} else {
+			if matchID == nil {
+				return errors.New("شناسه رکورد دفتری الزامی است")
+			}
+			entry, err := findBookEntry(line.BankAccountID, matchType, *matchID, tx)
+			if err != nil {
+				return err
+			}
+			if math.Abs(entry.Amount-line.Amount) >= 0.5 {
+				return errors.New("مبلغ رکورد دفتری با ردیف صورتحساب برابر نیست")
+			}
+			taken, err := takenMatches(line.BankAccountID, line.ID, tx)
+			if err != nil {
+				return err
+			}
+			if taken[matchType+fmt.Sprint(*matchID)] {
+				return errors.New("این رکورد دفتری قبلاً با ردیف دیگری تطبیق داده شده است")
+			}
+			line.MatchType = matchType
+			line.MatchID = matchID
+			line.MatchScore = 0
+		}
+
+		now := time.Now()
+		line.Status = models.StatementLineMatched
+		line.ConfirmedAt = &now
+		return tx.Save(&line).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &line, nil
+}
+
+// findBookEntry رکورد دفتری مشخص روی همین حساب بانکی
+func findBookEntry(bankAccountID uint, matchType string, matchID uint, db *gorm.DB) (*MatchCandidate, error) {
+	notFound := errors.New("رکورد دفتری روی این حساب بانکی یافت نشد")
+
+	switch matchType {
+	case models.MatchTransaction:
+		var trx models.Transaction
+		if err := db.Preload("SubTransactions").First(&trx, matchID).Error; err != nil {
+			return nil, notFound
+		}
+		if trx.MoneySourceType != "bank" || trx.BankAccountID == nil || *trx.BankAccountID != bankAccountID ||
+			!trx.IsPaid || len(trx.SubTransactions) > 0 {
+			return nil, notFound
+		}
+		return &MatchCandidate{MatchType: matchType, MatchID: trx.ID, Date: transactionDate(&trx),
+			Amount: transactionSign(trx.TransactionType) * trx.Amount}, nil
+
+	case models.MatchSubTransaction:
+		var sub models.SubTransaction
+		if err := db.First(&sub, matchID).Error; err != nil || !sub.IsPaid {
+			return nil, notFound
+		}
+		var trx models.Transaction
+		if err := db.First(&trx, sub.TransactionID).Error; err != nil {
+			return nil, notFound
+		}
+		if trx.MoneySourceType != "bank" || trx.BankAccountID == nil || *trx.BankAccountID != bankAccountID {
+			return nil, notFound
+		}
+		return &MatchCandidate{MatchType: matchType, MatchID: sub.ID,
+			Amount: transactionSign(trx.TransactionType) * sub.Amount}, nil
+
+	case models.MatchDeposit, models.MatchDepositSettlement:
+		var dep models.Deposit
+		if err := db.First(&dep, matchID).Error; err != nil {
+			return nil, notFound
+		}
+		if dep.MoneySourceType != "bank" || dep.BankAccountID == nil || *dep.BankAccountID != bankAccountID {
+			return nil, notFound
+		}
+		sign := 1.0
+		if dep.Type == models.DepositReceived {
+			sign = -1
+		}
+		if matchType == models.MatchDepositSettlement {
+			if dep.CompletedAt == nil {
+				return nil, errors.New("این ودیعه هنوز تسویه نشده است")
+			}
+			sign = -sign
+		}
+		return &MatchCandidate{MatchType: matchType, MatchID: dep.ID, Amount: sign * dep.Amount}, nil
+
+	case models.MatchBankCharge:
+		var charge models.BankCharge
+		if err := db.First(&charge, matchID).Error; err != nil || charge.BankAccountID != bankAccountID {
+			return nil, notFound
+		}
+		return &MatchCandidate{MatchType: matchType, MatchID: charge.ID, Date: charge.Date,
+			Amount: chargeDelta(charge.Kind, charge.Amount)}, nil
+	}
+
+	return nil, errors.New("نوع رکورد دفتری نامعتبر است")
+}
+
+// UnmatchStatementLine لغو تطبیق یا نادیده گرفتن ردیف
+func UnmatchStatementLine(lineID uint, db *gorm.DB) (*models.BankStatementLine, error) {
+	return setLineStatus(lineID, models.StatementLineUnmatched, db)
+}
+
+// IgnoreStatementLine ردیف بدون رکورد دفتری (مثلاً انتقال بین حساب‌های خودمان)
+func IgnoreStatementLine(lineID uint, db *gorm.DB) (*models.BankStatementLine, error) {
+	return setLineStatus(lineID, models.StatementLineIgnored, db)
+}
+
+func setLineStatus(lineID uint, status models.StatementLineStatus, db *gorm.DB) (*models.BankStatementLine, error) {
+	var line models.BankStatementLine
+	if err := db.First(&line, lineID).Error; err != nil {
+		return nil, errors.New("ردیف صورتحساب یافت نشد")
+	}
+	if err := CheckReconciliationLock(db, "bank", &line.BankAccountID, line.Date); err != nil {
+		return nil, err
+	}
+
+	line.Status = status
+	line.MatchType = ""
+	line.MatchID = nil
+	line.MatchScore = 0
+	line.ConfirmedAt = nil
+	if status == models.StatementLineIgnored {
+		now := time.Now()
+		line.ConfirmedAt = &now
+	}
+	if err := db.Save(&line).Error; err != nil {
+		return nil, err
+	}
+	return &line, nil
+}
+
+// CreateEntryFromLine ثبت تراکنش جاافتاده از روی ردیف صورتحساب و تطبیق آن
+func CreateEntryFromLine(lineID uint, trx *models.Transaction, db *gorm.DB) (*models.BankStatementLine, error) {
+	var line models.BankStatementLine
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&line, lineID).Error; err != nil {
+			return errors.New("ردیف صورتحساب یافت نشد")
+		}
+		if line.Status == models.StatementLineMatched || line.Status == models.StatementLineIgnored {
+			return errors.New("این ردیف قبلاً تعیین وضعیت شده است")
+		}
+
+		bankID := line.BankAccountID
+		date := line.Date
+		trx.MoneySourceType = "bank"
+		trx.BankAccountID = &bankID
+		trx.CashHolderID = nil
+		trx.TransactionDate = &date
+		trx.Amount = math.Abs(line.Amount)
+		trx.IsPaid = true
+		trx.SubTransactions = nil
+		if trx.TransactionType == "" {
+			trx.TransactionType = "expense"
+			if line.Amount > 0 {
+				trx.TransactionType = "income"
+			}
+		}
+		if transactionSign(trx.TransactionType)*line.Amount < 0 {
+			return errors.New("نوع تراکنش با جهت ردیف صورتحساب همخوانی ندارد")
+		}
+		if trx.PaymentMethod == "" {
+			trx.PaymentMethod = "card"
+		}
+		if trx.Notes == "" {
+			trx.Notes = strings.TrimSpace(line.Description + " " + line.Reference)
+		}
+
+		if err := CreateTransaction(trx, nil, tx); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		line.Status = models.StatementLineMatched
+		line.MatchType = models.MatchTransaction
+		line.MatchID = &trx.ID
+		line.MatchScore = 1
+		line.ConfirmedAt = &now
+		return tx.Save(&line).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &line, nil
+}
+
+// releaseStatementMatches با حذف رکورد دفتری، ردیف‌های تطبیق‌داده‌شده با آن آزاد می‌شوند
+func releaseStatementMatches(db *gorm.DB, matchTypes []string, matchIDs []uint) error {
+	if len(matchIDs) == 0 {
+		return nil
+	}
+	return db.Model(&models.BankStatementLine{}).
+		Where("match_type IN ? AND match_id IN ?", matchTypes, matchIDs).
+		Updates(map[string]any{
+			"status":       models.StatementLineUnmatched,
+			"match_type":   "",
+			"match_id":     nil,
+			"match_score":  0,
+			"confirmed_at": nil,
+		}).Error
+}
+
+// ReleaseDepositMatches برای حذف یا ویرایش ودیعه
+func ReleaseDepositMatches(depositID uint, db *gorm.DB) error {
+	return releaseStatementMatches(db, []string{models.MatchDeposit, models.MatchDepositSettlement}, []uint{depositID})
+}
+
+// ---------------- SUMMARY ----------------
+
+// GetReconciliationSummary مقایسه مانده دفتر با آخرین مانده صورتحساب و فهرست موارد باز
+func GetReconciliationSummary(bankAccountID uint, db *gorm.DB) (*ReconciliationSummary, error) {
+	var bank models.BankAccount
+	if err := db.First(&bank, bankAccountID).Error; err != nil {
+		return nil, errors.New("حساب بانکی یافت نشد")
+	}
+
+	summary := &ReconciliationSummary{BankAccountID: bank.ID, BookBalance: bank.Balance}
+
+	until, err := LockedUntil(bank.ID, db)
+	if err != nil {
+		return nil, err
+	}
+	summary.LockedUntil = until
+
+	var last models.BankStatementLine
+	err = db.Where("bank_account_id = ? AND balance IS NOT NULL", bank.ID).Order("date DESC, id DESC").First(&last).Error
+	if err == nil {
+		summary.StatementBalance = last.Balance
+		summary.StatementDate = &last.Date
+		diff := *last.Balance - bank.Balance
+		summary.Difference = &diff
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	for status, target := range map[models.StatementLineStatus]*int64{
+		models.StatementLineUnmatched: &summary.UnmatchedLines,
+		models.StatementLineSuggested: &summary.SuggestedLines,
+	} {
+		if err := db.Model(&models.BankStatementLine{}).
+			Where("bank_account_id = ? AND status = ?", bank.ID, status).Count(target).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	// رکوردهای دفتری بعد از آخرین قفل که در هیچ صورتحسابی دیده نشده‌اند
+	var first models.BankStatementLine
+	err = db.Where("bank_account_id = ?", bank.ID).Order("date ASC").First(&first).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		summary.UnmatchedEntries = []MatchCandidate{}
+		return summary, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	from := first.Date.AddDate(0, 0, -1)
+	if until != nil && until.After(from) {
+		from = *until
+	}
+	entries, err := bankBookEntries(bank.ID, from, time.Now().Add(time.Second), db)
+	if err != nil {
+		return nil, err
+	}
+	taken, err := takenMatches(bank.ID, 0, db)
+	if err != nil {
+		return nil, err
+	}
+
+	summary.UnmatchedEntries = []MatchCandidate{}
+	for _, entry := range entries {
+		if !taken[entry.MatchType+fmt.Sprint(entry.MatchID)] {
+			summary.UnmatchedEntries = append(summary.UnmatchedEntries, entry)
+		}
+	}
+	return summary, nil
+}
+
+// ---------------- COLUMN MAPPINGS ----------------
+
+func GetStatementMappings(db *gorm.DB) ([]models.StatementColumnMapping, error) {
+	var mappings []models.StatementColumnMapping
+	err := db.Order("id").Find(&mappings).Error
+	return mappings, err
+}
+
+func GetStatementMappingByID(id uint, db *gorm.DB) (*models.StatementColumnMapping, error) {
+	var mapping models.StatementColumnMapping
+	if err := db.First(&mapping, id).Error; err != nil {
+		return nil, err
+	}
+	return &mapping, nil
+}
+
+// FindStatementMapping نگاشت با شناسه یا نام (مثلاً "mellat")
+func FindStatementMapping(key string, db *gorm.DB) (*models.StatementColumnMapping, error) {
+	var mapping models.StatementColumnMapping
+	query := db.Where("name = ?", key)
+	if id, err := strconv.Atoi(key); err == nil {
+		query = db.Where("id = ?", id)
+	}
+	if err := query.First(&mapping).Error; err != nil {
+		return nil, errors.New("نگاشت ستون‌های صورتحساب یافت نشد")
+	}
+	return &mapping, nil
+}
+
+func CreateStatementMapping(mapping *models.StatementColumnMapping, db *gorm.DB) error {
+	mapping.ID = 0
+	mapping.IsPreset = false
+	return db.Create(mapping).Error
+}
+
+func UpdateStatementMapping(id uint, data *models.StatementColumnMapping, db *gorm.DB) (*models.StatementColumnMapping, error) {
+	mapping, err := GetStatementMappingByID(id, db)
+	if err != nil {
+		return nil, err
+	}
+	data.ID = mapping.ID
+	data.IsPreset = mapping.IsPreset
+	data.CreatedAt = mapping.CreatedAt
+	if err := db.Save(data).Error; err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func DeleteStatementMapping(id uint, db *gorm.DB) error {
+	var count int64
+	if err := db.Model(&models.BankStatement{}).Where("mapping_id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("این نگاشت در صورتحساب‌های واردشده استفاده شده و قابل حذف نیست")
+	}
+	return db.Delete(&models.StatementColumnMapping{}, id).Error
+}
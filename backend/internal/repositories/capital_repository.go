@@ -0,0 +1,344 @@
+package repositories
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	capitalIncreaseCategory = "افزایش سهام"
+	capitalDecreaseCategory = "کاهش سهام"
+)
+
+// CapitalEventInput ثبت دستی آورده یا برداشت سرمایه با جابجایی پول
+type CapitalEventInput struct {
+	Type            models.CapitalEventType `json:"type"`
+	Amount          float64                 `json:"amount"`
+	Date            time.Time               `json:"date"`
+	MoneySourceType string                  `json:"money_source_type"`
+	BankAccountID   *uint                   `json:"bank_account_id"`
+	CashHolderID    *uint                   `json:"cash_holder_id"`
+	Notes           string                  `json:"notes"`
+}
+
+// ShareSplit درصد سهم مؤثر یک سهامدار در یک تاریخ
+type ShareSplit struct {
+	ContactID     uint      `json:"contact_id"`
+	Percentage    float64   `json:"percentage"`
+	EffectiveFrom time.Time `json:"effective_from"`
+}
+
+// ---------------- CAPITAL EVENTS ----------------
+
+// recordTransactionCapital اگر دسته تراکنش افزایش/کاهش سهام باشد، رویداد سرمایه مرتبط را ثبت می‌کند
+func recordTransactionCapital(trx *models.Transaction, db *gorm.DB, amount float64) error {
+	var category models.Category
+	if err := db.First(&category, trx.CategoryID).Error; err != nil {
+		return err
+	}
+
+	var eventType models.CapitalEventType
+	switch category.Name {
+	case capitalIncreaseCategory:
+		eventType = models.CapitalContribution
+	case capitalDecreaseCategory:
+		eventType = models.CapitalWithdrawal
+	default:
+		return nil
+	}
+
+	date := time.Now()
+	if trx.TransactionDate != nil && len(trx.SubTransactions) == 0 {
+		date = *trx.TransactionDate
+	}
+
+	event := models.CapitalEvent{
+		ContactID:       trx.ContactID,
+		Type:            eventType,
+		Amount:          amount,
+		Date:            date,
+		TransactionID:   &trx.ID,
+		MoneySourceType: trx.MoneySourceType,
+		BankAccountID:   trx.BankAccountID,
+		CashHolderID:    trx.CashHolderID,
+		Notes:           trx.Notes,
+	}
+	return createCapitalEvent(&event, db)
+}
+
+// revertTransactionCapital رویدادهای سرمایه یک تراکنش حذف‌شده را پاک می‌کند
+func revertTransactionCapital(trx *models.Transaction, db *gorm.DB) error {
+	result := db.Where("transaction_id = ?", trx.ID).Delete(&models.CapitalEvent{})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+	return syncShareholderCapital(trx.ContactID, db)
+}
+
+func createCapitalEvent(event *models.CapitalEvent, db *gorm.DB) error {
+	if event.Amount <= 0 {
+		return errors.New("مبلغ باید بیشتر از صفر باشد")
+	}
+	if event.Type != models.CapitalContribution && event.Type != models.CapitalWithdrawal {
+		return errors.New("نوع رویداد سرمایه نامعتبر است")
+	}
+
+	if event.Type == models.CapitalWithdrawal {
+		capital, err := ShareholderCapital(event.ContactID, db)
+		if err != nil {
+			return err
+		}
+		if capital < event.Amount {
+			return errors.New("میزان سهام کافی نیست")
+		}
+	}
+
+	if err := db.Create(event).Error; err != nil {
+		return err
+	}
+	return syncShareholderCapital(event.ContactID, db)
+}
+
+// CreateCapitalEvent آورده/برداشت مستقیم سرمایه و اعمال آن روی حساب بانکی یا تنخواه
+func CreateCapitalEvent(contactID uint, in CapitalEventInput, db *gorm.DB) (*models.CapitalEvent, error) {
+	if in.Date.IsZero() {
+		in.Date = time.Now()
+	}
+
+	event := models.CapitalEvent{
+		ContactID:       contactID,
+		Type:            in.Type,
+		Amount:          in.Amount,
+		Date:            in.Date,
+		MoneySourceType: in.MoneySourceType,
+		BankAccountID:   in.BankAccountID,
+		CashHolderID:    in.CashHolderID,
+		Notes:           in.Notes,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var profile models.ShareholderProfile
+		if err := tx.Where("contact_id = ?", contactID).First(&profile).Error; err != nil {
+			return errors.New("مخاطب انتخاب‌شده سهامدار نیست")
+		}
+
+		if err := createCapitalEvent(&event, tx); err != nil {
+			return err
+		}
+
+		if err := CheckReconciliationLock(tx, in.MoneySourceType, in.BankAccountID, event.Date); err != nil {
+			return err
+		}
+		delta := event.Amount
+		if event.Type == models.CapitalWithdrawal {
+			delta = -delta
+		}
+		return moveMoney(tx, in.MoneySourceType, in.BankAccountID, in.CashHolderID, delta)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func GetCapitalEvents(contactID uint, db *gorm.DB) ([]models.CapitalEvent, error) {
+	var events []models.CapitalEvent
+	err := db.Preload("BankAccount").Preload("CashHolder").
+		Where("contact_id = ?", contactID).
+		Order("date ASC, id ASC").
+		Find(&events).Error
+	return events, err
+}
+
+// DeleteCapitalEvent فقط رویدادهای ثبت دستی قابل حذف هستند؛ رویداد تراکنش‌ها با حذف تراکنش حذف می‌شوند
+func DeleteCapitalEvent(id uint, db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var event models.CapitalEvent
+		if err := tx.First(&event, id).Error; err != nil {
+			return errors.New("رویداد سرمایه یافت نشد")
+		}
+		if event.TransactionID != nil {
+			return errors.New("این رویداد از طریق تراکنش ثبت شده و باید تراکنش حذف شود")
+		}
+
+		if event.MoneySourceType != "" {
+			if err := CheckReconciliationLock(tx, event.MoneySourceType, event.BankAccountID, event.Date); err != nil {
+				return err
+			}
+			delta := -event.Amount
+			if event.Type == models.CapitalWithdrawal {
+				delta = event.Amount
+			}
+			if err := moveMoney(tx, event.MoneySourceType, event.BankAccountID, event.CashHolderID, delta); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Delete(&event).Error; err != nil {
+			return err
+		}
+		return syncShareholderCapital(event.ContactID, tx)
+	})
+}
+
+// ShareholderCapital سرمایه خالص سهامدار از جمع رویدادهای سرمایه
+func ShareholderCapital(contactID uint, db *gorm.DB) (float64, error) {
+	return capitalUntil(contactID, nil, db)
+}
+
+func capitalUntil(contactID uint, at *time.Time, db *gorm.DB) (float64, error) {
+	var total float64
+	query := db.Model(&models.CapitalEvent{}).Where("contact_id = ?", contactID)
+	if at != nil {
+		query = query.Where("date <= ?", *at)
+	}
+	err := query.
+		Select("COALESCE(SUM(CASE WHEN type = ? THEN amount ELSE -amount END),0)", models.CapitalContribution).
+		Scan(&total).Error
+	return total, err
+}
+
+// syncShareholderCapital فیلد Amount مخاطب را از روی رویدادهای سرمایه بازمحاسبه می‌کند
+func syncShareholderCapital(contactID uint, db *gorm.DB) error {
+	capital, err := ShareholderCapital(contactID, db)
+	if err != nil {
+		return err
+	}
+	return db.Model(&models.ShareholderProfile{}).Where("contact_id = ?", contactID).Update("amount", capital).Error
+}
+
+// ---------------- SHARE PERCENTAGE HISTORY ----------------
+
+// RecordShareChange ثبت درصد سهم جدید با تاریخ اعمال
+func RecordShareChange(contactID uint, percentage float64, effectiveFrom time.Time, reason string, db *gorm.DB) (*models.SharePercentageChange, error) {
+	if percentage < 0 || percentage > 100 {
+		return nil, errors.New("درصد سهم باید بین ۰ تا ۱۰۰ باشد")
+	}
+
+	change := models.SharePercentageChange{
+		ContactID:     contactID,
+		Percentage:    percentage,
+		EffectiveFrom: effectiveFrom,
+		Reason:        reason,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&change).Error; err != nil {
+			return err
+		}
+		return syncCurrentSharePercentage(contactID, tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &change, nil
+}
+
+// syncCurrentSharePercentage درصد سهم فعلی مخاطب را برابر آخرین تغییر اعمال‌شده تا امروز قرار می‌دهد
+func syncCurrentSharePercentage(contactID uint, db *gorm.DB) error {
+	var latest models.SharePercentageChange
+	err := db.Where("contact_id = ? AND effective_from <= ?", contactID, time.Now()).
+		Order("effective_from DESC, id DESC").
+		First(&latest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return db.Model(&models.ShareholderProfile{}).Where("contact_id = ?", contactID).Update("share_percentage", latest.Percentage).Error
+}
+
+func GetShareHistory(contactID uint, db *gorm.DB) ([]models.SharePercentageChange, error) {
+	var changes []models.SharePercentageChange
+	err := db.Where("contact_id = ?", contactID).Order("effective_from ASC, id ASC").Find(&changes).Error
+	return changes, err
+}
+
+// ShareSplitAt درصد سهم مؤثر همه سهامداران در لحظه at (برای تقسیم سود دوره‌های گذشته)
+func ShareSplitAt(at time.Time, db *gorm.DB) ([]ShareSplit, error) {
+	var shareholders []models.ShareholderProfile
+	if err := db.Order("contact_id").Find(&shareholders).Error; err != nil {
+		return nil, err
+	}
+
+	var splits []ShareSplit
+	for _, sh := range shareholders {
+		var change models.SharePercentageChange
+		err := db.Where("contact_id = ? AND effective_from <= ?", sh.ContactID, at).
+			Order("effective_from DESC, id DESC").
+			First(&change).Error
+
+		switch {
+		case err == nil:
+			if change.Percentage > 0 {
+				splits = append(splits, ShareSplit{ContactID: sh.ContactID, Percentage: change.Percentage, EffectiveFrom: change.EffectiveFrom})
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			// بدون تاریخچه → درصد فعلی
+			var history int64
+			db.Model(&models.SharePercentageChange{}).Where("contact_id = ?", sh.ContactID).Count(&history)
+			if history == 0 && sh.SharePercentage > 0 {
+				splits = append(splits, ShareSplit{ContactID: sh.ContactID, Percentage: sh.SharePercentage})
+			}
+		default:
+			return nil, err
+		}
+	}
+	return splits, nil
+}
+
+// RecomputeSharesFromCapital درصد سهام را به نسبت سرمایه آورده‌شده تا تاریخ effectiveFrom بازمحاسبه می‌کند
+func RecomputeSharesFromCapital(effectiveFrom time.Time, db *gorm.DB) ([]models.SharePercentageChange, error) {
+	var changes []models.SharePercentageChange
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var shareholders []models.ShareholderProfile
+		if err := tx.Order("contact_id").Find(&shareholders).Error; err != nil {
+			return err
+		}
+
+		capitals := make([]float64, len(shareholders))
+		total := 0.0
+		for i, sh := range shareholders {
+			capital, err := capitalUntil(sh.ContactID, &effectiveFrom, tx)
+			if err != nil {
+				return err
+			}
+			if capital < 0 {
+				capital = 0
+			}
+			capitals[i] = capital
+			total += capital
+		}
+		if total <= 0 {
+			return errors.New("سرمایه‌ای برای محاسبه درصد سهام ثبت نشده است")
+		}
+
+		for i, sh := range shareholders {
+			percentage := math.Round(capitals[i]/total*10000) / 100
+			change := models.SharePercentageChange{
+				ContactID:     sh.ContactID,
+				Percentage:    percentage,
+				EffectiveFrom: effectiveFrom,
+				Reason:        "recompute",
+			}
+			if err := tx.Create(&change).Error; err != nil {
+				return err
+			}
+			if err := syncCurrentSharePercentage(sh.ContactID, tx); err != nil {
+				return err
+			}
+			changes = append(changes, change)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
@@ -0,0 +1,341 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/config"
+	"github.com/amirqodi/hgm/internal/models"
+	"github.com/amirqodi/hgm/internal/utils"
+	"gorm.io/gorm"
+)
+
+// BookUnit واحد مبالغ ثبت‌شده در دفتر (BOOK_UNIT=rial|toman، پیش‌فرض ریال)
+func BookUnit() models.CashUnit {
+	if models.CashUnit(config.Get("BOOK_UNIT", "rial")) == models.CashUnitToman {
+		return models.CashUnitToman
+	}
+	return models.CashUnitRial
+}
+
+// toBookUnit تبدیل مبلغ از واحد شمارش به واحد دفتر
+func toBookUnit(amount float64, unit models.CashUnit) float64 {
+	book := BookUnit()
+	switch {
+	case unit == book:
+		return amount
+	case unit == models.CashUnitRial:
+		return amount / 10
+	default:
+		return amount * 10
+	}
+}
+
+// Denominations اسکناس و سکه‌های مجاز در واحد داده‌شده
+func Denominations(unit models.CashUnit) []float64 {
+	result := make([]float64, len(models.RialDenominations))
+	for i, d := range models.RialDenominations {
+		if unit == models.CashUnitToman {
+			d /= 10
+		}
+		result[i] = d
+	}
+	return result
+}
+
+// prepareCashCount بررسی اقلام، ادغام اسکناس‌های تکراری و محاسبه جمع شمارش به واحد دفتر
+func prepareCashCount(count *models.CashCount) error {
+	if count.Unit == "" {
+		count.Unit = BookUnit()
+	}
+	if count.Unit != models.CashUnitRial && count.Unit != models.CashUnitToman {
+		return errors.New("واحد شمارش باید rial یا toman باشد")
+	}
+
+	valid := map[float64]bool{}
+	for _, d := range Denominations(count.Unit) {
+		valid[d] = true
+	}
+
+	quantities := map[float64]int{}
+	for _, item := range count.Items {
+		if !valid[item.Denomination] {
+			return fmt.Errorf("اسکناس یا سکه %.0f %s معتبر نیست", item.Denomination, count.Unit)
+		}
+		if item.Quantity < 0 {
+			return errors.New("تعداد نمی‌تواند منفی باشد")
+		}
+		quantities[item.Denomination] += item.Quantity
+	}
+
+	items := make([]models.CashCountItem, 0, len(quantities))
+	var total float64
+	for d, q := range quantities {
+		if q == 0 {
+			continue
+		}
+		items = append(items, models.CashCountItem{Denomination: d, Quantity: q, Total: d * float64(q)})
+		total += d * float64(q)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Denomination > items[j].Denomination })
+
+	count.Items = items
+	count.CountedTotal = toBookUnit(total, count.Unit)
+	return nil
+}
+
+// refreshCountDifference مقایسه جمع شمارش با موجودی فعلی تنخواه در دفتر
+func refreshCountDifference(count *models.CashCount, db *gorm.DB) error {
+	var holder models.CashHolder
+	if err := db.First(&holder, count.CashHolderID).Error; err != nil {
+		return errors.New("تنخواه یافت نشد")
+	}
+	if holder.Currency.IsForeign() {
+		return ErrForeignAccount
+	}
+	count.SystemBalance = holder.Balance
+	count.Difference = math.Round((count.CountedTotal-holder.Balance)*100) / 100
+	return nil
+}
+
+// ---------------- CASH COUNTS ----------------
+
+// CreateCashCount شروع جلسه شمارش (پیش‌نویس)
+func CreateCashCount(count *models.CashCount, db *gorm.DB) error {
+	if err := prepareCashCount(count); err != nil {
+		return err
+	}
+	if err := refreshCountDifference(count, db); err != nil {
+		return err
+	}
+	count.ID = 0
+	count.Status = models.CashCountDraft
+	count.PostedAt = nil
+	if count.CountedAt.IsZero() {
+		count.CountedAt = time.Now()
+	}
+	return db.Create(count).Error
+}
+
+// UpdateCashCount ویرایش اقلام شمارش تا زمانی که نهایی نشده است
+func UpdateCashCount(id uint, input *models.CashCount, db *gorm.DB) (*models.CashCount, error) {
+	var count models.CashCount
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&count, id).Error; err != nil {
+			return err
+		}
+		if count.Status != models.CashCountDraft {
+			return errors.New("شمارش نهایی‌شده قابل ویرایش نیست")
+		}
+
+		count.Unit = input.Unit
+		count.Items = input.Items
+		count.Notes = input.Notes
+		if !input.CountedAt.IsZero() {
+			count.CountedAt = input.CountedAt
+		}
+		if err := prepareCashCount(&count); err != nil {
+			return err
+		}
+		if err := refreshCountDifference(&count, tx); err != nil {
+			return err
+		}
+
+		if err := tx.Where("cash_count_id = ?", count.ID).Delete(&models.CashCountItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Session(&gorm.Session{FullSaveAssociations: true}).Save(&count).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &count, nil
+}
+
+// PostCashCount نهایی‌سازی شمارش؛ اختلاف با موجودی دفتری به عنوان اضافه/کسری صندوق ثبت
+// و موجودی تنخواه با مبلغ شمرده‌شده برابر می‌شود
+func PostCashCount(id uint, db *gorm.DB) (*models.CashCount, error) {
+	var count models.CashCount
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&count, id).Error; err != nil {
+			return err
+		}
+		if count.Status != models.CashCountDraft {
+			return errors.New("این شمارش قبلاً نهایی شده است")
+		}
+		if err := refreshCountDifference(&count, tx); err != nil {
+			return err
+		}
+		if count.Difference != 0 {
+			if err := moveMoney(tx, "cash", nil, &count.CashHolderID, count.Difference); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		count.Status = models.CashCountPosted
+		count.PostedAt = &now
+		return tx.Save(&count).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetCashCountByID(count.ID, db)
+}
+
+// DeleteCashCount فقط شمارش پیش‌نویس حذف می‌شود؛ شمارش نهایی سند کسری/اضافه است
+func DeleteCashCount(id uint, db *gorm.DB) error {
+	var count models.CashCount
+	if err := db.First(&count, id).Error; err != nil {
+		return err
+	}
+	if count.Status != models.CashCountDraft {
+		return errors.New("شمارش نهایی‌شده قابل حذف نیست")
+	}
+	return db.Select("Items").Delete(&count).Error
+}
+
+func GetCashCounts(cashHolderID uint, db *gorm.DB) ([]models.CashCount, error) {
+	var counts []models.CashCount
+	query := db.Preload("CashHolder").Order("counted_at DESC, id DESC")
+	if cashHolderID > 0 {
+		query = query.Where("cash_holder_id = ?", cashHolderID)
+	}
+	err := query.Find(&counts).Error
+	return counts, err
+}
+
+func GetCashCountByID(id uint, db *gorm.DB) (*models.CashCount, error) {
+	var count models.CashCount
+	if err := db.Preload("CashHolder").
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("denomination DESC") }).
+		First(&count, id).Error; err != nil {
+		return nil, err
+	}
+	return &count, nil
+}
+
+// SumCashOverShort جمع اضافه (درآمد) و کسری (هزینه) صندوق شمارش‌های نهایی در بازه [from, to)
+func SumCashOverShort(db *gorm.DB, from, to *time.Time) (over, short float64, err error) {
+	scope := func(condition, sum string) *gorm.DB {
+		q := db.Model(&models.CashCount{}).Where("status = ? AND "+condition, models.CashCountPosted)
+		if from != nil {
+			q = q.Where("posted_at >= ?", *from)
+		}
+		if to != nil {
+			q = q.Where("posted_at < ?", *to)
+		}
+		return q.Select(sum)
+	}
+	if err = scope("difference > 0", "COALESCE(SUM(difference),0)").Scan(&over).Error; err != nil {
+		return
+	}
+	err = scope("difference < 0", "COALESCE(SUM(-difference),0)").Scan(&short).Error
+	return
+}
+
+// ---------------- HANDOVERS ----------------
+
+// handoverPayload متن امضاشده رکورد تحویل صندوق
+func handoverPayload(h *models.CashHandover) string {
+	countID, recordedBy := uint(0), uint(0)
+	if h.CashCountID != nil {
+		countID = *h.CashCountID
+	}
+	if h.RecordedByID != nil {
+		recordedBy = *h.RecordedByID
+	}
+	return fmt.Sprintf("cash-handover|%d|%d|%d|%d|%.2f|%s|%d|%s",
+		h.ID, h.FromCashHolderID, h.ToCashHolderID, countID, h.Amount,
+		h.HandedOverAt.UTC().Format(time.RFC3339), recordedBy, h.Notes)
+}
+
+// CreateCashHandover انتقال موجودی صندوق از تنخواه‌دار فعلی به تنخواه‌دار جدید و امضای رکورد.
+// اگر شمارش نهایی‌شده تحویل‌دهنده داده شود و مبلغ خالی باشد، مبلغ شمرده‌شده تحویل می‌شود؛ در غیر این صورت کل موجودی.
+func CreateCashHandover(h *models.CashHandover, db *gorm.DB) error {
+	if h.FromCashHolderID == 0 || h.ToCashHolderID == 0 {
+		return errors.New("تحویل‌دهنده و تحویل‌گیرنده الزامی است")
+	}
+	if h.FromCashHolderID == h.ToCashHolderID {
+		return errors.New("تحویل‌دهنده و تحویل‌گیرنده نمی‌توانند یکسان باشند")
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var from, to models.CashHolder
+		if err := tx.First(&from, h.FromCashHolderID).Error; err != nil {
+			return errors.New("تنخواه تحویل‌دهنده یافت نشد")
+		}
+		if err := tx.First(&to, h.ToCashHolderID).Error; err != nil {
+			return errors.New("تنخواه تحویل‌گیرنده یافت نشد")
+		}
+
+		if h.CashCountID != nil {
+			var count models.CashCount
+			if err := tx.First(&count, *h.CashCountID).Error; err != nil {
+				return errors.New("شمارش صندوق یافت نشد")
+			}
+			if count.CashHolderID != from.ID || count.Status != models.CashCountPosted {
+				return errors.New("شمارش باید متعلق به تحویل‌دهنده و نهایی شده باشد")
+			}
+			if h.Amount == 0 {
+				h.Amount = count.CountedTotal
+			}
+		}
+		if h.Amount == 0 {
+			h.Amount = from.Balance
+		}
+		if h.Amount <= 0 {
+			return errors.New("مبلغ تحویل باید بیشتر از صفر باشد")
+		}
+
+		if err := moveMoney(tx, "cash", nil, &from.ID, -h.Amount); err != nil {
+			return err
+		}
+		if err := moveMoney(tx, "cash", nil, &to.ID, h.Amount); err != nil {
+			return err
+		}
+
+		if h.HandedOverAt.IsZero() {
+			h.HandedOverAt = time.Now()
+		}
+		h.HandedOverAt = h.HandedOverAt.Truncate(time.Second)
+		h.ID = 0
+		h.Signature = ""
+		if err := tx.Create(h).Error; err != nil {
+			return err
+		}
+		h.Signature = utils.SignRecord(handoverPayload(h))
+		return tx.Model(h).Update("signature", h.Signature).Error
+	})
+}
+
+// VerifyCashHandover بررسی امضای رکورد تحویل؛ false یعنی رکورد پس از ثبت دستکاری شده است
+func VerifyCashHandover(id uint, db *gorm.DB) (bool, error) {
+	var h models.CashHandover
+	if err := db.First(&h, id).Error; err != nil {
+		return false, err
+	}
+	return utils.VerifyRecordSignature(handoverPayload(&h), h.Signature), nil
+}
+
+// GetCashHandovers تحویل‌های یک تنخواه‌دار (تحویل‌دهنده یا تحویل‌گیرنده)
+func GetCashHandovers(cashHolderID uint, db *gorm.DB) ([]models.CashHandover, error) {
+	var handovers []models.CashHandover
+	query := db.Preload("FromCashHolder").Preload("ToCashHolder").Order("handed_over_at DESC, id DESC")
+	if cashHolderID > 0 {
+		query = query.Where("from_cash_holder_id = ? OR to_cash_holder_id = ?", cashHolderID, cashHolderID)
+	}
+	err := query.Find(&handovers).Error
+	return handovers, err
+}
+
+func GetCashHandoverByID(id uint, db *gorm.DB) (*models.CashHandover, error) {
+	var h models.CashHandover
+	if err := db.Preload("FromCashHolder").Preload("ToCashHolder").First(&h, id).Error; err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
@@ -10,6 +10,9 @@ import (
 
 // Create
 func CreateCashHolder(cashHolder *models.CashHolder) error {
+	if err := prepareAccountCurrency(&cashHolder.Currency, cashHolder.Balance, &cashHolder.BookValue); err != nil {
+		return err
+	}
 	return database.DB.Create(cashHolder).Error
 }
 
@@ -56,9 +59,17 @@ func UpdateCashHolder(id uint, data *models.CashHolder) (models.CashHolder, erro
 	if err := database.DB.First(&holder, id).Error; err != nil {
 		return holder, err
 	}
+	bookValue, err := updatedBookValue("cash_holder_id", id, holder.Currency, holder.Balance, holder.BookValue, data.Currency, data.Balance)
+	if err != nil {
+		return holder, err
+	}
+	data.BookValue = 0 // ارزش دفتری فقط از روی مانده و تسعیر تعیین می‌شود
 	if err := database.DB.Model(&holder).Updates(data).Error; err != nil {
 		return holder, err
 	}
+	if err := database.DB.Model(&holder).Update("book_value", bookValue).Error; err != nil {
+		return holder, err
+	}
 	return holder, nil
 }
 
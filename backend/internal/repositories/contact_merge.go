@@ -0,0 +1,347 @@
+package repositories
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/models"
+	"github.com/amirqodi/hgm/internal/utils"
+	"gorm.io/gorm"
+)
+
+// DuplicateGroup گروهی از مخاطبین که احتمالاً یک نفر هستند
+type DuplicateGroup struct {
+	Contacts []models.Contact `json:"contacts"`
+	Reasons  []string         `json:"reasons"` // phone, name
+	Score    float64          `json:"score"`   // بیشترین شباهت نام در گروه
+}
+
+// FindDuplicateContacts تشخیص مخاطبین تکراری با شماره موبایل یکسان (پس از نرمال‌سازی)
+// یا نام بسیار شبیه (شباهت بیشتر یا مساوی threshold)
+func FindDuplicateContacts(threshold float64, db *gorm.DB) ([]DuplicateGroup, error) {
+	var contacts []models.Contact
+	if err := db.Order("id").Find(&contacts).Error; err != nil {
+		return nil, err
+	}
+
+	// union-find روی اندیس مخاطبین
+	parent := make([]int, len(contacts))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	reasons := map[[2]int][]string{}
+	scores := map[[2]int]float64{}
+
+	phones := make([]string, len(contacts))
+	names := make([]string, len(contacts))
+	for i, c := range contacts {
+		phones[i] = utils.NormalizeIranianMobile(c.PhoneNumber)
+		names[i] = c.FirstName + " " + c.LastName
+	}
+
+	for i := 0; i < len(contacts); i++ {
+		for j := i + 1; j < len(contacts); j++ {
+			key := [2]int{i, j}
+			if phones[i] != "" && phones[i] == phones[j] {
+				reasons[key] = append(reasons[key], "phone")
+			}
+			if sim := utils.NameSimilarity(names[i], names[j]); sim >= threshold {
+				scores[key] = sim
+				reasons[key] = append(reasons[key], "name")
+			}
+			if len(reasons[key]) > 0 {
+				parent[find(i)] = find(j)
+			}
+		}
+	}
+
+	groupsByRoot := map[int]*DuplicateGroup{}
+	reasonSets := map[int]map[string]bool{}
+	for key, pairReasons := range reasons {
+		root := find(key[0])
+		if groupsByRoot[root] == nil {
+			groupsByRoot[root] = &DuplicateGroup{}
+			reasonSets[root] = map[string]bool{}
+		}
+		for _, reason := range pairReasons {
+			reasonSets[root][reason] = true
+		}
+		if scores[key] > groupsByRoot[root].Score {
+			groupsByRoot[root].Score = scores[key]
+		}
+	}
+
+	for i, c := range contacts {
+		if g, ok := groupsByRoot[find(i)]; ok {
+			g.Contacts = append(g.Contacts, c)
+		}
+	}
+
+	var groups []DuplicateGroup
+	for root, g := range groupsByRoot {
+		for reason := range reasonSets[root] {
+			g.Reasons = append(g.Reasons, reason)
+		}
+		sort.Strings(g.Reasons)
+		groups = append(groups, *g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Contacts[0].ID < groups[j].Contacts[0].ID
+	})
+
+	return groups, nil
+}
+
+// MergeContacts همه تراکنش‌ها، ودیعه‌ها و سوابق مخاطب تکراری را به مخاطب اصلی منتقل و تکراری را حذف می‌کند
+func MergeContacts(survivorID, duplicateID uint, userID *uint, db *gorm.DB) (*models.ContactMerge, error) {
+	if survivorID == duplicateID {
+		return nil, errors.New("مخاطب اصلی و تکراری نمی‌توانند یکسان باشند")
+	}
+
+	var merge models.ContactMerge
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var survivor, duplicate models.Contact
+		if err := ContactPreloads(tx).First(&survivor, survivorID).Error; err != nil {
+			return errors.New("مخاطب اصلی یافت نشد")
+		}
+		if err := ContactPreloads(tx).First(&duplicate, duplicateID).Error; err != nil {
+			return errors.New("مخاطب تکراری یافت نشد")
+		}
+
+		snapshot, err := json.Marshal(duplicate)
+		if err != nil {
+			return err
+		}
+
+		// --- انتقال سوابق ---
+		trxResult := tx.Model(&models.Transaction{}).Where("contact_id = ?", duplicate.ID).Update("contact_id", survivor.ID)
+		if trxResult.Error != nil {
+			return trxResult.Error
+		}
+		depResult := tx.Model(&models.Deposit{}).Where("contact_id = ?", duplicate.ID).Update("contact_id", survivor.ID)
+		if depResult.Error != nil {
+			return depResult.Error
+		}
+		for _, model := range []any{
+			&models.Dividend{}, &models.CapitalEvent{}, &models.ContactPhone{}, &models.ContactAddress{},
+			&models.Invoice{}, &models.Quote{}, &models.WorkOrder{}, &models.PurchaseOrder{}, &models.VendorBill{},
+		} {
+			if err := tx.Model(model).Where("contact_id = ?", duplicate.ID).Update("contact_id", survivor.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		// --- اطلاعات پایه خالی مخاطب اصلی ---
+		if survivor.PhoneNumber == "" {
+			survivor.PhoneNumber = duplicate.PhoneNumber
+		}
+		if survivor.NationalID == "" {
+			survivor.NationalID = duplicate.NationalID
+		}
+		if survivor.EconomicCode == "" {
+			survivor.EconomicCode = duplicate.EconomicCode
+		}
+		if err := tx.Model(&survivor).
+			Select("PhoneNumber", "NationalID", "EconomicCode").
+			Updates(&survivor).Error; err != nil {
+			return err
+		}
+
+		// --- نقش‌ها: نقش‌های تکراری به اصلی اضافه یا در آن ادغام می‌شوند ---
+		if err := mergeCustomerProfile(&survivor, &duplicate, tx); err != nil {
+			return err
+		}
+		if err := mergeVendorProfile(&survivor, &duplicate, tx); err != nil {
+			return err
+		}
+		if err := mergeShareholderProfile(&survivor, &duplicate, tx); err != nil {
+			return err
+		}
+
+		// --- برچسب‌ها ---
+		if len(duplicate.Tags) > 0 {
+			if err := tx.Model(&survivor).Association("Tags").Append(duplicate.Tags); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&duplicate).Association("Tags").Clear(); err != nil {
+			return err
+		}
+
+		if err := tx.Delete(&models.Contact{}, duplicate.ID).Error; err != nil {
+			return err
+		}
+
+		merge = models.ContactMerge{
+			SurvivorID:        survivor.ID,
+			DuplicateID:       duplicate.ID,
+			DuplicateSnapshot: string(snapshot),
+			MovedTransactions: trxResult.RowsAffected,
+			MovedDeposits:     depResult.RowsAffected,
+			MergedByUserID:    userID,
+		}
+		return tx.Create(&merge).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &merge, nil
+}
+
+// mergeCustomerProfile اطلاعات خالی خودرو و اعتبار از مخاطب تکراری تکمیل می‌شود
+func mergeCustomerProfile(survivor, duplicate *models.Contact, tx *gorm.DB) error {
+	dup := duplicate.Customer
+	if dup == nil {
+		return nil
+	}
+	if survivor.Customer == nil {
+		return tx.Model(dup).Update("contact_id", survivor.ID).Error
+	}
+
+	profile := survivor.Customer
+	if profile.CarType == nil || *profile.CarType == "" {
+		profile.CarType = dup.CarType
+	}
+	if profile.CarKilometer == nil ||
+		(dup.CarKilometer != nil && *dup.CarKilometer > *profile.CarKilometer) {
+		profile.CarKilometer = dup.CarKilometer
+	}
+	if profile.CreditLimit == nil {
+		profile.CreditLimit = dup.CreditLimit
+		profile.MaxOverdueDays = dup.MaxOverdueDays
+		profile.CreditPolicy = dup.CreditPolicy
+	}
+	if err := tx.Save(profile).Error; err != nil {
+		return err
+	}
+	return tx.Delete(dup).Error
+}
+
+func mergeVendorProfile(survivor, duplicate *models.Contact, tx *gorm.DB) error {
+	dup := duplicate.Vendor
+	if dup == nil {
+		return nil
+	}
+	if survivor.Vendor == nil {
+		return tx.Model(dup).Update("contact_id", survivor.ID).Error
+	}
+	if survivor.Vendor.Address == "" {
+		if err := tx.Model(survivor.Vendor).Update("address", dup.Address).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Delete(dup).Error
+}
+
+// mergeShareholderProfile تاریخچه درصد سهم دو مخاطب در یک خط زمانی ادغام می‌شود (درصد هر تاریخ = جمع
+// درصد مؤثر هر دو در آن تاریخ) تا تقسیم سود دوره‌های گذشته درست بماند؛ سپس سرمایه دوباره محاسبه می‌شود
+func mergeShareholderProfile(survivor, duplicate *models.Contact, tx *gorm.DB) error {
+	dup := duplicate.Shareholder
+	if dup == nil {
+		if survivor.Shareholder != nil {
+			return syncShareholderCapital(survivor.ID, tx)
+		}
+		return nil
+	}
+
+	survivorHistory, err := GetShareHistory(survivor.ID, tx)
+	if err != nil {
+		return err
+	}
+	duplicateHistory, err := GetShareHistory(duplicate.ID, tx)
+	if err != nil {
+		return err
+	}
+
+	// مخاطب بدون تاریخچه: درصد فعلی از ابتدای خط زمانی مؤثر بوده است
+	start := survivor.CreatedAt
+	if duplicate.CreatedAt.Before(start) {
+		start = duplicate.CreatedAt
+	}
+	for _, list := range [][]models.SharePercentageChange{survivorHistory, duplicateHistory} {
+		if len(list) > 0 && list[0].EffectiveFrom.Before(start) {
+			start = list[0].EffectiveFrom
+		}
+	}
+	if len(survivorHistory) == 0 && survivor.Shareholder != nil && survivor.Shareholder.SharePercentage > 0 {
+		survivorHistory = []models.SharePercentageChange{{Percentage: survivor.Shareholder.SharePercentage, EffectiveFrom: start}}
+	}
+	if len(duplicateHistory) == 0 && dup.SharePercentage > 0 {
+		duplicateHistory = []models.SharePercentageChange{{Percentage: dup.SharePercentage, EffectiveFrom: start}}
+	}
+
+	var dates []time.Time
+	for _, list := range [][]models.SharePercentageChange{survivorHistory, duplicateHistory} {
+		for _, change := range list {
+			dates = append(dates, change.EffectiveFrom)
+		}
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+
+	var merged []models.SharePercentageChange
+	for i, date := range dates {
+		if i > 0 && date.Equal(dates[i-1]) {
+			continue
+		}
+		percentage := percentageAt(survivorHistory, date) + percentageAt(duplicateHistory, date)
+		if n := len(merged); n > 0 && merged[n-1].Percentage == percentage {
+			continue
+		}
+		merged = append(merged, models.SharePercentageChange{
+			ContactID:     survivor.ID,
+			Percentage:    percentage,
+			EffectiveFrom: date,
+			Reason:        "merge",
+		})
+	}
+
+	if err := tx.Where("contact_id IN ?", []uint{survivor.ID, duplicate.ID}).Delete(&models.SharePercentageChange{}).Error; err != nil {
+		return err
+	}
+	if len(merged) > 0 {
+		if err := tx.Create(&merged).Error; err != nil {
+			return err
+		}
+	}
+
+	if survivor.Shareholder == nil {
+		if err := tx.Model(dup).Update("contact_id", survivor.ID).Error; err != nil {
+			return err
+		}
+	} else if err := tx.Delete(dup).Error; err != nil {
+		return err
+	}
+
+	if err := syncCurrentSharePercentage(survivor.ID, tx); err != nil {
+		return err
+	}
+	return syncShareholderCapital(survivor.ID, tx)
+}
+
+// percentageAt درصد مؤثر تاریخچه مرتب‌شده در تاریخ at (پیش از اولین تغییر صفر)
+func percentageAt(history []models.SharePercentageChange, at time.Time) float64 {
+	percentage := 0.0
+	for _, change := range history {
+		if change.EffectiveFrom.After(at) {
+			break
+		}
+		percentage = change.Percentage
+	}
+	return percentage
+}
+
+func GetContactMerges(db *gorm.DB) ([]models.ContactMerge, error) {
+	var merges []models.ContactMerge
+	err := db.Order("id DESC").Find(&merges).Error
+	return merges, err
+}
@@ -2,36 +2,116 @@ package repositories
 
 import (
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/amirqodi/hgm/internal/database"
 	"github.com/amirqodi/hgm/internal/models"
 	"gorm.io/gorm"
 )
 
+var (
+	ErrShareholderHasCapital = errors.New("سهامدار دارای سرمایه است و نقش سهامداری قابل حذف نیست")
+	ErrContactHasDividends   = errors.New("برای این مخاطب سود سهام ثبت شده و قابل حذف نیست")
+)
+
+// ContactRoleKeys نقش‌هایی که در بدنه بروزرسانی آمده‌اند؛ فقط همین نقش‌ها جایگزین می‌شوند
+// و مقدار nil برای آن‌ها یعنی حذف صریح نقش
+type ContactRoleKeys map[models.ContactType]bool
+
+// جدول رکورد هر نقش
+var contactRoleTables = map[models.ContactType]string{
+	models.Shareholder: "shareholder_profiles",
+	models.Customer:    "customer_profiles",
+	models.Vendor:      "vendor_profiles",
+}
+
+// ContactPreloads بارگذاری نقش‌ها، تلفن‌ها، آدرس‌ها و برچسب‌های مخاطب
+func ContactPreloads(db *gorm.DB) *gorm.DB {
+	return db.Preload("Shareholder").
+		Preload("Customer").
+		Preload("Vendor").
+		Preload("Phones").
+		Preload("Addresses").
+		Preload("Tags")
+}
+
+// WithContactRole فیلتر مخاطبین دارای نقش مشخص
+func WithContactRole(role models.ContactType) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		table, ok := contactRoleTables[role]
+		if !ok {
+			return db.Where("1 = 0")
+		}
+		return db.Where(fmt.Sprintf("EXISTS (SELECT 1 FROM %s WHERE %s.contact_id = contacts.id)", table, table))
+	}
+}
+
 // ---------------- Create ----------------
 func CreateContact(contact *models.Contact) error {
-	result := database.DB.Create(contact)
-	return result.Error
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := resolveTags(contact, tx); err != nil {
+			return err
+		}
+
+		// سرمایه از طریق رویدادهای سرمایه محاسبه می‌شود
+		opening := 0.0
+		if contact.Shareholder != nil {
+			opening = contact.Shareholder.Amount
+			contact.Shareholder.Amount = 0
+		}
+
+		if err := tx.Create(contact).Error; err != nil {
+			return err
+		}
+
+		if contact.Shareholder == nil {
+			return nil
+		}
+
+		if opening > 0 {
+			event := models.CapitalEvent{
+				ContactID: contact.ID,
+				Type:      models.CapitalContribution,
+				Amount:    opening,
+				Date:      contact.CreatedAt,
+				Notes:     "مانده اولیه سرمایه",
+			}
+			if err := createCapitalEvent(&event, tx); err != nil {
+				return err
+			}
+			contact.Shareholder.Amount = opening
+		}
+
+		return tx.Create(&models.SharePercentageChange{
+			ContactID:     contact.ID,
+			Percentage:    contact.Shareholder.SharePercentage,
+			EffectiveFrom: contact.CreatedAt,
+			Reason:        "manual",
+		}).Error
+	})
 }
 
 // ---------------- Read All ----------------
 func GetContacts() ([]models.Contact, error) {
 	var contacts []models.Contact
-	result := database.DB.Find(&contacts)
+	result := ContactPreloads(database.DB).Find(&contacts)
 	return contacts, result.Error
 }
 
-// ---------------- Read By Type ----------------
+// ---------------- Read By Role ----------------
 func GetContactsByType(contactType string) ([]models.Contact, error) {
 	var contacts []models.Contact
-	result := database.DB.Where("type = ?", contactType).Find(&contacts)
+	result := ContactPreloads(database.DB).
+		Scopes(WithContactRole(models.ContactType(contactType))).
+		Find(&contacts)
 	return contacts, result.Error
 }
 
 // ---------------- Read Single ----------------
 func GetContactByID(id uint) (models.Contact, error) {
 	var contact models.Contact
-	if err := database.DB.First(&contact, id).Error; err != nil {
+	if err := ContactPreloads(database.DB).First(&contact, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return contact, errors.New("contact not found")
 		}
@@ -41,21 +121,158 @@ func GetContactByID(id uint) (models.Contact, error) {
 }
 
 // ---------------- Update ----------------
-func UpdateContact(id uint, data *models.Contact) (models.Contact, error) {
+
+// UpdateContact اطلاعات پایه را بروزرسانی و نقش‌های ذکرشده در roles، تلفن‌ها، آدرس‌ها و برچسب‌ها را
+// با داده جدید جایگزین می‌کند
+func UpdateContact(id uint, data *models.Contact, roles ContactRoleKeys) (models.Contact, error) {
 	var contact models.Contact
 
 	// Find first
-	if err := database.DB.First(&contact, id).Error; err != nil {
+	if err := ContactPreloads(database.DB).First(&contact, id).Error; err != nil {
 		return contact, err
 	}
 
-	// Update fields (GORM only updates non-zero values)
-	if err := database.DB.Model(&contact).Updates(data).Error; err != nil {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&contact).
+			Select("FirstName", "LastName", "PhoneNumber", "NationalID", "EconomicCode").
+			Updates(data).Error; err != nil {
+			return err
+		}
+
+		if roles[models.Shareholder] {
+			if err := updateShareholderProfile(&contact, data.Shareholder, tx); err != nil {
+				return err
+			}
+		}
+		if roles[models.Customer] {
+			if err := replaceHasOne(tx, contact.ID, &models.CustomerProfile{}, data.Customer, func() {
+				data.Customer.ContactID = contact.ID
+			}); err != nil {
+				return err
+			}
+		}
+		if roles[models.Vendor] {
+			if err := replaceHasOne(tx, contact.ID, &models.VendorProfile{}, data.Vendor, func() {
+				data.Vendor.ContactID = contact.ID
+			}); err != nil {
+				return err
+			}
+		}
+
+		// تلفن‌ها و آدرس‌ها به‌طور کامل جایگزین می‌شوند
+		if err := tx.Where("contact_id = ?", contact.ID).Delete(&models.ContactPhone{}).Error; err != nil {
+			return err
+		}
+		for i := range data.Phones {
+			data.Phones[i].ID = 0
+			data.Phones[i].ContactID = contact.ID
+		}
+		if len(data.Phones) > 0 {
+			if err := tx.Create(&data.Phones).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("contact_id = ?", contact.ID).Delete(&models.ContactAddress{}).Error; err != nil {
+			return err
+		}
+		for i := range data.Addresses {
+			data.Addresses[i].ID = 0
+			data.Addresses[i].ContactID = contact.ID
+		}
+		if len(data.Addresses) > 0 {
+			if err := tx.Create(&data.Addresses).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := resolveTags(data, tx); err != nil {
+			return err
+		}
+		return tx.Model(&contact).Association("Tags").Replace(data.Tags)
+	})
+	if err != nil {
 		return contact, err
 	}
 
 	// Return updated contact
-	return contact, nil
+	return GetContactByID(id)
+}
+
+// updateShareholderProfile نقش سهامدار: سرمایه دست نمی‌خورد و تغییر درصد از امروز ثبت می‌شود
+func updateShareholderProfile(contact *models.Contact, profile *models.ShareholderProfile, tx *gorm.DB) error {
+	existing := contact.Shareholder
+
+	if profile == nil {
+		if existing == nil {
+			return nil
+		}
+		if existing.Amount != 0 {
+			return ErrShareholderHasCapital
+		}
+		if err := tx.Where("contact_id = ?", contact.ID).Delete(&models.SharePercentageChange{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(existing).Error
+	}
+
+	if existing == nil {
+		profile.ID = 0
+		profile.ContactID = contact.ID
+		profile.Amount = 0
+		if err := tx.Create(profile).Error; err != nil {
+			return err
+		}
+	} else if existing.SharePercentage == profile.SharePercentage {
+		return nil
+	}
+
+	if err := tx.Create(&models.SharePercentageChange{
+		ContactID:     contact.ID,
+		Percentage:    profile.SharePercentage,
+		EffectiveFrom: time.Now(),
+		Reason:        "manual",
+	}).Error; err != nil {
+		return err
+	}
+	return syncCurrentSharePercentage(contact.ID, tx)
+}
+
+// replaceHasOne رکورد نقش را حذف و در صورت وجود داده جدید دوباره ایجاد می‌کند
+func replaceHasOne(tx *gorm.DB, contactID uint, model any, data any, setContact func()) error {
+	if err := tx.Where("contact_id = ?", contactID).Delete(model).Error; err != nil {
+		return err
+	}
+
+	switch v := data.(type) {
+	case *models.CustomerProfile:
+		if v == nil {
+			return nil
+		}
+		v.ID = 0
+	case *models.VendorProfile:
+		if v == nil {
+			return nil
+		}
+		v.ID = 0
+	}
+	setContact()
+	return tx.Create(data).Error
+}
+
+// resolveTags برچسب‌ها بر اساس نام پیدا یا ایجاد می‌شوند
+func resolveTags(contact *models.Contact, tx *gorm.DB) error {
+	for i, tag := range contact.Tags {
+		if tag.Name == "" {
+			continue
+		}
+		found := models.Tag{Name: tag.Name}
+		if err := tx.Where(models.Tag{Name: tag.Name}).FirstOrCreate(&found).Error; err != nil {
+			return err
+		}
+		contact.Tags[i] = found
+	}
+	return nil
 }
 
 // ---------------- Delete ----------------
@@ -73,19 +290,56 @@ func DeleteContact(id uint) error {
 		return errors.New("این مخاطب در تراکنش‌ها استفاده شده و قابل حذف نیست")
 	}
 
-	// اگر استفاده نشده، حذف انجام شود
-	if err := database.DB.Delete(&models.Contact{}, id).Error; err != nil {
+	// آورده/برداشت مستقیم سرمایه که پول جابجا کرده باید اول حذف شود
+	if err := database.DB.Model(&models.CapitalEvent{}).
+		Where("contact_id = ? AND money_source_type <> ''", id).
+		Count(&count).Error; err != nil {
 		return err
 	}
+	if count > 0 {
+		return errors.New("این مخاطب در تراکنش‌ها استفاده شده و قابل حذف نیست")
+	}
 
-	return nil
+	// سود سهام تقسیم‌شده سابقه مالی سهامدار است
+	if err := database.DB.Model(&models.Dividend{}).Where("contact_id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrContactHasDividends
+	}
+
+	// سفارش خرید و پیش‌فاکتور مخاطب را نگه می‌دارند
+	for _, model := range []any{&models.PurchaseOrder{}, &models.Quote{}} {
+		if err := database.DB.Model(model).Where("contact_id = ?", id).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errors.New("این مخاطب در سفارش خرید یا پیش‌فاکتور استفاده شده و قابل حذف نیست")
+		}
+	}
+
+	// اگر استفاده نشده، حذف انجام شود
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []any{
+			&models.CapitalEvent{}, &models.SharePercentageChange{},
+			&models.ShareholderProfile{}, &models.CustomerProfile{}, &models.VendorProfile{},
+			&models.ContactPhone{}, &models.ContactAddress{},
+		} {
+			if err := tx.Where("contact_id = ?", id).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(&models.Contact{ID: id}).Association("Tags").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(&models.Contact{}, id).Error
+	})
 }
 
 // مجموع درصد سهم همه سهامداران (برای Create)
 func SumSharePercentage() float64 {
 	var total float64
-	database.DB.Model(&models.Contact{}).
-		Where("type = ?", models.Shareholder).
+	database.DB.Model(&models.ShareholderProfile{}).
 		Select("COALESCE(SUM(share_percentage),0)").
 		Row().Scan(&total)
 	return total
@@ -94,19 +348,20 @@ func SumSharePercentage() float64 {
 // مجموع درصد سهم همه سهامداران به جز یک ID مشخص (برای Update)
 func SumSharePercentageExcludingID(id uint) float64 {
 	var total float64
-	database.DB.Model(&models.Contact{}).
-		Where("type = ? AND id <> ?", models.Shareholder, id).
+	database.DB.Model(&models.ShareholderProfile{}).
+		Where("contact_id <> ?", id).
 		Select("COALESCE(SUM(share_percentage),0)").
 		Row().Scan(&total)
 	return total
 }
 
-// جستجو بر اساس نوع و متن
+// جستجو بر اساس نقش و متن
 func GetContactsByTypeAndSearch(contactType, search string) ([]models.Contact, error) {
 	var contacts []models.Contact
-	err := database.DB.
-		Where("type = ? AND (first_name ILIKE ? OR last_name ILIKE ? OR phone_number ILIKE ?)",
-			contactType, "%"+search+"%", "%"+search+"%", "%"+search+"%").
+	err := ContactPreloads(database.DB).
+		Scopes(WithContactRole(models.ContactType(contactType))).
+		Where("first_name ILIKE ? OR last_name ILIKE ? OR phone_number ILIKE ?",
+			"%"+search+"%", "%"+search+"%", "%"+search+"%").
 		Find(&contacts).Error
 	return contacts, err
 }
@@ -114,7 +369,7 @@ func GetContactsByTypeAndSearch(contactType, search string) ([]models.Contact, e
 // جستجو فقط بر اساس متن
 func GetContactsBySearch(search string) ([]models.Contact, error) {
 	var contacts []models.Contact
-	err := database.DB.
+	err := ContactPreloads(database.DB).
 		Where("first_name ILIKE ? OR last_name ILIKE ? OR phone_number ILIKE ?",
 			"%"+search+"%", "%"+search+"%", "%"+search+"%").
 		Find(&contacts).Error
@@ -0,0 +1,272 @@
+package repositories
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/config"
+	"github.com/amirqodi/hgm/internal/database"
+	"github.com/amirqodi/hgm/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNoRate          = errors.New("برای این ارز یا طلا هنوز نرخی ثبت نشده است")
+	ErrForeignAccount  = errors.New("این عملیات برای حساب یا تنخواه ارزی و طلا مجاز نیست")
+	ErrInvalidCurrency = errors.New("ارز باید یکی از irr، usd، tether، gold18 یا coin باشد")
+	ErrCurrencyInUse   = errors.New("ارز حساب یا تنخواهی که گردش دارد قابل تغییر نیست")
+)
+
+// ستون نرخ هر ارز در جدول prices
+var priceColumns = map[models.Currency]string{
+	models.CurrencyUSD:    "dollar",
+	models.CurrencyTether: "tether",
+	models.CurrencyGold18: "gold18",
+	models.CurrencyCoin:   "coin",
+}
+
+// PriceUnit واحد نرخ‌های ذخیره‌شده در Price (PRICE_UNIT=toman|rial، پیش‌فرض تومان مانند نواسان)
+func PriceUnit() models.CashUnit {
+	if models.CashUnit(config.Get("PRICE_UNIT", "toman")) == models.CashUnitRial {
+		return models.CashUnitRial
+	}
+	return models.CashUnitToman
+}
+
+// PriceColumn ستون نرخ ارز در جدول prices
+func PriceColumn(currency models.Currency) (string, error) {
+	column, ok := priceColumns[currency]
+	if !ok {
+		return "", ErrInvalidCurrency
+	}
+	return column, nil
+}
+
+// PriceToBookUnit تبدیل نرخ ذخیره‌شده (PRICE_UNIT) به واحد دفتر
+func PriceToBookUnit(v float64) float64 {
+	return toBookUnit(v, PriceUnit())
+}
+
+// RateAt نرخ هر واحد ارز یا طلا به واحد دفتر در زمان داده‌شده (برای ثبت اسناد):
+// آخرین نرخ ثبت‌شده تا آن زمان، وگرنه نخستین نرخ پس از آن
+func RateAt(db *gorm.DB, currency models.Currency, at time.Time) (float64, error) {
+	before, after, err := rateNeighbours(db, currency, at)
+	if err != nil {
+		return 0, err
+	}
+	price := before
+	if price == nil {
+		price = after
+	}
+	rate, _, err := rateOf(price, currency, at)
+	return rate, err
+}
+
+// NearestRate نزدیک‌ترین نرخ ثبت‌شده به زمان at (قبل یا بعد از آن، مانند UnitConverter) و زمان ثبت آن؛
+// برای تبدیل و گزارش ارزش در گذشته
+func NearestRate(db *gorm.DB, currency models.Currency, at time.Time) (float64, time.Time, error) {
+	before, after, err := rateNeighbours(db, currency, at)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	price := before
+	if price == nil || (after != nil && after.CreatedAt.Sub(at) < at.Sub(before.CreatedAt)) {
+		price = after
+	}
+	return rateOf(price, currency, at)
+}
+
+// rateNeighbours آخرین نرخ معتبر تا at و نخستین نرخ معتبر پس از آن (هر کدام ممکن است nil باشد)
+func rateNeighbours(db *gorm.DB, currency models.Currency, at time.Time) (before, after *models.Price, err error) {
+	if !currency.IsForeign() {
+		return nil, nil, nil
+	}
+	column, err := PriceColumn(currency)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var prices []models.Price
+	if err := db.Where(column+" > 0 AND created_at <= ?", at).Order("created_at DESC").Limit(1).Find(&prices).Error; err != nil {
+		return nil, nil, err
+	}
+	if len(prices) > 0 {
+		before = &prices[0]
+	}
+	prices = nil
+	if err := db.Where(column+" > 0 AND created_at > ?", at).Order("created_at ASC").Limit(1).Find(&prices).Error; err != nil {
+		return nil, nil, err
+	}
+	if len(prices) > 0 {
+		after = &prices[0]
+	}
+	if before == nil && after == nil {
+		return nil, nil, ErrNoRate
+	}
+	return before, after, nil
+}
+
+// rateOf نرخ price به واحد دفتر؛ price خالی یعنی واحد دفتر
+func rateOf(price *models.Price, currency models.Currency, at time.Time) (float64, time.Time, error) {
+	if price == nil {
+		return 1, at, nil
+	}
+	return PriceToBookUnit(price.Rate(currency)), price.CreatedAt, nil
+}
+
+// roundUnits گرد کردن مقدار ارز یا طلا (چهار رقم اعشار برای گرم طلا و سنت)
+func roundUnits(v float64) float64 {
+	return math.Round(v*10000) / 10000
+}
+
+// moneySourceCurrency ارز حساب بانکی یا تنخواه تراکنش
+func moneySourceCurrency(db *gorm.DB, sourceType string, bankAccountID, cashHolderID *uint) (models.Currency, error) {
+	switch {
+	case sourceType == "bank" && bankAccountID != nil:
+		var bank models.BankAccount
+		if err := db.Select("id", "currency").First(&bank, *bankAccountID).Error; err != nil {
+			return "", errors.New("حساب بانکی یافت نشد")
+		}
+		return bank.Currency, nil
+	case sourceType == "cash" && cashHolderID != nil:
+		var cash models.CashHolder
+		if err := db.Select("id", "currency").First(&cash, *cashHolderID).Error; err != nil {
+			return "", errors.New("تنخواه یافت نشد")
+		}
+		return cash.Currency, nil
+	}
+	return models.CurrencyRial, nil
+}
+
+// requireBookCurrency عملیاتی که مبلغ را مستقیم به مانده اضافه می‌کنند فقط روی حساب ریالی مجازند
+func requireBookCurrency(db *gorm.DB, sourceType string, bankAccountID, cashHolderID *uint) error {
+	currency, err := moneySourceCurrency(db, sourceType, bankAccountID, cashHolderID)
+	if err != nil {
+		return err
+	}
+	if currency.IsForeign() {
+		return ErrForeignAccount
+	}
+	return nil
+}
+
+// prepareForeignAmount برای حساب ارزی یا طلا نرخ تاریخ تراکنش را تعیین و معادل ریالی را محاسبه می‌کند.
+// اگر فقط مبلغ به واحد دفتر داده شده باشد (مثلاً تبدیل پیش‌فاکتور) مقدار ارزی از روی نرخ به دست می‌آید.
+func prepareForeignAmount(trx *models.Transaction, db *gorm.DB) error {
+	currency, err := moneySourceCurrency(db, trx.MoneySourceType, trx.BankAccountID, trx.CashHolderID)
+	if err != nil {
+		return err
+	}
+	if !currency.IsForeign() {
+		trx.Currency = models.CurrencyRial
+		trx.ForeignAmount = 0
+		trx.Rate = 0
+		return nil
+	}
+
+	if len(trx.SubTransactions) > 0 {
+		return errors.New("تراکنش قسطی با حساب ارزی یا طلا مجاز نیست")
+	}
+	trx.Currency = currency
+	if trx.Rate < 0 {
+		return errors.New("نرخ نامعتبر است")
+	}
+	if trx.Rate == 0 {
+		rate, err := RateAt(db, currency, transactionDate(trx))
+		if err != nil {
+			return err
+		}
+		trx.Rate = rate
+	}
+
+	switch {
+	case trx.ForeignAmount > 0:
+		trx.ForeignAmount = roundUnits(trx.ForeignAmount)
+		trx.Amount = roundAmount(trx.ForeignAmount * trx.Rate)
+	case trx.Amount > 0:
+		trx.ForeignAmount = roundUnits(trx.Amount / trx.Rate)
+	default:
+		return errors.New("مبلغ ارزی الزامیست")
+	}
+	return nil
+}
+
+// sourceAmounts تغییر مانده حساب (به واحد حساب) و ارزش دفتری برای مبلغ amount به واحد دفتر.
+// اختلاف نرخ خرید و فروش ارز تا تسعیر بعدی در ارزش دفتری باقی می‌ماند.
+func sourceAmounts(trx *models.Transaction, amount float64) (units, book float64) {
+	if !trx.Currency.IsForeign() || trx.Amount == 0 {
+		return amount, 0
+	}
+	return roundUnits(trx.ForeignAmount * amount / trx.Amount), amount
+}
+
+// rebaseBookValue ارزش دفتری حساب ارزی پس از تغییر دستی مانده؛ تغییر به نرخ روز ارزش‌گذاری می‌شود
+func rebaseBookValue(db *gorm.DB, currency models.Currency, oldBalance, newBalance, bookValue float64) (float64, error) {
+	if !currency.IsForeign() || oldBalance == newBalance {
+		return bookValue, nil
+	}
+	rate, err := RateAt(db, currency, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return roundAmount(bookValue + (newBalance-oldBalance)*rate), nil
+}
+
+// moneySourceInUse حساب یا تنخواهی که تراکنش، ودیعه یا تسعیر دارد
+func moneySourceInUse(db *gorm.DB, column string, id uint) (bool, error) {
+	for _, model := range []interface{}{&models.Transaction{}, &models.Deposit{}, &models.Revaluation{}} {
+		var count int64
+		if err := db.Model(model).Where(column+" = ?", id).Count(&count).Error; err != nil {
+			return false, err
+		}
+		if count > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// prepareAccountCurrency ارز حساب یا تنخواه جدید؛ ارزش دفتری مانده اولیه اگر داده نشده باشد به نرخ روز محاسبه می‌شود
+func prepareAccountCurrency(currency *models.Currency, balance float64, bookValue *float64) error {
+	if *currency == "" {
+		*currency = models.CurrencyRial
+	}
+	if !currency.Valid() {
+		return ErrInvalidCurrency
+	}
+	if !currency.IsForeign() {
+		*bookValue = 0
+		return nil
+	}
+	if *bookValue > 0 {
+		return nil
+	}
+	value, err := rebaseBookValue(database.DB, *currency, 0, balance, 0)
+	if err != nil {
+		return err
+	}
+	*bookValue = value
+	return nil
+}
+
+// updatedBookValue ارزش دفتری حساب پس از ویرایش؛ ارز حساب دارای گردش قابل تغییر نیست
+func updatedBookValue(column string, id uint, currency models.Currency, balance, bookValue float64, newCurrency models.Currency, newBalance float64) (float64, error) {
+	if newBalance == 0 {
+		newBalance = balance // Updates مقدار صفر را نادیده می‌گیرد
+	}
+	if newCurrency == "" || newCurrency == currency {
+		return rebaseBookValue(database.DB, currency, balance, newBalance, bookValue)
+	}
+	if !newCurrency.Valid() {
+		return 0, ErrInvalidCurrency
+	}
+	used, err := moneySourceInUse(database.DB, column, id)
+	if err != nil {
+		return 0, err
+	}
+	if used {
+		return 0, ErrCurrencyInUse
+	}
+	return rebaseBookValue(database.DB, newCurrency, 0, newBalance, 0)
+}
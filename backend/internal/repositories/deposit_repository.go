@@ -16,6 +16,9 @@ func CreateDeposit(dep *models.Deposit, db *gorm.DB) error {
 		if dep.MoneySourceType == "cash" && dep.CashHolderID == nil {
 			return errors.New("تنخواه الزامیست")
 		}
+		if err := requireBookCurrency(tx, dep.MoneySourceType, dep.BankAccountID, dep.CashHolderID); err != nil {
+			return err
+		}
 
 		// ذخیره ودیعه
 		if err := tx.Create(dep).Error; err != nil {
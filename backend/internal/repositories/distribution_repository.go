@@ -0,0 +1,260 @@
+package repositories
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/models"
+	"gorm.io/gorm"
+)
+
+// DistributionInput پارامترهای اجرای تقسیم سود
+type DistributionInput struct {
+	PeriodStart       time.Time `json:"period_start"`
+	PeriodEnd         time.Time `json:"period_end"`
+	DistributePercent float64   `json:"distribute_percent"` // پیش‌فرض ۱۰۰
+	Notes             string    `json:"notes"`
+
+	// اگر PayNow باشد سود همان لحظه از منبع انتخابی پرداخت می‌شود
+	PayNow          bool   `json:"pay_now"`
+	MoneySourceType string `json:"money_source_type"`
+	BankAccountID   *uint  `json:"bank_account_id"`
+	CashHolderID    *uint  `json:"cash_holder_id"`
+}
+
+// DividendPayment منبع پرداخت سود یک سهامدار
+type DividendPayment struct {
+	MoneySourceType string `json:"money_source_type"`
+	BankAccountID   *uint  `json:"bank_account_id"`
+	CashHolderID    *uint  `json:"cash_holder_id"`
+}
+
+// ---------------- CREATE ----------------
+func CreateProfitDistribution(in DistributionInput, db *gorm.DB) (*models.ProfitDistribution, error) {
+	if in.PeriodStart.IsZero() || in.PeriodEnd.IsZero() || !in.PeriodEnd.After(in.PeriodStart) {
+		return nil, errors.New("بازه دوره مالی نامعتبر است")
+	}
+	if in.DistributePercent == 0 {
+		in.DistributePercent = 100
+	}
+	if in.DistributePercent < 0 || in.DistributePercent > 100 {
+		return nil, errors.New("درصد تقسیم سود باید بین ۰ تا ۱۰۰ باشد")
+	}
+
+	var dist *models.ProfitDistribution
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// جلوگیری از تقسیم دوباره سود یک دوره
+		var overlap int64
+		if err := tx.Model(&models.ProfitDistribution{}).
+			Where("period_start < ? AND period_end > ?", in.PeriodEnd, in.PeriodStart).
+			Count(&overlap).Error; err != nil {
+			return err
+		}
+		if overlap > 0 {
+			return errors.New("برای این بازه قبلاً تقسیم سود ثبت شده است")
+		}
+
+		income, expense, err := PeriodIncomeExpense(tx, in.PeriodStart, in.PeriodEnd)
+		if err != nil {
+			return err
+		}
+		netProfit := income - expense
+		if netProfit <= 0 {
+			return errors.New("در این دوره سود خالصی برای تقسیم وجود ندارد")
+		}
+
+		// درصد سهم مؤثر در پایان دوره (تاریخچه تغییرات سهام)
+		splits, err := ShareSplitAt(in.PeriodEnd.Add(-time.Nanosecond), tx)
+		if err != nil {
+			return err
+		}
+		if len(splits) == 0 {
+			return errors.New("هیچ سهامداری با درصد سهم ثبت نشده است")
+		}
+
+		distributable := roundAmount(netProfit * in.DistributePercent / 100)
+		dist = &models.ProfitDistribution{
+			PeriodStart:       in.PeriodStart,
+			PeriodEnd:         in.PeriodEnd,
+			Income:            income,
+			Expense:           expense,
+			NetProfit:         netProfit,
+			DistributePercent: in.DistributePercent,
+			Notes:             in.Notes,
+		}
+
+		now := time.Now()
+		for _, split := range splits {
+			dividend := models.Dividend{
+				ContactID:       split.ContactID,
+				SharePercentage: split.Percentage,
+				Amount:          roundAmount(distributable * split.Percentage / 100),
+				Status:          models.DividendPayable,
+			}
+			if in.PayNow {
+				dividend.Status = models.DividendPaid
+				dividend.MoneySourceType = in.MoneySourceType
+				dividend.BankAccountID = in.BankAccountID
+				dividend.CashHolderID = in.CashHolderID
+				dividend.PaidAt = &now
+			}
+			dist.DistributedAmount += dividend.Amount
+			dist.Dividends = append(dist.Dividends, dividend)
+		}
+
+		if err := tx.Create(dist).Error; err != nil {
+			return err
+		}
+
+		if in.PayNow {
+			if err := CheckReconciliationLock(tx, in.MoneySourceType, in.BankAccountID, now); err != nil {
+				return err
+			}
+			if err := moveMoney(tx, in.MoneySourceType, in.BankAccountID, in.CashHolderID, -dist.DistributedAmount); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return GetProfitDistributionByID(dist.ID, db)
+}
+
+// ---------------- READ ----------------
+func GetProfitDistributions(db *gorm.DB) ([]models.ProfitDistribution, error) {
+	var dists []models.ProfitDistribution
+	err := db.Preload("Dividends.Contact").Order("period_end DESC").Find(&dists).Error
+	return dists, err
+}
+
+func GetProfitDistributionByID(id uint, db *gorm.DB) (*models.ProfitDistribution, error) {
+	var dist models.ProfitDistribution
+	err := db.Preload("Dividends.Contact").
+		Preload("Dividends.BankAccount").
+		Preload("Dividends.CashHolder").
+		First(&dist, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &dist, nil
+}
+
+// ---------------- PAY ----------------
+func PayDividend(id uint, payment DividendPayment, db *gorm.DB) (*models.Dividend, error) {
+	var dividend models.Dividend
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&dividend, id).Error; err != nil {
+			return errors.New("سود سهامدار یافت نشد")
+		}
+		if dividend.Status == models.DividendPaid {
+			return errors.New("این سود قبلاً پرداخت شده است")
+		}
+
+		now := time.Now()
+		if err := CheckReconciliationLock(tx, payment.MoneySourceType, payment.BankAccountID, now); err != nil {
+			return err
+		}
+		if err := moveMoney(tx, payment.MoneySourceType, payment.BankAccountID, payment.CashHolderID, -dividend.Amount); err != nil {
+			return err
+		}
+
+		dividend.Status = models.DividendPaid
+		dividend.MoneySourceType = payment.MoneySourceType
+		dividend.BankAccountID = payment.BankAccountID
+		dividend.CashHolderID = payment.CashHolderID
+		dividend.PaidAt = &now
+		return tx.Save(&dividend).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &dividend, nil
+}
+
+// ---------------- DELETE ----------------
+func DeleteProfitDistribution(id uint, db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		dist, err := GetProfitDistributionByID(id, tx)
+		if err != nil {
+			return err
+		}
+
+		// سودهای پرداخت‌شده به منبع پول برگردانده می‌شوند
+		for _, d := range dist.Dividends {
+			if d.Status != models.DividendPaid {
+				continue
+			}
+			if d.PaidAt != nil {
+				if err := CheckReconciliationLock(tx, d.MoneySourceType, d.BankAccountID, *d.PaidAt); err != nil {
+					return err
+				}
+			}
+			if err := moveMoney(tx, d.MoneySourceType, d.BankAccountID, d.CashHolderID, d.Amount); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("distribution_id = ?", dist.ID).Delete(&models.Dividend{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(dist).Error
+	})
+}
+
+// ProfitTransactions تراکنش‌های درآمد و هزینه بازه [from, to) برای سود و زیان؛ تاریخ خالی = زمان ثبت.
+// آورده و برداشت سهامداران (تراکنش‌های دارای رویداد سرمایه) سود یا زیان نیست.
+func ProfitTransactions(db *gorm.DB, from, to time.Time) *gorm.DB {
+	return db.Model(&models.Transaction{}).
+		Where("transaction_type IN ?", []string{"income", "expense"}).
+		Where("COALESCE(transaction_date, created_at) >= ? AND COALESCE(transaction_date, created_at) < ?", from, to).
+		Where("id NOT IN (?)", db.Model(&models.CapitalEvent{}).Select("transaction_id").Where("transaction_id IS NOT NULL"))
+}
+
+// PeriodIncomeExpense جمع درآمد و هزینه یک بازه مانند صورت سود و زیان (به‌علاوه OtherIncomeExpense)
+func PeriodIncomeExpense(db *gorm.DB, from, to time.Time) (income, expense float64, err error) {
+	var sums []struct {
+		TransactionType string
+		Total           float64
+	}
+	if err = ProfitTransactions(db, from, to).
+		Select("transaction_type, COALESCE(SUM(amount),0) AS total").
+		Group("transaction_type").Scan(&sums).Error; err != nil {
+		return
+	}
+	for _, sum := range sums {
+		if sum.TransactionType == "income" {
+			income = sum.Total
+		} else {
+			expense = sum.Total
+		}
+	}
+
+	otherIncome, otherExpense, err := OtherIncomeExpense(db, &from, &to)
+	if err != nil {
+		return
+	}
+	income += otherIncome
+	expense += otherExpense
+	return
+}
+
+// OtherIncomeExpense درآمد و هزینه‌های بدون تراکنش: سود/کارمزد بانکی و اضافه/کسری صندوق
+func OtherIncomeExpense(db *gorm.DB, from, to *time.Time) (income, expense float64, err error) {
+	interest, fees, err := SumBankCharges(db, from, to)
+	if err != nil {
+		return
+	}
+	over, short, err := SumCashOverShort(db, from, to)
+	if err != nil {
+		return
+	}
+	return interest + over, fees + short, nil
+}
+
+func roundAmount(v float64) float64 {
+	return math.Round(v)
+}
@@ -0,0 +1,320 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/config"
+	"github.com/amirqodi/hgm/internal/models"
+	"github.com/amirqodi/hgm/internal/utils"
+	ptime "github.com/yaa110/go-persian-calendar"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvoiceIssued = errors.New("برای این تراکنش فاکتور یا رسید معتبر صادر شده است؛ ابتدا آن را باطل کنید")
+
+var invoicePrefixes = map[models.InvoiceKind]string{
+	models.InvoiceSales:    "S",
+	models.InvoicePurchase: "P",
+	models.InvoiceReceipt:  "R",
+}
+
+type InvoiceFilter struct {
+	Kind          models.InvoiceKind
+	ContactID     uint
+	TransactionID uint
+	FiscalYear    int
+}
+
+// DefaultTaxRate نرخ مالیات بر ارزش افزوده پیش‌فرض فاکتورها (VAT_RATE، پیش‌فرض صفر)
+func DefaultTaxRate() float64 {
+	rate, err := strconv.ParseFloat(config.Get("VAT_RATE", "0"), 64)
+	if err != nil || rate < 0 {
+		return 0
+	}
+	return rate
+}
+
+// FiscalYear سال مالی (شمسی) یک تاریخ
+func FiscalYear(t time.Time) int {
+	return ptime.New(t).Year()
+}
+
+func InvoiceNumber(kind models.InvoiceKind, fiscalYear, sequence int) string {
+	return fmt.Sprintf("%s-%d-%05d", invoicePrefixes[kind], fiscalYear, sequence)
+}
+
+// nextDocumentNumber شماره بعدی سند را رزرو می‌کند؛ باید داخل تراکنش ثبت سند صدا زده شود
+// تا در صورت خطا شماره هم برگردد و فاصله‌ای در شماره‌ها ایجاد نشود.
+func nextDocumentNumber(tx *gorm.DB, kind models.InvoiceKind, fiscalYear int) (int, error) {
+	seq := models.DocumentSequence{Kind: kind, FiscalYear: fiscalYear}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&seq).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Model(&models.DocumentSequence{}).
+		Where("kind = ? AND fiscal_year = ?", kind, fiscalYear).
+		Update("last_number", gorm.Expr("last_number + 1")).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Where("kind = ? AND fiscal_year = ?", kind, fiscalYear).First(&seq).Error; err != nil {
+		return 0, err
+	}
+	return seq.LastNumber, nil
+}
+
+// CreateInvoice صدور فاکتور فروش/خرید یا رسید برای یک تراکنش.
+// مبلغ تراکنش شامل مالیات در نظر گرفته می‌شود؛ اگر اقلام داده نشود از کالا یا دسته تراکنش ساخته می‌شود.
+func CreateInvoice(inv *models.Invoice, db *gorm.DB) error {
+	if _, ok := invoicePrefixes[inv.Kind]; !ok {
+		return errors.New("نوع سند باید sales، purchase یا receipt باشد")
+	}
+	if inv.TaxRate < 0 || inv.TaxRate > 100 {
+		return errors.New("نرخ مالیات نامعتبر است")
+	}
+
+	var trx models.Transaction
+	if err := db.Preload("SubTransactions").Preload("Category").Preload("Product").
+		First(&trx, inv.TransactionID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("تراکنش یافت نشد")
+		}
+		return err
+	}
+
+	switch inv.Kind {
+	case models.InvoiceSales:
+		if trx.TransactionType != "income" {
+			return errors.New("فاکتور فروش فقط برای تراکنش درآمد صادر می‌شود")
+		}
+	case models.InvoicePurchase:
+		if trx.TransactionType != "expense" {
+			return errors.New("فاکتور خرید فقط برای تراکنش هزینه صادر می‌شود")
+		}
+	case models.InvoiceReceipt:
+		if trx.TransactionType != "income" && trx.TransactionType != "expense" {
+			return errors.New("رسید فقط برای تراکنش درآمد یا هزینه صادر می‌شود")
+		}
+	}
+
+	// تاریخ انتخابی کاربر بررسی می‌شود؛ تاریخ پیش‌فرض رسید (تاریخ پرداخت قسط) اگر معتبر نباشد امروز می‌شود
+	chosenDate := !inv.IssuedAt.IsZero()
+
+	if inv.Kind == models.InvoiceReceipt {
+		if err := prepareReceipt(inv, &trx); err != nil {
+			return err
+		}
+	} else {
+		inv.SubTransactionID = nil
+		if err := prepareInvoiceItems(inv, &trx); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	if inv.IssuedAt.IsZero() {
+		inv.IssuedAt = now
+	}
+	inv.ContactID = trx.ContactID
+	inv.Status = models.InvoiceIssued
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := checkIssueDate(tx, inv.Kind, inv.IssuedAt, now); err != nil {
+			if chosenDate {
+				return err
+			}
+			inv.IssuedAt = now
+		}
+		inv.FiscalYear = FiscalYear(inv.IssuedAt)
+
+		// هر تراکنش (یا قسط) فقط یک سند معتبر از هر نوع دارد
+		var existing models.Invoice
+		scope := tx.Where("kind = ? AND transaction_id = ? AND status = ?", inv.Kind, inv.TransactionID, models.InvoiceIssued)
+		if inv.SubTransactionID != nil {
+			scope = scope.Where("sub_transaction_id = ?", *inv.SubTransactionID)
+		} else {
+			scope = scope.Where("sub_transaction_id IS NULL")
+		}
+		if err := scope.First(&existing).Error; err == nil {
+			return fmt.Errorf("برای این تراکنش قبلاً سند شماره %s صادر شده است", existing.Number)
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		seq, err := nextDocumentNumber(tx, inv.Kind, inv.FiscalYear)
+		if err != nil {
+			return err
+		}
+		inv.Sequence = seq
+		inv.Number = InvoiceNumber(inv.Kind, inv.FiscalYear, seq)
+		return tx.Create(inv).Error
+	})
+}
+
+// checkIssueDate تاریخ صدور باید در سال مالی جاری، نه در آینده و نه پیش از روز آخرین سند همان نوع باشد
+// تا ترتیب شماره‌ها با ترتیب تاریخ‌ها یکی بماند
+func checkIssueDate(tx *gorm.DB, kind models.InvoiceKind, issuedAt, now time.Time) error {
+	year := FiscalYear(now)
+	if FiscalYear(issuedAt) != year {
+		return fmt.Errorf("تاریخ صدور باید در سال مالی جاری (%s) باشد", utils.PersianDigits(strconv.Itoa(year)))
+	}
+	if startOfDay(issuedAt).After(startOfDay(now)) {
+		return errors.New("تاریخ صدور نمی‌تواند در آینده باشد")
+	}
+
+	var last models.Invoice
+	err := tx.Where("kind = ? AND fiscal_year = ?", kind, year).Order("sequence DESC").First(&last).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if startOfDay(issuedAt).Before(startOfDay(last.IssuedAt)) {
+		return fmt.Errorf("تاریخ صدور نمی‌تواند پیش از تاریخ آخرین سند (%s در %s) باشد",
+			last.Number, utils.PersianDigits(utils.FormatJalali(last.IssuedAt)))
+	}
+	return nil
+}
+
+// prepareReceipt مبلغ رسید: کل تراکنش پرداخت‌شده یا یک قسط پرداخت‌شده
+func prepareReceipt(inv *models.Invoice, trx *models.Transaction) error {
+	inv.Items = nil
+	inv.TaxRate, inv.TaxAmount = 0, 0
+
+	if inv.SubTransactionID != nil {
+		var sub *models.SubTransaction
+		for i := range trx.SubTransactions {
+			if trx.SubTransactions[i].ID == *inv.SubTransactionID {
+				sub = &trx.SubTransactions[i]
+			}
+		}
+		if sub == nil {
+			return errors.New("قسط متعلق به این تراکنش نیست")
+		}
+		if !sub.IsPaid {
+			return errors.New("قسط هنوز پرداخت نشده است")
+		}
+		inv.Subtotal, inv.Total = sub.Amount, sub.Amount
+		if inv.IssuedAt.IsZero() && sub.PaidAt != nil {
+			inv.IssuedAt = *sub.PaidAt
+		}
+		return nil
+	}
+
+	if len(trx.SubTransactions) > 0 {
+		return errors.New("برای تراکنش اقساطی، رسید هر قسط جداگانه صادر می‌شود")
+	}
+	if !trx.IsPaid {
+		return errors.New("تراکنش هنوز پرداخت نشده است")
+	}
+	inv.Subtotal, inv.Total = trx.Amount, trx.Amount
+	return nil
+}
+
+// prepareInvoiceItems اقلام، مالیات و جمع فاکتور؛ جمع کل باید با مبلغ تراکنش برابر باشد
+func prepareInvoiceItems(inv *models.Invoice, trx *models.Transaction) error {
+	if len(inv.Items) == 0 {
+		subtotal := roundAmount(trx.Amount / (1 + inv.TaxRate/100))
+		item := models.InvoiceItem{Description: trx.Category.Name, Quantity: 1, Total: subtotal}
+		if trx.Product != nil {
+			item.ProductID = trx.ProductID
+			item.Description = trx.Product.Name
+			if trx.Quantity > 0 {
+				item.Quantity = float64(trx.Quantity)
+			}
+		}
+		item.UnitPrice = math.Round(subtotal/item.Quantity*100) / 100
+		inv.Items = []models.InvoiceItem{item}
+	}
+
+	inv.Subtotal = 0
+	for i := range inv.Items {
+		item := &inv.Items[i]
+		item.ID = 0
+		if item.Description == "" {
+			return errors.New("شرح اقلام الزامی است")
+		}
+		if item.Quantity <= 0 || item.UnitPrice < 0 {
+			return errors.New("تعداد یا مبلغ اقلام نامعتبر است")
+		}
+		if item.Total == 0 {
+			item.Total = roundAmount(item.Quantity * item.UnitPrice)
+		}
+		inv.Subtotal += item.Total
+	}
+	inv.Subtotal = roundAmount(inv.Subtotal)
+	inv.Total = trx.Amount
+	inv.TaxAmount = roundAmount(inv.Total - inv.Subtotal)
+
+	expectedTax := inv.Subtotal * inv.TaxRate / 100
+	if math.Abs(inv.TaxAmount-expectedTax) > 1 {
+		return fmt.Errorf("جمع اقلام به‌علاوه مالیات (%.0f) با مبلغ تراکنش (%.0f) برابر نیست", inv.Subtotal+expectedTax, trx.Amount)
+	}
+	return nil
+}
+
+// VoidInvoice ابطال سند؛ شماره آن رزرو می‌ماند و می‌توان برای همان تراکنش سند جدید صادر کرد
+func VoidInvoice(id uint, reason string, userID *uint, db *gorm.DB) (*models.Invoice, error) {
+	var inv models.Invoice
+	if err := db.First(&inv, id).Error; err != nil {
+		return nil, err
+	}
+	if inv.Status == models.InvoiceVoid {
+		return nil, errors.New("سند قبلاً باطل شده است")
+	}
+	if reason == "" {
+		return nil, errors.New("علت ابطال الزامی است")
+	}
+
+	now := time.Now()
+	if err := db.Model(&inv).Updates(map[string]interface{}{
+		"status":       models.InvoiceVoid,
+		"voided_at":    now,
+		"voided_by_id": userID,
+		"void_reason":  reason,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return GetInvoiceByID(id, db)
+}
+
+func GetInvoices(filter InvoiceFilter, db *gorm.DB) ([]models.Invoice, error) {
+	var invoices []models.Invoice
+	q := db.Preload("Contact")
+	if filter.Kind != "" {
+		q = q.Where("kind = ?", filter.Kind)
+	}
+	if filter.ContactID != 0 {
+		q = q.Where("contact_id = ?", filter.ContactID)
+	}
+	if filter.TransactionID != 0 {
+		q = q.Where("transaction_id = ?", filter.TransactionID)
+	}
+	if filter.FiscalYear != 0 {
+		q = q.Where("fiscal_year = ?", filter.FiscalYear)
+	}
+	err := q.Order("issued_at DESC, id DESC").Find(&invoices).Error
+	return invoices, err
+}
+
+// GetInvoiceByID سند همراه با اطلاعات لازم برای چاپ (مشتری، خودرو، پرداخت‌ها و اقساط)
+func GetInvoiceByID(id uint, db *gorm.DB) (*models.Invoice, error) {
+	var inv models.Invoice
+	err := db.Preload("Items").
+		Preload("Contact.Customer").
+		Preload("Contact.Vendor").
+		Preload("Contact.Addresses").
+		Preload("Transaction.SubTransactions").
+		Preload("Transaction.BankAccount").
+		Preload("Transaction.CashHolder").
+		Preload("SubTransaction").
+		First(&inv, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
@@ -0,0 +1,69 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/amirqodi/hgm/internal/database"
+	"github.com/amirqodi/hgm/internal/models"
+	"gorm.io/gorm"
+)
+
+func CreateLoginAttempt(attempt *models.LoginAttempt) error {
+	return database.DB.Create(attempt).Error
+}
+
+// FailedLogins تعداد تلاش‌های ناموفق column (username یا ip) پس از since و زمان آخرین آن‌ها
+func FailedLogins(column, value string, since time.Time) (int64, time.Time, error) {
+	query := database.DB.Model(&models.LoginAttempt{}).
+		Where(column+" = ? AND result IN ? AND created_at > ?", value, models.FailedLoginResults, since).
+		Session(&gorm.Session{})
+	var count int64
+	if err := query.Count(&count).Error; err != nil || count == 0 {
+		return count, time.Time{}, err
+	}
+	var last models.LoginAttempt
+	err := query.Order("created_at DESC, id DESC").First(&last).Error
+	return count, last.CreatedAt, err
+}
+
+// LastSuccessfulLogin زمان آخرین ورود موفق نام کاربری؛ ورود موفق شمارش تلاش‌های ناموفق را صفر می‌کند
+func LastSuccessfulLogin(username string) (time.Time, error) {
+	var attempts []models.LoginAttempt
+	err := database.DB.Where("username = ? AND result = ?", username, models.LoginSuccess).
+		Order("created_at DESC, id DESC").Limit(1).Find(&attempts).Error
+	if err != nil || len(attempts) == 0 {
+		return time.Time{}, err
+	}
+	return attempts[0].CreatedAt, nil
+}
+
+type LoginAttemptFilter struct {
+	UserID     uint
+	Username   string
+	IP         string
+	FailedOnly bool
+	Limit      int
+}
+
+// GetLoginAttempts سابقه ورود، جدیدترین اول
+func GetLoginAttempts(filter LoginAttemptFilter) ([]models.LoginAttempt, error) {
+	query := database.DB.Order("created_at DESC, id DESC")
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Username != "" {
+		query = query.Where("username = ?", filter.Username)
+	}
+	if filter.IP != "" {
+		query = query.Where("ip = ?", filter.IP)
+	}
+	if filter.FailedOnly {
+		query = query.Where("result <> ?", models.LoginSuccess)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	var list []models.LoginAttempt
+	err := query.Find(&list).Error
+	return list, err
+}
@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"errors"
+
+	"github.com/amirqodi/hgm/internal/models"
+	"gorm.io/gorm"
+)
+
+// moveMoney موجودی حساب بانکی یا تنخواه را به اندازه delta تغییر می‌دهد
+// (delta مثبت = واریز، منفی = برداشت) و از منفی شدن موجودی جلوگیری می‌کند؛
+// delta به واحد دفتر است و حساب ارزی یا طلا پذیرفته نمی‌شود
+func moveMoney(db *gorm.DB, sourceType string, bankAccountID, cashHolderID *uint, delta float64) error {
+	switch sourceType {
+	case "bank":
+		if bankAccountID == nil {
+			return errors.New("حساب بانکی الزامیست")
+		}
+		var bank models.BankAccount
+		if err := db.First(&bank, *bankAccountID).Error; err != nil {
+			return errors.New("حساب بانکی یافت نشد")
+		}
+		if bank.Currency.IsForeign() {
+			return ErrForeignAccount
+		}
+		if bank.Balance+delta < 0 {
+			return errors.New("موجودی حساب بانکی کافی نیست")
+		}
+		bank.Balance += delta
+		return db.Save(&bank).Error
+
+	case "cash":
+		if cashHolderID == nil {
+			return errors.New("تنخواه الزامیست")
+		}
+		var cash models.CashHolder
+		if err := db.First(&cash, *cashHolderID).Error; err != nil {
+			return errors.New("تنخواه یافت نشد")
+		}
+		if cash.Currency.IsForeign() {
+			return ErrForeignAccount
+		}
+		if cash.Balance+delta < 0 {
+			return errors.New("موجودی تنخواه کافی نیست")
+		}
+		cash.Balance += delta
+		return db.Save(&cash).Error
+
+	default:
+		return errors.New("نوع منبع پول نامعتبر است")
+	}
+}
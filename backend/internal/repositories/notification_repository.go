@@ -0,0 +1,48 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/amirqodi/hgm/internal/models"
+	"gorm.io/gorm"
+)
+
+func CreateNotification(n *models.Notification, db *gorm.DB) error {
+	return db.Create(n).Error
+}
+
+// HasUnreadNotification برای جلوگیری از تکرار اعلانی که هنوز خوانده نشده
+func HasUnreadNotification(kind models.NotificationKind, db *gorm.DB) (bool, error) {
+	var count int64
+	err := db.Model(&models.Notification{}).Where("kind = ? AND read_at IS NULL", kind).Count(&count).Error
+	return count > 0, err
+}
+
+// GetNotifications جدیدترین اعلان‌ها؛ unreadOnly فقط خوانده‌نشده‌ها
+func GetNotifications(unreadOnly bool, limit int, db *gorm.DB) ([]models.Notification, error) {
+	query := db.Order("created_at DESC, id DESC")
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	var list []models.Notification
+	err := query.Find(&list).Error
+	return list, err
+}
+
+func MarkNotificationRead(id uint, db *gorm.DB) error {
+	var n models.Notification
+	if err := db.First(&n, id).Error; err != nil {
+		return err
+	}
+	if n.ReadAt != nil {
+		return nil
+	}
+	return db.Model(&n).Update("read_at", time.Now()).Error
+}
+
+func MarkAllNotificationsRead(db *gorm.DB) error {
+	return db.Model(&models.Notification{}).Where("read_at IS NULL").Update("read_at", time.Now()).Error
+}
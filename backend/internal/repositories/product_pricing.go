@@ -0,0 +1,211 @@
+package repositories
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidPricingRule = errors.New("قاعده قیمت‌گذاری نامعتبر است؛ واحد باید ارز یا طلا و قیمت پایه و گرد کردن نامنفی باشد")
+	ErrPriceChangeClosed  = errors.New("این تغییر قیمت قبلاً بررسی شده است")
+)
+
+// ruleSellingPrice قیمت فروش از قاعده محصول با نرخ هر واحد به واحد دفتر
+func ruleSellingPrice(p *models.ProductService, rate float64) float64 {
+	price := *p.BasePrice * rate * (1 + p.Markup/100)
+	if p.RoundTo > 0 {
+		return math.Round(price/p.RoundTo) * p.RoundTo
+	}
+	return roundAmount(price)
+}
+
+// ValidatePricingRule بررسی فیلدهای قاعده؛ واحد خالی یا irr یعنی بدون قاعده
+func ValidatePricingRule(p *models.ProductService) error {
+	if p.PriceUnit == "" || p.PriceUnit == models.CurrencyRial {
+		return nil
+	}
+	if !p.PriceUnit.Valid() || p.BasePrice == nil || *p.BasePrice <= 0 || p.Markup <= -100 || p.RoundTo < 0 {
+		return ErrInvalidPricingRule
+	}
+	return nil
+}
+
+// ApplyPricingRule قیمت فروش محصول دارای قاعده را با آخرین نرخ تا at تنظیم می‌کند
+func ApplyPricingRule(p *models.ProductService, at time.Time, db *gorm.DB) error {
+	if !p.HasPricingRule() {
+		return nil
+	}
+	rate, err := RateAt(db, p.PriceUnit, at)
+	if err != nil {
+		return err
+	}
+	p.SellingPrice = ruleSellingPrice(p, rate)
+	return nil
+}
+
+// recordPriceChange ثبت سابقه تغییر قیمتی که مستقیماً اعمال شده است
+func recordPriceChange(p *models.ProductService, oldPrice float64, userID *uint, db *gorm.DB) error {
+	if oldPrice == p.SellingPrice {
+		return nil
+	}
+	now := time.Now()
+	change := models.ProductPriceChange{
+		ProductServiceID: p.ID,
+		Reason:           models.PriceChangeManual,
+		Status:           models.PriceChangeApproved,
+		PriceUnit:        p.PriceUnit,
+		OldPrice:         oldPrice,
+		NewPrice:         p.SellingPrice,
+		ReviewedByID:     userID,
+		ReviewedAt:       &now,
+	}
+	if p.HasPricingRule() {
+		change.Rate, _ = RateAt(db, p.PriceUnit, now)
+	}
+	return db.Create(&change).Error
+}
+
+// ProposeProductPrices پیشنهاد قیمت فروش جدید برای محصولات دارای قاعده پس از ثبت نرخ؛
+// پیشنهادهای در انتظار قبلی همان محصول جایگزین می‌شوند. ارزهایی که price آخرین نرخ آن‌ها نیست
+// (مثل نرخ دستی با تاریخ گذشته) نادیده گرفته می‌شوند. تعداد پیشنهادهای جدید برگردانده می‌شود.
+func ProposeProductPrices(price *models.Price, db *gorm.DB) (int, error) {
+	var products []models.ProductService
+	if err := db.Where("price_unit IS NOT NULL AND price_unit NOT IN ? AND base_price > 0", []string{"", string(models.CurrencyRial)}).
+		Order("id").Find(&products).Error; err != nil {
+		return 0, err
+	}
+
+	latest := map[models.Currency]bool{}
+	for _, p := range products {
+		if _, ok := latest[p.PriceUnit]; ok || price.Rate(p.PriceUnit) <= 0 {
+			continue
+		}
+		isLatest, err := isLatestRate(price, p.PriceUnit, db)
+		if err != nil {
+			return 0, err
+		}
+		latest[p.PriceUnit] = isLatest
+	}
+
+	created := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for i := range products {
+			p := &products[i]
+			if !latest[p.PriceUnit] {
+				continue
+			}
+			raw := price.Rate(p.PriceUnit)
+			rate := PriceToBookUnit(raw)
+			newPrice := ruleSellingPrice(p, rate)
+
+			if err := tx.Model(&models.ProductPriceChange{}).
+				Where("product_service_id = ? AND status = ?", p.ID, models.PriceChangePending).
+				Update("status", models.PriceChangeSuperseded).Error; err != nil {
+				return err
+			}
+			if newPrice == p.SellingPrice {
+				continue
+			}
+
+			priceID := price.ID
+			change := models.ProductPriceChange{
+				ProductServiceID: p.ID,
+				Reason:           models.PriceChangeRate,
+				Status:           models.PriceChangePending,
+				PriceID:          &priceID,
+				PriceUnit:        p.PriceUnit,
+				Rate:             rate,
+				OldPrice:         p.SellingPrice,
+				NewPrice:         newPrice,
+			}
+			if err := tx.Create(&change).Error; err != nil {
+				return err
+			}
+			created++
+		}
+		return nil
+	})
+	return created, err
+}
+
+// isLatestRate آیا پس از price نرخ معتبر دیگری برای currency ثبت نشده است
+func isLatestRate(price *models.Price, currency models.Currency, db *gorm.DB) (bool, error) {
+	column, err := PriceColumn(currency)
+	if err != nil {
+		return false, err
+	}
+	var newer int64
+	err = db.Model(&models.Price{}).
+		Where(column+" > 0 AND id <> ? AND (created_at > ? OR (created_at = ? AND id > ?))", price.ID, price.CreatedAt, price.CreatedAt, price.ID).
+		Count(&newer).Error
+	return newer == 0, err
+}
+
+type PriceChangeFilter struct {
+	ProductID uint
+	Status    models.PriceChangeStatus
+}
+
+func GetProductPriceChanges(filter PriceChangeFilter, db *gorm.DB) ([]models.ProductPriceChange, error) {
+	query := db.Preload("ProductService").Order("created_at DESC, id DESC")
+	if filter.ProductID != 0 {
+		query = query.Where("product_service_id = ?", filter.ProductID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	var list []models.ProductPriceChange
+	err := query.Find(&list).Error
+	return list, err
+}
+
+// ReviewProductPriceChanges تأیید (اعمال قیمت جدید) یا رد پیشنهادهای در انتظار؛ ids خالی = همه
+func ReviewProductPriceChanges(ids []uint, approve bool, userID *uint, db *gorm.DB) ([]models.ProductPriceChange, error) {
+	var changes []models.ProductPriceChange
+	err := db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Order("id")
+		if len(ids) > 0 {
+			query = query.Where("id IN ?", ids)
+		} else {
+			query = query.Where("status = ?", models.PriceChangePending)
+		}
+		if err := query.Find(&changes).Error; err != nil {
+			return err
+		}
+		if len(ids) > 0 && len(changes) != len(ids) {
+			return gorm.ErrRecordNotFound
+		}
+
+		now := time.Now()
+		status := models.PriceChangeRejected
+		if approve {
+			status = models.PriceChangeApproved
+		}
+		for i := range changes {
+			change := &changes[i]
+			if change.Status != models.PriceChangePending {
+				return ErrPriceChangeClosed
+			}
+			if approve {
+				if err := tx.Model(&models.ProductService{}).Where("id = ?", change.ProductServiceID).
+					Update("selling_price", change.NewPrice).Error; err != nil {
+					return err
+				}
+			}
+			change.Status, change.ReviewedByID, change.ReviewedAt = status, userID, &now
+			if err := tx.Model(change).Updates(map[string]any{
+				"status":         change.Status,
+				"reviewed_by_id": userID,
+				"reviewed_at":    now,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return changes, err
+}
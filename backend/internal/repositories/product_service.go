@@ -3,13 +3,22 @@ package repositories
 import (
 	"errors"
 	"strings"
+	"time"
 
 	"github.com/amirqodi/hgm/internal/database"
 	"github.com/amirqodi/hgm/internal/models"
+	"gorm.io/gorm"
 )
 
 // ---------------- CREATE ----------------
+// CreateProductService برای محصول دارای قاعده ارزی قیمت فروش با آخرین نرخ محاسبه می‌شود
 func CreateProductService(p *models.ProductService) error {
+	if err := ValidatePricingRule(p); err != nil {
+		return err
+	}
+	if err := ApplyPricingRule(p, time.Now(), database.DB); err != nil {
+		return err
+	}
 	return database.DB.Create(p).Error
 }
 
@@ -43,15 +52,48 @@ func GetProductServiceByID(id uint) (models.ProductService, error) {
 }
 
 // ---------------- UPDATE ----------------
-func UpdateProductService(id uint, data *models.ProductService) (models.ProductService, error) {
+// UpdateProductService فیلدهای قاعده قیمت‌گذاری فقط وقتی price_unit ارسال شود تغییر می‌کنند
+// (irr قاعده را برمی‌دارد)؛ تغییر قیمت فروش در سابقه قیمت محصول ثبت می‌شود.
+func UpdateProductService(id uint, data *models.ProductService, userID *uint) (models.ProductService, error) {
 	var product models.ProductService
-	if err := database.DB.First(&product, id).Error; err != nil {
+	if err := ValidatePricingRule(data); err != nil {
 		return product, err
 	}
-	if err := database.DB.Model(&product).Updates(data).Error; err != nil {
-		return product, err
-	}
-	return product, nil
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&product, id).Error; err != nil {
+			return err
+		}
+		oldPrice := product.SellingPrice
+
+		if err := tx.Model(&product).Updates(data).Error; err != nil {
+			return err
+		}
+		if data.PriceUnit != "" {
+			if err := tx.Model(&product).Select("price_unit", "base_price", "markup", "round_to").Updates(data).Error; err != nil {
+				return err
+			}
+			// پیشنهادهای قبلی بر اساس قاعده قدیمی بوده‌اند
+			if err := tx.Model(&models.ProductPriceChange{}).
+				Where("product_service_id = ? AND status = ?", id, models.PriceChangePending).
+				Update("status", models.PriceChangeSuperseded).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.First(&product, id).Error; err != nil {
+			return err
+		}
+
+		if product.HasPricingRule() {
+			if err := ApplyPricingRule(&product, time.Now(), tx); err != nil {
+				return err
+			}
+			if err := tx.Model(&product).Update("selling_price", product.SellingPrice).Error; err != nil {
+				return err
+			}
+		}
+		return recordPriceChange(&product, oldPrice, userID, tx)
+	})
+	return product, err
 }
 
 // ---------------- DELETE ----------------
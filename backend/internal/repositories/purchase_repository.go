@@ -0,0 +1,596 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/config"
+	"github.com/amirqodi/hgm/internal/models"
+	"gorm.io/gorm"
+)
+
+var ErrBillMatchException = errors.New("صورتحساب با سفارش و رسید انبار مغایرت دارد؛ ثبت آن فقط با تایید مدیر ممکن است")
+
+type PurchaseOrderFilter struct {
+	Status    models.PurchaseOrderStatus
+	ContactID uint
+}
+
+// BillPriceTolerance درصد مجاز اختلاف قیمت واحد صورتحساب با سفارش (BILL_PRICE_TOLERANCE، پیش‌فرض صفر)
+func BillPriceTolerance() float64 {
+	tolerance, err := strconv.ParseFloat(config.Get("BILL_PRICE_TOLERANCE", "0"), 64)
+	if err != nil || tolerance < 0 {
+		return 0
+	}
+	return tolerance
+}
+
+func PurchaseOrderNumber(fiscalYear, sequence int) string {
+	return fmt.Sprintf("PO-%d-%05d", fiscalYear, sequence)
+}
+
+func GoodsReceiptNumber(fiscalYear, sequence int) string {
+	return fmt.Sprintf("GR-%d-%05d", fiscalYear, sequence)
+}
+
+// requireVendor سفارش و صورتحساب خرید فقط برای مخاطب دارای نقش فروشنده
+func requireVendor(contactID uint, db *gorm.DB) error {
+	var vendor models.VendorProfile
+	if err := db.Where("contact_id = ?", contactID).First(&vendor).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("مخاطب انتخاب‌شده فروشنده نیست")
+		}
+		return err
+	}
+	return nil
+}
+
+// preparePurchaseOrderItems شرح و قیمت خرید پیش‌فرض از کالا، و جمع، مالیات و مبلغ کل سفارش
+func preparePurchaseOrderItems(po *models.PurchaseOrder, db *gorm.DB) error {
+	if len(po.Items) == 0 {
+		return errors.New("سفارش خرید باید حداقل یک قلم داشته باشد")
+	}
+	if po.TaxRate < 0 || po.TaxRate > 100 {
+		return errors.New("نرخ مالیات نامعتبر است")
+	}
+
+	po.Subtotal = 0
+	for i := range po.Items {
+		item := &po.Items[i]
+		item.ID, item.PurchaseOrderID, item.Product = 0, 0, nil
+		item.ReceivedQty, item.BilledQty = 0, 0
+
+		var product models.ProductService
+		if err := db.First(&product, item.ProductID).Error; err != nil {
+			return errors.New("محصول یافت نشد")
+		}
+		if item.Description == "" {
+			item.Description = product.Name
+		}
+		if item.UnitPrice == 0 && product.BuyingPrice != nil {
+			item.UnitPrice = *product.BuyingPrice
+		}
+		if item.Quantity <= 0 || item.UnitPrice < 0 {
+			return errors.New("تعداد یا مبلغ اقلام نامعتبر است")
+		}
+		item.Total = roundAmount(float64(item.Quantity) * item.UnitPrice)
+		po.Subtotal += item.Total
+	}
+	po.Subtotal = roundAmount(po.Subtotal)
+	po.TaxAmount = roundAmount(po.Subtotal * po.TaxRate / 100)
+	po.Total = po.Subtotal + po.TaxAmount
+	return nil
+}
+
+func CreatePurchaseOrder(po *models.PurchaseOrder, db *gorm.DB) error {
+	if err := requireVendor(po.ContactID, db); err != nil {
+		return err
+	}
+	if po.OrderDate.IsZero() {
+		po.OrderDate = time.Now()
+	}
+	if err := preparePurchaseOrderItems(po, db); err != nil {
+		return err
+	}
+	po.Status = models.PODraft
+	po.FiscalYear = FiscalYear(po.OrderDate)
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		seq, err := nextDocumentNumber(tx, models.DocumentPurchaseOrder, po.FiscalYear)
+		if err != nil {
+			return err
+		}
+		po.Sequence = seq
+		po.Number = PurchaseOrderNumber(po.FiscalYear, seq)
+		return tx.Create(po).Error
+	})
+}
+
+// UpdatePurchaseOrder ویرایش سفارش؛ فقط پیش‌نویس
+func UpdatePurchaseOrder(id uint, data *models.PurchaseOrder, db *gorm.DB) (*models.PurchaseOrder, error) {
+	var po models.PurchaseOrder
+	if err := db.First(&po, id).Error; err != nil {
+		return nil, err
+	}
+	if po.Status != models.PODraft {
+		return nil, errors.New("فقط سفارش پیش‌نویس قابل ویرایش است")
+	}
+
+	if data.ContactID != 0 {
+		po.ContactID = data.ContactID
+	}
+	if err := requireVendor(po.ContactID, db); err != nil {
+		return nil, err
+	}
+	if !data.OrderDate.IsZero() {
+		po.OrderDate = data.OrderDate
+		po.FiscalYear = FiscalYear(po.OrderDate)
+	}
+	po.ExpectedDate = data.ExpectedDate
+	po.TaxRate = data.TaxRate
+	po.Notes = data.Notes
+	po.Items = data.Items
+	if err := preparePurchaseOrderItems(&po, db); err != nil {
+		return nil, err
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("purchase_order_id = ?", po.ID).Delete(&models.PurchaseOrderItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Session(&gorm.Session{FullSaveAssociations: true}).Save(&po).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetPurchaseOrderByID(id, db)
+}
+
+// SetPurchaseOrderStatus ارسال سفارش (ordered)، لغو (cancelled) یا بستن باقی‌مانده (closed)
+func SetPurchaseOrderStatus(id uint, status models.PurchaseOrderStatus, db *gorm.DB) (*models.PurchaseOrder, error) {
+	var po models.PurchaseOrder
+	if err := db.Preload("Items").First(&po, id).Error; err != nil {
+		return nil, err
+	}
+
+	var received int64
+	for _, item := range po.Items {
+		received += item.ReceivedQty
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"status": status}
+	switch status {
+	case models.POOrdered:
+		if po.Status != models.PODraft {
+			return nil, errors.New("فقط سفارش پیش‌نویس قابل ارسال است")
+		}
+		updates["ordered_at"] = now
+	case models.POCancelled:
+		if po.Status != models.PODraft && po.Status != models.POOrdered {
+			return nil, errors.New("سفارش دریافت‌شده یا بسته‌شده قابل لغو نیست")
+		}
+		if received > 0 {
+			return nil, errors.New("برای این سفارش رسید انبار ثبت شده است؛ به جای لغو آن را ببندید")
+		}
+		updates["closed_at"] = now
+	case models.POClosed:
+		if po.Status != models.POOrdered && po.Status != models.POPartiallyReceived {
+			return nil, errors.New("فقط سفارش باز قابل بستن است")
+		}
+		updates["closed_at"] = now
+	default:
+		return nil, errors.New("وضعیت باید ordered، cancelled یا closed باشد")
+	}
+
+	if err := db.Model(&po).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	return GetPurchaseOrderByID(id, db)
+}
+
+// DeletePurchaseOrder فقط پیش‌نویس حذف می‌شود
+func DeletePurchaseOrder(id uint, db *gorm.DB) error {
+	var po models.PurchaseOrder
+	if err := db.First(&po, id).Error; err != nil {
+		return err
+	}
+	if po.Status != models.PODraft {
+		return errors.New("فقط سفارش پیش‌نویس قابل حذف است؛ سفارش ارسال‌شده را لغو کنید")
+	}
+	return db.Select("Items").Delete(&po).Error
+}
+
+// receiptStatus وضعیت سفارش باز بر اساس مقدار دریافت‌شده اقلام
+func receiptStatus(items []models.PurchaseOrderItem) models.PurchaseOrderStatus {
+	var ordered, received int64
+	for _, item := range items {
+		ordered += item.Quantity
+		received += item.ReceivedQty
+	}
+	switch {
+	case received == 0:
+		return models.POOrdered
+	case received >= ordered:
+		return models.POReceived
+	default:
+		return models.POPartiallyReceived
+	}
+}
+
+// adjustProductStock تغییر موجودی کالا؛ خدمت (بدون موجودی) تغییری نمی‌کند
+func adjustProductStock(productID uint, delta int64, tx *gorm.DB) error {
+	var product models.ProductService
+	if err := tx.First(&product, productID).Error; err != nil {
+		return errors.New("محصول یافت نشد")
+	}
+	if product.Stock == nil {
+		return nil
+	}
+	if *product.Stock+delta < 0 {
+		return fmt.Errorf("موجودی کالای %s کافی نیست", product.Name)
+	}
+	*product.Stock += delta
+	return tx.Save(&product).Error
+}
+
+// ReceiveGoods ثبت رسید انبار (کامل یا جزئی) برای سفارش باز و افزایش موجودی کالاهای دریافت‌شده
+func ReceiveGoods(poID uint, gr *models.GoodsReceipt, db *gorm.DB) error {
+	var po models.PurchaseOrder
+	if err := db.Preload("Items").First(&po, poID).Error; err != nil {
+		return err
+	}
+	if po.Status != models.POOrdered && po.Status != models.POPartiallyReceived {
+		return errors.New("رسید انبار فقط برای سفارش ارسال‌شده و باز ثبت می‌شود")
+	}
+	if len(gr.Items) == 0 {
+		return errors.New("رسید انبار باید حداقل یک قلم داشته باشد")
+	}
+
+	items := map[uint]*models.PurchaseOrderItem{}
+	for i := range po.Items {
+		items[po.Items[i].ID] = &po.Items[i]
+	}
+	for i := range gr.Items {
+		line := &gr.Items[i]
+		line.ID, line.GoodsReceiptID = 0, 0
+		item, ok := items[line.PurchaseOrderItemID]
+		if !ok {
+			return errors.New("قلم رسید متعلق به این سفارش نیست")
+		}
+		if line.Quantity <= 0 {
+			return errors.New("تعداد دریافتی نامعتبر است")
+		}
+		if item.ReceivedQty+line.Quantity > item.Quantity {
+			return fmt.Errorf("تعداد دریافتی %s بیشتر از باقی‌مانده سفارش (%d) است", item.Description, item.Quantity-item.ReceivedQty)
+		}
+		item.ReceivedQty += line.Quantity
+		line.ProductID = item.ProductID
+	}
+
+	if gr.ReceivedAt.IsZero() {
+		gr.ReceivedAt = time.Now()
+	}
+	gr.PurchaseOrderID = po.ID
+	gr.FiscalYear = FiscalYear(gr.ReceivedAt)
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		seq, err := nextDocumentNumber(tx, models.DocumentGoodsReceipt, gr.FiscalYear)
+		if err != nil {
+			return err
+		}
+		gr.Sequence = seq
+		gr.Number = GoodsReceiptNumber(gr.FiscalYear, seq)
+		if err := tx.Create(gr).Error; err != nil {
+			return err
+		}
+
+		for _, line := range gr.Items {
+			if err := adjustProductStock(line.ProductID, line.Quantity, tx); err != nil {
+				return err
+			}
+			if err := tx.Model(&models.PurchaseOrderItem{}).Where("id = ?", line.PurchaseOrderItemID).
+				Update("received_qty", items[line.PurchaseOrderItemID].ReceivedQty).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&po).Update("status", receiptStatus(po.Items)).Error
+	})
+}
+
+// DeleteGoodsReceipt برگشت رسید انبار اشتباه؛ مقداری که صورتحساب آن ثبت شده قابل برگشت نیست
+func DeleteGoodsReceipt(id uint, db *gorm.DB) error {
+	var gr models.GoodsReceipt
+	if err := db.Preload("Items").First(&gr, id).Error; err != nil {
+		return err
+	}
+	var po models.PurchaseOrder
+	if err := db.Preload("Items").First(&po, gr.PurchaseOrderID).Error; err != nil {
+		return err
+	}
+	if po.Status == models.POClosed {
+		return errors.New("سفارش بسته شده است و رسید آن قابل حذف نیست")
+	}
+
+	items := map[uint]*models.PurchaseOrderItem{}
+	for i := range po.Items {
+		items[po.Items[i].ID] = &po.Items[i]
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, line := range gr.Items {
+			item := items[line.PurchaseOrderItemID]
+			if item == nil {
+				continue
+			}
+			item.ReceivedQty -= line.Quantity
+			if item.ReceivedQty < item.BilledQty {
+				return fmt.Errorf("صورتحساب %s ثبت شده است؛ ابتدا صورتحساب را حذف کنید", item.Description)
+			}
+			if err := adjustProductStock(line.ProductID, -line.Quantity, tx); err != nil {
+				return err
+			}
+			if err := tx.Model(item).Update("received_qty", item.ReceivedQty).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Select("Items").Delete(&gr).Error; err != nil {
+			return err
+		}
+		return tx.Model(&po).Update("status", receiptStatus(po.Items)).Error
+	})
+}
+
+// matchVendorBill تطبیق سه‌طرفه: تعداد صورتحساب‌شده با سفارش و رسید انبار، و قیمت واحد با سفارش.
+// BilledQty اقلام باید شامل همین صورتحساب باشد.
+func matchVendorBill(bill *models.VendorBill, items map[uint]*models.PurchaseOrderItem) {
+	tolerance := BillPriceTolerance()
+	bill.MatchIssues = nil
+	for _, line := range bill.Items {
+		item := items[line.PurchaseOrderItemID]
+		if item == nil {
+			continue
+		}
+		if item.BilledQty > item.Quantity {
+			bill.MatchIssues = append(bill.MatchIssues,
+				fmt.Sprintf("%s: صورتحساب %d عدد در برابر سفارش %d عدد", item.Description, item.BilledQty, item.Quantity))
+		}
+		if item.BilledQty > item.ReceivedQty {
+			bill.MatchIssues = append(bill.MatchIssues,
+				fmt.Sprintf("%s: صورتحساب %d عدد در برابر دریافت %d عدد", item.Description, item.BilledQty, item.ReceivedQty))
+		}
+		if diff := math.Abs(line.UnitPrice - item.UnitPrice); diff > 1 && diff > item.UnitPrice*tolerance/100 {
+			bill.MatchIssues = append(bill.MatchIssues,
+				fmt.Sprintf("%s: قیمت واحد %.0f در برابر سفارش %.0f", item.Description, line.UnitPrice, item.UnitPrice))
+		}
+	}
+	bill.MatchStatus = models.BillMatched
+	if len(bill.MatchIssues) > 0 {
+		bill.MatchStatus = models.BillException
+	}
+}
+
+// CreateVendorBill ثبت صورتحساب فروشنده برای سفارش و تطبیق سه‌طرفه آن.
+// اگر taxAmount داده نشود با نرخ مالیات سفارش حساب می‌شود.
+func CreateVendorBill(poID uint, bill *models.VendorBill, taxAmount *float64, db *gorm.DB) error {
+	var po models.PurchaseOrder
+	if err := db.Preload("Items").First(&po, poID).Error; err != nil {
+		return err
+	}
+	if po.Status == models.PODraft || po.Status == models.POCancelled {
+		return errors.New("برای سفارش پیش‌نویس یا لغوشده صورتحساب ثبت نمی‌شود")
+	}
+	if len(bill.Items) == 0 {
+		return errors.New("صورتحساب باید حداقل یک قلم داشته باشد")
+	}
+	if bill.BillNumber != "" {
+		var count int64
+		if err := db.Model(&models.VendorBill{}).
+			Where("contact_id = ? AND bill_number = ?", po.ContactID, bill.BillNumber).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("صورتحساب شماره %s این فروشنده قبلاً ثبت شده است", bill.BillNumber)
+		}
+	}
+
+	items := map[uint]*models.PurchaseOrderItem{}
+	for i := range po.Items {
+		items[po.Items[i].ID] = &po.Items[i]
+	}
+	bill.Subtotal = 0
+	for i := range bill.Items {
+		line := &bill.Items[i]
+		line.ID, line.VendorBillID = 0, 0
+		item, ok := items[line.PurchaseOrderItemID]
+		if !ok {
+			return errors.New("قلم صورتحساب متعلق به این سفارش نیست")
+		}
+		if line.Quantity <= 0 || line.UnitPrice < 0 {
+			return errors.New("تعداد یا مبلغ اقلام نامعتبر است")
+		}
+		if line.Description == "" {
+			line.Description = item.Description
+		}
+		if line.UnitPrice == 0 {
+			line.UnitPrice = item.UnitPrice
+		}
+		line.Total = roundAmount(float64(line.Quantity) * line.UnitPrice)
+		bill.Subtotal += line.Total
+		item.BilledQty += line.Quantity
+	}
+	bill.Subtotal = roundAmount(bill.Subtotal)
+	if taxAmount != nil {
+		bill.TaxAmount = roundAmount(*taxAmount)
+	} else {
+		bill.TaxAmount = roundAmount(bill.Subtotal * po.TaxRate / 100)
+	}
+	bill.Total = bill.Subtotal + bill.TaxAmount
+	if bill.BillDate.IsZero() {
+		bill.BillDate = time.Now()
+	}
+	bill.PurchaseOrderID = po.ID
+	bill.ContactID = po.ContactID
+	matchVendorBill(bill, items)
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(bill).Error; err != nil {
+			return err
+		}
+		for _, line := range bill.Items {
+			if err := tx.Model(&models.PurchaseOrderItem{}).Where("id = ?", line.PurchaseOrderItemID).
+				Update("billed_qty", items[line.PurchaseOrderItemID].BilledQty).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RematchVendorBill تطبیق دوباره صورتحساب ثبت‌نشده، مثلاً پس از رسیدن بقیه کالا
+func RematchVendorBill(id uint, db *gorm.DB) (*models.VendorBill, error) {
+	var bill models.VendorBill
+	if err := db.Preload("Items").First(&bill, id).Error; err != nil {
+		return nil, err
+	}
+	if bill.PostedAt != nil {
+		return nil, errors.New("صورتحساب ثبت شده است")
+	}
+	var po models.PurchaseOrder
+	if err := db.Preload("Items").First(&po, bill.PurchaseOrderID).Error; err != nil {
+		return nil, err
+	}
+	items := map[uint]*models.PurchaseOrderItem{}
+	for i := range po.Items {
+		items[po.Items[i].ID] = &po.Items[i]
+	}
+
+	matchVendorBill(&bill, items)
+	if err := db.Model(&bill).Select("match_status", "match_issues").Updates(&bill).Error; err != nil {
+		return nil, err
+	}
+	return GetVendorBillByID(id, db)
+}
+
+// PostVendorBill ثبت صورتحساب به صورت تراکنش هزینه؛ کالا روی تراکنش گذاشته نمی‌شود چون
+// موجودی قبلاً با رسید انبار افزایش یافته است. صورتحساب مغایر فقط با force (مدیر) ثبت می‌شود.
+func PostVendorBill(id uint, trx *models.Transaction, force bool, userID *uint, db *gorm.DB) (*models.VendorBill, error) {
+	var bill models.VendorBill
+	if err := db.First(&bill, id).Error; err != nil {
+		return nil, err
+	}
+	if bill.PostedAt != nil {
+		return nil, errors.New("صورتحساب قبلاً ثبت شده است")
+	}
+	if bill.MatchStatus == models.BillException && !force {
+		return nil, ErrBillMatchException
+	}
+	var po models.PurchaseOrder
+	if err := db.First(&po, bill.PurchaseOrderID).Error; err != nil {
+		return nil, err
+	}
+
+	trx.ContactID = bill.ContactID
+	trx.TransactionType = "expense"
+	trx.Amount = bill.Total
+	trx.ProductID, trx.Quantity = nil, 0
+	if trx.TransactionDate == nil {
+		trx.TransactionDate = &bill.BillDate
+	}
+	if trx.Notes == "" {
+		trx.Notes = "سفارش خرید " + po.Number
+		if bill.BillNumber != "" {
+			trx.Notes += " - صورتحساب " + bill.BillNumber
+		}
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := CreateTransaction(trx, nil, tx); err != nil {
+			return err
+		}
+		return tx.Model(&bill).Updates(map[string]interface{}{
+			"transaction_id": trx.ID,
+			"posted_at":      time.Now(),
+			"posted_by_id":   userID,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetVendorBillByID(id, db)
+}
+
+// DeleteVendorBill حذف صورتحساب ثبت‌نشده و آزاد کردن مقدار صورتحساب‌شده اقلام سفارش
+func DeleteVendorBill(id uint, db *gorm.DB) error {
+	var bill models.VendorBill
+	if err := db.Preload("Items").First(&bill, id).Error; err != nil {
+		return err
+	}
+	if bill.PostedAt != nil {
+		return errors.New("صورتحساب ثبت‌شده قابل حذف نیست؛ ابتدا تراکنش آن را حذف کنید")
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, line := range bill.Items {
+			if err := tx.Model(&models.PurchaseOrderItem{}).Where("id = ?", line.PurchaseOrderItemID).
+				Update("billed_qty", gorm.Expr("billed_qty - ?", line.Quantity)).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Select("Items").Delete(&bill).Error
+	})
+}
+
+func GetPurchaseOrders(filter PurchaseOrderFilter, db *gorm.DB) ([]models.PurchaseOrder, error) {
+	var orders []models.PurchaseOrder
+	q := db.Preload("Contact")
+	if filter.Status != "" {
+		q = q.Where("status = ?", filter.Status)
+	}
+	if filter.ContactID != 0 {
+		q = q.Where("contact_id = ?", filter.ContactID)
+	}
+	err := q.Order("order_date DESC, id DESC").Find(&orders).Error
+	return orders, err
+}
+
+func GetPurchaseOrderByID(id uint, db *gorm.DB) (*models.PurchaseOrder, error) {
+	var po models.PurchaseOrder
+	err := db.Preload("Items.Product").
+		Preload("Contact.Vendor").
+		Preload("Receipts.Items").
+		Preload("Bills.Items").
+		First(&po, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &po, nil
+}
+
+// GetVendorBills ?contact_id=&purchase_order_id=&unposted=
+func GetVendorBills(contactID, poID uint, unpostedOnly bool, db *gorm.DB) ([]models.VendorBill, error) {
+	var bills []models.VendorBill
+	q := db.Preload("Items")
+	if contactID != 0 {
+		q = q.Where("contact_id = ?", contactID)
+	}
+	if poID != 0 {
+		q = q.Where("purchase_order_id = ?", poID)
+	}
+	if unpostedOnly {
+		q = q.Where("posted_at IS NULL")
+	}
+	err := q.Order("bill_date DESC, id DESC").Find(&bills).Error
+	return bills, err
+}
+
+func GetVendorBillByID(id uint, db *gorm.DB) (*models.VendorBill, error) {
+	var bill models.VendorBill
+	if err := db.Preload("Items").Preload("Transaction").First(&bill, id).Error; err != nil {
+		return nil, err
+	}
+	return &bill, nil
+}
@@ -0,0 +1,436 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/config"
+	"github.com/amirqodi/hgm/internal/models"
+	"gorm.io/gorm"
+)
+
+// مقصدهای تبدیل پیش‌فاکتور
+const (
+	QuoteToTransaction = "transaction" // فقط تراکنش درآمد
+	QuoteToInvoice     = "invoice"     // تراکنش درآمد به‌همراه فاکتور فروش با اقلام پیش‌فاکتور
+	QuoteToWorkOrder   = "work_order"  // دستور کار برای انجام کار؛ تبدیل به تراکنش بعداً انجام می‌شود
+)
+
+// quoteTransitions تغییر وضعیت‌های مجاز دستی؛ expired خودکار و با گذشت تاریخ اعتبار ثبت می‌شود
+var quoteTransitions = map[models.QuoteStatus][]models.QuoteStatus{
+	models.QuoteDraft:    {models.QuoteSent, models.QuoteAccepted, models.QuoteRejected},
+	models.QuoteSent:     {models.QuoteAccepted, models.QuoteRejected},
+	models.QuoteAccepted: {models.QuoteRejected},
+}
+
+type QuoteFilter struct {
+	Status    models.QuoteStatus
+	ContactID uint
+	From, To  time.Time // بازه تاریخ صدور [From, To)
+}
+
+// DefaultQuoteValidity مدت اعتبار پیش‌فرض پیش‌فاکتور (QUOTE_VALIDITY_DAYS، پیش‌فرض ۱۴ روز)
+func DefaultQuoteValidity() time.Duration {
+	days, err := strconv.Atoi(config.Get("QUOTE_VALIDITY_DAYS", "14"))
+	if err != nil || days <= 0 {
+		days = 14
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+func QuoteNumber(fiscalYear, sequence int) string {
+	return fmt.Sprintf("Q-%d-%05d", fiscalYear, sequence)
+}
+
+// ExpireQuotes پیش‌فاکتورهای پیش‌نویس و ارسال‌شده‌ای که تاریخ اعتبارشان گذشته را منقضی می‌کند
+func ExpireQuotes(db *gorm.DB) error {
+	return db.Model(&models.Quote{}).
+		Where("status IN ? AND valid_until < ?", []models.QuoteStatus{models.QuoteDraft, models.QuoteSent}, time.Now()).
+		Update("status", models.QuoteExpired).Error
+}
+
+// prepareQuoteItems شرح و قیمت پیش‌فرض اقلام از کالا/خدمت، و جمع، مالیات و مبلغ کل
+func prepareQuoteItems(q *models.Quote, db *gorm.DB) error {
+	if len(q.Items) == 0 {
+		return errors.New("پیش‌فاکتور باید حداقل یک قلم داشته باشد")
+	}
+	if q.TaxRate < 0 || q.TaxRate > 100 {
+		return errors.New("نرخ مالیات نامعتبر است")
+	}
+
+	q.Subtotal = 0
+	for i := range q.Items {
+		item := &q.Items[i]
+		item.ID, item.QuoteID, item.Product = 0, 0, nil
+		if item.ProductID != nil {
+			var product models.ProductService
+			if err := db.First(&product, *item.ProductID).Error; err != nil {
+				return errors.New("محصول یافت نشد")
+			}
+			if item.Description == "" {
+				item.Description = product.Name
+			}
+			if item.UnitPrice == 0 {
+				item.UnitPrice = product.SellingPrice
+			}
+			if product.Stock != nil && item.Quantity != math.Trunc(item.Quantity) {
+				return fmt.Errorf("تعداد کالای %s باید عدد صحیح باشد", product.Name)
+			}
+		}
+		if item.Description == "" {
+			return errors.New("شرح اقلام الزامی است")
+		}
+		if item.Quantity <= 0 || item.UnitPrice < 0 {
+			return errors.New("تعداد یا مبلغ اقلام نامعتبر است")
+		}
+		item.Total = roundAmount(item.Quantity * item.UnitPrice)
+		q.Subtotal += item.Total
+	}
+	q.Subtotal = roundAmount(q.Subtotal)
+	q.TaxAmount = roundAmount(q.Subtotal * q.TaxRate / 100)
+	q.Total = q.Subtotal + q.TaxAmount
+	return nil
+}
+
+func validateQuoteDates(q *models.Quote) error {
+	if q.IssuedAt.IsZero() {
+		q.IssuedAt = time.Now()
+	}
+	if q.ValidUntil.IsZero() {
+		q.ValidUntil = q.IssuedAt.Add(DefaultQuoteValidity())
+	}
+	if q.ValidUntil.Before(q.IssuedAt) {
+		return errors.New("تاریخ اعتبار باید بعد از تاریخ صدور باشد")
+	}
+	return nil
+}
+
+func CreateQuote(q *models.Quote, db *gorm.DB) error {
+	if err := db.First(&models.Contact{}, q.ContactID).Error; err != nil {
+		return errors.New("مخاطب یافت نشد")
+	}
+	if err := validateQuoteDates(q); err != nil {
+		return err
+	}
+	if err := prepareQuoteItems(q, db); err != nil {
+		return err
+	}
+	q.Status = models.QuoteDraft
+	q.FiscalYear = FiscalYear(q.IssuedAt)
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		seq, err := nextDocumentNumber(tx, models.DocumentQuote, q.FiscalYear)
+		if err != nil {
+			return err
+		}
+		q.Sequence = seq
+		q.Number = QuoteNumber(q.FiscalYear, seq)
+		return tx.Create(q).Error
+	})
+}
+
+// UpdateQuote ویرایش اقلام، تاریخ اعتبار و توضیحات؛ فقط پیش از پذیرش یا رد
+func UpdateQuote(id uint, data *models.Quote, db *gorm.DB) (*models.Quote, error) {
+	if err := ExpireQuotes(db); err != nil {
+		return nil, err
+	}
+	var q models.Quote
+	if err := db.First(&q, id).Error; err != nil {
+		return nil, err
+	}
+	if q.Status != models.QuoteDraft && q.Status != models.QuoteSent {
+		return nil, errors.New("فقط پیش‌فاکتور پیش‌نویس یا ارسال‌شده قابل ویرایش است")
+	}
+
+	if data.ContactID != 0 {
+		q.ContactID = data.ContactID
+	}
+	if err := db.First(&models.Contact{}, q.ContactID).Error; err != nil {
+		return nil, errors.New("مخاطب یافت نشد")
+	}
+	if !data.ValidUntil.IsZero() {
+		q.ValidUntil = data.ValidUntil
+	}
+	if err := validateQuoteDates(&q); err != nil {
+		return nil, err
+	}
+	q.TaxRate = data.TaxRate
+	q.Notes = data.Notes
+	q.Items = data.Items
+	if err := prepareQuoteItems(&q, db); err != nil {
+		return nil, err
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("quote_id = ?", q.ID).Delete(&models.QuoteItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Session(&gorm.Session{FullSaveAssociations: true}).Save(&q).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetQuoteByID(id, db)
+}
+
+// SetQuoteStatus تغییر دستی وضعیت (ارسال، پذیرش، رد)
+func SetQuoteStatus(id uint, status models.QuoteStatus, db *gorm.DB) (*models.Quote, error) {
+	if err := ExpireQuotes(db); err != nil {
+		return nil, err
+	}
+	var q models.Quote
+	if err := db.First(&q, id).Error; err != nil {
+		return nil, err
+	}
+	if q.ConvertedAt != nil {
+		return nil, errors.New("پیش‌فاکتور تبدیل شده است و وضعیت آن قابل تغییر نیست")
+	}
+
+	allowed := false
+	for _, s := range quoteTransitions[q.Status] {
+		allowed = allowed || s == status
+	}
+	if !allowed {
+		return nil, fmt.Errorf("تغییر وضعیت از %s به %s مجاز نیست", q.Status, status)
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"status": status}
+	switch status {
+	case models.QuoteSent:
+		updates["sent_at"] = now
+	case models.QuoteAccepted, models.QuoteRejected:
+		updates["decided_at"] = now
+	}
+	if err := db.Model(&q).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+	return GetQuoteByID(id, db)
+}
+
+// DeleteQuote فقط پیش‌نویس حذف می‌شود؛ شماره آن دوباره استفاده نمی‌شود
+func DeleteQuote(id uint, db *gorm.DB) error {
+	var q models.Quote
+	if err := db.First(&q, id).Error; err != nil {
+		return err
+	}
+	if q.Status != models.QuoteDraft {
+		return errors.New("فقط پیش‌فاکتور پیش‌نویس قابل حذف است")
+	}
+	return db.Select("Items").Delete(&q).Error
+}
+
+// quotePart اقلامی از پیش‌فاکتور که با یک تراکنش ثبت می‌شوند
+type quotePart struct {
+	items     []models.QuoteItem
+	productID *uint
+	quantity  uint
+}
+
+// splitQuote هر کالای انباری یک تراکنش جدا (تراکنش فقط یک کالا نگه می‌دارد) و بقیه اقلام یک تراکنش؛
+// موجودی هر کالا با جمع تعداد همه اقلام آن بررسی می‌شود
+func splitQuote(q *models.Quote, transactionType string, db *gorm.DB) ([]quotePart, error) {
+	needed := map[uint]float64{}
+	for _, item := range q.Items {
+		if item.ProductID != nil {
+			needed[*item.ProductID] += item.Quantity
+		}
+	}
+	stocked := map[uint]bool{}
+	for productID, qty := range needed {
+		product, err := checkProductStock(productID, int64(qty), transactionType, db)
+		if err != nil {
+			return nil, err
+		}
+		stocked[productID] = product.Stock != nil
+	}
+
+	var parts []quotePart
+	var rest quotePart
+	for _, item := range q.Items {
+		if item.ProductID != nil && stocked[*item.ProductID] {
+			parts = append(parts, quotePart{
+				items:     []models.QuoteItem{item},
+				productID: item.ProductID,
+				quantity:  uint(item.Quantity),
+			})
+			continue
+		}
+		rest.items = append(rest.items, item)
+	}
+	if len(rest.items) > 0 {
+		parts = append(parts, rest)
+	}
+	return parts, nil
+}
+
+// ConvertQuote تبدیل پیش‌فاکتور به تراکنش درآمد (و در صورت نیاز فاکتور فروش).
+// trx اطلاعات پرداخت (دسته، حساب یا تنخواه، روش پرداخت، اقساط) را دارد؛ مخاطب و مبلغ از پیش‌فاکتور می‌آید.
+// هر کالای انباری تراکنش جدا می‌گیرد و مبلغ هر تراکنش جمع اقلام آن به‌علاوه مالیات است؛ همه تراکنش‌ها
+// و بررسی موجودی کالاها در یک تراکنش پایگاه داده انجام می‌شود. trx اولین تراکنش ساخته‌شده می‌شود.
+// مقصد work_order فقط دستور کار باز می‌کند و trx استفاده نمی‌شود.
+func ConvertQuote(id uint, target string, trx *models.Transaction, userID *uint, db *gorm.DB) (*models.Quote, error) {
+	switch target {
+	case QuoteToTransaction, QuoteToInvoice, QuoteToWorkOrder:
+	default:
+		return nil, errors.New("مقصد تبدیل باید transaction، invoice یا work_order باشد")
+	}
+	if err := ExpireQuotes(db); err != nil {
+		return nil, err
+	}
+
+	var q models.Quote
+	if err := db.Preload("Items").First(&q, id).Error; err != nil {
+		return nil, err
+	}
+	if q.ConvertedAt != nil {
+		return nil, errors.New("پیش‌فاکتور قبلاً تبدیل شده است")
+	}
+	switch q.Status {
+	case models.QuoteRejected:
+		return nil, errors.New("پیش‌فاکتور ردشده قابل تبدیل نیست")
+	case models.QuoteExpired:
+		return nil, errors.New("تاریخ اعتبار پیش‌فاکتور گذشته است")
+	}
+
+	if target == QuoteToWorkOrder {
+		if err := createWorkOrder(&q, userID, db); err != nil {
+			return nil, err
+		}
+		return GetQuoteByID(id, db)
+	}
+
+	trx.ContactID = q.ContactID
+	trx.TransactionType = "income"
+	trx.QuoteID = &q.ID
+	if trx.Notes == "" {
+		trx.Notes = "پیش‌فاکتور " + q.Number
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		parts, err := splitQuote(&q, trx.TransactionType, tx)
+		if err != nil {
+			return err
+		}
+		if len(parts) > 1 && len(trx.SubTransactions) > 0 {
+			return errors.New("پیش‌فاکتور چند کالای انباری دارد و به چند تراکنش تبدیل می‌شود؛ اقساط فقط برای پیش‌فاکتور تک‌تراکنشی ممکن است")
+		}
+
+		now := time.Now()
+		updates := map[string]interface{}{
+			"status":       models.QuoteAccepted,
+			"converted_at": now,
+		}
+		if q.DecidedAt == nil {
+			updates["decided_at"] = now
+		}
+
+		template := *trx
+		remaining := q.Total
+		for i, part := range parts {
+			t := template
+			t.ProductID, t.Quantity = part.productID, part.quantity
+			if i == len(parts)-1 {
+				// آخرین تراکنش اختلاف گرد کردن را می‌گیرد تا جمع تراکنش‌ها برابر مبلغ پیش‌فاکتور باشد
+				t.Amount = roundAmount(remaining)
+			} else {
+				subtotal := 0.0
+				for _, item := range part.items {
+					subtotal += item.Total
+				}
+				t.Amount = roundAmount(subtotal * (1 + q.TaxRate/100))
+			}
+			remaining -= t.Amount
+
+			if err := CreateTransaction(&t, nil, tx); err != nil {
+				return err
+			}
+			if i == 0 {
+				*trx = t
+				updates["transaction_id"] = t.ID
+			}
+
+			if target == QuoteToInvoice {
+				inv := models.Invoice{
+					Kind:          models.InvoiceSales,
+					TransactionID: t.ID,
+					TaxRate:       q.TaxRate,
+					IssuedAt:      transactionDate(&t),
+					Notes:         q.Notes,
+				}
+				for _, item := range part.items {
+					inv.Items = append(inv.Items, models.InvoiceItem{
+						ProductID:   item.ProductID,
+						Description: item.Description,
+						Quantity:    item.Quantity,
+						UnitPrice:   item.UnitPrice,
+						Total:       item.Total,
+					})
+				}
+				if err := CreateInvoice(&inv, tx); err != nil {
+					return err
+				}
+				if i == 0 {
+					updates["invoice_id"] = inv.ID
+				}
+			}
+		}
+
+		// دستور کار باز با صدور تراکنش انجام‌شده حساب می‌شود
+		if err := tx.Model(&models.WorkOrder{}).
+			Where("quote_id = ? AND status = ?", q.ID, models.WorkOrderOpen).
+			Updates(map[string]interface{}{"status": models.WorkOrderDone, "closed_at": now}).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&q).Updates(updates).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetQuoteByID(id, db)
+}
+
+func GetQuotes(filter QuoteFilter, db *gorm.DB) ([]models.Quote, error) {
+	if err := ExpireQuotes(db); err != nil {
+		return nil, err
+	}
+	var quotes []models.Quote
+	q := db.Preload("Contact")
+	if filter.Status != "" {
+		q = q.Where("status = ?", filter.Status)
+	}
+	if filter.ContactID != 0 {
+		q = q.Where("contact_id = ?", filter.ContactID)
+	}
+	if !filter.From.IsZero() {
+		q = q.Where("issued_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		q = q.Where("issued_at < ?", filter.To)
+	}
+	err := q.Order("issued_at DESC, id DESC").Find(&quotes).Error
+	return quotes, err
+}
+
+func GetQuoteByID(id uint, db *gorm.DB) (*models.Quote, error) {
+	if err := ExpireQuotes(db); err != nil {
+		return nil, err
+	}
+	var q models.Quote
+	err := db.Preload("Items.Product").
+		Preload("Contact.Customer").
+		Preload("Contact.Addresses").
+		Preload("Transaction").
+		Preload("Transactions").
+		Preload("Invoice").
+		Preload("WorkOrder").
+		First(&q, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
@@ -0,0 +1,192 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/models"
+	"gorm.io/gorm"
+)
+
+// foreignAccount حساب بانکی یا تنخواه ارزی برای تسعیر
+type foreignAccount struct {
+	sourceType string
+	column     string
+	id         uint
+	currency   models.Currency
+	balance    float64
+	bookValue  float64
+}
+
+func foreignAccounts(db *gorm.DB) ([]foreignAccount, error) {
+	var banks []models.BankAccount
+	if err := db.Where("currency <> ?", models.CurrencyRial).Order("id").Find(&banks).Error; err != nil {
+		return nil, err
+	}
+	var holders []models.CashHolder
+	if err := db.Where("currency <> ?", models.CurrencyRial).Order("id").Find(&holders).Error; err != nil {
+		return nil, err
+	}
+
+	accounts := make([]foreignAccount, 0, len(banks)+len(holders))
+	for _, b := range banks {
+		accounts = append(accounts, foreignAccount{"bank", "bank_account_id", b.ID, b.Currency, b.Balance, b.BookValue})
+	}
+	for _, h := range holders {
+		accounts = append(accounts, foreignAccount{"cash", "cash_holder_id", h.ID, h.Currency, h.Balance, h.BookValue})
+	}
+	return accounts, nil
+}
+
+// foreignFlowsSince گردش پرداخت‌شده حساب پس از تاریخ داده‌شده (به واحد ارز و به واحد دفتر)
+func foreignFlowsSince(db *gorm.DB, column string, id uint, since time.Time) (units, book float64, err error) {
+	var row struct {
+		Units float64
+		Book  float64
+	}
+	err = db.Model(&models.Transaction{}).
+		Select(`COALESCE(SUM(CASE WHEN transaction_type IN ('income','share') THEN foreign_amount ELSE -foreign_amount END),0) AS units,
+			COALESCE(SUM(CASE WHEN transaction_type IN ('income','share') THEN amount ELSE -amount END),0) AS book`).
+		Where(column+" = ? AND is_paid = ?", id, true).
+		Where("COALESCE(transaction_date, created_at) > ?", since).
+		Scan(&row).Error
+	return row.Units, row.Book, err
+}
+
+func revaluationSource(r *models.Revaluation) (string, uint) {
+	if r.BankAccountID != nil {
+		return "bank_account_id", *r.BankAccountID
+	}
+	if r.CashHolderID != nil {
+		return "cash_holder_id", *r.CashHolderID
+	}
+	return "", 0
+}
+
+// RevalueAccounts تسعیر پایان دوره همه حساب‌ها و تنخواه‌های ارزی و طلا به نرخ تاریخ داده‌شده.
+// مانده و ارزش دفتری در آن تاریخ از روی گردش‌های بعدی بازسازی می‌شود و اختلاف با مانده × نرخ
+// به‌عنوان سود/زیان تسعیر به ارزش دفتری فعلی اضافه می‌شود.
+func RevalueAccounts(date time.Time, userID *uint, db *gorm.DB) ([]models.Revaluation, error) {
+	if date.After(time.Now()) {
+		return nil, errors.New("تاریخ تسعیر نمی‌تواند در آینده باشد")
+	}
+
+	var result []models.Revaluation
+	err := db.Transaction(func(tx *gorm.DB) error {
+		accounts, err := foreignAccounts(tx)
+		if err != nil {
+			return err
+		}
+		if len(accounts) == 0 {
+			return errors.New("حساب یا تنخواه ارزی و طلا وجود ندارد")
+		}
+
+		for _, acc := range accounts {
+			var later int64
+			if err := tx.Model(&models.Revaluation{}).
+				Where(acc.column+" = ? AND date >= ?", acc.id, date).
+				Count(&later).Error; err != nil {
+				return err
+			}
+			if later > 0 {
+				return errors.New("برای این تاریخ یا پس از آن قبلاً تسعیر ثبت شده است")
+			}
+
+			rate, err := RateAt(tx, acc.currency, date)
+			if err != nil {
+				return err
+			}
+			units, book, err := foreignFlowsSince(tx, acc.column, acc.id, date)
+			if err != nil {
+				return err
+			}
+
+			balance := roundUnits(acc.balance - units)
+			before := roundAmount(acc.bookValue - book)
+			after := roundAmount(balance * rate)
+			gain := after - before
+			if gain == 0 {
+				continue
+			}
+
+			rev := models.Revaluation{
+				Date:            date,
+				MoneySourceType: acc.sourceType,
+				Currency:        acc.currency,
+				Balance:         balance,
+				Rate:            rate,
+				BookValueBefore: before,
+				BookValueAfter:  after,
+				Gain:            gain,
+				CreatedByID:     userID,
+			}
+			id := acc.id
+			if acc.sourceType == "bank" {
+				rev.BankAccountID = &id
+			} else {
+				rev.CashHolderID = &id
+			}
+			if err := tx.Create(&rev).Error; err != nil {
+				return err
+			}
+			if err := shiftBookValue(tx, acc.sourceType, acc.id, gain); err != nil {
+				return err
+			}
+			result = append(result, rev)
+		}
+		return nil
+	})
+	return result, err
+}
+
+func shiftBookValue(db *gorm.DB, sourceType string, id uint, delta float64) error {
+	var model interface{} = &models.BankAccount{}
+	if sourceType == "cash" {
+		model = &models.CashHolder{}
+	}
+	return db.Model(model).Where("id = ?", id).
+		Update("book_value", gorm.Expr("book_value + ?", delta)).Error
+}
+
+type RevaluationFilter struct {
+	From, To *time.Time
+}
+
+func GetRevaluations(filter RevaluationFilter, db *gorm.DB) ([]models.Revaluation, error) {
+	var list []models.Revaluation
+	q := db.Order("date DESC, id DESC")
+	if filter.From != nil {
+		q = q.Where("date >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		q = q.Where("date < ?", *filter.To)
+	}
+	err := q.Find(&list).Error
+	return list, err
+}
+
+// DeleteRevaluation فقط آخرین تسعیر هر حساب حذف می‌شود و ارزش دفتری برمی‌گردد
+func DeleteRevaluation(id uint, db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var rev models.Revaluation
+		if err := tx.First(&rev, id).Error; err != nil {
+			return err
+		}
+		column, sourceID := revaluationSource(&rev)
+
+		var later int64
+		if err := tx.Model(&models.Revaluation{}).
+			Where(column+" = ? AND id <> ? AND date >= ?", sourceID, rev.ID, rev.Date).
+			Count(&later).Error; err != nil {
+			return err
+		}
+		if later > 0 {
+			return errors.New("ابتدا تسعیرهای بعدی این حساب را حذف کنید")
+		}
+
+		if err := shiftBookValue(tx, rev.MoneySourceType, sourceID, -rev.Gain); err != nil {
+			return err
+		}
+		return tx.Delete(&rev).Error
+	})
+}
@@ -0,0 +1,133 @@
+package repositories
+
+import (
+	"errors"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/database"
+	"github.com/amirqodi/hgm/internal/models"
+	"github.com/amirqodi/hgm/internal/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSessionInvalid     = errors.New("نشست منقضی یا باطل شده است؛ دوباره وارد شوید")
+	ErrRefreshTokenReused = errors.New("توکن تازه‌سازی قبلاً استفاده شده است؛ نشست برای امنیت باطل شد")
+)
+
+// CreateSession نشست جدید برای کاربر؛ توکن تازه‌سازی خام فقط همین‌جا برگردانده می‌شود
+func CreateSession(userID uint, userAgent, ip string) (*models.Session, string, error) {
+	token, hash, err := utils.NewRefreshToken()
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	session := models.Session{
+		UserID:     userID,
+		TokenHash:  hash,
+		UserAgent:  userAgent,
+		IP:         ip,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(utils.RefreshTokenTTL()),
+	}
+	if err := database.DB.Create(&session).Error; err != nil {
+		return nil, "", err
+	}
+	return &session, token, nil
+}
+
+// RotateSession تعویض توکن تازه‌سازی نشست و تمدید آن. ارائه دوباره توکن قبلی یعنی
+// توکن دزدیده شده است؛ در این صورت کل نشست باطل می‌شود.
+func RotateSession(refreshToken string) (*models.Session, *models.User, string, error) {
+	hash := utils.HashToken(refreshToken)
+	now := time.Now()
+
+	var session models.Session
+	err := database.DB.Where("token_hash = ?", hash).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if err := database.DB.Where("previous_hash = ?", hash).First(&session).Error; err == nil {
+			if err := revokeSessions(database.DB.Where("id = ?", session.ID)); err != nil {
+				return nil, nil, "", err
+			}
+			return nil, nil, "", ErrRefreshTokenReused
+		}
+		return nil, nil, "", ErrSessionInvalid
+	}
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if !session.Active(now) {
+		return nil, nil, "", ErrSessionInvalid
+	}
+
+	user, err := FindByID(session.UserID)
+	if err != nil || !user.Active {
+		return nil, nil, "", ErrSessionInvalid
+	}
+
+	token, newHash, err := utils.NewRefreshToken()
+	if err != nil {
+		return nil, nil, "", err
+	}
+	// شرط token_hash جلوی تازه‌سازی هم‌زمان با یک توکن را می‌گیرد
+	result := database.DB.Model(&models.Session{}).
+		Where("id = ? AND token_hash = ? AND revoked_at IS NULL", session.ID, hash).
+		Updates(map[string]any{
+			"token_hash":    newHash,
+			"previous_hash": hash,
+			"last_used_at":  now,
+			"expires_at":    now.Add(utils.RefreshTokenTTL()),
+		})
+	if result.Error != nil {
+		return nil, nil, "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil, "", ErrSessionInvalid
+	}
+	session.TokenHash, session.PreviousHash, session.LastUsedAt = newHash, hash, now
+	return &session, user, token, nil
+}
+
+// ValidateSession نشست توکن دسترسی متعلق به کاربر، فعال و کاربر آن فعال باشد
+func ValidateSession(sessionID, userID uint) error {
+	var session models.Session
+	if err := database.DB.Where("id = ? AND user_id = ?", sessionID, userID).First(&session).Error; err != nil {
+		return ErrSessionInvalid
+	}
+	if !session.Active(time.Now()) {
+		return ErrSessionInvalid
+	}
+	user, err := FindByID(userID)
+	if err != nil || !user.Active {
+		return ErrSessionInvalid
+	}
+	return nil
+}
+
+// GetUserSessions نشست‌های فعال کاربر، جدیدترین اول
+func GetUserSessions(userID uint) ([]models.Session, error) {
+	var sessions []models.Session
+	err := database.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+		Order("last_used_at DESC, id DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+// RevokeSession ابطال یک نشست کاربر
+func RevokeSession(id, userID uint) error {
+	var session models.Session
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&session).Error; err != nil {
+		return err
+	}
+	return revokeSessions(database.DB.Where("id = ?", id))
+}
+
+// RevokeUserSessions ابطال همه نشست‌های کاربر (خروج از همه دستگاه‌ها)
+func RevokeUserSessions(userID uint, db *gorm.DB) error {
+	return revokeSessions(db.Where("user_id = ?", userID))
+}
+
+func revokeSessions(query *gorm.DB) error {
+	return query.Model(&models.Session{}).Where("revoked_at IS NULL").
+		Update("revoked_at", time.Now()).Error
+}
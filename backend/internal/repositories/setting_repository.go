@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/amirqodi/hgm/internal/database"
+	"github.com/amirqodi/hgm/internal/models"
+	"gorm.io/gorm"
+)
+
+// GetSetting مقدار تنظیم key؛ اگر ثبت نشده باشد fallback
+func GetSetting(key, fallback string) (string, error) {
+	var setting models.Setting
+	err := database.DB.Where(&models.Setting{Key: key}).First(&setting).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fallback, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return setting.Value, nil
+}
+
+func SetSetting(key, value string) error {
+	return database.DB.Save(&models.Setting{Key: key, Value: value}).Error
+}
+
+// TwoFactorRequiredRoles نقش‌هایی که مدیر ورود دومرحله‌ای را برایشان اجباری کرده است
+func TwoFactorRequiredRoles() ([]models.UserRole, error) {
+	value, err := GetSetting(models.SettingTwoFactorRoles, "")
+	if err != nil {
+		return nil, err
+	}
+	roles := []models.UserRole{}
+	for _, part := range strings.Split(value, ",") {
+		if role := models.UserRole(strings.TrimSpace(part)); role.Valid() {
+			roles = append(roles, role)
+		}
+	}
+	return roles, nil
+}
+
+func SetTwoFactorRequiredRoles(roles []models.UserRole) error {
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		if !role.Valid() {
+			return ErrInvalidRole
+		}
+		names = append(names, string(role))
+	}
+	return SetSetting(models.SettingTwoFactorRoles, strings.Join(names, ","))
+}
+
+// IsTwoFactorRequired ورود دومرحله‌ای برای نقش role اجباری است
+func IsTwoFactorRequired(role models.UserRole) (bool, error) {
+	roles, err := TwoFactorRequiredRoles()
+	if err != nil {
+		return false, err
+	}
+	for _, r := range roles {
+		if r == role {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -2,10 +2,12 @@ package repositories
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"math"
 	"os"
 	"strings"
+	"time"
 
 	"mime/multipart"
 
@@ -131,23 +133,139 @@ func GetTransactionByID(id uint, db *gorm.DB) (*models.Transaction, error) {
 }
 
 // ---------------- UPDATE ----------------
-func UpdateTransaction(id uint, trx *models.Transaction, db *gorm.DB) error {
-	if err := validateTransaction(trx, db); err != nil {
+// checkNoIssuedInvoice خطای ErrInvoiceIssued اگر برای تراکنش فاکتور یا رسید معتبر صادر شده باشد
+func checkNoIssuedInvoice(tx *gorm.DB, transactionID uint) error {
+	var issued int64
+	if err := tx.Model(&models.Invoice{}).
+		Where("transaction_id = ? AND status = ?", transactionID, models.InvoiceIssued).
+		Count(&issued).Error; err != nil {
 		return err
 	}
+	if issued > 0 {
+		return ErrInvoiceIssued
+	}
+	return nil
+}
 
-	if err := db.Session(&gorm.Session{FullSaveAssociations: true}).Updates(trx).Error; err != nil {
-		return err
+func UpdateTransaction(id uint, trx *models.Transaction, db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var existing models.Transaction
+		if err := tx.Preload("SubTransactions").First(&existing, id).Error; err != nil {
+			return err
+		}
+		if err := CheckReconciliationLock(tx, existing.MoneySourceType, existing.BankAccountID, transactionDate(&existing)); err != nil {
+			return err
+		}
+
+		// تراکنش دارای فاکتور یا رسید معتبر ویرایش نمی‌شود
+		if err := checkNoIssuedInvoice(tx, id); err != nil {
+			return err
+		}
+
+		// اثر قبلی تراکنش نقدی (موجودی، کالا و رویداد سرمایه) برگردانده و با مقادیر جدید دوباره
+		// اعمال می‌شود تا ویرایش، مبلغ را دو بار اعمال نکند؛ پیش از اعتبارسنجی تا بررسی موجودی کالا
+		// تعداد قبلی همین فروش را کسرشده حساب نکند
+		if existing.IsPaid && len(existing.SubTransactions) == 0 {
+			if err := revertBalanceAndStock(&existing, tx); err != nil {
+				return err
+			}
+		}
+
+		trx.ID = id
+		if err := validateTransaction(trx, tx); err != nil {
+			return err
+		}
+
+		if err := tx.Session(&gorm.Session{FullSaveAssociations: true}).Updates(trx).Error; err != nil {
+			return err
+		}
+
+		var updated models.Transaction
+		if err := tx.Preload("SubTransactions").First(&updated, id).Error; err != nil {
+			return err
+		}
+
+		// تاریخ یا حساب جدید هم نباید در دوره تطبیق‌شده باشد
+		if err := CheckReconciliationLock(tx, updated.MoneySourceType, updated.BankAccountID, transactionDate(&updated)); err != nil {
+			return err
+		}
+		accountChanged := updated.MoneySourceType != existing.MoneySourceType || !sameUint(updated.BankAccountID, existing.BankAccountID)
+		subIDs := make([]uint, 0, len(updated.SubTransactions))
+		for _, sub := range updated.SubTransactions {
+			subIDs = append(subIDs, sub.ID)
+			if accountChanged && sub.PaidAt != nil {
+				if err := CheckReconciliationLock(tx, updated.MoneySourceType, updated.BankAccountID, *sub.PaidAt); err != nil {
+					return err
+				}
+			}
+		}
+
+		// مبلغ یا حساب عوض شده → تطبیق قبلی با صورتحساب بانک دیگر معتبر نیست
+		if accountChanged || updated.Amount != existing.Amount {
+			if err := releaseStatementMatches(tx, []string{models.MatchTransaction}, []uint{id}); err != nil {
+				return err
+			}
+		}
+		if accountChanged {
+			if err := releaseStatementMatches(tx, []string{models.MatchSubTransaction}, subIDs); err != nil {
+				return err
+			}
+		}
+
+		if updated.IsPaid && len(updated.SubTransactions) == 0 {
+			return adjustBalanceAndStock(&updated, tx, updated.Amount, false)
+		}
+		return nil
+	})
+}
+
+// sameUint دو شناسه اختیاری برابرند
+func sameUint(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
 	}
+	return *a == *b
+}
 
-	if trx.IsPaid && len(trx.SubTransactions) == 0 {
-		return adjustBalanceAndStock(trx, db, trx.Amount, false)
+// ---------------- DELETE ----------------
+
+// releaseQuote پیش‌فاکتور تبدیل‌شده وقتی دوباره قابل تبدیل است که هیچ تراکنشی از تبدیل آن نمانده باشد؛
+// در غیر این صورت به تراکنش باقی‌مانده (و فاکتور معتبر آن) اشاره می‌کند
+func releaseQuote(tx *gorm.DB, trx *models.Transaction) error {
+	quotes := tx.Model(&models.Quote{}).Where("transaction_id = ?", trx.ID)
+	if trx.QuoteID != nil {
+		quotes = tx.Model(&models.Quote{}).Where("id = ?", *trx.QuoteID)
+
+		var remaining []models.Transaction
+		if err := tx.Where("quote_id = ? AND id <> ?", *trx.QuoteID, trx.ID).Order("id").Limit(1).
+			Find(&remaining).Error; err != nil {
+			return err
+		}
+		if len(remaining) > 0 {
+			var invoices []models.Invoice
+			if err := tx.Where("transaction_id = ? AND kind = ? AND voided_at IS NULL", remaining[0].ID, models.InvoiceSales).
+				Order("id").Limit(1).Find(&invoices).Error; err != nil {
+				return err
+			}
+			var invoiceID *uint
+			if len(invoices) > 0 {
+				invoiceID = &invoices[0].ID
+			}
+			return quotes.Updates(map[string]interface{}{
+				"transaction_id": remaining[0].ID,
+				"invoice_id":     invoiceID,
+			}).Error
+		}
 	}
 
-	return nil
+	// پیش‌فاکتور تبدیل‌شده به این تراکنش دوباره قابل تبدیل شود
+	return quotes.Updates(map[string]interface{}{
+		"transaction_id": nil,
+		"invoice_id":     nil,
+		"converted_at":   nil,
+	}).Error
 }
 
-// ---------------- DELETE ----------------
 func DeleteTransaction(id uint, db *gorm.DB) error {
 	return db.Transaction(func(tx *gorm.DB) error {
 		trx, err := GetTransactionByID(id, tx)
@@ -155,11 +273,52 @@ func DeleteTransaction(id uint, db *gorm.DB) error {
 			return err
 		}
 
+		if err := CheckReconciliationLock(tx, trx.MoneySourceType, trx.BankAccountID, transactionDate(trx)); err != nil {
+			return err
+		}
+		for _, sub := range trx.SubTransactions {
+			if sub.PaidAt != nil {
+				if err := CheckReconciliationLock(tx, trx.MoneySourceType, trx.BankAccountID, *sub.PaidAt); err != nil {
+					return err
+				}
+			}
+		}
+
+		// تراکنش دارای فاکتور یا رسید معتبر حذف نمی‌شود
+		if err := checkNoIssuedInvoice(tx, trx.ID); err != nil {
+			return err
+		}
+
 		// همیشه همه چیز برگرده
 		if err := revertBalanceAndStock(trx, tx); err != nil {
 			return err
 		}
 
+		// ردیف‌های صورتحساب تطبیق‌داده‌شده با این تراکنش آزاد شوند
+		subIDs := make([]uint, 0, len(trx.SubTransactions))
+		for _, sub := range trx.SubTransactions {
+			subIDs = append(subIDs, sub.ID)
+		}
+		if err := releaseStatementMatches(tx, []string{models.MatchTransaction}, []uint{trx.ID}); err != nil {
+			return err
+		}
+		if err := releaseStatementMatches(tx, []string{models.MatchSubTransaction}, subIDs); err != nil {
+			return err
+		}
+
+		// صورتحساب خرید ثبت‌شده با این تراکنش دوباره قابل ثبت شود
+		if err := tx.Model(&models.VendorBill{}).Where("transaction_id = ?", trx.ID).Updates(map[string]interface{}{
+			"transaction_id": nil,
+			"posted_at":      nil,
+			"posted_by_id":   nil,
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := releaseQuote(tx, trx); err != nil {
+			return err
+		}
+
 		// حذف فایل‌های ضمیمه از سیستم
 		for _, att := range trx.Attachments {
 			_ = os.Remove("." + att.FilePath) // چون path مثل /uploads/... ذخیره کردی
@@ -182,7 +341,9 @@ func PaySubTransaction(sub *models.SubTransaction, db *gorm.DB) error {
 			return nil
 		}
 
+		now := time.Now()
 		sub.IsPaid = true
+		sub.PaidAt = &now
 		if err := tx.Save(sub).Error; err != nil {
 			return err
 		}
@@ -224,6 +385,11 @@ func PaySubTransaction(sub *models.SubTransaction, db *gorm.DB) error {
 
 // ---------------- VALIDATION ----------------
 func validateTransaction(trx *models.Transaction, db *gorm.DB) error {
+	// Foreign currency / gold account → Amount at the transaction date rate
+	if err := prepareForeignAmount(trx, db); err != nil {
+		return err
+	}
+
 	// Sub-transaction sum must match
 	if len(trx.SubTransactions) > 0 {
 		sum := 0.0
@@ -248,24 +414,141 @@ func validateTransaction(trx *models.Transaction, db *gorm.DB) error {
 		return errors.New("نوع تراکنش اشتباه است")
 	}
 
+	// Reconciled (locked) bank period
+	if err := CheckReconciliationLock(db, trx.MoneySourceType, trx.BankAccountID, transactionDate(trx)); err != nil {
+		return err
+	}
+
+	// Customer credit limit / overdue validation
+	if err := checkCreditPolicy(trx, db); err != nil {
+		return err
+	}
+
 	// Product stock validation
 	if trx.ProductID != nil && trx.Quantity > 0 {
-		var product models.ProductService
-		if err := db.First(&product, *trx.ProductID).Error; err != nil {
-			return errors.New("محصول یافت نشد")
+		if _, err := checkProductStock(*trx.ProductID, int64(trx.Quantity), trx.TransactionType, db); err != nil {
+			return err
 		}
-		if product.Stock != nil {
-			if trx.TransactionType == "income" {
-				// فروش => باید موجودی کافی داشته باشیم
-				if *product.Stock < int64(trx.Quantity) {
-					return errors.New("موجودی کالا کافی نیست")
-				}
+	}
+
+	return nil
+}
+
+// checkProductStock بررسی موجودی کالا برای تعداد داده‌شده؛ کالای بدون موجودی (خدمت) همیشه مجاز است
+func checkProductStock(productID uint, qty int64, transactionType string, db *gorm.DB) (*models.ProductService, error) {
+	var product models.ProductService
+	if err := db.First(&product, productID).Error; err != nil {
+		return nil, errors.New("محصول یافت نشد")
+	}
+	if product.Stock != nil {
+		if transactionType == "income" {
+			// فروش => باید موجودی کافی داشته باشیم
+			if *product.Stock < qty {
+				return &product, fmt.Errorf("موجودی کالای %s کافی نیست", product.Name)
 			}
-			// در expense (خرید) موجودی کم نمیاد، بلکه اضافه میشه
 		}
+		// در expense (خرید) موجودی کم نمیاد، بلکه اضافه میشه
 	}
+	return &product, nil
+}
 
-	return nil
+// checkCreditPolicy بررسی سقف اعتبار و اقساط معوق مشتری برای فروش نسیه یا قسطی
+func checkCreditPolicy(trx *models.Transaction, db *gorm.DB) error {
+	if trx.TransactionType != "income" || trx.ContactID == 0 {
+		return nil
+	}
+
+	// فقط فروش پرداخت‌نشده یا قسطی
+	newAmount := 0.0
+	if len(trx.SubTransactions) > 0 {
+		for _, sub := range trx.SubTransactions {
+			if !sub.IsPaid {
+				newAmount += sub.Amount
+			}
+		}
+	} else if !trx.IsPaid {
+		newAmount = trx.Amount
+	}
+	if newAmount <= 0 {
+		return nil
+	}
+
+	// سیاست اعتبار در رکورد نقش مشتری نگهداری می‌شود
+	var contact models.CustomerProfile
+	err := db.Where("contact_id = ?", trx.ContactID).First(&contact).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if contact.CreditLimit == nil && contact.MaxOverdueDays == nil {
+		return nil
+	}
+
+	var violations []string
+
+	if contact.CreditLimit != nil {
+		outstanding, err := customerOutstanding(contact.ContactID, trx.ID, db)
+		if err != nil {
+			return err
+		}
+		if outstanding+newAmount > *contact.CreditLimit {
+			violations = append(violations, fmt.Sprintf(
+				"مانده بدهی مشتری (%.0f) به‌همراه این تراکنش (%.0f) از سقف اعتبار (%.0f) بیشتر است",
+				outstanding, newAmount, *contact.CreditLimit,
+			))
+		}
+	}
+
+	if contact.MaxOverdueDays != nil {
+		cutoff := time.Now().AddDate(0, 0, -*contact.MaxOverdueDays)
+		var overdue int64
+		if err := db.Model(&models.SubTransaction{}).
+			Joins("JOIN transactions ON transactions.id = sub_transactions.transaction_id").
+			Where("transactions.contact_id = ? AND transactions.transaction_type = ? AND transactions.id <> ?", contact.ContactID, "income", trx.ID).
+			Where("sub_transactions.is_paid = ? AND sub_transactions.due_date < ?", false, cutoff).
+			Count(&overdue).Error; err != nil {
+			return err
+		}
+		if overdue > 0 {
+			violations = append(violations, fmt.Sprintf("مشتری %d قسط معوق بیش از %d روز دارد", overdue, *contact.MaxOverdueDays))
+		}
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	// مدیر می‌تواند محدودیت را نادیده بگیرد؛ در حالت هشدار فقط پیام برگردانده می‌شود
+	if trx.CreditOverride || contact.CreditPolicy == models.CreditPolicyWarn {
+		trx.Warnings = append(trx.Warnings, violations...)
+		return nil
+	}
+
+	return errors.New(strings.Join(violations, "؛ "))
+}
+
+// customerOutstanding مانده بدهی پرداخت‌نشده مشتری (فروش نسیه + اقساط پرداخت‌نشده)
+func customerOutstanding(contactID, excludeTrxID uint, db *gorm.DB) (float64, error) {
+	var unpaid, unpaidSubs float64
+
+	if err := db.Model(&models.Transaction{}).
+		Where("contact_id = ? AND transaction_type = ? AND is_paid = ? AND id <> ?", contactID, "income", false, excludeTrxID).
+		Where("id NOT IN (?)", db.Model(&models.SubTransaction{}).Select("transaction_id")).
+		Select("COALESCE(SUM(amount),0)").Scan(&unpaid).Error; err != nil {
+		return 0, err
+	}
+
+	if err := db.Model(&models.SubTransaction{}).
+		Joins("JOIN transactions ON transactions.id = sub_transactions.transaction_id").
+		Where("transactions.contact_id = ? AND transactions.transaction_type = ? AND transactions.id <> ?", contactID, "income", excludeTrxID).
+		Where("sub_transactions.is_paid = ?", false).
+		Select("COALESCE(SUM(sub_transactions.amount),0)").Scan(&unpaidSubs).Error; err != nil {
+		return 0, err
+	}
+
+	return unpaid + unpaidSubs, nil
 }
 
 func adjustBalanceAndStock(trx *models.Transaction, db *gorm.DB, overrideAmount float64, skipProductStock bool) error {
@@ -281,14 +564,17 @@ func adjustBalanceAndStock(trx *models.Transaction, db *gorm.DB, overrideAmount
 					return err
 				}
 
+				units, book := sourceAmounts(trx, amount)
 				switch trx.TransactionType {
 				case "income", "share":
-					bank.Balance += amount
+					bank.Balance += units
+					bank.BookValue += book
 				case "expense", "share_reduction":
-					if bank.Balance < amount {
+					if bank.Balance < units {
 						return errors.New("موجودی حساب بانکی کافی نیست")
 					}
-					bank.Balance -= amount
+					bank.Balance -= units
+					bank.BookValue -= book
 				}
 
 				if err := db.Save(&bank).Error; err != nil {
@@ -303,14 +589,17 @@ func adjustBalanceAndStock(trx *models.Transaction, db *gorm.DB, overrideAmount
 					return err
 				}
 
+				units, book := sourceAmounts(trx, amount)
 				switch trx.TransactionType {
 				case "income", "share":
-					cash.Balance += amount
+					cash.Balance += units
+					cash.BookValue += book
 				case "expense", "share_reduction":
-					if cash.Balance < amount {
+					if cash.Balance < units {
 						return errors.New("موجودی تنخواه کافی نیست")
 					}
-					cash.Balance -= amount
+					cash.Balance -= units
+					cash.BookValue -= book
 				}
 
 				if err := db.Save(&cash).Error; err != nil {
@@ -345,33 +634,9 @@ func adjustBalanceAndStock(trx *models.Transaction, db *gorm.DB, overrideAmount
 		}
 	}
 
-	// --- 3. سرمایه سهامدار ---
+	// --- 3. سرمایه سهامدار (ثبت رویداد آورده/برداشت) ---
 	if amount > 0 && trx.ContactID != 0 && trx.CategoryID != 0 {
-		var category models.Category
-		if err := db.First(&category, trx.CategoryID).Error; err != nil {
-			return err
-		}
-
-		var shareholder models.Contact
-		if err := db.First(&shareholder, trx.ContactID).Error; err != nil {
-			return err
-		}
-
-		if shareholder.Amount == nil {
-			shareholder.Amount = new(float64)
-		}
-
-		switch category.Name {
-		case "افزایش سهام":
-			*shareholder.Amount += amount
-		case "کاهش سهام":
-			if *shareholder.Amount < amount {
-				return errors.New("میزان سهام کافی نیست")
-			}
-			*shareholder.Amount -= amount
-		}
-
-		if err := db.Save(&shareholder).Error; err != nil {
+		if err := recordTransactionCapital(trx, db, amount); err != nil {
 			return err
 		}
 	}
@@ -390,13 +655,16 @@ func revertBalanceAndStock(trx *models.Transaction, db *gorm.DB) error {
 			if err := db.First(&bank, *trx.BankAccountID).Error; err != nil {
 				return err
 			}
+			units, book := sourceAmounts(trx, amount)
 			switch trx.TransactionType {
 			case "income", "share":
 				// قبلاً اضافه شده → حالا کم می‌کنیم
-				bank.Balance -= amount
+				bank.Balance -= units
+				bank.BookValue -= book
 			case "expense", "share_reduction":
 				// قبلاً کم شده → حالا زیاد می‌کنیم
-				bank.Balance += amount
+				bank.Balance += units
+				bank.BookValue += book
 			}
 			if bank.Balance < 0 {
 				bank.Balance = 0
@@ -411,11 +679,14 @@ func revertBalanceAndStock(trx *models.Transaction, db *gorm.DB) error {
 			if err := db.First(&cash, *trx.CashHolderID).Error; err != nil {
 				return err
 			}
+			units, book := sourceAmounts(trx, amount)
 			switch trx.TransactionType {
 			case "income", "share":
-				cash.Balance -= amount
+				cash.Balance -= units
+				cash.BookValue -= book
 			case "expense", "share_reduction":
-				cash.Balance += amount
+				cash.Balance += units
+				cash.BookValue += book
 			}
 			if cash.Balance < 0 {
 				cash.Balance = 0
@@ -451,35 +722,9 @@ func revertBalanceAndStock(trx *models.Transaction, db *gorm.DB) error {
 		}
 	}
 
-	// --- 3. سرمایه سهامدار ---
-	if trx.ContactID != 0 && trx.CategoryID != 0 {
-		var category models.Category
-		if err := db.First(&category, trx.CategoryID).Error; err != nil {
-			return err
-		}
-
-		var shareholder models.Contact
-		if err := db.First(&shareholder, trx.ContactID).Error; err != nil {
-			return err
-		}
-
-		if shareholder.Amount == nil {
-			shareholder.Amount = new(float64)
-		}
-
-		switch category.Name {
-		case "افزایش سهام":
-			// قبلاً اضافه شده → حالا کم می‌کنیم
-			*shareholder.Amount -= amount
-			if *shareholder.Amount < 0 {
-				*shareholder.Amount = 0
-			}
-		case "کاهش سهام":
-			// قبلاً کم شده → حالا زیاد می‌کنیم
-			*shareholder.Amount += amount
-		}
-
-		if err := db.Save(&shareholder).Error; err != nil {
+	// --- 3. سرمایه سهامدار (حذف رویدادهای سرمایه تراکنش) ---
+	if trx.ContactID != 0 {
+		if err := revertTransactionCapital(trx, db); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,90 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/amirqodi/hgm/internal/database"
+	"github.com/amirqodi/hgm/internal/models"
+	"gorm.io/gorm"
+)
+
+// SavePendingTOTPSecret کلید در حال ثبت؛ تا تأیید اولین کد، ورود دومرحله‌ای فعال نمی‌شود
+func SavePendingTOTPSecret(userID uint, secret string) error {
+	return database.DB.Model(&models.User{}).Where("id = ? AND two_factor_enabled = ?", userID, false).
+		Updates(map[string]any{"totp_secret": secret, "totp_last_step": 0}).Error
+}
+
+// UseTOTPStep ثبت بازه کد استفاده‌شده؛ false یعنی این کد (یا کد جدیدتری) قبلاً استفاده شده است
+func UseTOTPStep(userID uint, step int64) (bool, error) {
+	result := database.DB.Model(&models.User{}).Where("id = ? AND totp_last_step < ?", userID, step).
+		Update("totp_last_step", step)
+	return result.RowsAffected == 1, result.Error
+}
+
+// EnableTwoFactor فعال‌سازی ورود دومرحله‌ای با کدهای بازیابی جدید؛ نشست‌های دیگر کاربر
+// (که بدون کد دوم باز شده‌اند) به‌جز keepSessionID لغو می‌شوند
+func EnableTwoFactor(userID uint, codeHashes []string, keepSessionID uint) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).
+			Update("two_factor_enabled", true).Error; err != nil {
+			return err
+		}
+		if err := replaceRecoveryCodes(userID, codeHashes, tx); err != nil {
+			return err
+		}
+		return revokeSessions(tx.Where("user_id = ? AND id <> ?", userID, keepSessionID))
+	})
+}
+
+// DisableTwoFactor غیرفعال‌سازی ورود دومرحله‌ای و حذف کلید و کدهای بازیابی
+func DisableTwoFactor(userID uint) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]any{
+			"two_factor_enabled": false,
+			"totp_secret":        "",
+			"totp_last_step":     0,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+func ReplaceRecoveryCodes(userID uint, codeHashes []string) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		return replaceRecoveryCodes(userID, codeHashes, tx)
+	})
+}
+
+func replaceRecoveryCodes(userID uint, codeHashes []string, tx *gorm.DB) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	codes := make([]models.RecoveryCode, len(codeHashes))
+	for i, hash := range codeHashes {
+		codes[i] = models.RecoveryCode{UserID: userID, CodeHash: hash}
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	return tx.Create(&codes).Error
+}
+
+// UseRecoveryCode مصرف کد بازیابی؛ false یعنی کد نادرست یا قبلاً مصرف‌شده است
+func UseRecoveryCode(userID uint, codeHash string) (bool, error) {
+	result := database.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// CountRecoveryCodes تعداد کدهای بازیابی مصرف‌نشده
+func CountRecoveryCodes(userID uint) (int64, error) {
+	var count int64
+	err := database.DB.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
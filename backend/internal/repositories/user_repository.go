@@ -1,8 +1,12 @@
 package repositories
 
 import (
+	"errors"
+	"time"
+
 	"github.com/amirqodi/hgm/internal/database"
 	"github.com/amirqodi/hgm/internal/models"
+	"gorm.io/gorm"
 )
 
 func FindByUsername(username string) (*models.User, error) {
@@ -14,6 +18,167 @@ func FindByUsername(username string) (*models.User, error) {
 	return &user, nil
 }
 
+func FindByID(id uint) (*models.User, error) {
+	var user models.User
+	if err := database.DB.First(&user, id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 func CreateUser(user *models.User) error {
 	return database.DB.Create(user).Error
 }
+
+var (
+	ErrLastOwner          = errors.New("حداقل یک مالک فعال باید باقی بماند")
+	ErrUsernameTaken      = errors.New("این نام کاربری قبلاً ثبت شده است")
+	ErrCashierNeedsHolder = errors.New("برای صندوقدار تنخواه را مشخص کنید")
+	ErrInvalidRole        = errors.New("نقش کاربر نامعتبر است")
+	ErrCashHolderMissing  = errors.New("تنخواه انتخاب‌شده یافت نشد")
+)
+
+func GetUsers() ([]models.User, error) {
+	var users []models.User
+	err := database.DB.Preload("CashHolder").Order("id").Find(&users).Error
+	return users, err
+}
+
+// validateUser نقش معتبر و تنخواه صندوقدار؛ برای سایر نقش‌ها تنخواه حذف می‌شود
+func validateUser(user *models.User, db *gorm.DB) error {
+	if !user.Role.Valid() {
+		return ErrInvalidRole
+	}
+	if user.Role != models.RoleCashier {
+		user.CashHolderID = nil
+		return nil
+	}
+	if user.CashHolderID == nil {
+		return ErrCashierNeedsHolder
+	}
+	if err := db.First(&models.CashHolder{}, *user.CashHolderID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrCashHolderMissing
+		}
+		return err
+	}
+	return nil
+}
+
+// ensureOtherOwner اگر user آخرین مالک فعال باشد تغییر نقش، غیرفعال‌سازی یا حذف او مجاز نیست
+func ensureOtherOwner(user *models.User, db *gorm.DB) error {
+	if user.Role != models.RoleOwner || !user.Active {
+		return nil
+	}
+	var count int64
+	if err := db.Model(&models.User{}).
+		Where("role = ? AND active = ? AND id <> ?", models.RoleOwner, true, user.ID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+func usernameTaken(username string, exceptID uint, db *gorm.DB) (bool, error) {
+	var count int64
+	err := db.Model(&models.User{}).Where("username = ? AND id <> ?", username, exceptID).Count(&count).Error
+	return count > 0, err
+}
+
+// CreateManagedUser کاربر جدید با رمز هش‌شده
+func CreateManagedUser(user *models.User) error {
+	db := database.DB
+	if err := validateUser(user, db); err != nil {
+		return err
+	}
+	if taken, err := usernameTaken(user.Username, 0, db); err != nil {
+		return err
+	} else if taken {
+		return ErrUsernameTaken
+	}
+	user.Active = true
+	return db.Create(user).Error
+}
+
+// UpdateManagedUser تغییر نام کاربری، نقش، وضعیت، تنخواه و در صورت ارسال رمز هش‌شده جدید
+func UpdateManagedUser(id uint, data *models.User, hashedPassword string) (*models.User, error) {
+	var user models.User
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&user, id).Error; err != nil {
+			return err
+		}
+		if err := validateUser(data, tx); err != nil {
+			return err
+		}
+		if data.Role != user.Role || !data.Active {
+			if err := ensureOtherOwner(&user, tx); err != nil {
+				return err
+			}
+		}
+		if taken, err := usernameTaken(data.Username, id, tx); err != nil {
+			return err
+		} else if taken {
+			return ErrUsernameTaken
+		}
+
+		updates := map[string]any{
+			"username":       data.Username,
+			"role":           data.Role,
+			"active":         data.Active,
+			"cash_holder_id": data.CashHolderID,
+		}
+		if hashedPassword != "" {
+			updates["password"] = hashedPassword
+			updates["password_changed_at"] = time.Now()
+		}
+		if err := tx.Model(&user).Updates(updates).Error; err != nil {
+			return err
+		}
+		// تغییر رمز یا غیرفعال شدن، همه نشست‌های کاربر را فوراً باطل می‌کند
+		if hashedPassword != "" || !data.Active {
+			if err := RevokeUserSessions(id, tx); err != nil {
+				return err
+			}
+		}
+		return tx.Preload("CashHolder").First(&user, id).Error
+	})
+	return &user, err
+}
+
+func DeleteManagedUser(id uint) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.First(&user, id).Error; err != nil {
+			return err
+		}
+		if err := ensureOtherOwner(&user, tx); err != nil {
+			return err
+		}
+		if err := RevokeUserSessions(id, tx); err != nil {
+			return err
+		}
+		return tx.Delete(&user).Error
+	})
+}
+
+// SetUserPassword تغییر رمز کاربر و ابطال نشست‌های او به جز keepSessionID (نشست جاری در تغییر رمز
+// توسط خود کاربر)؛ mustChange یعنی کاربر باید در ورود بعدی رمز را عوض کند (بازنشانی توسط مدیر)
+func SetUserPassword(id uint, hashedPassword string, mustChange bool, keepSessionID uint) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).Where("id = ?", id).Updates(map[string]any{
+			"password":             hashedPassword,
+			"must_change_password": mustChange,
+			"password_changed_at":  time.Now(),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return revokeSessions(tx.Where("user_id = ? AND id <> ?", id, keepSessionID))
+	})
+}
@@ -0,0 +1,119 @@
+package repositories
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/models"
+	"gorm.io/gorm"
+)
+
+// workOrderTransitions دستور کار باز انجام یا لغو می‌شود
+var workOrderTransitions = map[models.WorkOrderStatus][]models.WorkOrderStatus{
+	models.WorkOrderOpen: {models.WorkOrderDone, models.WorkOrderCancelled},
+}
+
+type WorkOrderFilter struct {
+	Status    models.WorkOrderStatus
+	ContactID uint
+}
+
+func WorkOrderNumber(fiscalYear, sequence int) string {
+	return fmt.Sprintf("WO-%d-%05d", fiscalYear, sequence)
+}
+
+// createWorkOrder دستور کار پیش‌فاکتور؛ موجودی کالاها بررسی می‌شود ولی تا تبدیل به تراکنش کم نمی‌شود
+func createWorkOrder(q *models.Quote, userID *uint, db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var open int64
+		if err := tx.Model(&models.WorkOrder{}).
+			Where("quote_id = ? AND status <> ?", q.ID, models.WorkOrderCancelled).
+			Count(&open).Error; err != nil {
+			return err
+		}
+		if open > 0 {
+			return errors.New("برای این پیش‌فاکتور قبلاً دستور کار صادر شده است")
+		}
+		if _, err := splitQuote(q, "income", tx); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		wo := models.WorkOrder{
+			QuoteID:     q.ID,
+			ContactID:   q.ContactID,
+			Status:      models.WorkOrderOpen,
+			Notes:       q.Notes,
+			OpenedAt:    now,
+			FiscalYear:  FiscalYear(now),
+			CreatedByID: userID,
+		}
+		seq, err := nextDocumentNumber(tx, models.DocumentWorkOrder, wo.FiscalYear)
+		if err != nil {
+			return err
+		}
+		wo.Sequence = seq
+		wo.Number = WorkOrderNumber(wo.FiscalYear, seq)
+		if err := tx.Create(&wo).Error; err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{
+			"status":        models.QuoteAccepted,
+			"work_order_id": wo.ID,
+		}
+		if q.DecidedAt == nil {
+			updates["decided_at"] = now
+		}
+		return tx.Model(q).Updates(updates).Error
+	})
+}
+
+func GetWorkOrders(filter WorkOrderFilter, db *gorm.DB) ([]models.WorkOrder, error) {
+	var orders []models.WorkOrder
+	q := db.Preload("Contact")
+	if filter.Status != "" {
+		q = q.Where("status = ?", filter.Status)
+	}
+	if filter.ContactID != 0 {
+		q = q.Where("contact_id = ?", filter.ContactID)
+	}
+	err := q.Order("opened_at DESC, id DESC").Find(&orders).Error
+	return orders, err
+}
+
+func GetWorkOrderByID(id uint, db *gorm.DB) (*models.WorkOrder, error) {
+	var wo models.WorkOrder
+	err := db.Preload("Quote.Items.Product").
+		Preload("Contact.Customer").
+		First(&wo, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &wo, nil
+}
+
+// SetWorkOrderStatus انجام یا لغو دستور کار
+func SetWorkOrderStatus(id uint, status models.WorkOrderStatus, db *gorm.DB) (*models.WorkOrder, error) {
+	var wo models.WorkOrder
+	if err := db.First(&wo, id).Error; err != nil {
+		return nil, err
+	}
+
+	allowed := false
+	for _, s := range workOrderTransitions[wo.Status] {
+		allowed = allowed || s == status
+	}
+	if !allowed {
+		return nil, fmt.Errorf("تغییر وضعیت از %s به %s مجاز نیست", wo.Status, status)
+	}
+
+	if err := db.Model(&wo).Updates(map[string]interface{}{
+		"status":    status,
+		"closed_at": time.Now(),
+	}).Error; err != nil {
+		return nil, err
+	}
+	return GetWorkOrderByID(id, db)
+}
@@ -3,6 +3,7 @@ package routes
 import (
 	"github.com/amirqodi/hgm/internal/handlers"
 	"github.com/amirqodi/hgm/internal/middlewares"
+	"github.com/amirqodi/hgm/internal/models"
 	"github.com/amirqodi/hgm/internal/services"
 	"github.com/gofiber/fiber/v2"
 )
@@ -12,52 +13,150 @@ func Register(app *fiber.App) {
 
 	// ---------------- Auth ----------------
 	api.Post("/auth/login", handlers.Login)
+	api.Post("/auth/refresh", handlers.RefreshTokenHandler) // توکن دسترسی جدید با توکن تازه‌سازی
+	api.Post("/auth/logout", middlewares.JWTProtected(), handlers.LogoutHandler)
+	api.Post("/auth/logout-all", middlewares.JWTProtected(), handlers.LogoutAllHandler) // خروج از همه دستگاه‌ها
+	api.Get("/auth/sessions", middlewares.JWTProtected(), handlers.GetMySessionsHandler)
+	api.Delete("/auth/sessions/:id", middlewares.JWTProtected(), handlers.RevokeMySessionHandler)
+	api.Post("/auth/change-password", middlewares.JWTProtected(), handlers.ChangePasswordHandler)
+	api.Get("/auth/login-history", middlewares.JWTProtected(), handlers.GetMyLoginHistoryHandler)
 
-	api.Get("/auth/me", middlewares.JWTProtected(), func(c *fiber.Ctx) error {
-		return c.JSON(fiber.Map{
-			"user_id":  c.Locals("user_id"),
-			"username": c.Locals("username"),
-		})
-	})
+	// ورود دومرحله‌ای (TOTP)
+	api.Post("/auth/login/2fa", handlers.LoginTwoFactorHandler) // مرحله دوم ورود با challenge_token
+	api.Get("/auth/2fa", middlewares.JWTProtected(), handlers.GetTwoFactorStatusHandler)
+	api.Post("/auth/2fa/setup", middlewares.JWTProtected(), handlers.SetupTwoFactorHandler) // otpauth URI و QR
+	api.Post("/auth/2fa/enable", middlewares.JWTProtected(), handlers.EnableTwoFactorHandler)
+	api.Post("/auth/2fa/disable", middlewares.JWTProtected(), handlers.DisableTwoFactorHandler)
+	api.Post("/auth/2fa/recovery-codes", middlewares.JWTProtected(), handlers.RegenerateRecoveryCodesHandler)
+
+	api.Get("/auth/me", middlewares.JWTProtected(), handlers.GetMeHandler) // نقش و سطح دسترسی کاربر جاری
 
 	api.Get("/verify-token", services.VerifyToken)
 
+	// ---------------- Users ----------------
+	users := api.Group("/users", middlewares.JWTProtected(), middlewares.Authorize(models.ResourceUsers))
+	users.Get("/", handlers.GetUsersHandler)
+	users.Get("/login-history", handlers.GetLoginHistoryHandler) // ?username=&ip=&failed=true
+	users.Get("/2fa-policy", handlers.GetTwoFactorPolicyHandler)
+	users.Put("/2fa-policy", handlers.UpdateTwoFactorPolicyHandler) // نقش‌هایی که ورود دومرحله‌ای برایشان اجباری است
+	users.Post("/", handlers.CreateUserHandler)
+	users.Put("/:id", handlers.UpdateUserHandler)
+	users.Delete("/:id", handlers.DeleteUserHandler)
+	users.Get("/:id/sessions", handlers.GetUserSessionsHandler)
+	users.Delete("/:id/sessions", handlers.RevokeUserSessionsHandler) // خروج اجباری از همه دستگاه‌ها
+	users.Post("/:id/reset-password", handlers.ResetUserPasswordHandler)
+	users.Delete("/:id/2fa", handlers.ResetUserTwoFactorHandler) // غیرفعال‌سازی ورود دومرحله‌ای کاربر
+
 	// ---------------- Contacts ----------------
-	contacts := api.Group("/contacts", middlewares.JWTProtected())
+	contacts := api.Group("/contacts", middlewares.JWTProtected(), middlewares.Authorize(models.ResourceContacts))
 	contacts.Post("/", handlers.CreateContact)
 	contacts.Get("/", handlers.GetContacts)
+	contacts.Get("/duplicates", handlers.FindDuplicateContactsHandler) // ?threshold=0.85
+	contacts.Get("/merges", handlers.GetContactMergesHandler)          // سوابق ادغام
 	contacts.Get("/:id", handlers.GetContactByID)
 	contacts.Put("/:id", handlers.UpdateContact)
 	contacts.Delete("/:id", handlers.DeleteContact)
+	contacts.Post("/:id/merge", handlers.MergeContactsHandler) // ادغام مخاطب تکراری در این مخاطب
+
+	// ---------------- Shareholders ----------------
+	shareholders := api.Group("/shareholders", middlewares.JWTProtected(), middlewares.Authorize(models.ResourceShareholders))
+	shareholders.Get("/share-split", handlers.GetShareSplitHandler)                // ?date= درصد سهام مؤثر
+	shareholders.Post("/recompute-shares", handlers.RecomputeSharesHandler)        // درصد سهام از روی سرمایه
+	shareholders.Delete("/capital-events/:id", handlers.DeleteCapitalEventHandler) // حذف آورده/برداشت دستی
+	shareholders.Get("/:id/capital-events", handlers.GetCapitalEventsHandler)      // تاریخچه سرمایه
+	shareholders.Post("/:id/capital-events", handlers.CreateCapitalEventHandler)   // ثبت آورده/برداشت
+	shareholders.Get("/:id/share-history", handlers.GetShareHistoryHandler)        // تاریخچه درصد سهم
+	shareholders.Post("/:id/share-changes", handlers.CreateShareChangeHandler)     // تغییر درصد سهم با تاریخ اعمال
 
 	// ---------------- Bank Accounts ----------------
-	bank := api.Group("/bank-accounts", middlewares.JWTProtected())
+	bank := api.Group("/bank-accounts", middlewares.JWTProtected(), middlewares.Authorize(models.ResourceBanking))
 	bank.Post("/", handlers.CreateBankAccount)
 	bank.Get("/", handlers.GetBankAccounts)
 	bank.Get("/:id", handlers.GetBankAccountByID)
 	bank.Put("/:id", handlers.UpdateBankAccount)
 	bank.Delete("/:id", handlers.DeleteBankAccount)
+	bank.Get("/:id/reconciliation", handlers.GetReconciliationSummaryHandler)     // مانده دفتر در برابر صورتحساب
+	bank.Get("/:id/reconciliation/locks", handlers.GetReconciliationLocksHandler) // دوره‌های قفل‌شده
+	bank.Post("/:id/reconciliation/lock", handlers.LockReconciliationHandler)     // قفل دوره تطبیق‌شده
+
+	// ---------------- Bank Statements ----------------
+	statements := api.Group("/bank-statements", middlewares.JWTProtected(), middlewares.Authorize(models.ResourceBanking))
+	statements.Get("/mappings", handlers.GetStatementMappingsHandler)               // نگاشت ستون‌های خروجی بانک‌ها
+	statements.Post("/mappings", handlers.CreateStatementMappingHandler)            // نگاشت جدید
+	statements.Put("/mappings/:id", handlers.UpdateStatementMappingHandler)         // ویرایش نگاشت
+	statements.Delete("/mappings/:id", handlers.DeleteStatementMappingHandler)      // حذف نگاشت
+	statements.Post("/import", handlers.ImportBankStatementHandler)                 // ورود فایل CSV/XLSX
+	statements.Get("/", handlers.GetBankStatementsHandler)                          // ?bank_account_id=
+	statements.Get("/lines/:id/candidates", handlers.GetLineCandidatesHandler)      // رکوردهای قابل تطبیق
+	statements.Post("/lines/:id/confirm", handlers.ConfirmLineHandler)              // تایید تطبیق
+	statements.Post("/lines/:id/unmatch", handlers.UnmatchLineHandler)              // لغو تطبیق
+	statements.Post("/lines/:id/ignore", handlers.IgnoreLineHandler)                // نادیده گرفتن ردیف
+	statements.Post("/lines/:id/create-entry", handlers.CreateEntryFromLineHandler) // ثبت تراکنش جاافتاده
+	statements.Post("/lines/:id/charge", handlers.CreateChargeFromLineHandler)      // ثبت کارمزد/سود از ردیف
+	statements.Get("/:id", handlers.GetBankStatementByIDHandler)                    // صورتحساب و ردیف‌ها
+	statements.Delete("/:id", handlers.DeleteBankStatementHandler)                  // حذف صورتحساب
+	statements.Post("/:id/auto-match", handlers.AutoMatchStatementHandler)          // تطبیق خودکار دوباره
+	statements.Post("/:id/detect-charges", handlers.DetectStatementChargesHandler)  // ثبت خودکار کارمزد و سود
+
+	// ---------------- Bank Charges ----------------
+	charges := api.Group("/bank-charges", middlewares.JWTProtected(), middlewares.Authorize(models.ResourceBanking))
+	charges.Get("/rules", handlers.GetBankChargeRulesHandler)          // قواعد کارمزد ماهانه
+	charges.Post("/rules", handlers.CreateBankChargeRuleHandler)       // قاعده جدید
+	charges.Post("/rules/run", handlers.RunBankChargeRulesHandler)     // ثبت فوری موارد سررسیدشده
+	charges.Put("/rules/:id", handlers.UpdateBankChargeRuleHandler)    // ویرایش قاعده
+	charges.Delete("/rules/:id", handlers.DeleteBankChargeRuleHandler) // حذف قاعده
+	charges.Get("/", handlers.GetBankChargesHandler)                   // ?bank_account_id=&kind=&from=&to=
+	charges.Post("/", handlers.CreateBankChargeHandler)                // ثبت کارمزد یا سود
+	charges.Get("/:id", handlers.GetBankChargeByIDHandler)
+	charges.Put("/:id", handlers.UpdateBankChargeHandler)
+	charges.Delete("/:id", handlers.DeleteBankChargeHandler)
 
 	// ---------------- Cash Holders ----------------
-	cash := api.Group("/cash-holders", middlewares.JWTProtected())
+	cash := api.Group("/cash-holders", middlewares.JWTProtected(), middlewares.Authorize(models.ResourceCashHolders))
 	cash.Post("/", handlers.CreateCashHolder)
 	cash.Get("/", handlers.GetCashHolders)
 	cash.Get("/:id", handlers.GetCashHolderByID)
 	cash.Put("/:id", handlers.UpdateCashHolder)
 	cash.Delete("/:id", handlers.DeleteCashHolder)
 
+	// ---------------- Revaluations (FX / gold) ----------------
+	revaluations := api.Group("/revaluations", middlewares.JWTProtected(), middlewares.Authorize(models.ResourceBanking))
+	revaluations.Get("/", handlers.GetRevaluationsHandler)         // ?from=&to=
+	revaluations.Post("/", handlers.CreateRevaluationHandler)      // تسعیر پایان دوره {date}
+	revaluations.Delete("/:id", handlers.DeleteRevaluationHandler) // فقط آخرین تسعیر هر حساب
+
+	// ---------------- Cash Counts & Handovers ----------------
+	counts := api.Group("/cash-counts", middlewares.JWTProtected(), middlewares.Authorize(models.ResourceCashCounts))
+	counts.Get("/denominations", handlers.GetDenominationsHandler) // ?unit=rial|toman
+	counts.Get("/", handlers.GetCashCountsHandler)                 // ?cash_holder_id=
+	counts.Post("/", handlers.CreateCashCountHandler)              // شروع شمارش
+	counts.Get("/:id", handlers.GetCashCountByIDHandler)
+	counts.Put("/:id", handlers.UpdateCashCountHandler)
+	counts.Delete("/:id", handlers.DeleteCashCountHandler)
+	counts.Post("/:id/post", handlers.PostCashCountHandler) // نهایی‌سازی و ثبت اضافه/کسری
+
+	handovers := api.Group("/cash-handovers", middlewares.JWTProtected(), middlewares.Authorize(models.ResourceCashCounts))
+	handovers.Get("/", handlers.GetCashHandoversHandler)    // ?cash_holder_id=
+	handovers.Post("/", handlers.CreateCashHandoverHandler) // تحویل صندوق
+	handovers.Get("/:id", handlers.GetCashHandoverByIDHandler)
+	handovers.Get("/:id/verify", handlers.VerifyCashHandoverHandler) // بررسی امضا
+
 	// ---------------- Products / Services ----------------
-	products := api.Group("/products", middlewares.JWTProtected())
+	products := api.Group("/products", middlewares.JWTProtected(), middlewares.Authorize(models.ResourceProducts))
 	products.Post("/", handlers.CreateProductService)
 	products.Get("/all", handlers.GetProductServices)
 	products.Get("/products", handlers.GetProductsHandler)
 	products.Get("/services", handlers.GetServicesHandler)
+	products.Get("/price-changes", handlers.GetProductPriceChangesHandler)              // ?status=pending&product_id= پیش‌نمایش قیمت‌های پیشنهادی
+	products.Post("/price-changes/approve", handlers.ApproveProductPriceChangesHandler) // {ids} خالی = همه
+	products.Post("/price-changes/reject", handlers.RejectProductPriceChangesHandler)
+	products.Get("/:id/price-history", handlers.GetProductPriceHistoryHandler)
 	products.Get("/:id", handlers.GetProductServiceByID)
 	products.Put("/:id", handlers.UpdateProductService)
 	products.Delete("/:id", handlers.DeleteProductService)
 
 	// ---------------- Categories ----------------
-	categories := api.Group("/categories", middlewares.JWTProtected())
+	categories := api.Group("/categories", middlewares.JWTProtected(), middlewares.Authorize(models.ResourceProducts))
 	categories.Post("/", handlers.CreateCategoryHandler)
 	categories.Get("/", handlers.GetCategoriesHandler)
 	categories.Get("/:id", handlers.GetCategoryByIDHandler)
@@ -65,7 +164,7 @@ func Register(app *fiber.App) {
 	categories.Delete("/:id", handlers.DeleteCategoryHandler)
 
 	// ---------------- Transactions ----------------
-	transactions := api.Group("/transactions", middlewares.JWTProtected())
+	transactions := api.Group("/transactions", middlewares.JWTProtected(), middlewares.Authorize(models.ResourceTransactions))
 	transactions.Post("/", handlers.CreateTransaction)                     // Create
 	transactions.Get("/", handlers.GetTransactions)                        // Read all
 	transactions.Get("/upcoming-sub", handlers.GetUpcomingSubTransactions) // Read unpaid subtransactions
@@ -74,8 +173,51 @@ func Register(app *fiber.App) {
 	transactions.Delete("/:id", handlers.DeleteTransaction)                // Delete
 	transactions.Post("/sub/:id/pay", handlers.PaySubTransaction)          // Mark sub-transaction as paid
 
+	// ---------------- Invoices & Receipts ----------------
+	invoices := api.Group("/invoices", middlewares.JWTProtected(), middlewares.Authorize(models.ResourceTransactions))
+	invoices.Get("/", handlers.GetInvoicesHandler)          // ?kind=&contact_id=&transaction_id=&fiscal_year=
+	invoices.Post("/", handlers.CreateInvoiceHandler)       // صدور فاکتور فروش/خرید یا رسید
+	invoices.Get("/:id", handlers.GetInvoiceByIDHandler)    // ?format=pdf نسخه چاپی
+	invoices.Post("/:id/void", handlers.VoidInvoiceHandler) // ابطال (شماره آزاد نمی‌شود)
+
+	// ---------------- Quotes / Estimates ----------------
+	quotes := api.Group("/quotes", middlewares.JWTProtected(), middlewares.Authorize(models.ResourceQuotes))
+	quotes.Get("/", handlers.GetQuotesHandler)                 // ?status=&contact_id=
+	quotes.Post("/", handlers.CreateQuoteHandler)              // پیش‌فاکتور جدید (پیش‌نویس)
+	quotes.Get("/:id", handlers.GetQuoteByIDHandler)           // ?format=pdf نسخه چاپی
+	quotes.Put("/:id", handlers.UpdateQuoteHandler)            // فقط پیش‌نویس یا ارسال‌شده
+	quotes.Delete("/:id", handlers.DeleteQuoteHandler)         // فقط پیش‌نویس
+	quotes.Post("/:id/status", handlers.SetQuoteStatusHandler) // sent، accepted یا rejected
+	quotes.Post("/:id/convert", handlers.ConvertQuoteHandler)  // تبدیل به تراکنش، فاکتور فروش یا دستور کار
+
+	workOrders := api.Group("/work-orders", middlewares.JWTProtected(), middlewares.Authorize(models.ResourceQuotes))
+	workOrders.Get("/", handlers.GetWorkOrdersHandler)                 // ?status=&contact_id=
+	workOrders.Get("/:id", handlers.GetWorkOrderByIDHandler)           // با اقلام پیش‌فاکتور
+	workOrders.Post("/:id/status", handlers.SetWorkOrderStatusHandler) // done یا cancelled
+
+	// ---------------- Purchasing ----------------
+	purchaseOrders := api.Group("/purchase-orders", middlewares.JWTProtected(), middlewares.Authorize(models.ResourcePurchasing))
+	purchaseOrders.Get("/", handlers.GetPurchaseOrdersHandler)                 // ?status=&contact_id=
+	purchaseOrders.Post("/", handlers.CreatePurchaseOrderHandler)              // سفارش خرید جدید (پیش‌نویس)
+	purchaseOrders.Get("/:id", handlers.GetPurchaseOrderByIDHandler)           // همراه رسیدها و صورتحساب‌ها
+	purchaseOrders.Put("/:id", handlers.UpdatePurchaseOrderHandler)            // فقط پیش‌نویس
+	purchaseOrders.Delete("/:id", handlers.DeletePurchaseOrderHandler)         // فقط پیش‌نویس
+	purchaseOrders.Post("/:id/status", handlers.SetPurchaseOrderStatusHandler) // ordered، cancelled یا closed
+	purchaseOrders.Post("/:id/receipts", handlers.ReceiveGoodsHandler)         // رسید انبار (افزایش موجودی)
+	purchaseOrders.Post("/:id/bills", handlers.CreateVendorBillHandler)        // صورتحساب فروشنده و تطبیق سه‌طرفه
+
+	goodsReceipts := api.Group("/goods-receipts", middlewares.JWTProtected(), middlewares.Authorize(models.ResourcePurchasing))
+	goodsReceipts.Delete("/:id", handlers.DeleteGoodsReceiptHandler) // برگشت رسید و موجودی
+
+	vendorBills := api.Group("/vendor-bills", middlewares.JWTProtected(), middlewares.Authorize(models.ResourcePurchasing))
+	vendorBills.Get("/", handlers.GetVendorBillsHandler) // ?contact_id=&purchase_order_id=&unposted=true
+	vendorBills.Get("/:id", handlers.GetVendorBillByIDHandler)
+	vendorBills.Delete("/:id", handlers.DeleteVendorBillHandler)      // فقط ثبت‌نشده
+	vendorBills.Post("/:id/match", handlers.RematchVendorBillHandler) // تطبیق دوباره
+	vendorBills.Post("/:id/post", handlers.PostVendorBillHandler)     // ثبت تراکنش هزینه
+
 	// ---------------- Deposits ----------------
-	deposits := api.Group("/deposits", middlewares.JWTProtected())
+	deposits := api.Group("/deposits", middlewares.JWTProtected(), middlewares.Authorize(models.ResourceTransactions))
 	deposits.Post("/", handlers.CreateDepositHandler)      // ایجاد ودیعه
 	deposits.Put("/:id", handlers.UpdateDepositHandler)    // بروزرسانی ودیعه
 	deposits.Delete("/:id", handlers.DeleteDepositHandler) // حذف ودیعه
@@ -83,14 +225,35 @@ func Register(app *fiber.App) {
 	deposits.Get("/", handlers.GetDepositsHandler)         // لیست کامل ودیعه‌ها
 	deposits.Get("/:id", handlers.GetDepositByIDHandler)   // مشاهده تک ودیعه
 
-	reports := api.Group("/reports", middlewares.JWTProtected())
+	// ---------------- Profit Distribution ----------------
+	distributions := api.Group("/distributions", middlewares.JWTProtected(), middlewares.Authorize(models.ResourceShareholders))
+	distributions.Post("/", handlers.CreateProfitDistributionHandler)      // اجرای تقسیم سود یک دوره
+	distributions.Get("/", handlers.GetProfitDistributionsHandler)         // لیست تقسیم سودها
+	distributions.Get("/:id", handlers.GetProfitDistributionByIDHandler)   // جزئیات تقسیم سود
+	distributions.Delete("/:id", handlers.DeleteProfitDistributionHandler) // حذف و برگشت پرداخت‌ها
+	distributions.Post("/dividends/:id/pay", handlers.PayDividendHandler)  // پرداخت سود یک سهامدار
+
+	reports := api.Group("/reports", middlewares.JWTProtected(), middlewares.Authorize(models.ResourceReports))
 	reports.Get("/income-expense", handlers.GetIncomeExpenseReportHandler) // ?period=daily|weekly|monthly
 	reports.Get("/latest", handlers.GetLatestTransactionsHandler)
 	reports.Get("/total-balance", handlers.GetTotalBalanceHandler)
 	reports.Get("/balance-sheet", handlers.GetBalanceSheetHandler)
 	reports.Get("/summery", handlers.GetDashboardSummaryHandler)
+	reports.Get("/equity-statement", handlers.GetEquityStatementHandler)        // ?from=&to=
+	reports.Get("/cash-flow", handlers.GetCashFlowReportHandler)                // ?from=&to=
+	reports.Get("/profit-loss", handlers.GetProfitLossHandler)                  // ?from=&to=&compare=previous,last_year
+	reports.Get("/quote-conversion", handlers.GetQuoteConversionReportHandler)  // ?from=&to=
+	reports.Get("/open-purchase-orders", handlers.GetOpenPurchaseOrdersHandler) // ?vendor_id=
 
-	price := api.Group("/price", middlewares.JWTProtected())
+	price := api.Group("/price", middlewares.JWTProtected(), middlewares.Authorize(models.ResourcePrices))
 	price.Get("/", handlers.GetPrices)
+	price.Post("/", handlers.CreateManualPrice)     // ثبت دستی نرخ با برچسب منبع
+	price.Get("/history", handlers.GetPriceHistory) // ?item=usd&from=&to=&interval=day|week|month
+	price.Get("/convert", handlers.ConvertPrice)    // ?amount=&from=usd&to=irr&date=
+
+	notifications := api.Group("/notifications", middlewares.JWTProtected(), middlewares.Authorize(models.ResourceNotifications))
+	notifications.Get("/", handlers.GetNotificationsHandler) // ?unread=true&limit=
+	notifications.Post("/read-all", handlers.MarkAllNotificationsReadHandler)
+	notifications.Post("/:id/read", handlers.MarkNotificationReadHandler)
 
 }
@@ -1,32 +1,128 @@
 package services
 
 import (
+	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/amirqodi/hgm/internal/config"
 	"github.com/amirqodi/hgm/internal/models"
 	"github.com/amirqodi/hgm/internal/repositories"
 	"github.com/amirqodi/hgm/internal/utils"
 	"github.com/gofiber/fiber/v2"
 )
 
-// Authenticate بررسی نام کاربری و رمز عبور و بازگرداندن خطاها با کلید مشخص
-func Authenticate(username, password string) (*models.User, map[string][]string) {
+// LoginClient مشخصات درخواست ورود برای ثبت در سابقه ورود
+type LoginClient struct {
+	IP        string
+	UserAgent string
+}
+
+// Authenticate بررسی نام کاربری و رمز عبور و بازگرداندن خطاها با کلید مشخص؛ نتیجه در سابقه ورود ثبت می‌شود
+func Authenticate(username, password string, client LoginClient) (*models.User, map[string][]string) {
 	errorsMap := make(map[string][]string)
 
 	user, err := repositories.FindByUsername(username)
 	if err != nil {
+		recordLoginAttempt(username, nil, client, models.LoginUnknownUser)
 		errorsMap["username"] = append(errorsMap["username"], "نام کاربری یافت نشد")
 		return nil, errorsMap
 	}
 
 	if !utils.CheckPasswordHash(password, user.Password) {
+		recordLoginAttempt(username, &user.ID, client, models.LoginBadPassword)
 		errorsMap["password"] = append(errorsMap["password"], "رمز عبور اشتباه است")
 		return nil, errorsMap
 	}
 
+	if !user.Active {
+		recordLoginAttempt(username, &user.ID, client, models.LoginInactive)
+		errorsMap["username"] = append(errorsMap["username"], "حساب کاربری غیرفعال است")
+		return nil, errorsMap
+	}
+
+	// با ورود دومرحله‌ای، ورود موفق پس از کد درست ثبت می‌شود
+	result := models.LoginSuccess
+	if user.TwoFactorEnabled {
+		result = models.LoginPasswordOK
+	}
+	recordLoginAttempt(username, &user.ID, client, result)
 	return user, nil
 }
 
+func recordLoginAttempt(username string, userID *uint, client LoginClient, result models.LoginResult) {
+	attempt := models.LoginAttempt{
+		Username:  username,
+		UserID:    userID,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		Result:    result,
+	}
+	if err := repositories.CreateLoginAttempt(&attempt); err != nil {
+		fmt.Println("Failed to save login attempt:", err)
+	}
+}
+
+// LoginLimits آستانه قفل موقت ورود
+type LoginLimits struct {
+	MaxAttempts   int           // تلاش ناموفق مجاز برای هر نام کاربری (LOGIN_MAX_ATTEMPTS، پیش‌فرض ۵)
+	IPMaxAttempts int           // تلاش ناموفق مجاز از هر IP (LOGIN_IP_MAX_ATTEMPTS، پیش‌فرض ۲۰)
+	Lockout       time.Duration // مدت قفل پس از آخرین تلاش ناموفق (LOGIN_LOCKOUT، پیش‌فرض ۱۵ دقیقه)
+}
+
+func LoginLimitsFromEnv() LoginLimits {
+	limits := LoginLimits{MaxAttempts: 5, IPMaxAttempts: 20, Lockout: 15 * time.Minute}
+	if n, err := strconv.Atoi(config.Get("LOGIN_MAX_ATTEMPTS", "")); err == nil && n > 0 {
+		limits.MaxAttempts = n
+	}
+	if n, err := strconv.Atoi(config.Get("LOGIN_IP_MAX_ATTEMPTS", "")); err == nil && n > 0 {
+		limits.IPMaxAttempts = n
+	}
+	if d, err := time.ParseDuration(config.Get("LOGIN_LOCKOUT", "")); err == nil && d > 0 {
+		limits.Lockout = d
+	}
+	return limits
+}
+
+// CheckLoginLockout مدت باقی‌مانده قفل موقت نام کاربری یا IP (صفر = آزاد). تلاش‌های ناموفق در
+// بازه Lockout شمرده می‌شوند؛ ورود موفق شمارش نام کاربری را صفر می‌کند. تلاش در زمان قفل ثبت می‌شود.
+func CheckLoginLockout(username string, client LoginClient, limits LoginLimits) (time.Duration, error) {
+	now := time.Now()
+	since := now.Add(-limits.Lockout)
+	if last, err := repositories.LastSuccessfulLogin(username); err != nil {
+		return 0, err
+	} else if last.After(since) {
+		since = last
+	}
+
+	var wait time.Duration
+	checks := []struct {
+		column, value string
+		since         time.Time
+		max           int
+	}{
+		{"username", username, since, limits.MaxAttempts},
+		{"ip", client.IP, now.Add(-limits.Lockout), limits.IPMaxAttempts},
+	}
+	for _, check := range checks {
+		count, last, err := repositories.FailedLogins(check.column, check.value, check.since)
+		if err != nil {
+			return 0, err
+		}
+		if count >= int64(check.max) {
+			if remaining := last.Add(limits.Lockout).Sub(now); remaining > wait {
+				wait = remaining
+			}
+		}
+	}
+
+	if wait > 0 {
+		recordLoginAttempt(username, nil, client, models.LoginLocked)
+	}
+	return wait, nil
+}
+
 func VerifyToken(c *fiber.Ctx) error {
 	// گرفتن هدر Authorization
 	authHeader := c.Get("Authorization")
@@ -53,6 +149,15 @@ func VerifyToken(c *fiber.Ctx) error {
 		})
 	}
 
+	// نشست توکن نباید باطل شده باشد
+	userID, _ := (*claims)["user_id"].(float64)
+	sessionID, ok := (*claims)["sid"].(float64)
+	if !ok || repositories.ValidateSession(uint(sessionID), uint(userID)) != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"error": "invalid or expired token",
+		})
+	}
+
 	// موفقیت → برگردوندن اطلاعات کاربر
 	return c.JSON(fiber.Map{
 		"valid":    true,
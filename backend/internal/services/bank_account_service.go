@@ -0,0 +1,97 @@
+package services
+
+import (
+	"github.com/amirqodi/hgm/internal/models"
+	"github.com/amirqodi/hgm/internal/repositories"
+	"github.com/amirqodi/hgm/internal/utils"
+)
+
+// ValidateBankAccount شبا، شماره کارت و شماره حساب را یکسان‌سازی و اعتبارسنجی می‌کند و
+// در صورت خالی بودن نام بانک، آن را از روی شبا یا کارت تشخیص می‌دهد.
+// existing برای بروزرسانی است (nil برای ایجاد)؛ فیلدهای تغییرنکرده دوباره بررسی نمی‌شوند
+// تا حساب‌های قدیمی با مقادیر خالی یا نامعتبر همچنان قابل ویرایش باشند.
+// ناهمخوانی شماره حساب با شبا فقط هشدار است و در account.Warnings برمی‌گردد.
+func ValidateBankAccount(account *models.BankAccount, existing *models.BankAccount) map[string][]string {
+	errorsMap := make(map[string][]string)
+
+	account.IBAN = utils.NormalizeSheba(account.IBAN)
+	account.CardNumber = utils.NormalizeCardNumber(account.CardNumber)
+	account.AccountNumber = utils.NormalizeAccountNumber(account.AccountNumber)
+
+	var excludeID uint
+	ibanChanged, cardChanged, accountChanged, nameChanged := true, true, true, true
+	if existing != nil {
+		excludeID = existing.ID
+		ibanChanged = account.IBAN != utils.NormalizeSheba(existing.IBAN)
+		cardChanged = account.CardNumber != utils.NormalizeCardNumber(existing.CardNumber)
+		accountChanged = account.AccountNumber != utils.NormalizeAccountNumber(existing.AccountNumber)
+		nameChanged = account.BankName != existing.BankName
+	}
+
+	// --- شبا ---
+	shebaBank := ""
+	if utils.IsValidSheba(account.IBAN) {
+		shebaBank = utils.ShebaBankName(account.IBAN)
+	}
+	if ibanChanged {
+		if account.IBAN == "" {
+			errorsMap["iban"] = append(errorsMap["iban"], "شماره شبا الزامی است")
+		} else if !utils.IsValidSheba(account.IBAN) {
+			errorsMap["iban"] = append(errorsMap["iban"], "شماره شبا نامعتبر است")
+		} else if repositories.BankAccountFieldTaken("iban", account.IBAN, excludeID) {
+			errorsMap["iban"] = append(errorsMap["iban"], "این شماره شبا قبلاً ثبت شده است")
+		}
+	}
+
+	// --- کارت ---
+	cardBank := ""
+	if utils.IsValidCardNumber(account.CardNumber) {
+		cardBank = utils.CardBankName(account.CardNumber)
+	}
+	if cardChanged {
+		if account.CardNumber == "" {
+			errorsMap["card_number"] = append(errorsMap["card_number"], "شماره کارت الزامی است")
+		} else if !utils.IsValidCardNumber(account.CardNumber) {
+			errorsMap["card_number"] = append(errorsMap["card_number"], "شماره کارت نامعتبر است")
+		} else if repositories.BankAccountFieldTaken("card_number", account.CardNumber, excludeID) {
+			errorsMap["card_number"] = append(errorsMap["card_number"], "این شماره کارت قبلاً ثبت شده است")
+		}
+	}
+	if (ibanChanged || cardChanged) && shebaBank != "" && cardBank != "" && shebaBank != cardBank {
+		errorsMap["card_number"] = append(errorsMap["card_number"],
+			"کارت متعلق به بانک "+cardBank+" است ولی شبا متعلق به بانک "+shebaBank+" است")
+	}
+
+	// --- شماره حساب ---
+	if accountChanged {
+		if account.AccountNumber == "" {
+			errorsMap["account_number"] = append(errorsMap["account_number"], "شماره حساب الزامی است")
+		} else if utils.OnlyDigits(account.AccountNumber) == "" {
+			errorsMap["account_number"] = append(errorsMap["account_number"], "شماره حساب نامعتبر است")
+		} else if repositories.BankAccountFieldTaken("account_number", account.AccountNumber, excludeID) {
+			errorsMap["account_number"] = append(errorsMap["account_number"], "این شماره حساب قبلاً ثبت شده است")
+		}
+	}
+	// برخی بانک‌ها شماره حساب را به شکل دیگری در شبا می‌آورند؛ پس فقط هشدار
+	if (ibanChanged || accountChanged) && shebaBank != "" && utils.OnlyDigits(account.AccountNumber) != "" &&
+		!utils.AccountMatchesSheba(account.AccountNumber, account.IBAN) {
+		account.Warnings = append(account.Warnings, "شماره حساب با شماره شبا همخوانی ندارد")
+	}
+
+	// --- نام بانک ---
+	if account.BankName == "" {
+		switch {
+		case shebaBank != "":
+			account.BankName = shebaBank
+		case cardBank != "":
+			account.BankName = cardBank
+		case nameChanged:
+			errorsMap["bank_name"] = append(errorsMap["bank_name"], "نام بانک الزامی است")
+		}
+	}
+
+	if len(errorsMap) == 0 {
+		return nil
+	}
+	return errorsMap
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/repositories"
+	"gorm.io/gorm"
+)
+
+// BankChargeScheduler ثبت دوره‌ای کارمزدها و سودهای ماهانه سررسیدشده
+type BankChargeScheduler struct {
+	DB       *gorm.DB
+	Clock    Clock
+	Interval time.Duration
+}
+
+// NewBankChargeScheduler زمان‌بند با بازه پیش‌فرض یک ساعت
+func NewBankChargeScheduler(db *gorm.DB, clock Clock) *BankChargeScheduler {
+	if clock == nil {
+		clock = realClock{}
+	}
+	return &BankChargeScheduler{DB: db, Clock: clock, Interval: time.Hour}
+}
+
+// PostDue یک بار ثبت موارد سررسیدشده تا زمان ساعت زمان‌بند
+func (s *BankChargeScheduler) PostDue() int {
+	posted, errs := repositories.PostDueBankCharges(s.Clock.Now(), s.DB)
+	for _, err := range errs {
+		fmt.Println("Failed to post bank charge:", err)
+	}
+	if posted > 0 {
+		fmt.Println("Recurring bank charges posted:", posted)
+	}
+	return posted
+}
+
+// Run حلقه زمان‌بند تا لغو ctx؛ ابتدا Interval صبر می‌کند
+func (s *BankChargeScheduler) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.Clock.After(s.Interval):
+		}
+		s.PostDue()
+	}
+}
+
+// StartBankChargeScheduler اجرا در شروع سرور و سپس هر ساعت در پس‌زمینه
+func StartBankChargeScheduler(db *gorm.DB) {
+	s := NewBankChargeScheduler(db, realClock{})
+	s.PostDue()
+	go s.Run(context.Background())
+}
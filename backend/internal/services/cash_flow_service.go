@@ -0,0 +1,299 @@
+package services
+
+import (
+	"math"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/models"
+	"gorm.io/gorm"
+)
+
+type CashFlowActivity string
+
+const (
+	ActivityOperating CashFlowActivity = "operating" // دریافت از مشتریان، پرداخت به فروشندگان و هزینه‌ها
+	ActivityInvesting CashFlowActivity = "investing" // ودیعه‌ها
+	ActivityFinancing CashFlowActivity = "financing" // آورده، برداشت و سود سهامداران
+	ActivityExchange  CashFlowActivity = "exchange"  // تسعیر حساب‌های ارزی و طلا (جابجایی پول نیست)
+)
+
+type CashFlowLine struct {
+	Label   string  `json:"label"`
+	Inflow  float64 `json:"inflow"`
+	Outflow float64 `json:"outflow"`
+	Net     float64 `json:"net"`
+}
+
+type CashFlowSection struct {
+	Lines   []CashFlowLine `json:"lines"`
+	Inflow  float64        `json:"inflow"`
+	Outflow float64        `json:"outflow"`
+	Net     float64        `json:"net"`
+}
+
+type CashFlowStatement struct {
+	From        time.Time       `json:"from"`
+	To          time.Time       `json:"to"`
+	OpeningCash float64         `json:"opening_cash"`
+	Operating   CashFlowSection `json:"operating"`
+	Investing   CashFlowSection `json:"investing"`
+	Financing   CashFlowSection `json:"financing"`
+	// اثر تغییر نرخ بر موجودی حساب‌های ارزی و طلا (تسعیرهای دوره)
+	ExchangeEffect float64 `json:"exchange_effect"`
+	NetChange      float64 `json:"net_change"`
+	ClosingCash    float64 `json:"closing_cash"`
+
+	// موجودی فعلی (GetTotalBalance)؛ اگر to امروز یا بعد از آن باشد با ClosingCash برابر است
+	CurrentBalance TotalBalance `json:"current_balance"`
+}
+
+// cashFlow یک جابجایی واقعی پول روی حساب بانکی یا تنخواه؛ Amount مثبت = ورود وجه
+type cashFlow struct {
+	Date     time.Time
+	Activity CashFlowActivity
+	Label    string
+	Amount   float64
+}
+
+// GetCashFlowStatement صورت جریان وجوه نقد بازه [from, to).
+// مانده ابتدای دوره از موجودی فعلی منهای جریان‌های بعد از from به دست می‌آید، پس موجودی اولیه
+// حساب‌هایی که بعداً تعریف شده‌اند هم جزو مانده ابتدای دوره حساب می‌شود.
+func GetCashFlowStatement(db *gorm.DB, from, to time.Time) (*CashFlowStatement, error) {
+	flows, err := cashFlowsSince(db, from)
+	if err != nil {
+		return nil, err
+	}
+	current, err := GetTotalBalance(db)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CashFlowStatement{From: from, To: to, CurrentBalance: current}
+	sections := map[CashFlowActivity]*CashFlowSection{
+		ActivityOperating: &result.Operating,
+		ActivityInvesting: &result.Investing,
+		ActivityFinancing: &result.Financing,
+	}
+
+	var sinceFrom float64
+	for _, f := range flows {
+		sinceFrom += f.Amount
+		if !f.Date.Before(to) {
+			continue
+		}
+		if f.Activity == ActivityExchange {
+			result.ExchangeEffect += f.Amount
+		} else {
+			addCashFlow(sections[f.Activity], f)
+		}
+		result.NetChange += f.Amount
+	}
+
+	result.OpeningCash = roundMoney(current.Total - sinceFrom)
+	result.ExchangeEffect = roundMoney(result.ExchangeEffect)
+	result.NetChange = roundMoney(result.NetChange)
+	result.ClosingCash = roundMoney(result.OpeningCash + result.NetChange)
+	for _, section := range sections {
+		if section.Lines == nil {
+			section.Lines = []CashFlowLine{}
+		}
+	}
+	return result, nil
+}
+
+func addCashFlow(section *CashFlowSection, f cashFlow) {
+	idx := -1
+	for i := range section.Lines {
+		if section.Lines[i].Label == f.Label {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		section.Lines = append(section.Lines, CashFlowLine{Label: f.Label})
+		idx = len(section.Lines) - 1
+	}
+
+	line := &section.Lines[idx]
+	if f.Amount >= 0 {
+		line.Inflow += f.Amount
+		section.Inflow += f.Amount
+	} else {
+		line.Outflow -= f.Amount
+		section.Outflow -= f.Amount
+	}
+	line.Net += f.Amount
+	section.Net += f.Amount
+}
+
+// cashFlowsSince همه جابجایی‌های پول از تاریخ from تا امروز
+func cashFlowsSince(db *gorm.DB, from time.Time) ([]cashFlow, error) {
+	var flows []cashFlow
+	hasSource := "((money_source_type = 'bank' AND bank_account_id IS NOT NULL) OR (money_source_type = 'cash' AND cash_holder_id IS NOT NULL))"
+
+	// تراکنش‌های مرتبط با سرمایه سهامداران جزو فعالیت‌های تامین مالی هستند
+	capitalTrx := map[uint]bool{}
+	var capitalIDs []uint
+	if err := db.Model(&models.CapitalEvent{}).Where("transaction_id IS NOT NULL").
+		Pluck("transaction_id", &capitalIDs).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range capitalIDs {
+		capitalTrx[id] = true
+	}
+	classify := func(trxID uint, trxType string, amount float64) cashFlow {
+		sign := 1.0
+		if trxType == "expense" || trxType == "share_reduction" {
+			sign = -1
+		}
+		f := cashFlow{Activity: ActivityOperating, Amount: sign * amount}
+		switch {
+		case capitalTrx[trxID] || trxType == "share" || trxType == "share_reduction":
+			f.Activity = ActivityFinancing
+			f.Label = "آورده سهامداران"
+			if sign < 0 {
+				f.Label = "برداشت سهامداران"
+			}
+		case sign > 0:
+			f.Label = "دریافت از مشتریان"
+		default:
+			f.Label = "پرداخت به فروشندگان و هزینه‌ها"
+		}
+		return f
+	}
+
+	// --- تراکنش‌های نقدی (بدون قسط) ---
+	var trxs []models.Transaction
+	if err := db.Where(hasSource+" AND is_paid = ?", true).
+		Where("id NOT IN (?)", db.Model(&models.SubTransaction{}).Select("transaction_id")).
+		Where("COALESCE(transaction_date, created_at) >= ?", from).
+		Find(&trxs).Error; err != nil {
+		return nil, err
+	}
+	for _, trx := range trxs {
+		f := classify(trx.ID, trx.TransactionType, trx.Amount)
+		f.Date = trx.CreatedAt
+		if trx.TransactionDate != nil {
+			f.Date = *trx.TransactionDate
+		}
+		flows = append(flows, f)
+	}
+
+	// --- اقساط پرداخت‌شده ---
+	var subs []struct {
+		TransactionID   uint
+		Amount          float64
+		PaidAt          *time.Time
+		TransactionType string
+		TransactionDate *time.Time
+		CreatedAt       time.Time
+	}
+	if err := db.Model(&models.SubTransaction{}).
+		Select("sub_transactions.transaction_id, sub_transactions.amount, sub_transactions.paid_at, "+
+			"transactions.transaction_type, transactions.transaction_date, transactions.created_at").
+		Joins("JOIN transactions ON transactions.id = sub_transactions.transaction_id").
+		Where("sub_transactions.is_paid = ?", true).
+		Where("((transactions.money_source_type = 'bank' AND transactions.bank_account_id IS NOT NULL) OR " +
+			"(transactions.money_source_type = 'cash' AND transactions.cash_holder_id IS NOT NULL))").
+		Scan(&subs).Error; err != nil {
+		return nil, err
+	}
+	for _, sub := range subs {
+		// اقساط قدیمی بدون زمان پرداخت با تاریخ تراکنش در نظر گرفته می‌شوند
+		date := sub.CreatedAt
+		if sub.PaidAt != nil {
+			date = *sub.PaidAt
+		} else if sub.TransactionDate != nil {
+			date = *sub.TransactionDate
+		}
+		if date.Before(from) {
+			continue
+		}
+		f := classify(sub.TransactionID, sub.TransactionType, sub.Amount)
+		f.Date = date
+		flows = append(flows, f)
+	}
+
+	// --- آورده و برداشت مستقیم سرمایه ---
+	var events []models.CapitalEvent
+	if err := db.Where(hasSource+" AND transaction_id IS NULL AND date >= ?", from).Find(&events).Error; err != nil {
+		return nil, err
+	}
+	for _, ev := range events {
+		f := cashFlow{Date: ev.Date, Activity: ActivityFinancing, Label: "آورده سهامداران", Amount: ev.Amount}
+		if ev.Type == models.CapitalWithdrawal {
+			f.Label = "برداشت سهامداران"
+			f.Amount = -ev.Amount
+		}
+		flows = append(flows, f)
+	}
+
+	// --- سود سهام پرداخت‌شده ---
+	var dividends []models.Dividend
+	if err := db.Where(hasSource+" AND status = ? AND paid_at >= ?", models.DividendPaid, from).
+		Find(&dividends).Error; err != nil {
+		return nil, err
+	}
+	for _, d := range dividends {
+		flows = append(flows, cashFlow{Date: *d.PaidAt, Activity: ActivityFinancing, Label: "پرداخت سود سهام", Amount: -d.Amount})
+	}
+
+	// --- ودیعه‌ها: ثبت و تسویه (مطابق CreateDeposit و تسویه آن) ---
+	var deposits []models.Deposit
+	if err := db.Where(hasSource).Where("created_at >= ? OR completed_at >= ?", from, from).
+		Find(&deposits).Error; err != nil {
+		return nil, err
+	}
+	for _, dep := range deposits {
+		sign, label := 1.0, "ودیعه پرداختی"
+		if dep.Type == models.DepositReceived {
+			sign, label = -1, "ودیعه دریافتی"
+		}
+		if !dep.CreatedAt.Before(from) {
+			flows = append(flows, cashFlow{Date: dep.CreatedAt, Activity: ActivityInvesting, Label: label, Amount: sign * dep.Amount})
+		}
+		if dep.CompletedAt != nil && !dep.CompletedAt.Before(from) {
+			flows = append(flows, cashFlow{Date: *dep.CompletedAt, Activity: ActivityInvesting, Label: label, Amount: -sign * dep.Amount})
+		}
+	}
+
+	// --- سود و کارمزد بانکی ---
+	var charges []models.BankCharge
+	if err := db.Where("date >= ?", from).Find(&charges).Error; err != nil {
+		return nil, err
+	}
+	for _, charge := range charges {
+		f := cashFlow{Date: charge.Date, Activity: ActivityOperating, Label: "سود سپرده بانکی", Amount: charge.Amount}
+		if charge.Kind == models.BankChargeFee {
+			f.Label = "کارمزدهای بانکی"
+			f.Amount = -charge.Amount
+		}
+		flows = append(flows, f)
+	}
+
+	// --- اضافه و کسری صندوق ---
+	var counts []models.CashCount
+	if err := db.Where("status = ? AND difference <> 0 AND posted_at >= ?", models.CashCountPosted, from).
+		Find(&counts).Error; err != nil {
+		return nil, err
+	}
+	for _, count := range counts {
+		flows = append(flows, cashFlow{Date: *count.PostedAt, Activity: ActivityOperating, Label: "اضافه و کسری صندوق", Amount: count.Difference})
+	}
+
+	// --- تسعیر حساب‌های ارزی و طلا ---
+	var revaluations []models.Revaluation
+	if err := db.Where("date >= ?", from).Find(&revaluations).Error; err != nil {
+		return nil, err
+	}
+	for _, rev := range revaluations {
+		flows = append(flows, cashFlow{Date: rev.Date, Activity: ActivityExchange, Label: "اثر تغییر نرخ ارز و طلا", Amount: rev.Gain})
+	}
+
+	// تحویل صندوق بین تنخواه‌داران جابجایی داخلی است و در جمع کل اثری ندارد
+	return flows, nil
+}
+
+func roundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"sort"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/models"
+	"gorm.io/gorm"
+)
+
+type EquityMovement struct {
+	Date        time.Time `json:"date"`
+	Kind        string    `json:"kind"` // contribution, withdrawal, distribution, share_change
+	Amount      float64   `json:"amount"`
+	Percentage  *float64  `json:"percentage,omitempty"`
+	Description string    `json:"description,omitempty"`
+}
+
+type ShareholderEquity struct {
+	ContactID       uint             `json:"contact_id"`
+	Name            string           `json:"name"`
+	SharePercentage float64          `json:"share_percentage"`
+	OpeningCapital  float64          `json:"opening_capital"`
+	Contributions   float64          `json:"contributions"`
+	Withdrawals     float64          `json:"withdrawals"`
+	Distributions   float64          `json:"distributions"`
+	DividendsPaid   float64          `json:"dividends_paid"`
+	ClosingCapital  float64          `json:"closing_capital"`
+	Movements       []EquityMovement `json:"movements"`
+}
+
+type EquityStatement struct {
+	From         time.Time           `json:"from"`
+	To           time.Time           `json:"to"`
+	Shareholders []ShareholderEquity `json:"shareholders"`
+	Totals       struct {
+		OpeningCapital float64 `json:"opening_capital"`
+		Contributions  float64 `json:"contributions"`
+		Withdrawals    float64 `json:"withdrawals"`
+		Distributions  float64 `json:"distributions"`
+		DividendsPaid  float64 `json:"dividends_paid"`
+		ClosingCapital float64 `json:"closing_capital"`
+	} `json:"totals"`
+}
+
+// GetEquityStatement صورت تغییرات حقوق صاحبان سهام: آورده، برداشت و سود تقسیم‌شده هر سهامدار
+func GetEquityStatement(db *gorm.DB, from, to time.Time) (*EquityStatement, error) {
+	var shareholders []models.Contact
+	if err := db.Preload("Shareholder").
+		Where("EXISTS (SELECT 1 FROM shareholder_profiles WHERE shareholder_profiles.contact_id = contacts.id)").
+		Order("id").Find(&shareholders).Error; err != nil {
+		return nil, err
+	}
+
+	result := &EquityStatement{From: from, To: to}
+
+	for _, sh := range shareholders {
+		row := ShareholderEquity{
+			ContactID: sh.ID,
+			Name:      sh.FirstName + " " + sh.LastName,
+		}
+		if sh.Shareholder != nil {
+			row.SharePercentage = sh.Shareholder.SharePercentage
+		}
+
+		// --- آورده و برداشت سرمایه ---
+		var events []models.CapitalEvent
+		if err := db.Where("contact_id = ? AND date < ?", sh.ID, to).
+			Order("date ASC, id ASC").
+			Find(&events).Error; err != nil {
+			return nil, err
+		}
+
+		for _, ev := range events {
+			sign := 1.0
+			if ev.Type == models.CapitalWithdrawal {
+				sign = -1
+			}
+
+			if ev.Date.Before(from) {
+				row.OpeningCapital += sign * ev.Amount
+				continue
+			}
+			if sign > 0 {
+				row.Contributions += ev.Amount
+			} else {
+				row.Withdrawals += ev.Amount
+			}
+			row.Movements = append(row.Movements, EquityMovement{
+				Date:        ev.Date,
+				Kind:        string(ev.Type),
+				Amount:      ev.Amount,
+				Description: ev.Notes,
+			})
+		}
+
+		// --- تغییرات درصد سهم ---
+		var changes []models.SharePercentageChange
+		if err := db.Where("contact_id = ? AND effective_from >= ? AND effective_from < ?", sh.ID, from, to).
+			Order("effective_from ASC").
+			Find(&changes).Error; err != nil {
+			return nil, err
+		}
+		for _, ch := range changes {
+			percentage := ch.Percentage
+			row.Movements = append(row.Movements, EquityMovement{
+				Date:        ch.EffectiveFrom,
+				Kind:        "share_change",
+				Percentage:  &percentage,
+				Description: ch.Reason,
+			})
+		}
+
+		// --- سود تقسیم‌شده ---
+		var dividends []struct {
+			Amount    float64
+			Status    models.DividendStatus
+			PeriodEnd time.Time
+		}
+		if err := db.Model(&models.Dividend{}).
+			Joins("JOIN profit_distributions ON profit_distributions.id = dividends.distribution_id").
+			Where("dividends.contact_id = ? AND profit_distributions.period_end > ? AND profit_distributions.period_end <= ?", sh.ID, from, to).
+			Select("dividends.amount, dividends.status, profit_distributions.period_end").
+			Scan(&dividends).Error; err != nil {
+			return nil, err
+		}
+		for _, d := range dividends {
+			row.Distributions += d.Amount
+			if d.Status == models.DividendPaid {
+				row.DividendsPaid += d.Amount
+			}
+			row.Movements = append(row.Movements, EquityMovement{
+				Date:   d.PeriodEnd,
+				Kind:   "distribution",
+				Amount: d.Amount,
+			})
+		}
+
+		sort.Slice(row.Movements, func(i, j int) bool {
+			return row.Movements[i].Date.Before(row.Movements[j].Date)
+		})
+
+		row.ClosingCapital = row.OpeningCapital + row.Contributions - row.Withdrawals
+
+		result.Totals.OpeningCapital += row.OpeningCapital
+		result.Totals.Contributions += row.Contributions
+		result.Totals.Withdrawals += row.Withdrawals
+		result.Totals.Distributions += row.Distributions
+		result.Totals.DividendsPaid += row.DividendsPaid
+		result.Totals.ClosingCapital += row.ClosingCapital
+
+		result.Shareholders = append(result.Shareholders, row)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/amirqodi/hgm/internal/config"
+	"github.com/amirqodi/hgm/internal/repositories"
+	"github.com/amirqodi/hgm/internal/utils"
+)
+
+var (
+	ErrWeakPassword  = errors.New("رمز عبور ضعیف است")
+	ErrWrongPassword = errors.New("رمز عبور فعلی اشتباه است")
+	ErrSamePassword  = errors.New("رمز عبور جدید باید با رمز فعلی متفاوت باشد")
+)
+
+// commonPasswords رمزهای رایج (از جمله رمز پیش‌فرض ادمین) که پذیرفته نمی‌شوند
+var commonPasswords = map[string]bool{
+	"admin123": true, "password": true, "password1": true, "12345678": true,
+	"123456789": true, "1234567890": true, "qwerty123": true, "11111111": true,
+}
+
+// PasswordMinLength حداقل طول رمز (PASSWORD_MIN_LENGTH، پیش‌فرض ۸)
+func PasswordMinLength() int {
+	n, err := strconv.Atoi(config.Get("PASSWORD_MIN_LENGTH", "8"))
+	if err != nil || n < 1 {
+		return 8
+	}
+	return n
+}
+
+// ValidatePassword سیاست رمز: حداقل طول، شامل حرف و عدد، غیر از نام کاربری و رمزهای رایج
+func ValidatePassword(password, username string) error {
+	minLength := PasswordMinLength()
+	if utf8.RuneCountInString(password) < minLength {
+		return fmt.Errorf("%w: حداقل %s نویسه لازم است", ErrWeakPassword, utils.PersianDigits(strconv.Itoa(minLength)))
+	}
+	var letter, digit bool
+	for _, r := range password {
+		letter = letter || unicode.IsLetter(r)
+		digit = digit || unicode.IsDigit(r)
+	}
+	if !letter || !digit {
+		return fmt.Errorf("%w: رمز باید هم حرف و هم عدد داشته باشد", ErrWeakPassword)
+	}
+	if strings.EqualFold(password, username) || commonPasswords[strings.ToLower(password)] {
+		return fmt.Errorf("%w: رمز نباید نام کاربری یا رمزی رایج باشد", ErrWeakPassword)
+	}
+	return nil
+}
+
+// ChangePassword تغییر رمز توسط خود کاربر؛ سایر نشست‌های او باطل می‌شوند
+func ChangePassword(userID uint, current, next string, sessionID uint) error {
+	user, err := repositories.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if !utils.CheckPasswordHash(current, user.Password) {
+		return ErrWrongPassword
+	}
+	if current == next {
+		return ErrSamePassword
+	}
+	if err := ValidatePassword(next, user.Username); err != nil {
+		return err
+	}
+	hashed, err := utils.HashPassword(next)
+	if err != nil {
+		return err
+	}
+	return repositories.SetUserPassword(user.ID, hashed, false, sessionID)
+}
+
+// ResetPassword بازنشانی رمز توسط مدیر؛ همه نشست‌های کاربر باطل می‌شود و باید رمز را در ورود بعدی عوض کند
+func ResetPassword(userID uint, password string) error {
+	user, err := repositories.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if err := ValidatePassword(password, user.Username); err != nil {
+		return err
+	}
+	hashed, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	return repositories.SetUserPassword(user.ID, hashed, true, 0)
+}
@@ -0,0 +1,209 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/config"
+	"github.com/amirqodi/hgm/internal/models"
+	"github.com/amirqodi/hgm/internal/repositories"
+	"github.com/amirqodi/hgm/internal/utils"
+	"gorm.io/gorm"
+)
+
+// PriceAlert اعلان وقتی نرخ یک ارز نسبت به نرخ ثبت‌شده قبلی بیش از Percent درصد تغییر کند
+type PriceAlert struct {
+	Currency models.Currency
+	Percent  float64
+}
+
+const defaultPriceAlerts = "usd:3"
+
+var priceItemTitles = map[models.Currency]string{
+	models.CurrencyUSD:    "دلار",
+	models.CurrencyTether: "تتر",
+	models.CurrencyGold18: "طلای ۱۸ عیار",
+	models.CurrencyCoin:   "سکه امامی",
+}
+
+// ParsePriceAlerts خواندن فهرست هشدارها به شکل currency:percent جداشده با کاما (مثل usd:3,gold18:5)
+func ParsePriceAlerts(value string) ([]PriceAlert, error) {
+	var alerts []PriceAlert
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		currency, percent, ok := strings.Cut(part, ":")
+		alert := PriceAlert{Currency: models.Currency(strings.TrimSpace(currency))}
+		var err error
+		if ok {
+			alert.Percent, err = strconv.ParseFloat(strings.TrimSpace(percent), 64)
+		}
+		if !ok || err != nil || alert.Percent <= 0 || !alert.Currency.IsForeign() || !alert.Currency.Valid() {
+			return nil, fmt.Errorf("هشدار نرخ نامعتبر است: %s", part)
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, nil
+}
+
+// PriceAlertsFromEnv هشدارهای PRICE_ALERTS (پیش‌فرض تغییر ۳ درصدی دلار؛ مقدار none یعنی بدون هشدار)
+func PriceAlertsFromEnv() []PriceAlert {
+	value := config.Get("PRICE_ALERTS", defaultPriceAlerts)
+	if value == "none" {
+		return nil
+	}
+	alerts, err := ParsePriceAlerts(value)
+	if err != nil {
+		fmt.Println("Invalid PRICE_ALERTS, using defaults:", err)
+		alerts, _ = ParsePriceAlerts(defaultPriceAlerts)
+	}
+	return alerts
+}
+
+// SavePrice ذخیره نرخ جدید، بررسی هشدارها و پیشنهاد قیمت فروش کالاهای ارزی (فقط برای ارزهایی که
+// این نرخ آخرین نرخ آن‌هاست)؛ خطای این مراحل مانع ذخیره نرخ نمی‌شود
+func SavePrice(db *gorm.DB, price *models.Price, alerts []PriceAlert) error {
+	if err := db.Create(price).Error; err != nil {
+		return err
+	}
+	if err := CheckPriceAlerts(db, price, alerts); err != nil {
+		fmt.Println("Failed to check price alerts:", err)
+	}
+	if err := proposeProductPrices(db, price); err != nil {
+		fmt.Println("Failed to propose product prices:", err)
+	}
+	return nil
+}
+
+// proposeProductPrices پیشنهاد قیمت‌های جدید و اعلان برای تأیید آن‌ها
+func proposeProductPrices(db *gorm.DB, price *models.Price) error {
+	count, err := repositories.ProposeProductPrices(price, db)
+	if err != nil || count == 0 {
+		return err
+	}
+	n := models.Notification{
+		Kind:    models.NotificationProductPrices,
+		Title:   "قیمت‌های جدید در انتظار تأیید",
+		Message: fmt.Sprintf("با نرخ جدید (منبع: %s) قیمت فروش %s کالا تغییر می‌کند؛ پیش از اعمال آن‌ها را بررسی و تأیید کنید.", price.Source, utils.PersianDigits(strconv.Itoa(count))),
+	}
+	return repositories.CreateNotification(&n, db)
+}
+
+// CheckPriceAlerts مقایسه نرخ‌های price با آخرین نرخ معتبر قبلی هر ارز و ثبت اعلان
+func CheckPriceAlerts(db *gorm.DB, price *models.Price, alerts []PriceAlert) error {
+	for _, alert := range alerts {
+		current := price.Rate(alert.Currency)
+		if current <= 0 {
+			continue
+		}
+		column, err := repositories.PriceColumn(alert.Currency)
+		if err != nil {
+			return err
+		}
+
+		var previous models.Price
+		err = db.Where(column+" > 0 AND id <> ? AND created_at <= ?", price.ID, price.CreatedAt).
+			Order("created_at DESC, id DESC").First(&previous).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		last := previous.Rate(alert.Currency)
+		change := (current - last) / last * 100
+		if math.Abs(change) < alert.Percent {
+			continue
+		}
+
+		title := priceItemTitles[alert.Currency]
+		n := models.Notification{
+			Kind:  models.NotificationPriceMove,
+			Title: fmt.Sprintf("تغییر %s٪ نرخ %s", utils.PersianDigits(fmt.Sprintf("%+.1f", change)), title),
+			Message: fmt.Sprintf("نرخ %s از %s به %s رسید (منبع: %s، نرخ قبلی در %s).",
+				title,
+				utils.GroupThousands(last, 0, ","),
+				utils.GroupThousands(current, 0, ","),
+				price.Source,
+				utils.FormatJalali(previous.CreatedAt)),
+		}
+		if err := repositories.CreateNotification(&n, db); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notifyPriceFetchFailed اعلان شکست دریافت خودکار نرخ؛ تا خوانده نشده اعلان تکراری ثبت نمی‌شود
+func notifyPriceFetchFailed(db *gorm.DB, provider string, fetchErr error) {
+	exists, err := repositories.HasUnreadNotification(models.NotificationPriceFetchFailed, db)
+	if err != nil || exists {
+		return
+	}
+	n := models.Notification{
+		Kind:    models.NotificationPriceFetchFailed,
+		Title:   "دریافت نرخ ناموفق بود",
+		Message: fmt.Sprintf("نرخ‌ها از %s دریافت نشد (%v). تا رفع مشکل نرخ‌ها را دستی وارد کنید.", provider, fetchErr),
+	}
+	if err := repositories.CreateNotification(&n, db); err != nil {
+		fmt.Println("Failed to save notification:", err)
+	}
+}
+
+var (
+	ErrNegativePrice = errors.New("نرخ نمی‌تواند منفی باشد")
+	ErrEmptyPrice    = errors.New("حداقل یک نرخ باید وارد شود")
+)
+
+// ManualPriceInput نرخ‌های ورود دستی به واحد PRICE_UNIT؛ نرخ صفر یعنی ثبت نشده
+type ManualPriceInput struct {
+	USD    float64    `json:"usd"`
+	Tether float64    `json:"tether"`
+	Gold18 float64    `json:"gold18"`
+	Coin   float64    `json:"coin"`
+	Source string     `json:"source"` // مثل نام صرافی؛ پیش‌فرض manual
+	At     *time.Time `json:"-"`      // زمان نرخ؛ خالی = اکنون
+}
+
+// SaveManualPrice ثبت نرخ دستی (مثلاً وقتی منبع خودکار در دسترس نیست) با بررسی هشدارها
+func SaveManualPrice(db *gorm.DB, input ManualPriceInput, userID *uint) (*models.Price, error) {
+	values := map[models.Currency]float64{
+		models.CurrencyUSD:    input.USD,
+		models.CurrencyTether: input.Tether,
+		models.CurrencyGold18: input.Gold18,
+		models.CurrencyCoin:   input.Coin,
+	}
+	price := models.Price{Source: strings.TrimSpace(input.Source), CreatedByID: userID, CreatedAt: time.Now()}
+	if price.Source == "" {
+		price.Source = "manual"
+	}
+	if input.At != nil {
+		price.CreatedAt = *input.At
+	}
+
+	entered := false
+	for currency, v := range values {
+		if v < 0 {
+			return nil, ErrNegativePrice
+		}
+		if v > 0 {
+			price.SetRate(currency, v)
+			entered = true
+		}
+	}
+	if !entered {
+		return nil, ErrEmptyPrice
+	}
+
+	if err := SavePrice(db, &price, PriceAlertsFromEnv()); err != nil {
+		return nil, err
+	}
+	return &price, nil
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/models"
+	"github.com/amirqodi/hgm/internal/repositories"
+	"github.com/amirqodi/hgm/internal/utils"
+	ptime "github.com/yaa110/go-persian-calendar"
+	"gorm.io/gorm"
+)
+
+const (
+	PriceIntervalDay   = "day"
+	PriceIntervalWeek  = "week"  // شنبه تا جمعه
+	PriceIntervalMonth = "month" // ماه شمسی
+)
+
+// PriceCandle خلاصه نرخ‌های ثبت‌شده در یک بازه (به واحد دفتر)
+type PriceCandle struct {
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Label   string    `json:"label"` // تاریخ شمسی شروع بازه
+	Open    float64   `json:"open"`
+	High    float64   `json:"high"`
+	Low     float64   `json:"low"`
+	Close   float64   `json:"close"`
+	Samples int       `json:"samples"`
+}
+
+type PriceHistory struct {
+	Item     models.Currency `json:"item"`
+	Unit     models.CashUnit `json:"unit"` // واحد دفتر
+	Interval string          `json:"interval"`
+	From     time.Time       `json:"from"`
+	To       time.Time       `json:"to"`
+	Candles  []PriceCandle   `json:"candles"`
+}
+
+// priceBucket شروع و پایان بازه‌ای که t در آن است
+func priceBucket(interval string, t time.Time) (time.Time, time.Time) {
+	t = t.In(time.Local)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+	switch interval {
+	case PriceIntervalWeek:
+		start := day.AddDate(0, 0, -((int(day.Weekday()) + 1) % 7))
+		return start, start.AddDate(0, 0, 7)
+	case PriceIntervalMonth:
+		pt := ptime.New(day)
+		start := ptime.Date(pt.Year(), pt.Month(), 1, 0, 0, 0, 0, time.Local).Time()
+		year, month := pt.Year(), pt.Month()+1
+		if month > ptime.Esfand {
+			year, month = year+1, ptime.Farvardin
+		}
+		return start, ptime.Date(year, month, 1, 0, 0, 0, 0, time.Local).Time()
+	default:
+		return day, day.AddDate(0, 0, 1)
+	}
+}
+
+// GetPriceHistory نرخ‌های ثبت‌شده یک ارز در بازه [from, to) به‌صورت باز/بیشینه/کمینه/بسته در هر بازه زمانی
+func GetPriceHistory(db *gorm.DB, item models.Currency, from, to time.Time, interval string) (*PriceHistory, error) {
+	switch interval {
+	case "":
+		interval = PriceIntervalDay
+	case PriceIntervalDay, PriceIntervalWeek, PriceIntervalMonth:
+	default:
+		return nil, errors.New("بازه باید day، week یا month باشد")
+	}
+	column, err := repositories.PriceColumn(item)
+	if err != nil {
+		return nil, err
+	}
+
+	var prices []models.Price
+	if err := db.Where(column+" > 0 AND created_at >= ? AND created_at < ?", from, to).
+		Order("created_at ASC, id ASC").Find(&prices).Error; err != nil {
+		return nil, err
+	}
+
+	history := &PriceHistory{Item: item, Unit: repositories.BookUnit(), Interval: interval, From: from, To: to, Candles: []PriceCandle{}}
+	var candle *PriceCandle
+	for _, p := range prices {
+		v := repositories.PriceToBookUnit(p.Rate(item))
+		if candle == nil || !p.CreatedAt.Before(candle.End) {
+			start, end := priceBucket(interval, p.CreatedAt)
+			history.Candles = append(history.Candles, PriceCandle{
+				Start: start, End: end, Label: utils.FormatJalali(start),
+				Open: v, High: v, Low: v,
+			})
+			candle = &history.Candles[len(history.Candles)-1]
+		}
+		if v > candle.High {
+			candle.High = v
+		}
+		if v < candle.Low {
+			candle.Low = v
+		}
+		candle.Close = v
+		candle.Samples++
+	}
+	return history, nil
+}
+
+// PriceConversion تبدیل مبلغ بین دو ارز با نرخ تاریخ داده‌شده (واحد دفتر = irr)
+type PriceConversion struct {
+	Amount     float64         `json:"amount"`
+	From       models.Currency `json:"from"`
+	To         models.Currency `json:"to"`
+	Date       time.Time       `json:"date"`
+	FromRate   float64         `json:"from_rate"` // هر واحد From به واحد دفتر
+	FromRateAt time.Time       `json:"from_rate_at"`
+	ToRate     float64         `json:"to_rate"`
+	ToRateAt   time.Time       `json:"to_rate_at"`
+	Result     float64         `json:"result"`
+}
+
+// ConvertPrice نزدیک‌ترین نرخ ثبت‌شده به تاریخ (قبل یا بعد از آن) برای هر دو ارز
+func ConvertPrice(db *gorm.DB, amount float64, from, to models.Currency, date time.Time) (*PriceConversion, error) {
+	if !from.Valid() || !to.Valid() {
+		return nil, repositories.ErrInvalidCurrency
+	}
+	result := &PriceConversion{Amount: amount, From: from, To: to, Date: date}
+
+	var err error
+	if result.FromRate, result.FromRateAt, err = repositories.NearestRate(db, from, date); err != nil {
+		return nil, err
+	}
+	if result.ToRate, result.ToRateAt, err = repositories.NearestRate(db, to, date); err != nil {
+		return nil, err
+	}
+	// مبلغ ریالی تا دو رقم و مقدار ارز یا طلا تا چهار رقم اعشار
+	result.Result = amount * result.FromRate / result.ToRate
+	if to.IsForeign() {
+		result.Result = math.Round(result.Result*10000) / 10000
+	} else {
+		result.Result = roundMoney(result.Result)
+	}
+	return result, nil
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/config"
+	"github.com/amirqodi/hgm/internal/models"
+)
+
+// PriceProvider منبع نرخ ارز و طلا؛ کدها همان کدهای آیتم در PRICE_ITEMS هستند.
+// در صورت خطا در بخشی از آیتم‌ها، مقادیر دریافت‌شده به‌همراه خطا برگردانده می‌شوند.
+type PriceProvider interface {
+	Name() string
+	Fetch(ctx context.Context, codes []string) (map[string]float64, error)
+}
+
+// ErrManualPrices در حالت ورود دستی نرخ‌ها به‌صورت خودکار دریافت نمی‌شوند
+var ErrManualPrices = errors.New("نرخ‌ها به‌صورت دستی وارد می‌شوند")
+
+// PriceItem نگاشت یک ارز دفتر به کد آیتم در منبع نرخ
+type PriceItem struct {
+	Currency models.Currency
+	Code     string
+}
+
+const defaultPriceItems = "usd:usd_sell,tether:usdt,gold18:18ayar,coin:sekkeh"
+
+// ParsePriceItems خواندن فهرست آیتم‌ها به شکل currency:code جداشده با کاما
+func ParsePriceItems(value string) ([]PriceItem, error) {
+	var items []PriceItem
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		currency, code, ok := strings.Cut(part, ":")
+		item := PriceItem{Currency: models.Currency(strings.TrimSpace(currency)), Code: strings.TrimSpace(code)}
+		if !ok || item.Code == "" || !item.Currency.IsForeign() || !item.Currency.Valid() {
+			return nil, fmt.Errorf("آیتم نرخ نامعتبر است: %s", part)
+		}
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		return nil, errors.New("فهرست آیتم‌های نرخ خالی است")
+	}
+	return items, nil
+}
+
+// PriceItemsFromEnv آیتم‌های PRICE_ITEMS (پیش‌فرض دلار، تتر، طلای ۱۸ و سکه امامی نواسان)
+func PriceItemsFromEnv() []PriceItem {
+	items, err := ParsePriceItems(config.Get("PRICE_ITEMS", defaultPriceItems))
+	if err != nil {
+		fmt.Println("Invalid PRICE_ITEMS, using defaults:", err)
+		items, _ = ParsePriceItems(defaultPriceItems)
+	}
+	return items
+}
+
+// PriceProviderFromEnv منبع نرخ بر اساس PRICE_PROVIDER=navasan|file|manual
+func PriceProviderFromEnv() (PriceProvider, error) {
+	switch name := config.Get("PRICE_PROVIDER", "navasan"); name {
+	case "navasan":
+		timeout, err := time.ParseDuration(config.Get("PRICE_HTTP_TIMEOUT", "15s"))
+		if err != nil {
+			return nil, fmt.Errorf("PRICE_HTTP_TIMEOUT نامعتبر است: %w", err)
+		}
+		return NewNavasanProvider(os.Getenv("NAVASAN_API_KEY"), timeout), nil
+	case "file":
+		path := config.Get("PRICE_FILE", "./prices.json")
+		return &FilePriceProvider{Path: path}, nil
+	case "manual":
+		return ManualPriceProvider{}, nil
+	default:
+		return nil, fmt.Errorf("PRICE_PROVIDER نامعتبر است: %s", name)
+	}
+}
+
+// parsePriceValue عدد یا رشته‌ای مثل "1,234,500"
+func parsePriceValue(raw json.RawMessage) (float64, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		s = string(raw)
+	}
+	return strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(s), ",", ""), 64)
+}
+
+// ---------------- NAVASAN ----------------
+
+// NavasanProvider دریافت نرخ از api.navasan.tech (مقادیر به تومان)
+type NavasanProvider struct {
+	APIKey  string
+	BaseURL string
+	Client  *http.Client
+}
+
+func NewNavasanProvider(apiKey string, timeout time.Duration) *NavasanProvider {
+	return &NavasanProvider{
+		APIKey:  apiKey,
+		BaseURL: "http://api.navasan.tech/latest/",
+		Client:  &http.Client{Timeout: timeout},
+	}
+}
+
+func (p *NavasanProvider) Name() string { return "navasan" }
+
+// مدل پاسخ نواسـان
+type NavasanItem struct {
+	Value json.RawMessage `json:"value"`
+}
+
+func (p *NavasanProvider) Fetch(ctx context.Context, codes []string) (map[string]float64, error) {
+	values := map[string]float64{}
+	var errs []error
+	for _, code := range codes {
+		v, err := p.fetchItem(ctx, code)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", code, err))
+			continue
+		}
+		values[code] = v
+	}
+	return values, errors.Join(errs...)
+}
+
+// دریافت یک آیتم از نواسـان
+func (p *NavasanProvider) fetchItem(ctx context.Context, item string) (float64, error) {
+	query := url.Values{"api_key": {p.APIKey}, "item": {item}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.BaseURL+"?"+query.Encode(), nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("navasan status %d", resp.StatusCode)
+	}
+
+	var data map[string]NavasanItem
+	if err := json.Unmarshal(body, &data); err != nil {
+		fmt.Println("RAW NAVASAN RESPONSE:", string(body))
+		return 0, err
+	}
+	v, ok := data[item]
+	if !ok {
+		return 0, fmt.Errorf("navasan response has no item %q", item)
+	}
+	return parsePriceValue(v.Value)
+}
+
+// ---------------- FILE ----------------
+
+// FilePriceProvider خواندن نرخ‌ها از فایل JSON محلی مثل {"usd_sell": 60000, "18ayar": "3,500,000"}؛
+// برای محیط بدون اینترنت یا به‌روزرسانی دستی فایل
+type FilePriceProvider struct {
+	Path string
+}
+
+func (p *FilePriceProvider) Name() string { return "file" }
+
+func (p *FilePriceProvider) Fetch(ctx context.Context, codes []string) (map[string]float64, error) {
+	content, err := os.ReadFile(p.Path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return nil, fmt.Errorf("فایل نرخ نامعتبر است: %w", err)
+	}
+
+	values := map[string]float64{}
+	var errs []error
+	for _, code := range codes {
+		v, ok := raw[code]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: not in %s", code, p.Path))
+			continue
+		}
+		f, err := parsePriceValue(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", code, err))
+			continue
+		}
+		values[code] = f
+	}
+	return values, errors.Join(errs...)
+}
+
+// ---------------- MANUAL ----------------
+
+// ManualPriceProvider نرخ‌ها فقط دستی ثبت می‌شوند و زمان‌بند چیزی دریافت نمی‌کند
+type ManualPriceProvider struct{}
+
+func (ManualPriceProvider) Name() string { return "manual" }
+
+func (ManualPriceProvider) Fetch(ctx context.Context, codes []string) (map[string]float64, error) {
+	return nil, ErrManualPrices
+}
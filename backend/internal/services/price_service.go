@@ -1,136 +1,278 @@
 package services
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
-	"io"
-	"net/http"
-	"os"
+	"math"
 	"strconv"
-	"strings"
 	"time"
 
-	"github.com/amirqodi/hgm/internal/database"
+	"github.com/amirqodi/hgm/internal/config"
 	"github.com/amirqodi/hgm/internal/models"
+	"github.com/amirqodi/hgm/internal/repositories"
 	"gorm.io/gorm"
 )
 
-// مدل پاسخ نواسـان
-type NavasanItem struct {
-	Value string `json:"value"`
+// Clock زمان و انتظار؛ در آزمایش‌ها با ساعت ساختگی جایگزین می‌شود
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
 }
 
-// دریافت یک آیتم از نواسـان
-func fetchItem(item string) (float64, error) {
-	apiKey := os.Getenv("NAVASAN_API_KEY")
-	url := fmt.Sprintf("http://api.navasan.tech/latest/?api_key=%s&item=%s", apiKey, item)
+type realClock struct{}
 
-	resp, err := http.Get(url)
-	if err != nil {
-		return 0, err
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// PriceScheduler دریافت دوره‌ای نرخ‌ها از Provider و ذخیره در جدول prices
+type PriceScheduler struct {
+	DB       *gorm.DB
+	Provider PriceProvider
+	Clock    Clock
+	Items    []PriceItem
+	Alerts   []PriceAlert
+	Interval time.Duration // فاصله دریافت نرخ
+
+	// تلاش مجدد پس از خطا: Backoff، دو برابر در هر بار تا MaxBackoff
+	Retries    int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// NewPriceScheduler زمان‌بند با تنظیمات پیش‌فرض (هر ۶ ساعت، ۳ تلاش مجدد از ۳۰ ثانیه)
+func NewPriceScheduler(db *gorm.DB, provider PriceProvider, clock Clock, items []PriceItem) *PriceScheduler {
+	if clock == nil {
+		clock = realClock{}
+	}
+	return &PriceScheduler{
+		DB:         db,
+		Provider:   provider,
+		Clock:      clock,
+		Items:      items,
+		Interval:   6 * time.Hour,
+		Retries:    3,
+		Backoff:    30 * time.Second,
+		MaxBackoff: 10 * time.Minute,
 	}
-	defer resp.Body.Close()
+}
 
-	body, err := io.ReadAll(resp.Body)
+// PriceSchedulerFromEnv زمان‌بند با PRICE_PROVIDER، PRICE_ITEMS، PRICE_ALERTS، PRICE_INTERVAL و PRICE_RETRIES
+func PriceSchedulerFromEnv(db *gorm.DB) (*PriceScheduler, error) {
+	provider, err := PriceProviderFromEnv()
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-
-	var data map[string]NavasanItem
-	if err := json.Unmarshal(body, &data); err != nil {
-		fmt.Println("RAW NAVASAN RESPONSE:", string(body))
-		return 0, err
+	s := NewPriceScheduler(db, provider, realClock{}, PriceItemsFromEnv())
+	s.Alerts = PriceAlertsFromEnv()
+	if v := config.Get("PRICE_INTERVAL", ""); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("PRICE_INTERVAL نامعتبر است: %s", v)
+		}
+		s.Interval = d
 	}
-
-	for _, v := range data {
-		val := strings.ReplaceAll(v.Value, ",", "")
-		f, err := strconv.ParseFloat(val, 64)
-		if err != nil {
-			return 0, err
+	if v := config.Get("PRICE_RETRIES", ""); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("PRICE_RETRIES نامعتبر است: %s", v)
 		}
-		return f, nil
+		s.Retries = n
 	}
-
-	return 0, fmt.Errorf("no data for %s", item)
+	return s, nil
 }
 
-// دریافت قیمت‌ها و ذخیره در دیتابیس
-func fetchAndSave() error {
-	usd, err := fetchItem("usd_sell")
-	if err != nil {
-		return err
+// FetchAndSave یک بار دریافت نرخ‌ها و ذخیره؛ آیتم‌های ناموفق صفر می‌مانند
+// (RateAt نرخ صفر را نادیده می‌گیرد و به آخرین نرخ معتبر برمی‌گردد)
+func (s *PriceScheduler) FetchAndSave(ctx context.Context) (*models.Price, error) {
+	codes := make([]string, len(s.Items))
+	for i, item := range s.Items {
+		codes[i] = item.Code
 	}
 
-	tether, err := fetchItem("usdt")
-	if err != nil {
-		tether = usd
+	values, err := s.Provider.Fetch(ctx, codes)
+	if len(values) == 0 {
+		if err == nil {
+			err = errors.New("no prices returned")
+		}
+		return nil, err
 	}
-
-	gold18, err := fetchItem("18ayar")
 	if err != nil {
-		return err
+		fmt.Println("Some prices could not be fetched:", err)
 	}
 
-	price := models.Price{
-		Dollar: usd,
-		Tether: tether,
-		Gold18: gold18,
+	price := models.Price{Source: s.Provider.Name(), CreatedAt: s.Clock.Now()}
+	for _, item := range s.Items {
+		if v, ok := values[item.Code]; ok {
+			price.SetRate(item.Currency, v)
+		}
+	}
+	if err := SavePrice(s.DB, &price, s.Alerts); err != nil {
+		return nil, err
 	}
-	database.DB.Create(&price)
 
-	fmt.Println("Prices updated:", price)
-	return nil
+	fmt.Println("Prices updated from", s.Provider.Name()+":", price)
+	return &price, nil
 }
 
-// دریافت آخرین قیمت از دیتابیس
-func GetLastPrice() (usd, tether, gold18 float64, err error) {
-	var price models.Price
-	if err := database.DB.Order("created_at desc").First(&price).Error; err != nil {
-		return 0, 0, 0, err
+// fetchWithRetry تلاش مجدد با فاصله افزایشی؛ حالت دستی تلاش مجدد ندارد
+func (s *PriceScheduler) fetchWithRetry(ctx context.Context) error {
+	backoff := s.Backoff
+	for attempt := 0; ; attempt++ {
+		_, err := s.FetchAndSave(ctx)
+		if err == nil || errors.Is(err, ErrManualPrices) || attempt >= s.Retries {
+			return err
+		}
+		fmt.Printf("Failed to fetch prices (attempt %d), retrying in %s: %v\n", attempt+1, backoff, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.Clock.After(backoff):
+		}
+		backoff *= 2
+		if s.MaxBackoff > 0 && backoff > s.MaxBackoff {
+			backoff = s.MaxBackoff
+		}
 	}
-	return price.Dollar, price.Tether, price.Gold18, nil
 }
 
-// آپدیت در شروع سرور فقط اگر آخرین رکورد > ۶ ساعت گذشته باشد
-func UpdateOnStartup() {
+// UpdateOnStartup دریافت نرخ فقط اگر رکوردی نباشد یا آخرین رکورد قدیمی‌تر از Interval باشد
+func (s *PriceScheduler) UpdateOnStartup(ctx context.Context) {
 	var last models.Price
-	err := database.DB.Order("created_at desc").First(&last).Error
+	err := s.DB.Order("created_at desc").First(&last).Error
 
-	// اگه هیچ رکوردی پیدا نشد → بلافاصله fetch کن
-	if errors.Is(err, gorm.ErrRecordNotFound) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
 		fmt.Println("No price data found, fetching initial prices...")
-		if err := fetchAndSave(); err != nil {
-			fmt.Println("Failed to fetch initial prices:", err)
-		}
+	case err != nil:
+		fmt.Println("DB error while checking last price:", err)
+		return
+	case s.Clock.Now().Sub(last.CreatedAt) > s.Interval:
+		fmt.Println("Last price is old, fetching new prices...")
+	default:
+		fmt.Println("Last price is recent, skipping startup fetch")
 		return
 	}
 
-	// اگه خطای دیگه‌ای بود
-	if err != nil {
-		fmt.Println("DB error while checking last price:", err)
-		return
+	if err := s.fetchWithRetry(ctx); err != nil {
+		fmt.Println("Failed to fetch prices on startup:", err)
+		notifyPriceFetchFailed(s.DB, s.Provider.Name(), err)
 	}
+}
 
-	// اگه رکورد هست ولی قدیمیه → fetch کن
-	if time.Since(last.CreatedAt) > 6*time.Hour {
-		fmt.Println("Last price is old, fetching new prices...")
-		if err := fetchAndSave(); err != nil {
-			fmt.Println("Failed to fetch prices on startup:", err)
+// Run حلقه زمان‌بند تا لغو ctx؛ ابتدا Interval صبر می‌کند
+func (s *PriceScheduler) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.Clock.After(s.Interval):
+		}
+		if err := s.fetchWithRetry(ctx); err != nil && !errors.Is(err, ErrManualPrices) {
+			fmt.Println("Failed to fetch prices:", err)
+			notifyPriceFetchFailed(s.DB, s.Provider.Name(), err)
 		}
-	} else {
-		fmt.Println("Last price is recent, skipping startup fetch")
 	}
 }
 
-// زمان‌بندی آپدیت قیمت‌ها هر ۶ ساعت
-func StartPriceScheduler() {
+// StartPriceScheduler به‌روزرسانی نرخ‌ها در شروع سرور و سپس در پس‌زمینه
+func StartPriceScheduler(db *gorm.DB) {
+	s, err := PriceSchedulerFromEnv(db)
+	if err != nil {
+		fmt.Println("Price scheduler disabled:", err)
+		return
+	}
+	if _, manual := s.Provider.(ManualPriceProvider); manual {
+		fmt.Println("PRICE_PROVIDER=manual, automatic price updates disabled")
+		return
+	}
+
+	ctx := context.Background()
 	go func() {
-		for {
-			time.Sleep(6 * time.Hour) // ابتدا صبر ۶ ساعت
-			if err := fetchAndSave(); err != nil {
-				fmt.Println("Failed to fetch prices:", err)
+		s.UpdateOnStartup(ctx)
+		s.Run(ctx)
+	}()
+}
+
+// PriceItemStatus آخرین نرخ معتبر یک ارز و تازگی آن
+type PriceItemStatus struct {
+	Item      models.Currency `json:"item"`
+	Value     float64         `json:"value"` // به واحد PRICE_UNIT؛ صفر اگر نرخی ثبت نشده باشد
+	Source    string          `json:"source,omitempty"`
+	UpdatedAt *time.Time      `json:"updated_at,omitempty"`
+	AgeHours  float64         `json:"age_hours"`
+	Stale     bool            `json:"stale"`
+}
+
+// PriceStatus نرخ‌های جاری؛ Stale یعنی هیچ نرخی ثبت نشده یا دست‌کم یک نرخ ثبت‌شده قدیمی‌تر از StaleAfter است
+// (ارزی که هرگز نرخ نداشته، مثلاً چون در PRICE_ITEMS نیست، وضعیت کلی را قدیمی نمی‌کند)
+type PriceStatus struct {
+	USD        float64           `json:"usd"`
+	Tether     float64           `json:"tether"`
+	Gold18     float64           `json:"gold18"`
+	Coin       float64           `json:"coin"`
+	Items      []PriceItemStatus `json:"items"`
+	Stale      bool              `json:"stale"`
+	StaleAfter string            `json:"stale_after"`
+}
+
+// PriceStaleAfter مدت اعتبار نرخ از PRICE_STALE_AFTER (پیش‌فرض ۲۴ ساعت)
+func PriceStaleAfter() time.Duration {
+	v := config.Get("PRICE_STALE_AFTER", "24h")
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		fmt.Println("Invalid PRICE_STALE_AFTER, using 24h:", v)
+		return 24 * time.Hour
+	}
+	return d
+}
+
+// GetPriceStatus آخرین نرخ معتبر هر ارز (نرخ صفرِ دریافت ناموفق نادیده گرفته می‌شود) با پرچم قدیمی بودن
+func GetPriceStatus(db *gorm.DB, now time.Time, staleAfter time.Duration) (*PriceStatus, error) {
+	status := &PriceStatus{StaleAfter: staleAfter.String(), Stale: true}
+	recorded := false
+	for _, currency := range models.Currencies {
+		if !currency.IsForeign() {
+			continue
+		}
+		column, err := repositories.PriceColumn(currency)
+		if err != nil {
+			return nil, err
+		}
+
+		item := PriceItemStatus{Item: currency, Stale: true}
+		var price models.Price
+		err = db.Where(column + " > 0").Order("created_at DESC, id DESC").First(&price).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+		case err != nil:
+			return nil, err
+		default:
+			age := now.Sub(price.CreatedAt)
+			item.Value = price.Rate(currency)
+			item.Source = price.Source
+			item.UpdatedAt = &price.CreatedAt
+			item.AgeHours = math.Round(age.Hours()*10) / 10
+			item.Stale = age > staleAfter
+			if !recorded {
+				recorded, status.Stale = true, false
 			}
+			status.Stale = status.Stale || item.Stale
 		}
-	}()
+		status.Items = append(status.Items, item)
+	}
+
+	for _, item := range status.Items {
+		switch item.Item {
+		case models.CurrencyUSD:
+			status.USD = item.Value
+		case models.CurrencyTether:
+			status.Tether = item.Value
+		case models.CurrencyGold18:
+			status.Gold18 = item.Value
+		case models.CurrencyCoin:
+			status.Coin = item.Value
+		}
+	}
+	return status, nil
 }
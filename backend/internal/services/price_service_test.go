@@ -0,0 +1,283 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/models"
+	"github.com/amirqodi/hgm/internal/repositories"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fakeClock زمان ثابت؛ After بلافاصله برمی‌گردد تا budget تمام شود و سپس برای همیشه منتظر می‌ماند
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	waits  []time.Duration
+	budget int // تعداد انتظارهای باقی‌مانده؛ منفی = نامحدود
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.waits = append(c.waits, d)
+	if c.budget == 0 {
+		return nil
+	}
+	c.budget--
+	ch := make(chan time.Time, 1)
+	ch <- c.now.Add(d)
+	return ch
+}
+
+func (c *fakeClock) Waits() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]time.Duration(nil), c.waits...)
+}
+
+// fakeProvider failures بار اول خطا و سپس values را برمی‌گرداند
+type fakeProvider struct {
+	mu       sync.Mutex
+	failures int
+	values   map[string]float64
+	calls    int
+}
+
+func (p *fakeProvider) Name() string { return "fake" }
+
+func (p *fakeProvider) Fetch(ctx context.Context, codes []string) (map[string]float64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	if p.failures != 0 {
+		p.failures--
+		return nil, errors.New("provider down")
+	}
+	return p.values, nil
+}
+
+func (p *fakeProvider) Calls() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls
+}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&models.Price{}, &models.Notification{}, &models.ProductService{}, &models.ProductPriceChange{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func newTestScheduler(t *testing.T, provider PriceProvider, clock *fakeClock) *PriceScheduler {
+	t.Helper()
+	s := NewPriceScheduler(newTestDB(t), provider, clock, []PriceItem{
+		{Currency: models.CurrencyUSD, Code: "usd_sell"},
+		{Currency: models.CurrencyGold18, Code: "18ayar"},
+	})
+	s.Interval = time.Hour
+	s.Backoff = 30 * time.Second
+	s.MaxBackoff = time.Minute
+	return s
+}
+
+func equalDurations(a, b []time.Duration) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFetchWithRetryBacksOffUntilSuccess(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC), budget: -1}
+	provider := &fakeProvider{failures: 3, values: map[string]float64{"usd_sell": 60000, "18ayar": 3500000}}
+	s := newTestScheduler(t, provider, clock)
+	s.Retries = 3
+
+	if err := s.fetchWithRetry(context.Background()); err != nil {
+		t.Fatalf("fetchWithRetry: %v", err)
+	}
+	if provider.Calls() != 4 {
+		t.Errorf("calls = %d, want 4", provider.Calls())
+	}
+	// ۳۰ ثانیه، دو برابر و سپس محدود به MaxBackoff
+	if want := []time.Duration{30 * time.Second, time.Minute, time.Minute}; !equalDurations(clock.Waits(), want) {
+		t.Errorf("waits = %v, want %v", clock.Waits(), want)
+	}
+
+	var price models.Price
+	if err := s.DB.First(&price).Error; err != nil {
+		t.Fatalf("price not saved: %v", err)
+	}
+	if price.Dollar != 60000 || price.Gold18 != 3500000 || price.Source != "fake" || !price.CreatedAt.Equal(clock.now) {
+		t.Errorf("saved price = %+v", price)
+	}
+}
+
+func TestFetchWithRetryGivesUpAfterRetries(t *testing.T) {
+	clock := &fakeClock{now: time.Now(), budget: -1}
+	provider := &fakeProvider{failures: -1}
+	s := newTestScheduler(t, provider, clock)
+	s.Retries = 2
+
+	if err := s.fetchWithRetry(context.Background()); err == nil {
+		t.Fatal("expected error")
+	}
+	if provider.Calls() != 3 {
+		t.Errorf("calls = %d, want 3", provider.Calls())
+	}
+	if want := []time.Duration{30 * time.Second, time.Minute}; !equalDurations(clock.Waits(), want) {
+		t.Errorf("waits = %v, want %v", clock.Waits(), want)
+	}
+	var count int64
+	s.DB.Model(&models.Price{}).Count(&count)
+	if count != 0 {
+		t.Errorf("prices saved = %d, want 0", count)
+	}
+}
+
+func TestFetchWithRetryStopsOnCancel(t *testing.T) {
+	clock := &fakeClock{now: time.Now(), budget: 0} // انتظار هیچ‌وقت تمام نمی‌شود
+	provider := &fakeProvider{failures: -1}
+	s := newTestScheduler(t, provider, clock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.fetchWithRetry(ctx) }()
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("err = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("fetchWithRetry did not stop after cancel")
+	}
+}
+
+func TestRunFetchesEveryIntervalAndNotifiesOnce(t *testing.T) {
+	clock := &fakeClock{now: time.Now(), budget: 2} // دو دوره و سپس انتظار تا لغو
+	provider := &fakeProvider{failures: -1}
+	s := newTestScheduler(t, provider, clock)
+	s.Retries = 0
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(clock.Waits()) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("scheduler did not run two intervals")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if provider.Calls() != 2 {
+		t.Errorf("calls = %d, want 2", provider.Calls())
+	}
+	for _, d := range clock.Waits() {
+		if d != s.Interval {
+			t.Errorf("wait %v, want interval %v", d, s.Interval)
+		}
+	}
+	// اعلان خطای خوانده‌نشده تکرار نمی‌شود
+	var count int64
+	s.DB.Model(&models.Notification{}).Where("kind = ?", models.NotificationPriceFetchFailed).Count(&count)
+	if count != 1 {
+		t.Errorf("notifications = %d, want 1", count)
+	}
+}
+
+func TestUpdateOnStartupSkipsRecentPrice(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC), budget: -1}
+	provider := &fakeProvider{values: map[string]float64{"usd_sell": 60000}}
+	s := newTestScheduler(t, provider, clock)
+	s.DB.Create(&models.Price{Dollar: 59000, CreatedAt: clock.now.Add(-30 * time.Minute)})
+
+	s.UpdateOnStartup(context.Background())
+	if provider.Calls() != 0 {
+		t.Errorf("recent price: calls = %d, want 0", provider.Calls())
+	}
+
+	clock.now = clock.now.Add(2 * time.Hour)
+	s.UpdateOnStartup(context.Background())
+	if provider.Calls() != 1 {
+		t.Errorf("stale price: calls = %d, want 1", provider.Calls())
+	}
+}
+
+func TestNavasanMissingItemIsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"usd_buy":{"value":"59000"}}`))
+	}))
+	defer server.Close()
+
+	p := NewNavasanProvider("key", time.Second)
+	p.BaseURL = server.URL + "/"
+	values, err := p.Fetch(context.Background(), []string{"usd_sell"})
+	if err == nil || !strings.Contains(err.Error(), "usd_sell") {
+		t.Fatalf("err = %v, want error naming usd_sell", err)
+	}
+	if len(values) != 0 {
+		t.Errorf("values = %v, want none", values)
+	}
+}
+
+func TestSavePriceProposesOnlyForLatestRate(t *testing.T) {
+	db := newTestDB(t)
+	base := 10.0
+	product := models.ProductService{Code: "P1", Name: "کالا", SellingPrice: 500000, PriceUnit: models.CurrencyUSD, BasePrice: &base}
+	if err := db.Create(&product).Error; err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	if err := SavePrice(db, &models.Price{Dollar: 60000, Source: "manual", CreatedAt: now}, nil); err != nil {
+		t.Fatal(err)
+	}
+	// نرخ دستی با تاریخ گذشته پیشنهاد تازه نمی‌سازد و پیشنهاد در انتظار را کنار نمی‌گذارد
+	if err := SavePrice(db, &models.Price{Dollar: 40000, Source: "manual", CreatedAt: now.AddDate(0, 0, -3)}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var changes []models.ProductPriceChange
+	db.Order("id").Find(&changes)
+	if len(changes) != 1 || changes[0].Status != models.PriceChangePending || changes[0].Rate != repositories.PriceToBookUnit(60000) {
+		t.Fatalf("changes = %+v, want one pending change for the latest rate", changes)
+	}
+	var count int64
+	db.Model(&models.Notification{}).Where("kind = ?", models.NotificationProductPrices).Count(&count)
+	if count != 1 {
+		t.Errorf("notifications = %d, want 1", count)
+	}
+}
@@ -0,0 +1,363 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/models"
+	"github.com/amirqodi/hgm/internal/repositories"
+	ptime "github.com/yaa110/go-persian-calendar"
+	"gorm.io/gorm"
+)
+
+const (
+	PLCurrent  = "current"
+	PLPrevious = "previous"  // دوره هم‌طول قبلی
+	PLLastYear = "last_year" // همین بازه در سال قبل (شمسی)
+)
+
+type PLColumn struct {
+	Key  string    `json:"key"`
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// PLNode یک دسته‌بندی با مبالغ جمع‌شده از زیرشاخه‌ها؛ CategoryID خالی برای ردیف‌های بدون دسته (مثل کارمزد بانکی)
+type PLNode struct {
+	CategoryID       *uint              `json:"category_id,omitempty"`
+	Name             string             `json:"name"`
+	Amounts          map[string]float64 `json:"amounts"`
+	PercentOfRevenue map[string]float64 `json:"percent_of_revenue"`
+	Children         []*PLNode          `json:"children,omitempty"`
+}
+
+type PLSection struct {
+	Lines            []*PLNode          `json:"lines"`
+	Total            map[string]float64 `json:"total"`
+	PercentOfRevenue map[string]float64 `json:"percent_of_revenue"`
+}
+
+type ProfitLossStatement struct {
+	Columns   []PLColumn         `json:"columns"`
+	Revenue   PLSection          `json:"revenue"`
+	Expenses  PLSection          `json:"expenses"`
+	NetProfit map[string]float64 `json:"net_profit"`
+	NetMargin map[string]float64 `json:"net_margin"`     // درصد سود خالص از درآمد
+	Unit      models.Currency    `json:"unit,omitempty"` // واحد گزارش در صورت تبدیل به دلار/طلا/تتر
+}
+
+// plEntry مبلغ یک دسته در یک ستون
+type plEntry struct {
+	Column     string
+	Revenue    bool
+	CategoryID *uint
+	Label      string // برای ردیف‌های بدون دسته
+	Amount     float64
+}
+
+// ProfitLossColumns ستون دوره جاری و ستون‌های مقایسه‌ای درخواستی
+func ProfitLossColumns(from, to time.Time, compare []string) ([]PLColumn, error) {
+	columns := []PLColumn{{Key: PLCurrent, From: from, To: to}}
+	for _, key := range compare {
+		switch key {
+		case PLPrevious:
+			columns = append(columns, PLColumn{Key: key, From: from.Add(-to.Sub(from)), To: from})
+		case PLLastYear:
+			columns = append(columns, PLColumn{Key: key, From: shiftJalaliYear(from, -1), To: shiftJalaliYear(to, -1)})
+		case "":
+		default:
+			return nil, errors.New("مقایسه باید previous یا last_year باشد")
+		}
+	}
+	return columns, nil
+}
+
+// shiftJalaliYear همان روز و ماه شمسی در سال دیگر (۳۰ اسفند در سال غیرکبیسه ۲۹ اسفند می‌شود)
+func shiftJalaliYear(t time.Time, years int) time.Time {
+	pt := ptime.New(t)
+	year, month, day := pt.Year()+years, pt.Month(), pt.Day()
+	if month == ptime.Esfand && day == 30 && !ptime.Date(year, month, 1, 0, 0, 0, 0, t.Location()).IsLeap() {
+		day = 29
+	}
+	return ptime.Date(year, month, day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location()).Time()
+}
+
+// GetProfitLossStatement صورت سود و زیان بر اساس درخت دسته‌بندی‌ها.
+// تراکنش‌های آورده/برداشت سرمایه درآمد یا هزینه نیستند و حذف می‌شوند.
+func GetProfitLossStatement(db *gorm.DB, columns []PLColumn, conv *UnitConverter) (*ProfitLossStatement, error) {
+	var entries []plEntry
+	for _, col := range columns {
+		colEntries, err := profitLossEntries(db, col, conv)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, colEntries...)
+	}
+
+	var categories []models.Category
+	if err := db.Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]models.Category, len(categories))
+	for _, cat := range categories {
+		byID[cat.ID] = cat
+	}
+
+	result := &ProfitLossStatement{
+		Columns:   columns,
+		Unit:      conv.Currency(),
+		NetProfit: map[string]float64{},
+		NetMargin: map[string]float64{},
+	}
+	result.Revenue = buildPLSection(entries, true, columns, byID)
+	result.Expenses = buildPLSection(entries, false, columns, byID)
+
+	for _, col := range columns {
+		revenue := result.Revenue.Total[col.Key]
+		result.NetProfit[col.Key] = roundMoney(revenue - result.Expenses.Total[col.Key])
+		result.NetMargin[col.Key] = percentOf(result.NetProfit[col.Key], revenue)
+	}
+	for _, section := range []*PLSection{&result.Revenue, &result.Expenses} {
+		section.PercentOfRevenue = map[string]float64{}
+		for _, col := range columns {
+			section.PercentOfRevenue[col.Key] = percentOf(section.Total[col.Key], result.Revenue.Total[col.Key])
+		}
+		setPercentOfRevenue(section.Lines, result.Revenue.Total)
+	}
+	return result, nil
+}
+
+// profitLossEntries مبالغ هر دسته در یک ستون؛ با conv هر ردیف با نرخ نزدیک به تاریخ خودش تبدیل می‌شود
+func profitLossEntries(db *gorm.DB, col PLColumn, conv *UnitConverter) ([]plEntry, error) {
+	var entries []plEntry
+
+	// --- تراکنش‌های درآمد و هزینه ---
+	var rows []struct {
+		CategoryID      uint
+		TransactionType string
+		Amount          float64
+		TransactionDate *time.Time
+		CreatedAt       time.Time
+	}
+	if err := repositories.ProfitTransactions(db, col.From, col.To).
+		Select("category_id, transaction_type, amount, transaction_date, created_at").
+		Order("category_id, transaction_type").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	type trxKey struct {
+		categoryID uint
+		revenue    bool
+	}
+	trxIndex := map[trxKey]int{}
+	for _, row := range rows {
+		key := trxKey{row.CategoryID, row.TransactionType == "income"}
+		i, ok := trxIndex[key]
+		if !ok {
+			entry := plEntry{Column: col.Key, Revenue: key.revenue}
+			if key.categoryID != 0 {
+				categoryID := key.categoryID
+				entry.CategoryID = &categoryID
+			} else {
+				entry.Label = "بدون دسته‌بندی"
+			}
+			entries = append(entries, entry)
+			i = len(entries) - 1
+			trxIndex[key] = i
+		}
+		date := row.CreatedAt
+		if row.TransactionDate != nil {
+			date = *row.TransactionDate
+		}
+		entries[i].Amount += conv.Convert(row.Amount, date)
+	}
+
+	// --- سود و کارمزد بانکی ---
+	var charges []models.BankCharge
+	if err := db.Where("date >= ? AND date < ?", col.From, col.To).
+		Order("category_id, kind").Find(&charges).Error; err != nil {
+		return nil, err
+	}
+	chargeIndex := map[string]int{}
+	for _, charge := range charges {
+		key := string(charge.Kind)
+		if charge.CategoryID != nil {
+			key += ":" + strconv.FormatUint(uint64(*charge.CategoryID), 10)
+		}
+		i, ok := chargeIndex[key]
+		if !ok {
+			entry := plEntry{Column: col.Key, Revenue: charge.Kind == models.BankChargeInterest, CategoryID: charge.CategoryID}
+			if charge.CategoryID == nil {
+				entry.Label = "کارمزدهای بانکی"
+				if entry.Revenue {
+					entry.Label = "سود سپرده بانکی"
+				}
+			}
+			entries = append(entries, entry)
+			i = len(entries) - 1
+			chargeIndex[key] = i
+		}
+		entries[i].Amount += conv.Convert(charge.Amount, charge.Date)
+	}
+
+	// --- اضافه و کسری صندوق ---
+	from, to := col.From, col.To
+	var counts []models.CashCount
+	if err := db.Where("status = ? AND difference <> 0 AND posted_at >= ? AND posted_at < ?", models.CashCountPosted, from, to).
+		Find(&counts).Error; err != nil {
+		return nil, err
+	}
+	var over, short float64
+	for _, count := range counts {
+		if v := conv.Convert(count.Difference, *count.PostedAt); v > 0 {
+			over += v
+		} else {
+			short -= v
+		}
+	}
+	if over > 0 {
+		entries = append(entries, plEntry{Column: col.Key, Revenue: true, Label: "اضافه صندوق", Amount: over})
+	}
+	if short > 0 {
+		entries = append(entries, plEntry{Column: col.Key, Revenue: false, Label: "کسری صندوق", Amount: short})
+	}
+
+	// --- تسعیر حساب‌های ارزی و طلا (سود/زیان تحقق‌نیافته) ---
+	var revaluations []models.Revaluation
+	if err := db.Where("date >= ? AND date < ?", from, to).Find(&revaluations).Error; err != nil {
+		return nil, err
+	}
+	var revGain, revLoss float64
+	for _, rev := range revaluations {
+		if v := conv.Convert(rev.Gain, rev.Date); v > 0 {
+			revGain += v
+		} else {
+			revLoss -= v
+		}
+	}
+	if revGain > 0 {
+		entries = append(entries, plEntry{Column: col.Key, Revenue: true, Label: "سود تسعیر ارز و طلا", Amount: revGain})
+	}
+	if revLoss > 0 {
+		entries = append(entries, plEntry{Column: col.Key, Revenue: false, Label: "زیان تسعیر ارز و طلا", Amount: revLoss})
+	}
+
+	return entries, nil
+}
+
+// buildPLSection درخت دسته‌ها را می‌سازد و مبالغ را به همه والدها اضافه می‌کند
+func buildPLSection(entries []plEntry, revenue bool, columns []PLColumn, byID map[uint]models.Category) PLSection {
+	section := PLSection{Lines: []*PLNode{}, Total: map[string]float64{}}
+	for _, col := range columns {
+		section.Total[col.Key] = 0
+	}
+
+	newNode := func(categoryID *uint, name string) *PLNode {
+		node := &PLNode{CategoryID: categoryID, Name: name, Amounts: map[string]float64{}}
+		for _, col := range columns {
+			node.Amounts[col.Key] = 0
+		}
+		return node
+	}
+
+	categoryNodes := map[uint]*PLNode{}
+	labelNodes := map[string]*PLNode{}
+
+	// nodeFor گره دسته و زنجیره والدهای آن (با جلوگیری از حلقه در Parent)
+	var nodeFor func(id uint, visited map[uint]bool) *PLNode
+	nodeFor = func(id uint, visited map[uint]bool) *PLNode {
+		if node, ok := categoryNodes[id]; ok {
+			return node
+		}
+		cat, ok := byID[id]
+		name := cat.Name
+		if !ok {
+			name = "دسته حذف‌شده"
+		}
+		categoryID := id
+		node := newNode(&categoryID, name)
+		categoryNodes[id] = node
+
+		visited[id] = true
+		if ok && cat.Parent != nil && *cat.Parent != id && !visited[*cat.Parent] {
+			parent := nodeFor(*cat.Parent, visited)
+			parent.Children = append(parent.Children, node)
+		} else {
+			section.Lines = append(section.Lines, node)
+		}
+		return node
+	}
+
+	for _, e := range entries {
+		if e.Revenue != revenue || e.Amount == 0 {
+			continue
+		}
+		section.Total[e.Column] += e.Amount
+
+		if e.CategoryID == nil {
+			node, ok := labelNodes[e.Label]
+			if !ok {
+				node = newNode(nil, e.Label)
+				labelNodes[e.Label] = node
+				section.Lines = append(section.Lines, node)
+			}
+			node.Amounts[e.Column] += e.Amount
+			continue
+		}
+
+		// مبلغ به خود دسته و همه والدهای آن اضافه می‌شود
+		nodeFor(*e.CategoryID, map[uint]bool{})
+		visited := map[uint]bool{}
+		for id := e.CategoryID; id != nil && !visited[*id]; {
+			visited[*id] = true
+			node := categoryNodes[*id]
+			if node == nil {
+				break
+			}
+			node.Amounts[e.Column] += e.Amount
+			cat, ok := byID[*id]
+			if !ok {
+				break
+			}
+			id = cat.Parent
+		}
+	}
+
+	for key, v := range section.Total {
+		section.Total[key] = roundMoney(v)
+	}
+	sortPLNodes(section.Lines)
+	return section
+}
+
+func sortPLNodes(nodes []*PLNode) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].Amounts[PLCurrent] > nodes[j].Amounts[PLCurrent]
+	})
+	for _, node := range nodes {
+		for key, v := range node.Amounts {
+			node.Amounts[key] = roundMoney(v)
+		}
+		sortPLNodes(node.Children)
+	}
+}
+
+func setPercentOfRevenue(nodes []*PLNode, revenue map[string]float64) {
+	for _, node := range nodes {
+		node.PercentOfRevenue = map[string]float64{}
+		for key, amount := range node.Amounts {
+			node.PercentOfRevenue[key] = percentOf(amount, revenue[key])
+		}
+		setPercentOfRevenue(node.Children, revenue)
+	}
+}
+
+func percentOf(amount, base float64) float64 {
+	if base == 0 {
+		return 0
+	}
+	return math.Round(amount/base*10000) / 100
+}
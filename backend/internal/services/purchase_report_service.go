@@ -0,0 +1,98 @@
+package services
+
+import (
+	"sort"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/models"
+	"gorm.io/gorm"
+)
+
+// OpenPurchaseLine قلم سفارش خریدی که هنوز کامل نرسیده
+type OpenPurchaseLine struct {
+	PurchaseOrderID uint       `json:"purchase_order_id"`
+	Number          string     `json:"number"`
+	VendorID        uint       `json:"vendor_id"`
+	Vendor          string     `json:"vendor"`
+	ProductID       uint       `json:"product_service_id"`
+	Description     string     `json:"description"`
+	OrderDate       time.Time  `json:"order_date"`
+	ExpectedDate    *time.Time `json:"expected_date,omitempty"` // قلم، وگرنه سفارش
+	Ordered         int64      `json:"ordered"`
+	Received        int64      `json:"received"`
+	Remaining       int64      `json:"remaining"`
+	RemainingAmount float64    `json:"remaining_amount"` // بدون مالیات
+	DaysLate        int        `json:"days_late"`        // روزهای گذشته از تاریخ پیش‌بینی
+}
+
+type OpenPurchaseOrdersReport struct {
+	Lines           []OpenPurchaseLine `json:"lines"`
+	RemainingAmount float64            `json:"remaining_amount"`
+	LateLines       int                `json:"late_lines"`
+}
+
+// GetOpenPurchaseOrders اقلام دریافت‌نشده سفارش‌های ارسال‌شده به ترتیب تاریخ پیش‌بینی رسیدن
+func GetOpenPurchaseOrders(db *gorm.DB, vendorID uint, now time.Time) (*OpenPurchaseOrdersReport, error) {
+	var orders []models.PurchaseOrder
+	q := db.Preload("Items").Preload("Contact").
+		Where("status IN ?", []models.PurchaseOrderStatus{models.POOrdered, models.POPartiallyReceived})
+	if vendorID != 0 {
+		q = q.Where("contact_id = ?", vendorID)
+	}
+	if err := q.Find(&orders).Error; err != nil {
+		return nil, err
+	}
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	report := &OpenPurchaseOrdersReport{Lines: []OpenPurchaseLine{}}
+	for _, po := range orders {
+		vendor := ""
+		if po.Contact != nil {
+			vendor = po.Contact.FirstName + " " + po.Contact.LastName
+		}
+		for _, item := range po.Items {
+			remaining := item.Quantity - item.ReceivedQty
+			if remaining <= 0 {
+				continue
+			}
+			line := OpenPurchaseLine{
+				PurchaseOrderID: po.ID,
+				Number:          po.Number,
+				VendorID:        po.ContactID,
+				Vendor:          vendor,
+				ProductID:       item.ProductID,
+				Description:     item.Description,
+				OrderDate:       po.OrderDate,
+				ExpectedDate:    item.ExpectedDate,
+				Ordered:         item.Quantity,
+				Received:        item.ReceivedQty,
+				Remaining:       remaining,
+				RemainingAmount: float64(remaining) * item.UnitPrice,
+			}
+			if line.ExpectedDate == nil {
+				line.ExpectedDate = po.ExpectedDate
+			}
+			if line.ExpectedDate != nil && line.ExpectedDate.Before(today) {
+				line.DaysLate = int(today.Sub(*line.ExpectedDate).Hours() / 24)
+				report.LateLines++
+			}
+			report.RemainingAmount += line.RemainingAmount
+			report.Lines = append(report.Lines, line)
+		}
+	}
+
+	// بدون تاریخ پیش‌بینی در انتها
+	sort.SliceStable(report.Lines, func(i, j int) bool {
+		a, b := report.Lines[i].ExpectedDate, report.Lines[j].ExpectedDate
+		switch {
+		case a == nil:
+			return false
+		case b == nil:
+			return true
+		case !a.Equal(*b):
+			return a.Before(*b)
+		}
+		return report.Lines[i].PurchaseOrderID < report.Lines[j].PurchaseOrderID
+	})
+	return report, nil
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/models"
+	"github.com/amirqodi/hgm/internal/repositories"
+	ptime "github.com/yaa110/go-persian-calendar"
+	"gorm.io/gorm"
+)
+
+// QuoteConversionRow آمار پیش‌فاکتورهای صادرشده در یک ماه (بر اساس تاریخ صدور)
+type QuoteConversionRow struct {
+	Period string `json:"period"`
+
+	Quoted       int     `json:"quoted"`
+	QuotedAmount float64 `json:"quoted_amount"`
+	Open         int     `json:"open"`    // پیش‌نویس یا ارسال‌شده و هنوز معتبر
+	Won          int     `json:"won"`     // پذیرفته‌شده (تبدیل‌شده یا نشده)
+	Lost         int     `json:"lost"`    // ردشده
+	Expired      int     `json:"expired"` // بدون پاسخ منقضی شده
+	WonAmount    float64 `json:"won_amount"`
+
+	Converted       int     `json:"converted"` // تبدیل‌شده به تراکنش/فاکتور
+	ConvertedAmount float64 `json:"converted_amount"`
+
+	// WinRate = Won / (Won + Lost + Expired)؛ پیش‌فاکتورهای باز در آن حساب نمی‌شوند
+	WinRate        float64 `json:"win_rate"`
+	ConversionRate float64 `json:"conversion_rate"` // Converted / Quoted
+	AvgDaysToWin   float64 `json:"avg_days_to_win"` // میانگین فاصله صدور تا پذیرش
+
+	daysToWin float64
+}
+
+type QuoteConversionReport struct {
+	From  time.Time            `json:"from"`
+	To    time.Time            `json:"to"`
+	Rows  []QuoteConversionRow `json:"rows"`
+	Total QuoteConversionRow   `json:"total"`
+}
+
+// GetQuoteConversionReport نرخ تبدیل پیش‌فاکتور به فروش برای پیش‌فاکتورهای صادرشده در [from, to)
+func GetQuoteConversionReport(db *gorm.DB, from, to time.Time) (*QuoteConversionReport, error) {
+	quotes, err := repositories.GetQuotes(repositories.QuoteFilter{From: from, To: to}, db)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &QuoteConversionReport{From: from, To: to, Total: QuoteConversionRow{Period: "جمع"}}
+	index := map[string]int{}
+
+	// GetQuotes نزولی مرتب می‌کند؛ ردیف‌ها از قدیم به جدید
+	for i := len(quotes) - 1; i >= 0; i-- {
+		q := quotes[i]
+		pt := ptime.New(q.IssuedAt)
+		period := fmt.Sprintf("%s %d", pt.Month().String(), pt.Year())
+		pos, ok := index[period]
+		if !ok {
+			pos = len(report.Rows)
+			index[period] = pos
+			report.Rows = append(report.Rows, QuoteConversionRow{Period: period})
+		}
+		addQuote(&report.Rows[pos], &q)
+		addQuote(&report.Total, &q)
+	}
+
+	for i := range report.Rows {
+		finishQuoteRow(&report.Rows[i])
+	}
+	finishQuoteRow(&report.Total)
+	return report, nil
+}
+
+func addQuote(row *QuoteConversionRow, q *models.Quote) {
+	row.Quoted++
+	row.QuotedAmount += q.Total
+
+	switch q.Status {
+	case models.QuoteAccepted:
+		row.Won++
+		row.WonAmount += q.Total
+		if q.DecidedAt != nil {
+			row.daysToWin += q.DecidedAt.Sub(q.IssuedAt).Hours() / 24
+		}
+	case models.QuoteRejected:
+		row.Lost++
+	case models.QuoteExpired:
+		row.Expired++
+	default:
+		row.Open++
+	}
+
+	if q.ConvertedAt != nil {
+		row.Converted++
+		row.ConvertedAmount += q.Total
+	}
+}
+
+func finishQuoteRow(row *QuoteConversionRow) {
+	row.WinRate = percentOf(float64(row.Won), float64(row.Won+row.Lost+row.Expired))
+	row.ConversionRate = percentOf(float64(row.Converted), float64(row.Quoted))
+	if row.Won > 0 {
+		row.AvgDaysToWin = math.Round(row.daysToWin/float64(row.Won)*10) / 10
+	}
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/models"
+	"github.com/amirqodi/hgm/internal/repositories"
+	"gorm.io/gorm"
+)
+
+// UnitConverter تبدیل مبالغ دفتر به دلار، طلا یا تتر با نرخ ثبت‌شده نزدیک به تاریخ هر مبلغ
+// تا سودآوری دوره‌های مختلف با حذف اثر تورم مقایسه شود. مبدل nil مبالغ را تغییر نمی‌دهد.
+type UnitConverter struct {
+	Unit  models.Currency
+	times []time.Time
+	rates []float64 // هر واحد به واحد دفتر
+}
+
+// NewUnitConverter برای unit خالی یا irr مبدل nil برمی‌گرداند
+func NewUnitConverter(db *gorm.DB, unit models.Currency) (*UnitConverter, error) {
+	if !unit.IsForeign() {
+		return nil, nil
+	}
+	switch unit {
+	case models.CurrencyUSD, models.CurrencyGold18, models.CurrencyTether:
+	default:
+		return nil, errors.New("واحد گزارش باید usd، gold18 یا tether باشد")
+	}
+	column, err := repositories.PriceColumn(unit)
+	if err != nil {
+		return nil, err
+	}
+
+	var prices []models.Price
+	if err := db.Where(column + " > 0").Order("created_at ASC").Find(&prices).Error; err != nil {
+		return nil, err
+	}
+	if len(prices) == 0 {
+		return nil, repositories.ErrNoRate
+	}
+
+	conv := &UnitConverter{Unit: unit}
+	for _, p := range prices {
+		conv.times = append(conv.times, p.CreatedAt)
+		conv.rates = append(conv.rates, repositories.PriceToBookUnit(p.Rate(unit)))
+	}
+	return conv, nil
+}
+
+// RateAt نزدیک‌ترین نرخ ثبت‌شده به زمان t (قبل یا بعد از آن)
+func (c *UnitConverter) RateAt(t time.Time) float64 {
+	if c == nil {
+		return 1
+	}
+	i := sort.Search(len(c.times), func(i int) bool { return !c.times[i].Before(t) })
+	switch {
+	case i == 0:
+		return c.rates[0]
+	case i == len(c.times):
+		return c.rates[i-1]
+	case c.times[i].Sub(t) < t.Sub(c.times[i-1]):
+		return c.rates[i]
+	default:
+		return c.rates[i-1]
+	}
+}
+
+// Convert مبلغ دفتر در زمان t به واحد گزارش
+func (c *UnitConverter) Convert(amount float64, t time.Time) float64 {
+	if c == nil {
+		return amount
+	}
+	return amount / c.RateAt(t)
+}
+
+// Currency واحد گزارش؛ خالی برای واحد دفتر
+func (c *UnitConverter) Currency() models.Currency {
+	if c == nil {
+		return ""
+	}
+	return c.Unit
+}
@@ -1,9 +1,12 @@
 package services
 
 import (
+	"errors"
 	"sort"
+	"time"
 
 	"github.com/amirqodi/hgm/internal/models"
+	"github.com/amirqodi/hgm/internal/repositories"
 	ptime "github.com/yaa110/go-persian-calendar"
 	"gorm.io/gorm"
 )
@@ -15,12 +18,33 @@ type IncomeExpenseReport struct {
 	NetProfit float64 `json:"net_profit"`
 }
 
+// TotalBalance موجودی حساب‌ها به واحد دفتر؛ حساب‌های ارزی و طلا با ارزش دفتری (آخرین تسعیر) جمع می‌شوند
 type TotalBalance struct {
 	BankBalance       float64 `json:"bank_balance"`
 	CashHolderBalance float64 `json:"cash_holder_balance"`
 	Total             float64 `json:"total"`
+
+	Foreign        []ForeignHolding `json:"foreign,omitempty"`
+	MarketTotal    float64          `json:"market_total"`    // Total با ارزش روز حساب‌های ارزی
+	UnrealisedGain float64          `json:"unrealised_gain"` // سود/زیان تسعیرنشده تا امروز
+}
+
+// ForeignHolding حساب بانکی یا تنخواه ارزی و طلا با ارزش دفتری و ارزش روز
+type ForeignHolding struct {
+	MoneySourceType string          `json:"money_source_type"`
+	ID              uint            `json:"id"`
+	Name            string          `json:"name"`
+	Currency        models.Currency `json:"currency"`
+	Balance         float64         `json:"balance"` // به واحد ارز
+	BookValue       float64         `json:"book_value"`
+	Rate            float64         `json:"rate"` // صفر اگر نرخی ثبت نشده باشد
+	MarketValue     float64         `json:"market_value"`
+	UnrealisedGain  float64         `json:"unrealised_gain"`
 }
 
+// carryingValueSQL مانده حساب به واحد دفتر؛ حساب ارزی با ارزش دفتری
+const carryingValueSQL = "COALESCE(SUM(CASE WHEN currency IS NULL OR currency IN ('', 'irr') THEN balance ELSE book_value END),0)"
+
 type BalanceSheet struct {
 	Assets struct {
 		BankAccounts     float64 `json:"bank_accounts"`
@@ -32,9 +56,10 @@ type BalanceSheet struct {
 	} `json:"assets"`
 
 	Liabilities struct {
-		DepositsPaid float64 `json:"deposits_paid"` // ودیعه‌های پرداختی
-		Payables     float64 `json:"payables"`
-		Total        float64 `json:"total"`
+		DepositsPaid     float64 `json:"deposits_paid"` // ودیعه‌های پرداختی
+		Payables         float64 `json:"payables"`
+		DividendsPayable float64 `json:"dividends_payable"` // سود سهام پرداختنی
+		Total            float64 `json:"total"`
 	} `json:"liabilities"`
 
 	Equity struct {
@@ -46,14 +71,37 @@ type BalanceSheet struct {
 	TotalAssets float64 `json:"total_assets"`
 	TotalLiab   float64 `json:"total_liabilities"`
 	TotalEquity float64 `json:"total_equity"`
+
+	// گزارش به دلار یا طلا: همه ارقام با نرخ روز تبدیل شده‌اند
+	Unit models.Currency `json:"unit,omitempty"`
+	Rate float64         `json:"rate,omitempty"`
+}
+
+// convert همه ارقام ترازنامه را با یک نرخ تبدیل می‌کند (ترازنامه یک لحظه است)
+func (bs *BalanceSheet) convert(conv *UnitConverter, at time.Time) {
+	if conv == nil {
+		return
+	}
+	bs.Unit, bs.Rate = conv.Unit, conv.RateAt(at)
+	for _, v := range []*float64{
+		&bs.Assets.BankAccounts, &bs.Assets.CashHolders, &bs.Assets.Inventory, &bs.Assets.DepositsReceived,
+		&bs.Assets.Receivables, &bs.Assets.Total,
+		&bs.Liabilities.DepositsPaid, &bs.Liabilities.Payables, &bs.Liabilities.DividendsPayable, &bs.Liabilities.Total,
+		&bs.Equity.Capital, &bs.Equity.RetainedEarnings, &bs.Equity.Total,
+		&bs.TotalAssets, &bs.TotalLiab, &bs.TotalEquity,
+	} {
+		*v = roundMoney(*v / bs.Rate)
+	}
 }
 
-func GetIncomeExpenseReport(db *gorm.DB, period string) ([]IncomeExpenseReport, error) {
+// GetIncomeExpenseReport درآمد و هزینه به تفکیک بازه؛ با conv هر مبلغ با نرخ نزدیک به تاریخ خودش تبدیل می‌شود
+func GetIncomeExpenseReport(db *gorm.DB, period string, conv *UnitConverter) ([]IncomeExpenseReport, error) {
 	var results []IncomeExpenseReport
 	var transactions []models.Transaction
+	from, to := reportRange(period, time.Now())
 
-	// همه تراکنش‌ها رو میاریم (فیلتر میشه بر اساس نوع بعدا)
-	if err := db.Find(&transactions).Error; err != nil {
+	// تراکنش‌های بازه گزارش (فیلتر میشه بر اساس نوع بعدا)
+	if err := db.Where("transaction_date >= ? AND transaction_date < ?", from, to).Find(&transactions).Error; err != nil {
 		return nil, err
 	}
 
@@ -90,34 +138,52 @@ func GetIncomeExpenseReport(db *gorm.DB, period string) ([]IncomeExpenseReport,
 			continue // اگه تاریخ نداشت، رد کن
 		}
 
-		pt := ptime.New(*tx.TransactionDate) // 👈 اینجا dereference کن
-
-		var key string
-		switch period {
-		case "daily":
-			// شنبه = 0
-			key = periods[(int(pt.Weekday())+6)%7]
-		case "weekly":
-			day := pt.Day()
-			week := (day-1)/7 + 1
-			if week > 4 {
-				week = 4
-			}
-			key = periods[week-1]
-		default: // monthly
-			key = periods[pt.Month()-1]
-		}
+		key := periodKey(period, periods, *tx.TransactionDate)
 
+		amount := conv.Convert(tx.Amount, *tx.TransactionDate)
 		if tx.TransactionType == "income" {
-			dataMap[key].Income += tx.Amount
+			dataMap[key].Income += amount
 		} else if tx.TransactionType == "expense" {
-			dataMap[key].Expense += tx.Amount
+			dataMap[key].Expense += amount
+		}
+	}
+
+	// کارمزد (هزینه) و سود (درآمد) بانکی
+	var charges []models.BankCharge
+	if err := db.Where("date >= ? AND date < ?", from, to).Find(&charges).Error; err != nil {
+		return nil, err
+	}
+	for _, charge := range charges {
+		key := periodKey(period, periods, charge.Date)
+		amount := conv.Convert(charge.Amount, charge.Date)
+		if charge.Kind == models.BankChargeInterest {
+			dataMap[key].Income += amount
+		} else {
+			dataMap[key].Expense += amount
+		}
+	}
+
+	// اضافه (درآمد) و کسری (هزینه) صندوق در شمارش‌های نهایی
+	var counts []models.CashCount
+	if err := db.Where("status = ? AND difference <> 0 AND posted_at >= ? AND posted_at < ?", models.CashCountPosted, from, to).Find(&counts).Error; err != nil {
+		return nil, err
+	}
+	for _, count := range counts {
+		key := periodKey(period, periods, *count.PostedAt)
+		difference := conv.Convert(count.Difference, *count.PostedAt)
+		if difference > 0 {
+			dataMap[key].Income += difference
+		} else {
+			dataMap[key].Expense -= difference
 		}
 	}
 
 	// محاسبه سود خالص
 	for _, p := range periods {
 		r := dataMap[p]
+		if conv != nil {
+			r.Income, r.Expense = roundMoney(r.Income), roundMoney(r.Expense)
+		}
 		r.NetProfit = r.Income - r.Expense
 		results = append(results, *r)
 	}
@@ -135,6 +201,42 @@ func GetIncomeExpenseReport(db *gorm.DB, period string) ([]IncomeExpenseReport,
 	return results, nil
 }
 
+// periodKey بازه‌ای از periods که تاریخ t در آن قرار می‌گیرد
+// reportRange بازه گزارش درآمد و هزینه (شمسی): daily هفته جاری، weekly ماه جاری و monthly سال جاری
+func reportRange(period string, now time.Time) (from, to time.Time) {
+	pt := ptime.New(now)
+	switch period {
+	case "daily":
+		start := pt.BeginningOfWeek()
+		return start.Time(), start.AddDate(0, 0, 7).Time()
+	case "weekly":
+		start := pt.BeginningOfMonth()
+		return start.Time(), start.AddDate(0, 1, 0).Time()
+	default: // monthly
+		start := pt.BeginningOfYear()
+		return start.Time(), start.AddDate(1, 0, 0).Time()
+	}
+}
+
+func periodKey(period string, periods []string, t time.Time) string {
+	pt := ptime.New(t)
+
+	switch period {
+	case "daily":
+		// شنبه = 0
+		return periods[(int(pt.Weekday())+6)%7]
+	case "weekly":
+		day := pt.Day()
+		week := (day-1)/7 + 1
+		if week > 4 {
+			week = 4
+		}
+		return periods[week-1]
+	default: // monthly
+		return periods[pt.Month()-1]
+	}
+}
+
 func GetLatestTransactions(db *gorm.DB, limit int) ([]models.Transaction, error) {
 	var txs []models.Transaction
 	if err := db.
@@ -151,27 +253,77 @@ func GetTotalBalance(db *gorm.DB) (TotalBalance, error) {
 	var result TotalBalance
 
 	if err := db.Model(&models.BankAccount{}).
-		Select("COALESCE(SUM(balance),0)").Scan(&result.BankBalance).Error; err != nil {
+		Select(carryingValueSQL).Scan(&result.BankBalance).Error; err != nil {
 		return result, err
 	}
 
 	if err := db.Model(&models.CashHolder{}).
-		Select("COALESCE(SUM(balance),0)").Scan(&result.CashHolderBalance).Error; err != nil {
+		Select(carryingValueSQL).Scan(&result.CashHolderBalance).Error; err != nil {
 		return result, err
 	}
 
 	result.Total = result.BankBalance + result.CashHolderBalance
+
+	foreign, err := foreignHoldings(db, time.Now())
+	if err != nil {
+		return result, err
+	}
+	result.Foreign = foreign
+	for _, h := range foreign {
+		result.UnrealisedGain += h.UnrealisedGain
+	}
+	result.UnrealisedGain = roundMoney(result.UnrealisedGain)
+	result.MarketTotal = roundMoney(result.Total + result.UnrealisedGain)
+	return result, nil
+}
+
+// foreignHoldings حساب‌ها و تنخواه‌های ارزی و طلا با ارزش روز در زمان داده‌شده
+func foreignHoldings(db *gorm.DB, at time.Time) ([]ForeignHolding, error) {
+	var banks []models.BankAccount
+	if err := db.Where("currency <> ?", models.CurrencyRial).Order("id").Find(&banks).Error; err != nil {
+		return nil, err
+	}
+	var holders []models.CashHolder
+	if err := db.Where("currency <> ?", models.CurrencyRial).Order("id").Find(&holders).Error; err != nil {
+		return nil, err
+	}
+
+	var result []ForeignHolding
+	for _, b := range banks {
+		result = append(result, ForeignHolding{MoneySourceType: "bank", ID: b.ID, Name: b.BankName + " " + b.AccountNumber,
+			Currency: b.Currency, Balance: b.Balance, BookValue: b.BookValue})
+	}
+	for _, h := range holders {
+		result = append(result, ForeignHolding{MoneySourceType: "cash", ID: h.ID, Name: h.FirstName + " " + h.LastName,
+			Currency: h.Currency, Balance: h.Balance, BookValue: h.BookValue})
+	}
+
+	for i := range result {
+		h := &result[i]
+		rate, err := repositories.RateAt(db, h.Currency, at)
+		if errors.Is(err, repositories.ErrNoRate) {
+			h.MarketValue = h.BookValue
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		h.Rate = rate
+		h.MarketValue = roundMoney(h.Balance * rate)
+		h.UnrealisedGain = roundMoney(h.MarketValue - h.BookValue)
+	}
 	return result, nil
 }
 
-func GetBalanceSheet(db *gorm.DB) (*BalanceSheet, error) {
+// GetBalanceSheet ترازنامه به واحد دفتر؛ با conv به نرخ روز به دلار یا طلا تبدیل می‌شود
+func GetBalanceSheet(db *gorm.DB, conv *UnitConverter) (*BalanceSheet, error) {
 	var result BalanceSheet
 
 	// --- دارایی‌ها ---
 	var bankTotal, cashTotal, inventory, depositReceived, incomeReceivable, subIncome float64
 
-	db.Model(&models.BankAccount{}).Select("COALESCE(SUM(balance),0)").Scan(&bankTotal)
-	db.Model(&models.CashHolder{}).Select("COALESCE(SUM(balance),0)").Scan(&cashTotal)
+	db.Model(&models.BankAccount{}).Select(carryingValueSQL).Scan(&bankTotal)
+	db.Model(&models.CashHolder{}).Select(carryingValueSQL).Scan(&cashTotal)
 	db.Model(&models.ProductService{}).Select("COALESCE(SUM(stock * buying_price),0)").Scan(&inventory)
 
 	// فقط ودیعه‌های دریافتی که هنوز پرداخت نشده‌اند
@@ -214,13 +366,19 @@ func GetBalanceSheet(db *gorm.DB) (*BalanceSheet, error) {
 		Where("sub_transactions.is_paid = ? AND transactions.transaction_type = ?", false, "expense").
 		Select("COALESCE(SUM(sub_transactions.amount),0)").Scan(&subExpense)
 
+	var dividendsPayable float64
+	db.Model(&models.Dividend{}).
+		Where("status = ?", models.DividendPayable).
+		Select("COALESCE(SUM(amount),0)").Scan(&dividendsPayable)
+
 	result.Liabilities.DepositsPaid = depositPaid
 	result.Liabilities.Payables = expensePayables + subExpense
-	result.Liabilities.Total = depositPaid + expensePayables + subExpense
+	result.Liabilities.DividendsPayable = dividendsPayable
+	result.Liabilities.Total = depositPaid + expensePayables + subExpense + dividendsPayable
 
 	// --- سرمایه و سود انباشته ---
 	var shareHolders float64
-	db.Model(&models.Contact{}).Where("type = ?", "shareholder").Select("COALESCE(SUM(amount),0)").Scan(&shareHolders)
+	db.Model(&models.ShareholderProfile{}).Select("COALESCE(SUM(amount),0)").Scan(&shareHolders)
 
 	result.Equity.Capital = shareHolders
 	result.Equity.RetainedEarnings = result.Assets.Total - shareHolders - result.Liabilities.Total
@@ -231,6 +389,7 @@ func GetBalanceSheet(db *gorm.DB) (*BalanceSheet, error) {
 	result.TotalEquity = result.Equity.Total
 	result.TotalAssets = result.TotalLiab + result.TotalEquity
 
+	result.convert(conv, time.Now())
 	return &result, nil
 }
 
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/amirqodi/hgm/internal/models"
+	"github.com/amirqodi/hgm/internal/utils"
+	"github.com/xuri/excelize/v2"
+)
+
+// StatementRowError ردیفی که خوانده نشد (مثلاً ردیف جمع انتهای فایل)
+type StatementRowError struct {
+	RowNumber int    `json:"row_number"`
+	Error     string `json:"error"`
+}
+
+// ParseBankStatement فایل CSV یا XLSX صورتحساب را با نگاشت ستون داده‌شده می‌خواند
+func ParseBankStatement(fileName string, data []byte, mapping *models.StatementColumnMapping) ([]models.BankStatementLine, []StatementRowError, error) {
+	var rows [][]string
+	var err error
+
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".xlsx":
+		rows, err = readXLSXRows(data)
+	case ".csv", ".txt":
+		rows, err = readCSVRows(data, mapping.Delimiter)
+	default:
+		return nil, nil, errors.New("فرمت فایل باید CSV یا XLSX باشد")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if mapping.SkipRows >= len(rows) {
+		return nil, nil, errors.New("فایل صورتحساب خالی است")
+	}
+	header := rows[mapping.SkipRows]
+
+	col := func(spec string) (int, error) {
+		if spec == "" {
+			return -1, nil
+		}
+		return resolveColumn(header, spec)
+	}
+
+	dateCol, err := col(mapping.DateColumn)
+	if err != nil || dateCol < 0 {
+		return nil, nil, fmt.Errorf("ستون تاریخ «%s» در فایل یافت نشد", mapping.DateColumn)
+	}
+	var cols [6]int
+	for i, spec := range []string{mapping.TimeColumn, mapping.DescriptionColumn, mapping.ReferenceColumn,
+		mapping.DebitColumn, mapping.CreditColumn, mapping.AmountColumn} {
+		if cols[i], err = col(spec); err != nil {
+			return nil, nil, err
+		}
+	}
+	timeCol, descCol, refCol, debitCol, creditCol, amountCol := cols[0], cols[1], cols[2], cols[3], cols[4], cols[5]
+	if amountCol < 0 && debitCol < 0 && creditCol < 0 {
+		return nil, nil, errors.New("ستون مبلغ یا ستون‌های برداشت/واریز مشخص نشده است")
+	}
+	balanceCol, err := col(mapping.BalanceColumn)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var result []models.BankStatementLine
+	var rowErrors []StatementRowError
+
+	for i := mapping.SkipRows + 1; i < len(rows); i++ {
+		row := rows[i]
+		rowNumber := i + 1
+		if isEmptyRow(row) {
+			continue
+		}
+
+		dateValue := cell(row, dateCol)
+		if timeCol >= 0 && cell(row, timeCol) != "" {
+			dateValue += " " + cell(row, timeCol)
+		}
+		date, _, err := utils.ParseDate(dateValue)
+		if err != nil {
+			rowErrors = append(rowErrors, StatementRowError{RowNumber: rowNumber, Error: "تاریخ نامعتبر: " + dateValue})
+			continue
+		}
+
+		var amount float64
+		if amountCol >= 0 {
+			if amount, err = utils.ParseAmount(cell(row, amountCol)); err != nil {
+				rowErrors = append(rowErrors, StatementRowError{RowNumber: rowNumber, Error: "مبلغ نامعتبر"})
+				continue
+			}
+		} else {
+			debit, errDebit := utils.ParseAmount(cell(row, debitCol))
+			credit, errCredit := utils.ParseAmount(cell(row, creditCol))
+			if errDebit != nil || errCredit != nil {
+				rowErrors = append(rowErrors, StatementRowError{RowNumber: rowNumber, Error: "مبلغ نامعتبر"})
+				continue
+			}
+			amount = credit - abs(debit)
+		}
+		if amount == 0 {
+			continue
+		}
+
+		parsed := models.BankStatementLine{
+			RowNumber:   rowNumber,
+			Date:        date,
+			Description: strings.TrimSpace(cell(row, descCol)),
+			Reference:   strings.TrimSpace(utils.NormalizeDigits(cell(row, refCol))),
+			Amount:      amount,
+		}
+		if balanceCol >= 0 && cell(row, balanceCol) != "" {
+			if balance, err := utils.ParseAmount(cell(row, balanceCol)); err == nil {
+				parsed.Balance = &balance
+			}
+		}
+		result = append(result, parsed)
+	}
+
+	if len(result) == 0 {
+		return nil, rowErrors, errors.New("هیچ ردیف معتبری در فایل یافت نشد")
+	}
+	return result, rowErrors, nil
+}
+
+func readCSVRows(data []byte, delimiter string) ([][]string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")) // BOM اکسل
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	if delimiter != "" {
+		reader.Comma = []rune(delimiter)[0]
+	}
+
+	var rows [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.New("فایل CSV قابل خواندن نیست")
+		}
+		rows = append(rows, record)
+	}
+	return rows, nil
+}
+
+func readXLSXRows(data []byte) ([][]string, error) {
+	f, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("فایل اکسل قابل خواندن نیست")
+	}
+	defer f.Close()
+
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, errors.New("فایل اکسل خالی است")
+	}
+	return f.GetRows(sheets[0])
+}
+
+// resolveColumn ستون را با شماره (از ۱) یا عنوان سرتیتر پیدا می‌کند
+func resolveColumn(header []string, spec string) (int, error) {
+	if n, err := strconv.Atoi(utils.NormalizeDigits(spec)); err == nil {
+		if n < 1 {
+			return -1, fmt.Errorf("شماره ستون «%s» نامعتبر است", spec)
+		}
+		return n - 1, nil
+	}
+
+	want := utils.NormalizePersianName(spec)
+	for i, h := range header {
+		if utils.NormalizePersianName(h) == want {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("ستون «%s» در فایل یافت نشد", spec)
+}
+
+func cell(row []string, i int) string {
+	if i < 0 || i >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[i])
+}
+
+func isEmptyRow(row []string) bool {
+	for _, v := range row {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(v float64) float64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/amirqodi/hgm/internal/config"
+	"github.com/amirqodi/hgm/internal/database"
+	"github.com/amirqodi/hgm/internal/models"
+	"github.com/amirqodi/hgm/internal/repositories"
+	"github.com/amirqodi/hgm/internal/utils"
+)
+
+var (
+	ErrTwoFactorNotPending  = errors.New("ابتدا ورود دومرحله‌ای را راه‌اندازی کنید")
+	ErrTwoFactorEnabled     = errors.New("ورود دومرحله‌ای قبلاً فعال شده است")
+	ErrTwoFactorDisabled    = errors.New("ورود دومرحله‌ای فعال نیست")
+	ErrInvalidTwoFactorCode = errors.New("کد ورود دومرحله‌ای نادرست است")
+	ErrTwoFactorMandatory   = errors.New("ورود دومرحله‌ای برای نقش شما اجباری است و نمی‌توان آن را غیرفعال کرد")
+	ErrChallengeExpired     = errors.New("مهلت وارد کردن کد تمام شده است؛ دوباره وارد شوید")
+)
+
+const (
+	recoveryCodeCount = 10
+	challengeTTL      = 5 * time.Minute // مهلت وارد کردن کد پس از رمز درست
+)
+
+// TwoFactorSetup اطلاعات افزودن حساب به برنامه احراز هویت (Google Authenticator، Aegis و ...)
+type TwoFactorSetup struct {
+	Secret string `json:"secret"`      // برای وارد کردن دستی
+	URI    string `json:"otpauth_uri"` // otpauth://totp/...
+	QRCode string `json:"qr_code"`     // تصویر PNG به شکل data URI
+}
+
+type TwoFactorStatus struct {
+	Enabled           bool  `json:"enabled"`
+	Required          bool  `json:"required"` // مدیر برای نقش کاربر اجباری کرده است
+	RecoveryCodesLeft int64 `json:"recovery_codes_left"`
+}
+
+// totpIssuer نام نمایش‌داده‌شده در برنامه احراز هویت (TOTP_ISSUER، پیش‌فرض COMPANY_NAME یا HGM)
+func totpIssuer() string {
+	return config.Get("TOTP_ISSUER", config.Get("COMPANY_NAME", "HGM"))
+}
+
+func GetTwoFactorStatus(user *models.User) (*TwoFactorStatus, error) {
+	required, err := repositories.IsTwoFactorRequired(user.Role)
+	if err != nil {
+		return nil, err
+	}
+	status := &TwoFactorStatus{Enabled: user.TwoFactorEnabled, Required: required}
+	if user.TwoFactorEnabled {
+		if status.RecoveryCodesLeft, err = repositories.CountRecoveryCodes(user.ID); err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+// SetupTwoFactor ساخت کلید جدید؛ تا تأیید اولین کد با EnableTwoFactor فعال نمی‌شود
+func SetupTwoFactor(user *models.User) (*TwoFactorSetup, error) {
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorEnabled
+	}
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := repositories.SavePendingTOTPSecret(user.ID, secret); err != nil {
+		return nil, err
+	}
+	setup := &TwoFactorSetup{Secret: secret, URI: utils.TOTPURI(totpIssuer(), user.Username, secret)}
+	if setup.QRCode, err = utils.QRCodeDataURI(setup.URI, 256); err != nil {
+		return nil, err
+	}
+	return setup, nil
+}
+
+// EnableTwoFactor فعال‌سازی با اولین کد برنامه احراز هویت؛ کدهای بازیابی فقط همین‌جا نمایش داده می‌شوند.
+// همه نشست‌های کاربر به‌جز sessionID بسته می‌شوند.
+func EnableTwoFactor(user *models.User, code string, sessionID uint) ([]string, error) {
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrTwoFactorNotPending
+	}
+	if err := verifyTOTP(user, code); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := repositories.EnableTwoFactor(user.ID, hashes, sessionID); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTwoFactor غیرفعال‌سازی توسط خود کاربر با رمز و کد؛ برای نقش‌های اجباری مجاز نیست
+func DisableTwoFactor(user *models.User, password, code string) error {
+	if !user.TwoFactorEnabled {
+		return ErrTwoFactorDisabled
+	}
+	if !utils.CheckPasswordHash(password, user.Password) {
+		return ErrWrongPassword
+	}
+	if required, err := repositories.IsTwoFactorRequired(user.Role); err != nil {
+		return err
+	} else if required {
+		return ErrTwoFactorMandatory
+	}
+	if err := VerifySecondFactor(user, code); err != nil {
+		return err
+	}
+	return repositories.DisableTwoFactor(user.ID)
+}
+
+// RegenerateRecoveryCodes کدهای بازیابی جدید به جای کدهای قبلی
+func RegenerateRecoveryCodes(user *models.User, code string) ([]string, error) {
+	if !user.TwoFactorEnabled {
+		return nil, ErrTwoFactorDisabled
+	}
+	if err := verifyTOTP(user, code); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := repositories.ReplaceRecoveryCodes(user.ID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// ResetTwoFactor غیرفعال‌سازی توسط مدیر (مثلاً گم شدن گوشی)؛ همه نشست‌های کاربر باطل می‌شود
+func ResetTwoFactor(userID uint) error {
+	if err := repositories.DisableTwoFactor(userID); err != nil {
+		return err
+	}
+	return repositories.RevokeUserSessions(userID, database.DB)
+}
+
+// VerifySecondFactor بررسی کد شش رقمی برنامه احراز هویت یا یک کد بازیابی
+func VerifySecondFactor(user *models.User, code string) error {
+	digits := utils.NormalizeDigits(strings.TrimSpace(code))
+	if len(digits) == 6 && strings.Trim(digits, "0123456789") == "" {
+		return verifyTOTP(user, digits)
+	}
+	used, err := repositories.UseRecoveryCode(user.ID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// verifyTOTP بررسی کد و ثبت بازه آن تا همان کد دوباره پذیرفته نشود
+func verifyTOTP(user *models.User, code string) error {
+	step, ok := utils.VerifyTOTP(user.TOTPSecret, code, time.Now(), user.TOTPLastStep)
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+	fresh, err := repositories.UseTOTPStep(user.ID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrInvalidTwoFactorCode
+	}
+	user.TOTPLastStep = step
+	return nil
+}
+
+// newRecoveryCodes کدهای بازیابی به شکل xxxxx-xxxxx و هش آن‌ها
+func newRecoveryCodes() (codes, hashes []string, err error) {
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		if _, err = rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := hex.EncodeToString(b)
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode هش کد بازیابی بدون خط تیره و فاصله و حساسیت به حروف
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(utils.NormalizeDigits(code))
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	return utils.HashToken(code)
+}
+
+// NewLoginChallenge توکن مرحله دوم ورود برای کاربری که رمزش درست بوده است
+func NewLoginChallenge(user *models.User) (string, error) {
+	return utils.GenerateChallengeToken(user.ID, challengeTTL)
+}
+
+// ChallengeUser کاربر توکن مرحله دوم ورود
+func ChallengeUser(challenge string) (*models.User, error) {
+	userID, err := utils.ParseChallengeToken(challenge)
+	if err != nil {
+		return nil, ErrChallengeExpired
+	}
+	user, err := repositories.FindByID(userID)
+	if err != nil || !user.Active || !user.TwoFactorEnabled {
+		return nil, ErrChallengeExpired
+	}
+	return user, nil
+}
+
+// CompleteLogin مرحله دوم ورود؛ نتیجه در سابقه ورود ثبت می‌شود و کد نادرست در قفل موقت شمرده می‌شود
+func CompleteLogin(user *models.User, code string, client LoginClient) error {
+	if err := VerifySecondFactor(user, code); err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			recordLoginAttempt(user.Username, &user.ID, client, models.LoginBadCode)
+		}
+		return err
+	}
+	recordLoginAttempt(user.Username, &user.ID, client, models.LoginSuccess)
+	return nil
+}
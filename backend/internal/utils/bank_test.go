@@ -0,0 +1,111 @@
+package utils
+
+import "testing"
+
+func TestIsValidSheba(t *testing.T) {
+	tests := []struct {
+		name  string
+		sheba string
+		want  bool
+	}{
+		{"valid", "IR820540102680020817909002", true},
+		{"valid lowercase with spaces", "ir82 0540 1026 8002 0817 9090 02", true},
+		{"valid persian digits", "IR۸۲۰۵۴۰۱۰۲۶۸۰۰۲۰۸۱۷۹۰۹۰۰۲", true},
+		{"valid without IR", "820540102680020817909002", true},
+		{"wrong check digits", "IR830540102680020817909002", false},
+		{"changed account digit", "IR820540102680020817909003", false},
+		{"too short", "IR82054010268002081790900", false},
+		{"wrong country", "DE820540102680020817909002", false},
+		{"letters in body", "IR82054010268002081790900A", false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsValidSheba(tt.sheba); got != tt.want {
+				t.Errorf("IsValidSheba(%q) = %v, want %v", tt.sheba, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsValidCardNumber(t *testing.T) {
+	tests := []struct {
+		name string
+		card string
+		want bool
+	}{
+		{"valid", "6037991111111112", true},
+		{"valid with dashes", "6104-3311-1111-1119", true},
+		{"valid persian digits", "۶۲۷۳۵۳۱۱۱۱۱۱۱۱۱۹", true},
+		{"wrong check digit", "6037991111111113", false},
+		{"swapped digits", "6037991111111121", false},
+		{"fifteen digits", "603799111111111", false},
+		{"seventeen digits", "60379911111111120", false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsValidCardNumber(tt.card); got != tt.want {
+				t.Errorf("IsValidCardNumber(%q) = %v, want %v", tt.card, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBankDetection(t *testing.T) {
+	tests := []struct {
+		name  string
+		sheba string
+		card  string
+		want  string
+	}{
+		{"melli", "IR680170000000000001234567", "6037991111111112", "ملی"},
+		{"mellat", "IR160120000000001234567890", "6104331111111119", "ملت"},
+		{"parsian sheba", "IR820540102680020817909002", "", "پارسیان"},
+		{"tejarat card", "", "6273531111111119", "تجارت"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.sheba != "" {
+				if got := ShebaBankName(tt.sheba); got != tt.want {
+					t.Errorf("ShebaBankName(%q) = %q, want %q", tt.sheba, got, tt.want)
+				}
+			}
+			if tt.card != "" {
+				if got := CardBankName(tt.card); got != tt.want {
+					t.Errorf("CardBankName(%q) = %q, want %q", tt.card, got, tt.want)
+				}
+			}
+		})
+	}
+
+	if got := ShebaBankName("IR829990102680020817909002"); got != "" {
+		t.Errorf("unknown bank code: got %q, want empty", got)
+	}
+	if got := CardBankName("1234561111111111"); got != "" {
+		t.Errorf("unknown card bin: got %q, want empty", got)
+	}
+}
+
+func TestAccountMatchesSheba(t *testing.T) {
+	tests := []struct {
+		name    string
+		account string
+		sheba   string
+		want    bool
+	}{
+		{"plain", "1234567", "IR680170000000000001234567", true},
+		{"with dashes", "0102680020-817909-002", "IR820540102680020817909002", true},
+		{"zero padded differently", "102.68.2.8179.9.2", "IR820540102680020817909002", true},
+		{"different account", "999888", "IR680170000000000001234567", false},
+		{"no digits", "abc", "IR680170000000000001234567", false},
+		{"short sheba", "1234567", "IR68017000000000000123456", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AccountMatchesSheba(tt.account, tt.sheba); got != tt.want {
+				t.Errorf("AccountMatchesSheba(%q, %q) = %v, want %v", tt.account, tt.sheba, got, tt.want)
+			}
+		})
+	}
+}
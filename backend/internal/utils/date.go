@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	ptime "github.com/yaa110/go-persian-calendar"
+)
+
+var errInvalidDate = errors.New("invalid date")
+
+// ParseDate تاریخ میلادی (RFC3339 یا 2006-01-02) یا شمسی (1403/05/12) با ساعت اختیاری را می‌خواند.
+// dateOnly مشخص می‌کند که ورودی ساعت نداشته است.
+func ParseDate(value string) (t time.Time, dateOnly bool, err error) {
+	value = strings.TrimSpace(NormalizeDigits(value))
+	if value == "" {
+		return time.Time{}, false, errInvalidDate
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+
+	datePart, timePart, _ := strings.Cut(value, " ")
+	if i := strings.Index(datePart, "T"); i > 0 && timePart == "" {
+		datePart, timePart = datePart[:i], datePart[i+1:]
+	}
+	datePart = strings.NewReplacer("/", "-", ".", "-").Replace(datePart)
+
+	var year, month, day int
+	if _, err := fmt.Sscanf(datePart, "%d-%d-%d", &year, &month, &day); err != nil {
+		return time.Time{}, false, errInvalidDate
+	}
+	// سال دو رقمی در خروجی برخی بانک‌ها (مثلاً 03/05/12)
+	if year < 100 {
+		year += 1400
+	}
+
+	var hour, minute, sec int
+	dateOnly = true
+	if timePart = strings.TrimSpace(timePart); timePart != "" {
+		dateOnly = false
+		parts := strings.Split(timePart, ":")
+		if len(parts) < 2 {
+			return time.Time{}, false, errInvalidDate
+		}
+		if _, err := fmt.Sscanf(strings.Join(parts, " "), "%d %d", &hour, &minute); err != nil {
+			return time.Time{}, false, errInvalidDate
+		}
+		if len(parts) > 2 {
+			fmt.Sscanf(parts[2], "%d", &sec)
+		}
+	}
+
+	if month < 1 || month > 12 || day < 1 || day > 31 || hour > 23 || minute > 59 || sec > 59 {
+		return time.Time{}, false, errInvalidDate
+	}
+
+	// سال‌های کمتر از ۱۷۰۰ شمسی در نظر گرفته می‌شوند
+	if year < 1700 {
+		// روزهای ناموجود (۳۱ در نیمه دوم سال، ۳۰ اسفند سال غیرکبیسه) به ماه بعد نروند
+		pt := ptime.Date(year, ptime.Month(month), day, hour, minute, sec, 0, time.Local)
+		if pt.Day() != day || int(pt.Month()) != month {
+			return time.Time{}, false, errInvalidDate
+		}
+		return pt.Time(), dateOnly, nil
+	}
+
+	t = time.Date(year, time.Month(month), day, hour, minute, sec, 0, time.Local)
+	if t.Day() != day {
+		return time.Time{}, false, errInvalidDate
+	}
+	return t, dateOnly, nil
+}
+
+// FormatJalali تاریخ را به فرم 1403/05/12 شمسی برمی‌گرداند
+func FormatJalali(t time.Time) string {
+	return ptime.New(t).Format("yyyy/MM/dd")
+}
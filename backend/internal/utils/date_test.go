@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDateJalali(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string // تاریخ شمسی پس از تبدیل؛ خالی = نامعتبر
+	}{
+		{"leap year esfand 30", "1403/12/30", "1403/12/30"},
+		{"leap year 1399 esfand 30", "1399/12/30", "1399/12/30"},
+		{"non-leap esfand 30", "1402/12/30", ""},
+		{"non-leap esfand 29", "1402/12/29", "1402/12/29"},
+		{"esfand 31", "1403/12/31", ""},
+		{"shahrivar 31", "1403/06/31", "1403/06/31"},
+		{"mehr 30", "1403/07/30", "1403/07/30"},
+		{"mehr 31", "1403/07/31", ""},
+		{"farvardin 1", "1403/01/01", "1403/01/01"},
+		{"dashes", "1403-05-12", "1403/05/12"},
+		{"persian digits", "۱۴۰۳/۰۵/۱۲", "1403/05/12"},
+		{"two digit year", "03/05/12", "1403/05/12"},
+		{"month 13", "1403/13/01", ""},
+		{"day 0", "1403/05/00", ""},
+		{"garbage", "tomorrow", ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, dateOnly, err := ParseDate(tt.value)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("ParseDate(%q) = %s, want error", tt.value, FormatJalali(got))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseDate(%q): %v", tt.value, err)
+			}
+			if !dateOnly {
+				t.Errorf("ParseDate(%q): dateOnly = false", tt.value)
+			}
+			if s := FormatJalali(got); s != tt.want {
+				t.Errorf("ParseDate(%q) = %s, want %s", tt.value, s, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDateGregorian(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		want     time.Time
+		dateOnly bool
+		wantErr  bool
+	}{
+		{"leap day", "2024-02-29", time.Date(2024, 2, 29, 0, 0, 0, 0, time.Local), true, false},
+		{"non-leap feb 29", "2023-02-29", time.Time{}, false, true},
+		{"april 31", "2024-04-31", time.Time{}, false, true},
+		{"december 31", "2024-12-31", time.Date(2024, 12, 31, 0, 0, 0, 0, time.Local), true, false},
+		{"with time", "2024-05-01 14:30", time.Date(2024, 5, 1, 14, 30, 0, 0, time.Local), false, false},
+		{"rfc3339", "2024-05-01T10:00:00Z", time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), false, false},
+		{"hour 24", "2024-05-01 24:00", time.Time{}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, dateOnly, err := ParseDate(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseDate(%q) = %v, want error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseDate(%q): %v", tt.value, err)
+			}
+			if !got.Equal(tt.want) || dateOnly != tt.dateOnly {
+				t.Errorf("ParseDate(%q) = (%v, %v), want (%v, %v)", tt.value, got, dateOnly, tt.want, tt.dateOnly)
+			}
+		})
+	}
+}
+
+func TestParseDateJalaliTime(t *testing.T) {
+	got, dateOnly, err := ParseDate("1403/05/12 08:15:30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dateOnly {
+		t.Error("dateOnly = true, want false")
+	}
+	if got.Hour() != 8 || got.Minute() != 15 || got.Second() != 30 || FormatJalali(got) != "1403/05/12" {
+		t.Errorf("got %s %s", FormatJalali(got), got.Format("15:04:05"))
+	}
+}
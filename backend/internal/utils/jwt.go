@@ -1,26 +1,75 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"os"
 	"time"
 
+	"github.com/amirqodi/hgm/internal/config"
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// devJwtSecret فقط برای توسعه؛ در production راه‌اندازی بدون JWT_SECRET متوقف می‌شود
+const devJwtSecret = "supersecretkey"
+
+const minJwtSecretLength = 32
+
 func JwtSecret() []byte {
 	secret := os.Getenv("JWT_SECRET")
 	if secret == "" {
-		secret = "supersecretkey" // fallback
+		secret = devJwtSecret // fallback
 	}
 	return []byte(secret)
 }
 
-func GenerateJWT(userID uint, username string) (string, error) {
+// CheckJwtSecret در حالت production (APP_ENV=production) کلید JWT_SECRET باید تنظیم شده،
+// غیر از کلید پیش‌فرض و حداقل ۳۲ نویسه باشد
+func CheckJwtSecret() error {
+	if !config.IsProduction() {
+		return nil
+	}
+	secret := os.Getenv("JWT_SECRET")
+	if secret == "" || secret == devJwtSecret || len(secret) < minJwtSecretLength {
+		return fmt.Errorf("JWT_SECRET must be set to a random value of at least %d characters in production", minJwtSecretLength)
+	}
+	return nil
+}
+
+// envDuration مدت زمان از متغیر محیطی؛ مقدار نامعتبر = fallback
+func envDuration(key string, fallback time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		fmt.Printf("Invalid %s, using %s: %s\n", key, fallback, v)
+		return fallback
+	}
+	return d
+}
+
+// AccessTokenTTL عمر توکن دسترسی (ACCESS_TOKEN_TTL، پیش‌فرض ۱۵ دقیقه)
+func AccessTokenTTL() time.Duration {
+	return envDuration("ACCESS_TOKEN_TTL", 15*time.Minute)
+}
+
+// RefreshTokenTTL عمر نشست و توکن تازه‌سازی (REFRESH_TOKEN_TTL، پیش‌فرض ۳۰ روز)
+func RefreshTokenTTL() time.Duration {
+	return envDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+}
+
+// GenerateJWT توکن دسترسی کوتاه‌عمر برای نشست sessionID
+func GenerateJWT(userID uint, username string, sessionID uint, ttl time.Duration) (string, error) {
 	claims := jwt.MapClaims{
 		"user_id":  userID,
 		"username": username,
-		"exp":      time.Now().Add(time.Hour * 24).Unix(),
+		"sid":      sessionID,
+		"exp":      time.Now().Add(ttl).Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -45,3 +94,46 @@ func ParseJWT(tokenStr string) (*jwt.MapClaims, error) {
 
 	return nil, errors.New("invalid token")
 }
+
+// NewRefreshToken توکن تصادفی تازه‌سازی و هش آن برای ذخیره در دیتابیس
+func NewRefreshToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = hex.EncodeToString(b)
+	return token, HashToken(token), nil
+}
+
+// HashToken هش sha256 توکن تازه‌سازی
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// twoFactorChallengePurpose توکن مرحله دوم ورود؛ بدون sid است پس به‌جای توکن دسترسی پذیرفته نمی‌شود
+const twoFactorChallengePurpose = "2fa"
+
+// GenerateChallengeToken توکن کوتاه‌عمر پس از رمز درست، برای ارسال کد ورود دومرحله‌ای
+func GenerateChallengeToken(userID uint, ttl time.Duration) (string, error) {
+	claims := jwt.MapClaims{
+		"user_id": userID,
+		"purpose": twoFactorChallengePurpose,
+		"exp":     time.Now().Add(ttl).Unix(),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(JwtSecret())
+}
+
+// ParseChallengeToken کاربر توکن مرحله دوم ورود
+func ParseChallengeToken(tokenStr string) (uint, error) {
+	claims, err := ParseJWT(tokenStr)
+	if err != nil {
+		return 0, err
+	}
+	userID, ok := (*claims)["user_id"].(float64)
+	if (*claims)["purpose"] != twoFactorChallengePurpose || !ok {
+		return 0, errors.New("invalid challenge token")
+	}
+	return uint(userID), nil
+}
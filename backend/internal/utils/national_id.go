@@ -0,0 +1,62 @@
+package utils
+
+// IsValidNationalID اعتبارسنجی کد ملی ۱۰ رقمی اشخاص حقیقی با رقم کنترل
+func IsValidNationalID(code string) bool {
+	code = NormalizeDigits(code)
+	if len(code) != 10 {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	// کدهای تکراری مانند 1111111111 معتبر نیستند
+	allSame := true
+	for i := 1; i < 10; i++ {
+		if code[i] != code[0] {
+			allSame = false
+			break
+		}
+	}
+	if allSame {
+		return false
+	}
+
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += int(code[i]-'0') * (10 - i)
+	}
+	remainder := sum % 11
+	check := int(code[9] - '0')
+	if remainder < 2 {
+		return check == remainder
+	}
+	return check == 11-remainder
+}
+
+// IsValidLegalNationalID اعتبارسنجی شناسه ملی ۱۱ رقمی اشخاص حقوقی
+func IsValidLegalNationalID(code string) bool {
+	code = NormalizeDigits(code)
+	if len(code) != 11 {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	coefficients := []int{29, 27, 23, 19, 17, 29, 27, 23, 19, 17}
+	decimal := int(code[9]-'0') + 2
+	sum := 0
+	for i := 0; i < 10; i++ {
+		sum += (int(code[i]-'0') + decimal) * coefficients[i]
+	}
+	remainder := sum % 11
+	if remainder == 10 {
+		remainder = 0
+	}
+	return int(code[10]-'0') == remainder
+}
@@ -0,0 +1,186 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// NormalizeDigits ارقام فارسی و عربی را به ارقام انگلیسی تبدیل می‌کند
+func NormalizeDigits(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		switch {
+		case r >= '۰' && r <= '۹':
+			b.WriteRune('0' + (r - '۰'))
+		case r >= '٠' && r <= '٩':
+			b.WriteRune('0' + (r - '٠'))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// OnlyDigits فقط ارقام (پس از تبدیل ارقام فارسی) را نگه می‌دارد
+func OnlyDigits(s string) string {
+	var b strings.Builder
+	for _, r := range NormalizeDigits(s) {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// NormalizeIranianMobile شماره موبایل را به فرم 09xxxxxxxxx تبدیل می‌کند
+// (ورودی می‌تواند با +98، 0098، 98 یا بدون صفر و با ارقام فارسی باشد).
+// اگر شماره موبایل معتبر نباشد، فقط ارقام آن برگردانده می‌شود.
+func NormalizeIranianMobile(phone string) string {
+	digits := OnlyDigits(phone)
+
+	switch {
+	case strings.HasPrefix(digits, "0098"):
+		digits = "0" + digits[4:]
+	case strings.HasPrefix(digits, "98") && len(digits) == 12:
+		digits = "0" + digits[2:]
+	case strings.HasPrefix(digits, "9") && len(digits) == 10:
+		digits = "0" + digits
+	}
+
+	return digits
+}
+
+// IsIranianMobile بررسی فرم 09xxxxxxxxx
+func IsIranianMobile(phone string) bool {
+	n := NormalizeIranianMobile(phone)
+	return len(n) == 11 && strings.HasPrefix(n, "09")
+}
+
+// NormalizePersianName یکسان‌سازی حروف عربی/فارسی، حذف نیم‌فاصله و اعراب برای مقایسه نام‌ها
+func NormalizePersianName(name string) string {
+	replacer := strings.NewReplacer(
+		"ي", "ی", "ى", "ی", "ئ", "ی",
+		"ك", "ک",
+		"ة", "ه", "ۀ", "ه",
+		"أ", "ا", "إ", "ا", "آ", "ا",
+		"ؤ", "و",
+		"‌", " ", "‏", "", "‎", "",
+	)
+	name = replacer.Replace(NormalizeDigits(name))
+
+	var b strings.Builder
+	for _, r := range name {
+		// حذف اعراب (فتحه، کسره، تشدید و ...)
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// NameSimilarity شباهت دو نام بین ۰ تا ۱ بر اساس فاصله ویرایشی (Levenshtein)
+func NameSimilarity(a, b string) float64 {
+	ra := []rune(NormalizePersianName(a))
+	rb := []rune(NormalizePersianName(b))
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1
+	}
+
+	maxLen := len(ra)
+	if len(rb) > maxLen {
+		maxLen = len(rb)
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(maxLen)
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// ParseAmount مبلغ نوشته‌شده با ارقام فارسی، جداکننده هزارگان و علامت منفی یا پرانتز را می‌خواند
+func ParseAmount(s string) (float64, error) {
+	s = strings.TrimSpace(NormalizeDigits(s))
+	if s == "" || s == "-" {
+		return 0, nil
+	}
+
+	negative := false
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		negative = true
+		s = s[1 : len(s)-1]
+	}
+	if strings.HasSuffix(s, "-") {
+		negative = true
+		s = strings.TrimSuffix(s, "-")
+	}
+	if strings.HasPrefix(s, "-") {
+		negative = !negative
+		s = strings.TrimPrefix(s, "-")
+	}
+
+	s = strings.NewReplacer(",", "", "٬", "", "،", "", " ", "", "‌", "", "٫", ".").Replace(s)
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if negative {
+		v = -v
+	}
+	return v, nil
+}
+
+// PersianDigits ارقام انگلیسی را به ارقام فارسی تبدیل می‌کند
+func PersianDigits(s string) string {
+	var b strings.Builder
+	b.Grow(len(s) * 2)
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune('۰' + (r - '0'))
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// GroupThousands بخش صحیح عدد را با جداکننده هزارگان می‌نویسد (مثلاً 1,250,000)
+func GroupThousands(v float64, decimals int, sep string) string {
+	s := strconv.FormatFloat(v, 'f', decimals, 64)
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	intPart, frac, _ := strings.Cut(s, ".")
+
+	var b strings.Builder
+	for i, r := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteString(sep)
+		}
+		b.WriteRune(r)
+	}
+	if frac != "" {
+		b.WriteString("." + frac)
+	}
+	return sign + b.String()
+}
@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// SignRecord امضای HMAC-SHA256 یک رکورد با کلید سرور (JWT_SECRET)
+func SignRecord(payload string) string {
+	mac := hmac.New(sha256.New, JwtSecret())
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyRecordSignature بررسی اینکه رکورد پس از امضا تغییر نکرده است
+func VerifyRecordSignature(payload, signature string) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, JwtSecret())
+	mac.Write([]byte(payload))
+	return hmac.Equal(mac.Sum(nil), expected)
+}
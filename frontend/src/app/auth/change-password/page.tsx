@@ -0,0 +1,12 @@
+import ChangePasswordForm from "@/components/pages/auth/ChangePasswordForm";
+import React from "react";
+
+const page = () => {
+  return (
+    <div className="w-full grid place-items-center">
+      <ChangePasswordForm />
+    </div>
+  );
+};
+
+export default page;
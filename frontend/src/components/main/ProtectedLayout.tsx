@@ -2,6 +2,7 @@
 import { useEffect } from "react";
 import { useRouter } from "next/navigation";
 import Cookies from "js-cookie";
+import { useTokenRefresh } from "@/hooks/useTokenRefresh";
 
 export default function ProtectedLayout({
   children,
@@ -9,13 +10,27 @@ export default function ProtectedLayout({
   children: React.ReactNode;
 }) {
   const router = useRouter();
+  useTokenRefresh();
 
   useEffect(() => {
     const token = Cookies.get("auth_token");
 
     if (!token) {
       router.push("/auth");
+      return;
     }
+
+    // تا رمز عوض نشود بک‌اند بقیه درخواست‌ها را رد می‌کند
+    fetch(`${process.env.NEXT_PUBLIC_API_URL}/api/auth/me`, {
+      headers: { Authorization: `Bearer ${token}` },
+    })
+      .then((res) => (res.ok ? res.json() : null))
+      .then((me) => {
+        if (me?.must_change_password) {
+          router.push("/auth/change-password");
+        }
+      })
+      .catch(() => undefined);
   }, [router]);
 
   return <>{children}</>;
@@ -3,7 +3,7 @@
 
 import Link from "next/link";
 import { usePathname } from "next/navigation";
-import Cookies from "js-cookie";
+import { logout } from "@/lib/session";
 import { useState, useEffect } from "react";
 import { motion, AnimatePresence } from "framer-motion";
 import {
@@ -108,7 +108,7 @@ const Sidebar = () => {
     setOpenMenus((prev) => ({ ...prev, [index]: !prev[index] }));
   };
   const handleLogOut = async () => {
-    Cookies.remove("auth_token");
+    await logout();
     router.push("/auth");
   };
 
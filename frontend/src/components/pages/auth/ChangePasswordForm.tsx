@@ -0,0 +1,146 @@
+"use client";
+
+import { useRouter } from "next/navigation";
+import { useForm } from "react-hook-form";
+import { z } from "zod";
+import { zodResolver } from "@hookform/resolvers/zod";
+import { motion } from "framer-motion";
+import { Lock } from "lucide-react";
+import { useEffect, useState } from "react";
+import Cookies from "js-cookie";
+import { useNotification } from "@/components/main/NotificationProvider";
+import { TOKEN_COOKIE } from "@/lib/auth";
+
+// ✅ Zod validation schema (سیاست رمز در بک‌اند بررسی می‌شود)
+const changePasswordSchema = z
+  .object({
+    current_password: z.string().min(1, "رمز فعلی الزامی است"),
+    new_password: z.string().min(1, "رمز جدید الزامی است"),
+    confirm_password: z.string(),
+  })
+  .refine((data) => data.new_password === data.confirm_password, {
+    path: ["confirm_password"],
+    message: "تکرار رمز با رمز جدید یکسان نیست",
+  });
+
+type ChangePasswordFormData = z.infer<typeof changePasswordSchema>;
+
+const fields: { name: keyof ChangePasswordFormData; label: string }[] = [
+  { name: "current_password", label: "رمز فعلی" },
+  { name: "new_password", label: "رمز جدید" },
+  { name: "confirm_password", label: "تکرار رمز جدید" },
+];
+
+// تغییر رمز اجباری پس از ورود با رمز پیش‌فرض یا بازنشانی‌شده
+export default function ChangePasswordForm() {
+  const router = useRouter();
+  const [serverError, setServerError] = useState("");
+  const API_URL = process.env.NEXT_PUBLIC_API_URL;
+  const { notify } = useNotification();
+
+  const {
+    register,
+    handleSubmit,
+    formState: { errors, isSubmitting },
+  } = useForm<ChangePasswordFormData>({
+    resolver: zodResolver(changePasswordSchema),
+  });
+
+  useEffect(() => {
+    if (!Cookies.get(TOKEN_COOKIE)) {
+      router.push("/auth");
+    }
+  }, [router]);
+
+  const onSubmit = async (data: ChangePasswordFormData) => {
+    try {
+      setServerError("");
+
+      const res = await fetch(`${API_URL}/api/auth/change-password`, {
+        method: "POST",
+        headers: {
+          "Content-Type": "application/json",
+          Authorization: `Bearer ${Cookies.get(TOKEN_COOKIE)}`,
+        },
+        body: JSON.stringify({
+          current_password: data.current_password,
+          new_password: data.new_password,
+        }),
+      });
+
+      if (res.status === 401) {
+        router.push("/auth");
+        return;
+      }
+      if (!res.ok) {
+        const result = await res.json();
+        setServerError(result.error || "مشکلی پیش آمد");
+        return;
+      }
+
+      notify("success", "رمز عبور با موفقیت تغییر کرد");
+      router.push("/");
+    } catch (err: any) {
+      console.log(err);
+      setServerError(err.error || "مشکلی پیش آمد");
+    }
+  };
+
+  return (
+    <div className="flex items-center justify-center min-h-screen bg-background">
+      <motion.form
+        onSubmit={handleSubmit(onSubmit)}
+        initial={{ opacity: 0, y: 40 }}
+        animate={{ opacity: 1, y: 0 }}
+        transition={{ duration: 0.5 }}
+        className="bg-box p-8 rounded-2xl shadow-2xl w-lg"
+      >
+        <h1 className="text-3xl font-bold mb-6 text-foreground text-center">
+          تغییر رمز عبور
+        </h1>
+        <p className="text-muted text-sm mb-12 text-center">
+          پیش از ادامه، رمز عبور خود را عوض کنید.
+        </p>
+
+        {serverError && (
+          <p className="text-destructive text-sm mb-4 text-center">
+            {serverError}
+          </p>
+        )}
+
+        {fields.map((field) => (
+          <div className="mb-8" key={field.name}>
+            <label className="block mb-1 text-sm font-medium text-foreground">
+              {field.label}
+            </label>
+            <div className="relative">
+              <Lock className="absolute left-3 top-2.5 text-muted" size={18} />
+              <input
+                type="password"
+                placeholder={field.label}
+                {...register(field.name)}
+                className="w-full pl-10 pr-3 py-2 border rounded-lg bg-background text-foreground focus:outline-none focus:ring-2 focus:ring-primary"
+                dir="ltr"
+              />
+            </div>
+            {errors[field.name] && (
+              <p className="text-destructive text-xs mt-1">
+                {errors[field.name]?.message}
+              </p>
+            )}
+          </div>
+        ))}
+
+        {/* Submit */}
+        <motion.button
+          type="submit"
+          disabled={isSubmitting}
+          whileTap={{ scale: 0.95 }}
+          className="w-full bg-primary text-white py-2 rounded-lg hover:brightness-110 transition disabled:opacity-50"
+        >
+          {isSubmitting ? "در حال ذخیره..." : "تغییر رمز"}
+        </motion.button>
+      </motion.form>
+    </div>
+  );
+}
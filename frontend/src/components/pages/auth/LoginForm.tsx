@@ -8,8 +8,10 @@ import { zodResolver } from "@hookform/resolvers/zod";
 import { motion } from "framer-motion";
 import { User, Lock, Eye, EyeOff } from "lucide-react";
 import { useState } from "react";
-import Cookies from "js-cookie";
 import { useNotification } from "@/components/main/NotificationProvider";
+import { saveSession } from "@/lib/session";
+import { AuthTokens } from "@/lib/auth";
+import TwoFactorForm from "@/components/pages/auth/TwoFactorForm";
 
 // ✅ Zod validation schema
 const loginSchema = z.object({
@@ -23,6 +25,7 @@ export default function LoginPage() {
   const router = useRouter();
   const [serverError, setServerError] = useState("");
   const [showPassword, setShowPassword] = useState(false);
+  const [challengeToken, setChallengeToken] = useState("");
   const API_URL = process.env.NEXT_PUBLIC_API_URL;
   const { notify } = useNotification();
 
@@ -35,6 +38,12 @@ export default function LoginPage() {
     resolver: zodResolver(loginSchema),
   });
 
+  const finishLogin = (tokens: AuthTokens) => {
+    saveSession(tokens);
+    // رمز پیش‌فرض یا بازنشانی‌شده → اول تغییر رمز
+    router.push(tokens.must_change_password ? "/auth/change-password" : "/");
+  };
+
   const onSubmit = async (data: LoginFormData) => {
     try {
       setServerError("");
@@ -67,10 +76,15 @@ export default function LoginPage() {
         return;
       }
 
+      // ورود دومرحله‌ای → مرحله دوم با کد
+      if (result?.two_factor_required) {
+        setChallengeToken(result.challenge_token);
+        return;
+      }
+
       // اگر موفق بود
       if (result?.token) {
-        Cookies.set("auth_token", result.token, { expires: 1 });
-        router.push("/");
+        finishLogin(result);
         return;
       }
     } catch (err: any) {
@@ -79,6 +93,18 @@ export default function LoginPage() {
     }
   };
 
+  if (challengeToken) {
+    return (
+      <div className="flex items-center justify-center min-h-screen bg-background">
+        <TwoFactorForm
+          challengeToken={challengeToken}
+          onSuccess={finishLogin}
+          onCancel={() => setChallengeToken("")}
+        />
+      </div>
+    );
+  }
+
   return (
     <div className="flex items-center justify-center min-h-screen bg-background">
       <motion.form
@@ -0,0 +1,122 @@
+"use client";
+
+import { useForm } from "react-hook-form";
+import { z } from "zod";
+import { zodResolver } from "@hookform/resolvers/zod";
+import { motion } from "framer-motion";
+import { KeyRound } from "lucide-react";
+import { useState } from "react";
+import { AuthTokens } from "@/lib/auth";
+
+// کد شش‌رقمی برنامه احراز هویت یا کد بازیابی
+const twoFactorSchema = z.object({
+  code: z.string().trim().min(6, "کد ورود دومرحله‌ای را وارد کنید"),
+});
+
+type TwoFactorFormData = z.infer<typeof twoFactorSchema>;
+
+// مرحله دوم ورود با challenge_token مرحله اول
+export default function TwoFactorForm({
+  challengeToken,
+  onSuccess,
+  onCancel,
+}: {
+  challengeToken: string;
+  onSuccess: (tokens: AuthTokens) => void;
+  onCancel: () => void;
+}) {
+  const [serverError, setServerError] = useState("");
+  const API_URL = process.env.NEXT_PUBLIC_API_URL;
+
+  const {
+    register,
+    handleSubmit,
+    formState: { errors, isSubmitting },
+  } = useForm<TwoFactorFormData>({
+    resolver: zodResolver(twoFactorSchema),
+  });
+
+  const onSubmit = async (data: TwoFactorFormData) => {
+    try {
+      setServerError("");
+
+      const res = await fetch(`${API_URL}/api/auth/login/2fa`, {
+        method: "POST",
+        headers: { "Content-Type": "application/json" },
+        credentials: "include",
+        body: JSON.stringify({ challenge_token: challengeToken, code: data.code }),
+      });
+      const result = await res.json();
+
+      if (!res.ok) {
+        setServerError(result.error || "مشکلی پیش آمد");
+        return;
+      }
+      onSuccess(result);
+    } catch (err: any) {
+      console.log(err);
+      setServerError(err.error || "مشکلی پیش آمد");
+    }
+  };
+
+  return (
+    <motion.form
+      onSubmit={handleSubmit(onSubmit)}
+      initial={{ opacity: 0, y: 40 }}
+      animate={{ opacity: 1, y: 0 }}
+      transition={{ duration: 0.5 }}
+      className="bg-box p-8 rounded-2xl shadow-2xl w-lg"
+    >
+      <h1 className="text-3xl font-bold mb-6 text-foreground text-center">
+        ورود دومرحله‌ای
+      </h1>
+      <p className="text-muted text-sm mb-12 text-center">
+        کد برنامه احراز هویت یا یکی از کدهای بازیابی را وارد کنید.
+      </p>
+
+      {serverError && (
+        <p className="text-destructive text-sm mb-4 text-center">
+          {serverError}
+        </p>
+      )}
+
+      <div className="mb-8">
+        <label className="block mb-1 text-sm font-medium text-foreground">
+          کد
+        </label>
+        <div className="relative">
+          <KeyRound className="absolute left-3 top-2.5 text-muted" size={18} />
+          <input
+            type="text"
+            inputMode="numeric"
+            autoComplete="one-time-code"
+            autoFocus
+            placeholder="123456"
+            {...register("code")}
+            className="w-full pl-10 pr-3 py-2 border rounded-lg bg-background text-foreground focus:outline-none focus:ring-2 focus:ring-primary"
+            dir="ltr"
+          />
+        </div>
+        {errors.code && (
+          <p className="text-destructive text-xs mt-1">{errors.code.message}</p>
+        )}
+      </div>
+
+      <motion.button
+        type="submit"
+        disabled={isSubmitting}
+        whileTap={{ scale: 0.95 }}
+        className="w-full bg-primary text-white py-2 rounded-lg hover:brightness-110 transition disabled:opacity-50"
+      >
+        {isSubmitting ? "در حال بررسی..." : "تأیید"}
+      </motion.button>
+      <button
+        type="button"
+        onClick={onCancel}
+        className="w-full mt-4 text-sm text-muted hover:text-foreground"
+      >
+        بازگشت به ورود
+      </button>
+    </motion.form>
+  );
+}
@@ -102,6 +102,8 @@ export default function BankAccountForm({
       const result = await res.json();
       if (result) {
         notify("success", "بانک با موفقیت ثبت شد");
+        // ناهمخوانی شماره حساب با شبا مانع ثبت نیست
+        result.warnings?.forEach((w: string) => notify("info", w));
       }
     } catch (err: any) {
       notify("error", err);
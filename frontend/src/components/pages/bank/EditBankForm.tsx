@@ -127,7 +127,9 @@ export default function EditBankAccountForm() {
         return;
       }
 
+      const result = await res.json();
       notify("success", "اطلاعات حساب با موفقیت بروزرسانی شد ✅");
+      result.warnings?.forEach((w: string) => notify("info", w));
       router.back();
     } catch (err: any) {
       notify("error", "مشکلی پیش آمد ❌");
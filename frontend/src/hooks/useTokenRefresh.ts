@@ -0,0 +1,55 @@
+"use client";
+
+import { useEffect } from "react";
+import Cookies from "js-cookie";
+import { useRouter } from "next/navigation";
+import { EXPIRES_COOKIE, REFRESH_COOKIE, refreshTokens } from "@/lib/auth";
+import { clearSession, saveSession } from "@/lib/session";
+
+// حاشیه تازه‌سازی پیش از انقضای توکن دسترسی
+const REFRESH_MARGIN = 60_000;
+
+/**
+ * تمدید توکن دسترسی پیش از انقضا تا درخواست‌های صفحه با 401 روبه‌رو نشوند.
+ * توکن تازه‌سازی یک‌بارمصرف است؛ تب‌ها با تأخیر تصادفی و بررسی دوباره کوکی
+ * از تازه‌سازی هم‌زمان با یک توکن (که نشست را لغو می‌کند) پرهیز می‌کنند.
+ */
+export function useTokenRefresh() {
+  const router = useRouter();
+
+  useEffect(() => {
+    let timer: ReturnType<typeof setTimeout>;
+
+    const expiresIn = () =>
+      Number(Cookies.get(EXPIRES_COOKIE) || 0) - Date.now();
+
+    const schedule = () => {
+      const delay = expiresIn() - REFRESH_MARGIN - Math.random() * 30_000;
+      timer = setTimeout(run, Math.max(delay, 0));
+    };
+
+    const run = async () => {
+      // تب دیگری پیش‌تر تازه کرده است
+      if (expiresIn() > REFRESH_MARGIN) return schedule();
+
+      const refreshToken = Cookies.get(REFRESH_COOKIE);
+      if (!refreshToken) return;
+
+      const tokens = await refreshTokens(
+        process.env.NEXT_PUBLIC_API_URL,
+        refreshToken
+      );
+      if (tokens) {
+        saveSession(tokens);
+        return schedule();
+      }
+      if (Cookies.get(REFRESH_COOKIE) !== refreshToken) return schedule();
+
+      clearSession();
+      router.push("/auth");
+    };
+
+    schedule();
+    return () => clearTimeout(timer);
+  }, [router]);
+}
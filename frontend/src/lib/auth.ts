@@ -0,0 +1,62 @@
+import type { NextResponse } from "next/server";
+
+// کوکی‌های نشست؛ توکن دسترسی کوتاه‌عمر است و با توکن تازه‌سازی تمدید می‌شود
+export const TOKEN_COOKIE = "auth_token";
+export const REFRESH_COOKIE = "refresh_token";
+export const EXPIRES_COOKIE = "auth_expires_at"; // زمان انقضای توکن دسترسی (میلی‌ثانیه)
+
+// عمر کوکی‌ها به روز؛ هم‌اندازه عمر پیش‌فرض نشست در بک‌اند (REFRESH_TOKEN_TTL)
+export const SESSION_DAYS = 30;
+
+// پاسخ ورود و تازه‌سازی بک‌اند
+export type AuthTokens = {
+  token: string;
+  refresh_token: string;
+  expires_in: number; // ثانیه
+  must_change_password?: boolean;
+};
+
+export function tokenCookies(tokens: AuthTokens): [string, string][] {
+  return [
+    [TOKEN_COOKIE, tokens.token],
+    [REFRESH_COOKIE, tokens.refresh_token],
+    [EXPIRES_COOKIE, String(Date.now() + tokens.expires_in * 1000)],
+  ];
+}
+
+/**
+ * گرفتن توکن دسترسی جدید؛ توکن تازه‌سازی هم عوض می‌شود و توکن قبلی دیگر معتبر نیست
+ * @returns null اگر نشست منقضی یا لغو شده باشد
+ */
+export async function refreshTokens(
+  apiUrl: string | undefined,
+  refreshToken: string
+): Promise<AuthTokens | null> {
+  try {
+    const res = await fetch(`${apiUrl}/api/auth/refresh`, {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ refresh_token: refreshToken }),
+    });
+    if (!res.ok) return null;
+    return await res.json();
+  } catch {
+    return null;
+  }
+}
+
+// ذخیره توکن‌های جدید در پاسخ middleware یا proxy
+export function setTokenCookies(response: NextResponse, tokens: AuthTokens) {
+  for (const [name, value] of tokenCookies(tokens)) {
+    response.cookies.set(name, value, {
+      path: "/",
+      maxAge: SESSION_DAYS * 24 * 60 * 60,
+    });
+  }
+}
+
+export function clearTokenCookies(response: NextResponse) {
+  for (const name of [TOKEN_COOKIE, REFRESH_COOKIE, EXPIRES_COOKIE]) {
+    response.cookies.delete(name);
+  }
+}
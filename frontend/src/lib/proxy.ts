@@ -1,4 +1,12 @@
 import { NextRequest, NextResponse } from "next/server";
+import {
+  AuthTokens,
+  REFRESH_COOKIE,
+  TOKEN_COOKIE,
+  clearTokenCookies,
+  refreshTokens,
+  setTokenCookies,
+} from "@/lib/auth";
 
 /**
  * Generic proxy handler
@@ -11,28 +19,44 @@ export async function proxy(
   path: string,
   options: RequestInit = {}
 ) {
-  const token = req.cookies.get("auth_token")?.value;
+  const send = (token?: string) =>
+    fetch(`${process.env.API_URL}${path}`, {
+      ...options,
+      headers: {
+        ...(options.headers || {}),
+        Authorization: token ? `Bearer ${token}` : "",
+      },
+    });
 
-  const res = await fetch(`${process.env.API_URL}${path}`, {
-    ...options,
-    headers: {
-      ...(options.headers || {}),
-      Authorization: token ? `Bearer ${token}` : "",
-    },
-  });
+  let res = await send(req.cookies.get(TOKEN_COOKIE)?.value);
 
-  // اگر توکن معتبر نباشه
+  // توکن دسترسی منقضی شده → یک بار تمدید و تکرار درخواست
+  let tokens: AuthTokens | null = null;
+  if (res.status === 401) {
+    const refreshToken = req.cookies.get(REFRESH_COOKIE)?.value;
+    if (refreshToken) {
+      tokens = await refreshTokens(process.env.API_URL, refreshToken);
+    }
+    if (tokens) {
+      res = await send(tokens.token);
+    }
+  }
+
+  // اگر نشست معتبر نباشه
   if (res.status === 401) {
-    // می‌تونی اینجا مستقیم redirect کنی یا خطا بدی
     const url = req.nextUrl.clone();
     url.pathname = "/auth";
 
-    // کوکی رو هم پاک می‌کنیم
+    // کوکی‌ها رو هم پاک می‌کنیم
     const response = NextResponse.redirect(url);
-    response.cookies.delete("auth_token");
+    clearTokenCookies(response);
     return response;
   }
 
   const data = await res.json();
-  return NextResponse.json(data, { status: res.status });
+  const response = NextResponse.json(data, { status: res.status });
+  if (tokens) {
+    setTokenCookies(response, tokens);
+  }
+  return response;
 }
@@ -0,0 +1,36 @@
+"use client";
+
+import Cookies from "js-cookie";
+import {
+  AuthTokens,
+  EXPIRES_COOKIE,
+  REFRESH_COOKIE,
+  SESSION_DAYS,
+  TOKEN_COOKIE,
+  tokenCookies,
+} from "@/lib/auth";
+
+// ذخیره توکن‌های ورود یا تازه‌سازی در کوکی‌ها (سمت کلاینت)
+export function saveSession(tokens: AuthTokens) {
+  for (const [name, value] of tokenCookies(tokens)) {
+    Cookies.set(name, value, { expires: SESSION_DAYS });
+  }
+}
+
+export function clearSession() {
+  Cookies.remove(TOKEN_COOKIE);
+  Cookies.remove(REFRESH_COOKIE);
+  Cookies.remove(EXPIRES_COOKIE);
+}
+
+// خروج از نشست جاری در بک‌اند و پاک کردن کوکی‌ها
+export async function logout() {
+  const token = Cookies.get(TOKEN_COOKIE);
+  if (token) {
+    await fetch(`${process.env.NEXT_PUBLIC_API_URL}/api/auth/logout`, {
+      method: "POST",
+      headers: { Authorization: `Bearer ${token}` },
+    }).catch(() => undefined);
+  }
+  clearSession();
+}
@@ -1,36 +1,47 @@
 // middleware.ts
 import { NextResponse } from "next/server";
 import type { NextRequest } from "next/server";
+import {
+  REFRESH_COOKIE,
+  TOKEN_COOKIE,
+  clearTokenCookies,
+  refreshTokens,
+  setTokenCookies,
+} from "@/lib/auth";
 
 export async function middleware(req: NextRequest) {
-  const token = req.cookies.get("auth_token")?.value;
-
-  // اگر توکن نبود → بفرست به صفحه‌ی لاگین
-  if (!token) {
-    const url = req.nextUrl.clone();
-    url.pathname = "/auth";
-    return NextResponse.redirect(url);
-  }
+  const token = req.cookies.get(TOKEN_COOKIE)?.value;
 
   // ✅ اعتبارسنجی توکن از بک‌اند
-  const isValid = await fetch(
-    `${process.env.NEXT_PUBLIC_API_URL}/api/verify-token`,
-    {
+  const isValid =
+    !!token &&
+    (await fetch(`${process.env.NEXT_PUBLIC_API_URL}/api/verify-token`, {
       headers: { Authorization: `Bearer ${token}` },
-    }
-  ).then((res) => res.ok);
-
-  if (!isValid) {
-    const url = req.nextUrl.clone();
-    url.pathname = "/auth";
+    }).then((res) => res.ok));
 
-    const response = NextResponse.redirect(url);
-    response.cookies.set("auth_token", "", { maxAge: -1 }); // پاک کردن کوکی
+  if (isValid) {
+    return NextResponse.next();
+  }
 
+  // توکن دسترسی منقضی شده → تمدید با توکن تازه‌سازی
+  const refreshToken = req.cookies.get(REFRESH_COOKIE)?.value;
+  const tokens = refreshToken
+    ? await refreshTokens(process.env.NEXT_PUBLIC_API_URL, refreshToken)
+    : null;
+  if (tokens) {
+    const response = NextResponse.next();
+    setTokenCookies(response, tokens);
     return response;
   }
 
-  return NextResponse.next();
+  // نشست نامعتبر → بفرست به صفحه‌ی لاگین
+  const url = req.nextUrl.clone();
+  url.pathname = "/auth";
+
+  const response = NextResponse.redirect(url);
+  clearTokenCookies(response);
+
+  return response;
 }
 
 // ✅ matcher: فقط روی صفحات اعمال بشه